@@ -0,0 +1,71 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// isAdmin reports whether a request carries the admin token as
+// "Authorization: Bearer <token>"
+func (s *ApiServer) isAdmin(r *http.Request) bool {
+	if s.adminToken == "" {
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1
+}
+
+// requireAdmin wraps an admin handler so it only runs for requests carrying
+// the admin token as "Authorization: Bearer <token>"
+func (s *ApiServer) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken == "" {
+			writeJson(w, http.StatusForbidden, map[string]string{"error": "admin routes are disabled: no admin token is configured"})
+			return
+		}
+
+		if !s.isAdmin(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJson(w, http.StatusUnauthorized, map[string]string{"error": "invalid or missing admin token"})
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// handleListBackups handles GET /admin/backups requests
+func (s *ApiServer) handleListBackups(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	backups, err := s.svc.ListBackups(r.Context())
+	if err != nil {
+		writeJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to retrieve backups"})
+		return
+	}
+
+	writeJson(w, http.StatusOK, backups)
+}
+
+// handleCreateBackup handles POST /admin/backups requests
+// Takes an online backup of the database and returns it with HTTP 201 status
+func (s *ApiServer) handleCreateBackup(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	backup, err := s.svc.CreateBackup(r.Context())
+	if err != nil {
+		writeServiceError(w, err, "failed to create backup")
+		return
+	}
+
+	writeJson(w, http.StatusCreated, backup)
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		wantStatus    int
+		want          string
+	}{
+		{"disabled", "", "Bearer ", http.StatusForbidden, `"error":"admin routes are disabled: no admin token is configured"`},
+		{"missing token", "s3cret", "", http.StatusUnauthorized, `"error":"invalid or missing admin token"`},
+		{"wrong token", "s3cret", "Bearer guess", http.StatusUnauthorized, `"error":"invalid or missing admin token"`},
+		{"wrong scheme", "s3cret", "Basic s3cret", http.StatusUnauthorized, `"error":"invalid or missing admin token"`},
+		{"valid token", "s3cret", "Bearer s3cret", http.StatusOK, `"status":"ok"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t)
+			server.SetAdminToken(tt.token)
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/backups", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			server.requireAdmin(server.handleHealthCheck)(rec, req)
+			checkResponse(t, rec, tt.wantStatus, tt.want)
+		})
+	}
+}
@@ -1,19 +1,54 @@
 package api
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"go-circleci/services"
 	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/graph-gophers/graphql-go"
 )
 
 type ApiServer struct {
-	svc services.Service
+	svc            services.Service
+	graphql        *graphql.Schema
+	adminToken     string
+	scannerTokens  map[string]string
+	trustedProxies []netip.Prefix
+	stockDisabled  string
 }
 
 func NewApiServer(svc services.Service) *ApiServer {
-	return &ApiServer{svc: svc}
+	return &ApiServer{svc: svc, graphql: newGraphQLSchema(svc)}
+}
+
+// SetAdminToken sets the bearer token that /admin routes require. Admin routes
+// are disabled until a token is set.
+func (s *ApiServer) SetAdminToken(token string) {
+	s.adminToken = token
+}
+
+// SetScannerTokens sets the bearer tokens that warehouse scanners present to
+// open the live inventory WebSocket, mapped to the scanner names their stock
+// adjustments are audited under. The admin token is also accepted.
+func (s *ApiServer) SetScannerTokens(tokens map[string]string) {
+	s.scannerTokens = tokens
+}
+
+// SetTrustedProxies sets the networks of the authenticating proxies allowed to
+// name the user a request is made by in the X-Actor header. The header is
+// ignored on requests from anywhere else, and on all requests until a network is set.
+func (s *ApiServer) SetTrustedProxies(networks []netip.Prefix) {
+	s.trustedProxies = networks
+}
+
+// SetStockDisabled disables the order, cart, reservation and stock routes,
+// which answer 501 Not Implemented with reason, for storage that only keeps
+// the product catalogue such as --storage=memory
+func (s *ApiServer) SetStockDisabled(reason string) {
+	s.stockDisabled = reason
 }
 
 func (s *ApiServer) Start(listenAddress string) error {
@@ -32,6 +67,16 @@ func (s *ApiServer) Start(listenAddress string) error {
 		}
 	})
 	
+	http.HandleFunc("/products:batch", s.handleBatchProducts)
+	http.HandleFunc("/products/import", s.handleImportProducts)
+	http.HandleFunc("/products/export", s.handleExportProducts)
+	http.HandleFunc("/products/trash", s.handleListDeletedProducts)
+	http.HandleFunc("/products/events", s.handleProductEvents)
+	http.HandleFunc("/products/{id}/restore", s.handleRestoreProduct)
+	http.HandleFunc("/products/{id}/history", s.requireAdmin(s.handleGetProductHistory))
+	http.HandleFunc("/products/{id}/versions", s.handleListProductVersions)
+	http.HandleFunc("/products/{id}/versions/diff", s.handleDiffProductVersions)
+	
 	http.HandleFunc("/products/", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodGet {
 			s.handleGetProduct(w, r)
@@ -44,9 +89,132 @@ func (s *ApiServer) Start(listenAddress string) error {
 		}
 	})
 
+	// Reservation routes
+	http.HandleFunc("/reservations", s.requireStock(s.handleCreateReservation))
+	http.HandleFunc("/reservations/{id}", s.requireStock(s.handleGetReservation))
+	http.HandleFunc("/reservations/{id}/confirm", s.requireStock(s.handleConfirmReservation))
+	http.HandleFunc("/reservations/{id}/release", s.requireStock(s.handleReleaseReservation))
+
+	// Inventory routes
+	http.HandleFunc("/locations", s.requireStock(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			s.handleListLocations(w, r)
+		} else if r.Method == http.MethodPost {
+			s.requireAdmin(s.handleCreateLocation)(w, r)
+		} else {
+			writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	}))
+	http.HandleFunc("/products/{id}/stock", s.requireStock(s.handleGetProductStock))
+	http.HandleFunc("/stock/transfers", s.requireStock(s.requireAdmin(s.handleTransferStock)))
+	http.HandleFunc("/inventory/low-stock", s.requireStock(s.handleGetLowStockReport))
+	http.HandleFunc("/ws/inventory", s.handleInventoryWebSocket)
+
+	// Order routes
+	http.HandleFunc("/orders", s.requireStock(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			s.handleListOrders(w, r)
+		} else if r.Method == http.MethodPost {
+			s.handleCreateOrder(w, r)
+		} else {
+			writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	}))
+	http.HandleFunc("/orders/{id}", s.requireStock(s.handleGetOrder))
+	http.HandleFunc("/orders/{id}/{action}", s.requireStock(s.handleOrderAction))
+
+	// Cart routes
+	http.HandleFunc("/carts", s.requireStock(s.handleCreateCart))
+	http.HandleFunc("/carts/{id}", s.requireStock(s.handleGetCart))
+	http.HandleFunc("/carts/{id}/lines", s.requireStock(s.handleAddCartLine))
+	http.HandleFunc("/carts/{id}/lines/{product_id}", s.requireStock(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			s.handleUpdateCartLine(w, r)
+		} else if r.Method == http.MethodDelete {
+			s.handleRemoveCartLine(w, r)
+		} else {
+			writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	}))
+	http.HandleFunc("/carts/{id}/checkout", s.requireStock(s.handleCheckoutCart))
+
+	// Promotion routes; promotions list their coupon codes, so only coupon checks are public
+	http.HandleFunc("/promotions", s.requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			s.handleListPromotions(w, r)
+		} else if r.Method == http.MethodPost {
+			s.handleCreatePromotion(w, r)
+		} else {
+			writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	}))
+	http.HandleFunc("/promotions/{id}", s.requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			s.handleGetPromotion(w, r)
+		} else if r.Method == http.MethodDelete {
+			s.handleDeletePromotion(w, r)
+		} else {
+			writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	}))
+	http.HandleFunc("/coupons/validate", s.handleValidateCoupon)
+
+	// Tax routes
+	http.HandleFunc("/tax/rules", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			s.handleListTaxRules(w, r)
+		} else if r.Method == http.MethodPost {
+			s.requireAdmin(s.handleCreateTaxRule)(w, r)
+		} else {
+			writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	})
+	http.HandleFunc("/tax/rules/{id}", s.requireAdmin(s.handleDeleteTaxRule))
+
+	// Admin routes
+	http.HandleFunc("/admin/backups", s.requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			s.handleListBackups(w, r)
+		} else if r.Method == http.MethodPost {
+			s.handleCreateBackup(w, r)
+		} else {
+			writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	}))
+	http.HandleFunc("/admin/products/{id}", s.requireAdmin(s.handlePurgeProduct))
+	http.HandleFunc("/admin/outbox", s.requireAdmin(s.handleListOutbox))
+	http.HandleFunc("/admin/outbox/{id}/retry", s.requireAdmin(s.handleRetryOutboxEvent))
+	http.HandleFunc("/audit", s.requireAdmin(s.handleListAuditEntries))
+	http.HandleFunc("/webhooks", s.requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			s.handleListWebhooks(w, r)
+		} else if r.Method == http.MethodPost {
+			s.handleCreateWebhook(w, r)
+		} else {
+			writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	}))
+	http.HandleFunc("/webhooks/{id}", s.requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			s.handleGetWebhook(w, r)
+		} else if r.Method == http.MethodPut {
+			s.handleUpdateWebhook(w, r)
+		} else if r.Method == http.MethodDelete {
+			s.handleDeleteWebhook(w, r)
+		} else {
+			writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	}))
+	http.HandleFunc("/webhooks/{id}/deliveries", s.requireAdmin(s.handleListWebhookDeliveries))
+	http.HandleFunc("/webhooks/{id}/deliveries/{deliveryID}/redeliver", s.requireAdmin(s.handleRedeliverWebhook))
+	http.HandleFunc("/audit/verify", s.requireAdmin(s.handleVerifyAuditLog))
+
+	// GraphQL route
+	http.HandleFunc("/graphql", s.handleGraphQL)
+
 	fmt.Printf("API server listening on %s\n", listenAddress)
 
-	return http.ListenAndServe(listenAddress, nil)
+	return http.ListenAndServe(listenAddress, s.withRequestContext(http.DefaultServeMux))
 }
 
 func (s *ApiServer) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
@@ -58,7 +226,7 @@ func (s *ApiServer) handleTest(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *ApiServer) handleGetCatFact(w http.ResponseWriter, r *http.Request) {
-	fact, err := s.svc.GetCatFact(context.Background())
+	fact, err := s.svc.GetCatFact(r.Context())
 	if err != nil {
 		writeJson(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
 		return
@@ -67,7 +235,39 @@ func (s *ApiServer) handleGetCatFact(w http.ResponseWriter, r *http.Request) {
 }
 
 func writeJson(w http.ResponseWriter, s int, v any) error {
+	// Headers must be set before the status is written
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(s)
-	w.Header().Add("Content-Type", "application/json")
 	return json.NewEncoder(w).Encode(v)
 }
+
+// writeServiceError maps service errors to HTTP status codes by their message,
+// falling back to a generic message for internal errors
+func writeServiceError(w http.ResponseWriter, err error, fallback string) {
+	status := serviceErrorStatus(err.Error())
+	if status == http.StatusInternalServerError {
+		writeJson(w, status, map[string]string{"error": fallback})
+		return
+	}
+	writeJson(w, status, map[string]string{"error": err.Error()})
+}
+
+// serviceErrorStatus maps a service error message to its HTTP status code
+func serviceErrorStatus(msg string) int {
+	switch {
+	case strings.Contains(msg, "not found"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "insufficient stock") ||
+		strings.Contains(msg, "not active") ||
+		strings.Contains(msg, "already exists") ||
+		strings.Contains(msg, "cannot be") ||
+		strings.Contains(msg, "concurrently"):
+		return http.StatusConflict
+	case strings.Contains(msg, "required") ||
+		strings.Contains(msg, "must be") ||
+		strings.Contains(msg, "invalid"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-circleci/logger"
+	"go-circleci/services"
+)
+
+func TestHandleGetCatFact(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantStatus int
+		want       string
+	}{
+		{"fact", http.StatusOK, `{"fact":"Cats have five toes on their front paws.","length":40}`, http.StatusOK, `{"fact":"Cats have five toes on their front paws."}`},
+		{"upstream error", http.StatusBadGateway, `{}`, http.StatusUnprocessableEntity, `"error":"cat fact upstream returned 502 Bad Gateway"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Stand in for the cat fact API so the test does not depend on the network
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer upstream.Close()
+
+			catFactService := services.NewCatFactService(upstream.URL)
+			// Through the logging wrapper, which must cope with a failed call
+			server := NewApiServer(logger.NewLoggingService(services.NewCompositeService(services.CompositeOptions{CatFact: catFactService})))
+
+			rec := serve(server.handleGetCatFact, http.MethodGet, "/", "")
+			checkResponse(t, rec, tt.wantStatus, tt.want)
+		})
+	}
+}
+
+func TestHandleHealthCheck(t *testing.T) {
+	rec := serve(newTestServer(t).handleHealthCheck, http.MethodGet, "/healthz", "")
+	checkResponse(t, rec, http.StatusOK, `{"status":"ok"}`)
+}
+
+func TestDemoModeDisablesStockRoutes(t *testing.T) {
+	// The in-memory catalogue of --storage=memory, as main sets it up
+	server := newTestServer(t)
+	server.SetStockDisabled("orders are not available with --storage=memory")
+
+	rec := serve(server.handleCreateProduct, http.MethodPost, "/products", `{"name":"Widget","price":9.99,"stock":5}`)
+	checkResponse(t, rec, http.StatusCreated, `"name":"Widget"`)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		body    string
+	}{
+		{"create order", server.handleCreateOrder, http.MethodPost, "/orders", `{"lines":[{"product_id":1,"quantity":1}]}`},
+		{"create cart", server.handleCreateCart, http.MethodPost, "/carts", `{}`},
+		{"create reservation", server.handleCreateReservation, http.MethodPost, "/reservations", `{"product_id":1,"quantity":1}`},
+		{"product stock", server.handleGetProductStock, http.MethodGet, "/products/1/stock", ""},
+		{"low stock report", server.handleGetLowStockReport, http.MethodGet, "/inventory/low-stock", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(server.requireStock(tt.handler), tt.method, tt.target, tt.body)
+			checkResponse(t, rec, http.StatusNotImplemented, `"error":"orders are not available with --storage=memory"`)
+		})
+	}
+
+	// Without a reason the routes run as usual
+	server.SetStockDisabled("")
+	rec = serve(server.requireStock(server.handleHealthCheck), http.MethodGet, "/healthz", "")
+	checkResponse(t, rec, http.StatusOK, `"status":"ok"`)
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-circleci/types"
+)
+
+// handleGetProductHistory handles GET /products/{id}/history requests
+// Returns every recorded change to the product, newest first. Admin only.
+func (s *ApiServer) handleGetProductHistory(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "product")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	entries, err := s.svc.GetProductHistory(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err, "failed to retrieve product history")
+		return
+	}
+
+	writeJson(w, http.StatusOK, entries)
+}
+
+// handleListAuditEntries handles GET /audit requests
+// Supports ?product_id=1&actor=admin&operation=update&request_id=...&from=2026-01-01&to=2026-02-01&limit=50,
+// where to is exclusive
+func (s *ApiServer) handleListAuditEntries(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	query := r.URL.Query()
+	filter := types.AuditFilter{
+		Actor:     query.Get("actor"),
+		Operation: query.Get("operation"),
+		RequestID: query.Get("request_id"),
+	}
+
+	if value := query.Get("product_id"); value != "" {
+		id, err := parseID(value, "product")
+		if err != nil {
+			writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		filter.ProductID = id
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid limit: must be a positive integer"})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(r, "from"); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if filter.To, err = parseTimeParam(r, "to"); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	entries, err := s.svc.ListAuditEntries(r.Context(), filter)
+	if err != nil {
+		writeServiceError(w, err, "failed to retrieve audit entries")
+		return
+	}
+
+	writeJson(w, http.StatusOK, entries)
+}
+
+// handleVerifyAuditLog handles GET /audit/verify requests
+// Checks the hash chain of the audit log and reports the first broken entry
+func (s *ApiServer) handleVerifyAuditLog(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	result, err := s.svc.VerifyAuditLog(r.Context())
+	if err != nil {
+		writeJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to verify audit log"})
+		return
+	}
+
+	writeJson(w, http.StatusOK, result)
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"encoding/json"
+	"go-circleci/types"
+	"net/http"
+)
+
+// handleCreateCart handles POST /carts requests
+// Opens an empty cart and returns it with HTTP 201 status
+func (s *ApiServer) handleCreateCart(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	// The body is optional; an empty body opens an anonymous cart
+	var req types.CreateCartRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON format"})
+			return
+		}
+	}
+
+	cart, err := s.svc.CreateCart(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, err, "failed to create cart")
+		return
+	}
+
+	writeJson(w, http.StatusCreated, cart)
+}
+
+// handleGetCart handles GET /carts/{id} requests
+// Returns the cart repriced at current product prices
+func (s *ApiServer) handleGetCart(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "cart")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	cart, err := s.svc.GetCart(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err, "failed to retrieve cart")
+		return
+	}
+
+	writeJson(w, http.StatusOK, cart)
+}
+
+// handleAddCartLine handles POST /carts/{id}/lines requests
+// Adds units of a product to the cart
+func (s *ApiServer) handleAddCartLine(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "cart")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Parse JSON request body
+	var req types.CartLineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON format"})
+		return
+	}
+
+	cart, err := s.svc.AddCartLine(r.Context(), id, &req)
+	if err != nil {
+		writeServiceError(w, err, "failed to update cart")
+		return
+	}
+
+	writeJson(w, http.StatusOK, cart)
+}
+
+// handleUpdateCartLine handles PUT /carts/{id}/lines/{product_id} requests
+// Sets the quantity of a product in the cart; a quantity of 0 removes it
+func (s *ApiServer) handleUpdateCartLine(w http.ResponseWriter, r *http.Request) {
+	// Only allow PUT method
+	if r.Method != http.MethodPut {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "cart")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	productID, err := parseID(r.PathValue("product_id"), "product")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Parse JSON request body
+	var req types.CartLineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON format"})
+		return
+	}
+	req.ProductID = productID
+
+	cart, err := s.svc.UpdateCartLine(r.Context(), id, &req)
+	if err != nil {
+		writeServiceError(w, err, "failed to update cart")
+		return
+	}
+
+	writeJson(w, http.StatusOK, cart)
+}
+
+// handleRemoveCartLine handles DELETE /carts/{id}/lines/{product_id} requests
+func (s *ApiServer) handleRemoveCartLine(w http.ResponseWriter, r *http.Request) {
+	// Only allow DELETE method
+	if r.Method != http.MethodDelete {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "cart")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	productID, err := parseID(r.PathValue("product_id"), "product")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	cart, err := s.svc.RemoveCartLine(r.Context(), id, productID)
+	if err != nil {
+		writeServiceError(w, err, "failed to update cart")
+		return
+	}
+
+	writeJson(w, http.StatusOK, cart)
+}
+
+// handleCheckoutCart handles POST /carts/{id}/checkout requests
+// Converts the cart into a pending order and returns the order with HTTP 201 status
+func (s *ApiServer) handleCheckoutCart(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "cart")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	order, err := s.svc.CheckoutCart(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err, "failed to check out cart")
+		return
+	}
+
+	writeJson(w, http.StatusCreated, order)
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/graph-gophers/graphql-go"
+)
+
+const (
+	// maxGraphQLComplexity bounds the estimated cost of a GraphQL operation, as
+	// charged by chargeGraphQLCost
+	maxGraphQLComplexity = 3000
+
+	// maxGraphQLDepth bounds how deeply the fields of a GraphQL query may nest
+	maxGraphQLDepth = 15
+
+	// maxGraphQLQueryLength bounds the length of a GraphQL query in bytes
+	maxGraphQLQueryLength = 16 << 10
+)
+
+// errGraphQLReadOnly is returned by mutations run from a GET request
+var errGraphQLReadOnly = errors.New("mutation operations must be sent with POST")
+
+// graphqlKey is the context key of the state of a /graphql request
+type graphqlKey struct{}
+
+// graphqlState is the state a /graphql request shares between its resolvers:
+// the cost charged so far and whether mutations are allowed
+type graphqlState struct {
+	cost     atomic.Int64
+	readOnly bool
+}
+
+// withGraphQLState returns a context carrying fresh state for a /graphql request
+func withGraphQLState(ctx context.Context, readOnly bool) context.Context {
+	return context.WithValue(ctx, graphqlKey{}, &graphqlState{readOnly: readOnly})
+}
+
+// chargeGraphQLCost adds the cost of the field being resolved to the cost of
+// its operation and fails once that exceeds maxGraphQLComplexity, before the
+// field reads anything. A field costs one, plus one for every field selected
+// under it once for each of the pageSize items it returns. Only the fields that
+// read data are charged; nesting is bounded by maxGraphQLDepth.
+func chargeGraphQLCost(ctx context.Context, pageSize int) error {
+	state, ok := ctx.Value(graphqlKey{}).(*graphqlState)
+	if !ok {
+		return nil
+	}
+	cost := int64(1 + pageSize*len(graphql.SelectedFieldNames(ctx)))
+	if total := state.cost.Add(cost); total > maxGraphQLComplexity {
+		message := fmt.Sprintf("query complexity %d exceeds the limit of %d", total, maxGraphQLComplexity)
+		return &graphqlError{message: message, code: "QUERY_TOO_COMPLEX"}
+	}
+	return nil
+}
+
+// checkGraphQLWritable fails mutations run from a GET request
+func checkGraphQLWritable(ctx context.Context) error {
+	if state, ok := ctx.Value(graphqlKey{}).(*graphqlState); ok && state.readOnly {
+		return errGraphQLReadOnly
+	}
+	return nil
+}
+
+// graphqlRequest is a GraphQL operation sent to /graphql
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// handleGraphQL handles GET and POST /graphql requests
+// POST takes a JSON body with the query, operationName and variables; GET takes
+// them as query parameters and only runs queries. Products are priced for the
+// customer, coupon, at and region query parameters like GET /products.
+func (s *ApiServer) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphqlRequest
+	switch r.Method {
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeGraphQLError(w, http.StatusBadRequest, "invalid JSON format")
+			return
+		}
+	case http.MethodGet:
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				writeGraphQLError(w, http.StatusBadRequest, "invalid variables: must be a JSON object")
+				return
+			}
+		}
+	default:
+		writeGraphQLError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if req.Query == "" {
+		writeGraphQLError(w, http.StatusBadRequest, "query is required")
+		return
+	}
+	if len(req.Query) > maxGraphQLQueryLength {
+		writeGraphQLError(w, http.StatusBadRequest, fmt.Sprintf("query must be at most %d bytes", maxGraphQLQueryLength))
+		return
+	}
+
+	ctx, err := pricingContext(r)
+	if err != nil {
+		writeGraphQLError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx = withGraphQLState(withProductLoader(ctx, s.svc), r.Method != http.MethodPost)
+	res := s.graphql.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	for _, err := range res.Errors {
+		if errors.Is(err.ResolverError, errGraphQLReadOnly) {
+			writeGraphQLError(w, http.StatusMethodNotAllowed, errGraphQLReadOnly.Error())
+			return
+		}
+	}
+	writeJson(w, http.StatusOK, res)
+}
+
+// writeGraphQLError writes a GraphQL response carrying a single error
+func writeGraphQLError(w http.ResponseWriter, status int, message string) {
+	writeJson(w, status, map[string]any{"errors": []map[string]string{{"message": message}}})
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+// graphqlBody returns the JSON body of a GraphQL request
+func graphqlBody(t *testing.T, query string, variables map[string]any) string {
+	t.Helper()
+	body, err := json.Marshal(graphqlRequest{Query: query, Variables: variables})
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+	return string(body)
+}
+
+func TestHandleGraphQL(t *testing.T) {
+	gadget := &types.CreateProductRequest{Name: "Gadget", Category: "tools", Price: 25, Stock: 0, SKU: "G-1"}
+	gizmo := &types.CreateProductRequest{Name: "Gizmo", Category: "tools", Price: 40, Stock: 3, SKU: "G-2"}
+
+	tests := []struct {
+		name       string
+		server     *ApiServer
+		method     string
+		body       string
+		wantStatus int
+		want       string
+	}{
+		{"product", newTestServer(t, widget), http.MethodPost, `{"query":"{ product(id: 1) { id sku name price stock available } }"}`, http.StatusOK,
+			`{"data":{"product":{"id":"1","sku":"W-1","name":"Widget","price":9.99,"stock":5,"available":5}}}`},
+		{"missing product", newTestServer(t), http.MethodPost, `{"query":"{ product(id: 42) { name } }"}`, http.StatusOK, `{"data":{"product":null}}`},
+		{"invalid product ID", newTestServer(t), http.MethodPost, `{"query":"{ product(id: \"abc\") { name } }"}`, http.StatusOK, `"message":"invalid product ID format: must be an integer"`},
+		{"first page", newTestServer(t, widget, gadget, gizmo), http.MethodPost, `{"query":"{ products(first: 2) { totalCount nodes { name } pageInfo { hasNextPage endCursor } } }"}`, http.StatusOK,
+			`{"totalCount":3,"nodes":[{"name":"Widget"},{"name":"Gadget"}],"pageInfo":{"hasNextPage":true,"endCursor":"cHJvZHVjdDoy"}}`},
+		{"next page", newTestServer(t, widget, gadget, gizmo), http.MethodPost, `{"query":"{ products(first: 2, after: \"cHJvZHVjdDoy\") { nodes { name } pageInfo { hasNextPage } } }"}`, http.StatusOK,
+			`"nodes":[{"name":"Gizmo"}],"pageInfo":{"hasNextPage":false}`},
+		{"filter", newTestServer(t, widget, gadget, gizmo), http.MethodPost, `{"query":"{ products(filter: {category: \"tools\", inStock: true, maxPrice: 50}) { totalCount edges { cursor node { name } } } }"}`, http.StatusOK,
+			`{"totalCount":1,"edges":[{"cursor":"cHJvZHVjdDoz","node":{"name":"Gizmo"}}]}`},
+		{"page too large", newTestServer(t), http.MethodPost, `{"query":"{ products(first: 101) { totalCount } }"}`, http.StatusOK, `"message":"first must be between 1 and 100"`},
+		{"invalid cursor", newTestServer(t), http.MethodPost, `{"query":"{ products(after: \"nope\") { totalCount } }"}`, http.StatusOK, `"message":"invalid cursor"`},
+		{"create", newTestServer(t), http.MethodPost, `{"query":"mutation { createProduct(input: {name: \"Widget\", price: 9.99, stock: 5, taxClass: \"standard\"}) { id name taxClass } }"}`, http.StatusOK,
+			`{"data":{"createProduct":{"id":"1","name":"Widget","taxClass":"standard"}}}`},
+		{"create invalid", newTestServer(t), http.MethodPost, `{"query":"mutation { createProduct(input: {name: \"Widget\", price: -1, stock: 5}) { id } }"}`, http.StatusOK,
+			`"message":"product price must be greater than or equal to 0","path":["createProduct"],"extensions":{"code":"BAD_USER_INPUT"}`},
+		{"update", newTestServer(t, widget), http.MethodPost, `{"query":"mutation { updateProduct(id: 1, input: {name: \"Renamed\", price: 5, stock: 2}) { name stock } }"}`, http.StatusOK,
+			`{"data":{"updateProduct":{"name":"Renamed","stock":2}}}`},
+		{"update missing", newTestServer(t), http.MethodPost, `{"query":"mutation { updateProduct(id: 42, input: {name: \"Renamed\", price: 5, stock: 2}) { name } }"}`, http.StatusOK,
+			`"message":"product with ID 42 not found","path":["updateProduct"],"extensions":{"code":"NOT_FOUND"}`},
+		{"delete then read", newTestServer(t, widget), http.MethodPost, `{"query":"mutation { deleteProduct(id: 1) }"}`, http.StatusOK, `{"data":{"deleteProduct":true}}`},
+		{"service failure", NewApiServer(failingService{err: errors.New("disk on fire")}), http.MethodPost, `{"query":"mutation { deleteProduct(id: 1) }"}`, http.StatusOK,
+			`"message":"failed to delete product","path":["deleteProduct"],"extensions":{"code":"INTERNAL_SERVER_ERROR"}`},
+		{"invalid query", newTestServer(t), http.MethodPost, `{"query":"{ product(id: 1) { colour } }"}`, http.StatusOK, `Cannot query field \"colour\" on type \"Product\"`},
+		{"too complex", newTestServer(t), http.MethodPost, `{"query":"{ a: products(first: 100) { ...page } b: products(first: 100) { ...page } } fragment page on ProductConnection { nodes { id sku name description category price effectivePrice stock onHand available reorderPoint reorderQty taxClass promotions { id name } } }"}`, http.StatusOK,
+			`"message":"query complexity 3402 exceeds the limit of 3000"`},
+		{"missing query", newTestServer(t), http.MethodPost, `{}`, http.StatusBadRequest, `"message":"query is required"`},
+		{"invalid JSON", newTestServer(t), http.MethodPost, `{"query":`, http.StatusBadRequest, `"message":"invalid JSON format"`},
+		{"method not allowed", newTestServer(t), http.MethodPut, `{}`, http.StatusMethodNotAllowed, `"message":"method not allowed"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.server.handleGraphQL, tt.method, "/graphql", tt.body)
+			checkResponse(t, rec, tt.wantStatus, tt.want)
+		})
+	}
+}
+
+func TestHandleGraphQLGet(t *testing.T) {
+	server := newTestServer(t, widget)
+
+	query := url.Values{"query": {"query Get($id: ID!) { product(id: $id) { name } }"}, "variables": {`{"id":"1"}`}}
+	rec := serve(server.handleGraphQL, http.MethodGet, "/graphql?"+query.Encode(), "")
+	checkResponse(t, rec, http.StatusOK, `{"data":{"product":{"name":"Widget"}}}`)
+
+	mutation := url.Values{"query": {"mutation { deleteProduct(id: 1) }"}}
+	rec = serve(server.handleGraphQL, http.MethodGet, "/graphql?"+mutation.Encode(), "")
+	checkResponse(t, rec, http.StatusMethodNotAllowed, `"message":"mutation operations must be sent with POST"`)
+}
+
+// batchCountingService counts the batched product reads made through it
+type batchCountingService struct {
+	services.Service
+	batches atomic.Int64
+}
+
+func (s *batchCountingService) GetProductsByIDs(ctx context.Context, ids []int) ([]*types.Product, error) {
+	s.batches.Add(1)
+	return s.Service.GetProductsByIDs(ctx, ids)
+}
+
+func TestHandleGraphQLBatchesProductLookups(t *testing.T) {
+	svc := &batchCountingService{Service: newTestServer(t, widget, &types.CreateProductRequest{Name: "Gadget"}, &types.CreateProductRequest{Name: "Gizmo"}).svc}
+	server := NewApiServer(svc)
+
+	query := `{ a: product(id: 1) { name } b: product(id: 2) { name } c: product(id: 3) { name } again: product(id: 1) { sku } missing: product(id: 9) { name } }`
+	rec := serve(server.handleGraphQL, http.MethodPost, "/graphql", graphqlBody(t, query, nil))
+	checkResponse(t, rec, http.StatusOK, `"missing":null`)
+	if strings.Contains(rec.Body.String(), `"errors"`) {
+		t.Fatalf("body = %s, want no errors", rec.Body)
+	}
+	if got := svc.batches.Load(); got != 1 {
+		t.Errorf("GetProductsByIDs calls = %d, want 1", got)
+	}
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+const (
+	// productBatchWait is how long the product loader collects IDs before it
+	// reads them, long enough for the fields a query resolves concurrently to
+	// join the same batch
+	productBatchWait = 2 * time.Millisecond
+
+	// maxProductBatch bounds the number of products read in one batch
+	maxProductBatch = 100
+)
+
+// productLoader batches the product lookups of one GraphQL request, so that a
+// query asking for many products reads them with one GetProductsByIDs instead
+// of one query each. Every product is read at most once per request.
+type productLoader struct {
+	svc     services.Service
+	mu      sync.Mutex
+	loads   map[int]*productLoad
+	pending []*productLoad
+	timer   *time.Timer
+}
+
+// productLoad is the lookup of one product, done once its batch is read
+type productLoad struct {
+	id      int
+	done    chan struct{}
+	product *types.Product
+	err     error
+}
+
+// productLoaderKey is the context key of the product loader of a request
+type productLoaderKey struct{}
+
+func newProductLoader(svc services.Service) *productLoader {
+	return &productLoader{svc: svc, loads: make(map[int]*productLoad)}
+}
+
+// withProductLoader returns a context carrying a new product loader over svc
+func withProductLoader(ctx context.Context, svc services.Service) context.Context {
+	return context.WithValue(ctx, productLoaderKey{}, newProductLoader(svc))
+}
+
+// productLoaderFrom returns the product loader of a request
+func productLoaderFrom(ctx context.Context) *productLoader {
+	return ctx.Value(productLoaderKey{}).(*productLoader)
+}
+
+// Load returns the product with the given ID, or nil if there is none. The read
+// waits briefly for other lookups to batch with.
+func (l *productLoader) Load(ctx context.Context, id int) (*types.Product, error) {
+	products, err := l.wait(ctx, l.enqueue(ctx, []int{id}))
+	if err != nil || len(products) == 0 {
+		return nil, err
+	}
+	return products[0], nil
+}
+
+// Prime stores a product the request has just written, so later lookups see it
+func (l *productLoader) Prime(product *types.Product) {
+	load := &productLoad{id: product.ID, done: make(chan struct{}), product: product}
+	close(load.done)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.loads[product.ID] = load
+}
+
+// Forget records that a product the request has just deleted is gone
+func (l *productLoader) Forget(id int) {
+	load := &productLoad{id: id, done: make(chan struct{})}
+	close(load.done)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.loads[id] = load
+}
+
+// enqueue returns the lookups of the given IDs, adding those not yet made to
+// the pending batch. The batch is read when it is full or once productBatchWait
+// has passed.
+func (l *productLoader) enqueue(ctx context.Context, ids []int) []*productLoad {
+	l.mu.Lock()
+	loads := make([]*productLoad, len(ids))
+	for i, id := range ids {
+		load, ok := l.loads[id]
+		if !ok {
+			load = &productLoad{id: id, done: make(chan struct{})}
+			l.loads[id] = load
+			l.pending = append(l.pending, load)
+		}
+		loads[i] = load
+	}
+
+	var batch []*productLoad
+	if len(l.pending) >= maxProductBatch {
+		batch = l.takePending()
+	} else if len(l.pending) > 0 && l.timer == nil {
+		l.timer = time.AfterFunc(productBatchWait, func() {
+			l.mu.Lock()
+			batch := l.takePending()
+			l.mu.Unlock()
+			l.read(ctx, batch)
+		})
+	}
+	l.mu.Unlock()
+
+	l.read(ctx, batch)
+	return loads
+}
+
+// takePending removes and returns the pending batch. It must be called with
+// l.mu held.
+func (l *productLoader) takePending() []*productLoad {
+	batch := l.pending
+	l.pending = nil
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	return batch
+}
+
+// read reads a batch of products and completes its lookups
+func (l *productLoader) read(ctx context.Context, batch []*productLoad) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ids := make([]int, len(batch))
+	for i, load := range batch {
+		ids[i] = load.id
+	}
+
+	products, err := l.svc.GetProductsByIDs(ctx, ids)
+	byID := make(map[int]*types.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+	for _, load := range batch {
+		load.product, load.err = byID[load.id], err
+		close(load.done)
+	}
+}
+
+// wait waits for lookups to be done and returns the products they found
+func (l *productLoader) wait(ctx context.Context, loads []*productLoad) ([]*types.Product, error) {
+	products := make([]*types.Product, 0, len(loads))
+	for _, load := range loads {
+		select {
+		case <-load.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if load.err != nil {
+			return nil, load.err
+		}
+		if load.product != nil {
+			products = append(products, load.product)
+		}
+	}
+	return products, nil
+}
@@ -0,0 +1,590 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/graph-gophers/graphql-go"
+
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+// graphqlSchema is the schema served at /graphql
+const graphqlSchema = `
+schema {
+	query: Query
+	mutation: Mutation
+}
+
+type Query {
+	product(id: ID!): Product
+	products(first: Int = 20, after: String, filter: ProductFilter): ProductConnection!
+	catFact: CatFact!
+}
+
+type Mutation {
+	createProduct(input: ProductInput!): Product!
+	updateProduct(id: ID!, input: ProductInput!): Product!
+	deleteProduct(id: ID!): Boolean!
+}
+
+input ProductFilter {
+	category: String
+	search: String
+	minPrice: Float
+	maxPrice: Float
+	inStock: Boolean
+}
+
+input ProductInput {
+	sku: String
+	name: String!
+	description: String
+	category: String
+	taxClass: String
+	price: Float!
+	stock: Int!
+	reorderPoint: Int
+	reorderQty: Int
+}
+
+type Product {
+	id: ID!
+	sku: String!
+	name: String!
+	description: String!
+	category: String!
+	price: Float!
+	effectivePrice: Float!
+	promotions: [AppliedPromotion!]!
+	taxClass: String!
+	tax: TaxAmount
+	stock: Int!
+	onHand: Int!
+	available: Int!
+	reorderPoint: Int!
+	reorderQty: Int!
+}
+
+type AppliedPromotion {
+	id: ID!
+	name: String!
+	couponCode: String
+	discount: Float!
+}
+
+type TaxAmount {
+	region: String!
+	rate: Float!
+	inclusive: Boolean!
+	net: Float!
+	tax: Float!
+	gross: Float!
+}
+
+type ProductConnection {
+	edges: [ProductEdge!]!
+	nodes: [Product!]!
+	pageInfo: PageInfo!
+	totalCount: Int!
+}
+
+type ProductEdge {
+	cursor: String!
+	node: Product!
+}
+
+type PageInfo {
+	hasNextPage: Boolean!
+	endCursor: String
+}
+
+type CatFact {
+	fact: String!
+}
+`
+
+// maxProductsPage bounds the number of products a products query may ask for
+const maxProductsPage = 100
+
+// newGraphQLSchema parses the /graphql schema with resolvers that delegate to svc
+func newGraphQLSchema(svc services.Service) *graphql.Schema {
+	return graphql.MustParseSchema(graphqlSchema, &graphqlResolver{svc: svc},
+		graphql.MaxDepth(maxGraphQLDepth),
+		graphql.MaxQueryLength(maxGraphQLQueryLength),
+	)
+}
+
+// graphqlError is a service error as a GraphQL error. Its code extension
+// classifies it the way the REST handlers pick a status code.
+type graphqlError struct {
+	message string
+	code    string
+}
+
+func (e *graphqlError) Error() string {
+	return e.message
+}
+
+// Extensions adds the error code to the error in the response
+func (e *graphqlError) Extensions() map[string]any {
+	return map[string]any{"code": e.code}
+}
+
+// resolverError maps a service error to a GraphQL error, hiding the message of
+// internal errors behind fallback
+func resolverError(err error, fallback string) error {
+	switch serviceErrorStatus(err.Error()) {
+	case http.StatusNotFound:
+		return &graphqlError{message: err.Error(), code: "NOT_FOUND"}
+	case http.StatusConflict:
+		return &graphqlError{message: err.Error(), code: "CONFLICT"}
+	case http.StatusBadRequest:
+		return &graphqlError{message: err.Error(), code: "BAD_USER_INPUT"}
+	default:
+		return &graphqlError{message: fallback, code: "INTERNAL_SERVER_ERROR"}
+	}
+}
+
+// graphqlResolver resolves the Query and Mutation fields
+type graphqlResolver struct {
+	svc services.Service
+}
+
+// Product resolves a product by ID, or null if there is none. Lookups made by
+// the same request are batched into one read.
+func (r *graphqlResolver) Product(ctx context.Context, args struct{ ID graphql.ID }) (*productResolver, error) {
+	if err := chargeGraphQLCost(ctx, 1); err != nil {
+		return nil, err
+	}
+	id, err := parseID(string(args.ID), "product")
+	if err != nil {
+		return nil, resolverError(err, "")
+	}
+
+	product, err := productLoaderFrom(ctx).Load(ctx, id)
+	if err != nil {
+		return nil, resolverError(err, "failed to retrieve product")
+	}
+	if product == nil {
+		return nil, nil
+	}
+	return &productResolver{product}, nil
+}
+
+// productsArgs are the arguments of the products query
+type productsArgs struct {
+	First  int32
+	After  *string
+	Filter *productFilter
+}
+
+// Products resolves a page of the products matching a filter, in ID order. The
+// page is read and priced in one query, and primes the product loader.
+func (r *graphqlResolver) Products(ctx context.Context, args productsArgs) (*productConnectionResolver, error) {
+	first := int(args.First)
+	if first < 1 || first > maxProductsPage {
+		return nil, resolverError(fmt.Errorf("first must be between 1 and %d", maxProductsPage), "")
+	}
+	if err := chargeGraphQLCost(ctx, first); err != nil {
+		return nil, err
+	}
+
+	after := 0
+	if args.After != nil {
+		id, err := decodeProductCursor(*args.After)
+		if err != nil {
+			return nil, resolverError(err, "")
+		}
+		after = id
+	}
+
+	// Read one product past the page to tell whether there is a next page
+	page, err := r.svc.ListProductsPage(ctx, args.Filter.productFilter(), after, first+1)
+	if err != nil {
+		return nil, resolverError(err, "failed to retrieve products")
+	}
+
+	products := page.Products
+	hasNextPage := len(products) > first
+	if hasNextPage {
+		products = products[:first]
+	}
+
+	loader := productLoaderFrom(ctx)
+	for _, product := range products {
+		loader.Prime(product)
+	}
+	return &productConnectionResolver{products: products, total: page.Total, hasNextPage: hasNextPage}, nil
+}
+
+// CatFact resolves a random cat fact
+func (r *graphqlResolver) CatFact(ctx context.Context) (*catFactResolver, error) {
+	if err := chargeGraphQLCost(ctx, 1); err != nil {
+		return nil, err
+	}
+	fact, err := r.svc.GetCatFact(ctx)
+	if err != nil {
+		return nil, resolverError(err, "failed to retrieve cat fact")
+	}
+	return &catFactResolver{fact}, nil
+}
+
+// productInput is the input of the createProduct and updateProduct mutations
+type productInput struct {
+	SKU          *string
+	Name         string
+	Description  *string
+	Category     *string
+	TaxClass     *string
+	Price        float64
+	Stock        int32
+	ReorderPoint *int32
+	ReorderQty   *int32
+}
+
+// createRequest converts the input to a request to create a product
+func (in *productInput) createRequest() *types.CreateProductRequest {
+	return &types.CreateProductRequest{
+		SKU:          stringValue(in.SKU),
+		Name:         in.Name,
+		Description:  stringValue(in.Description),
+		Category:     stringValue(in.Category),
+		TaxClass:     stringValue(in.TaxClass),
+		Price:        in.Price,
+		Stock:        int(in.Stock),
+		ReorderPoint: intValue(in.ReorderPoint),
+		ReorderQty:   intValue(in.ReorderQty),
+	}
+}
+
+// updateRequest converts the input to a request to replace a product
+func (in *productInput) updateRequest() *types.UpdateProductRequest {
+	req := in.createRequest()
+	return &types.UpdateProductRequest{
+		SKU:          req.SKU,
+		Name:         req.Name,
+		Description:  req.Description,
+		Category:     req.Category,
+		TaxClass:     req.TaxClass,
+		Price:        req.Price,
+		Stock:        req.Stock,
+		ReorderPoint: req.ReorderPoint,
+		ReorderQty:   req.ReorderQty,
+	}
+}
+
+// CreateProduct creates a product
+func (r *graphqlResolver) CreateProduct(ctx context.Context, args struct{ Input productInput }) (*productResolver, error) {
+	if err := checkGraphQLWritable(ctx); err != nil {
+		return nil, err
+	}
+	product, err := r.svc.CreateProduct(ctx, args.Input.createRequest())
+	if err != nil {
+		return nil, resolverError(err, "failed to create product")
+	}
+	productLoaderFrom(ctx).Prime(product)
+	return &productResolver{product}, nil
+}
+
+// UpdateProduct replaces a product's fields
+func (r *graphqlResolver) UpdateProduct(ctx context.Context, args struct {
+	ID    graphql.ID
+	Input productInput
+}) (*productResolver, error) {
+	if err := checkGraphQLWritable(ctx); err != nil {
+		return nil, err
+	}
+	id, err := parseID(string(args.ID), "product")
+	if err != nil {
+		return nil, resolverError(err, "")
+	}
+
+	product, err := r.svc.UpdateProduct(ctx, id, args.Input.updateRequest())
+	if err != nil {
+		return nil, resolverError(err, "failed to update product")
+	}
+	productLoaderFrom(ctx).Prime(product)
+	return &productResolver{product}, nil
+}
+
+// DeleteProduct moves a product to the trash
+func (r *graphqlResolver) DeleteProduct(ctx context.Context, args struct{ ID graphql.ID }) (bool, error) {
+	if err := checkGraphQLWritable(ctx); err != nil {
+		return false, err
+	}
+	id, err := parseID(string(args.ID), "product")
+	if err != nil {
+		return false, resolverError(err, "")
+	}
+
+	if err := r.svc.DeleteProduct(ctx, id); err != nil {
+		return false, resolverError(err, "failed to delete product")
+	}
+	productLoaderFrom(ctx).Forget(id)
+	return true, nil
+}
+
+// productFilter is the filter of the products query. It applies to list prices.
+type productFilter struct {
+	Category *string
+	Search   *string
+	MinPrice *float64
+	MaxPrice *float64
+	InStock  *bool
+}
+
+// productFilter converts the filter to a repository filter. A nil filter
+// matches every product.
+func (f *productFilter) productFilter() types.ProductFilter {
+	if f == nil {
+		return types.ProductFilter{}
+	}
+	return types.ProductFilter{
+		Category: stringValue(f.Category),
+		Search:   stringValue(f.Search),
+		MinPrice: f.MinPrice,
+		MaxPrice: f.MaxPrice,
+		InStock:  f.InStock,
+	}
+}
+
+// productCursorPrefix prefixes the product ID in a products cursor
+const productCursorPrefix = "product:"
+
+// encodeProductCursor returns the opaque cursor of a product in a products page
+func encodeProductCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(productCursorPrefix + strconv.Itoa(id)))
+}
+
+// decodeProductCursor returns the product ID of a products cursor
+func decodeProductCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		if idStr, ok := strings.CutPrefix(string(raw), productCursorPrefix); ok {
+			if id, err := strconv.Atoi(idStr); err == nil && id > 0 {
+				return id, nil
+			}
+		}
+	}
+	return 0, errors.New("invalid cursor")
+}
+
+// productConnectionResolver resolves a page of products
+type productConnectionResolver struct {
+	products    []*types.Product
+	total       int
+	hasNextPage bool
+}
+
+func (r *productConnectionResolver) Edges() []*productEdgeResolver {
+	edges := make([]*productEdgeResolver, len(r.products))
+	for i, product := range r.products {
+		edges[i] = &productEdgeResolver{product}
+	}
+	return edges
+}
+
+func (r *productConnectionResolver) Nodes() []*productResolver {
+	nodes := make([]*productResolver, len(r.products))
+	for i, product := range r.products {
+		nodes[i] = &productResolver{product}
+	}
+	return nodes
+}
+
+func (r *productConnectionResolver) PageInfo() *pageInfoResolver {
+	info := &pageInfoResolver{hasNextPage: r.hasNextPage}
+	if len(r.products) > 0 {
+		cursor := encodeProductCursor(r.products[len(r.products)-1].ID)
+		info.endCursor = &cursor
+	}
+	return info
+}
+
+func (r *productConnectionResolver) TotalCount() int32 {
+	return int32(r.total)
+}
+
+// productEdgeResolver resolves a product in a page of products
+type productEdgeResolver struct {
+	product *types.Product
+}
+
+func (r *productEdgeResolver) Cursor() string {
+	return encodeProductCursor(r.product.ID)
+}
+
+func (r *productEdgeResolver) Node() *productResolver {
+	return &productResolver{r.product}
+}
+
+// pageInfoResolver resolves where a page of products ends
+type pageInfoResolver struct {
+	hasNextPage bool
+	endCursor   *string
+}
+
+func (r *pageInfoResolver) HasNextPage() bool {
+	return r.hasNextPage
+}
+
+func (r *pageInfoResolver) EndCursor() *string {
+	return r.endCursor
+}
+
+// productResolver resolves the fields of a product
+type productResolver struct {
+	p *types.Product
+}
+
+func (r *productResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(r.p.ID))
+}
+
+func (r *productResolver) SKU() string {
+	return r.p.SKU
+}
+
+func (r *productResolver) Name() string {
+	return r.p.Name
+}
+
+func (r *productResolver) Description() string {
+	return r.p.Description
+}
+
+func (r *productResolver) Category() string {
+	return r.p.Category
+}
+
+func (r *productResolver) Price() float64 {
+	return r.p.Price
+}
+
+func (r *productResolver) EffectivePrice() float64 {
+	return r.p.EffectivePrice
+}
+
+func (r *productResolver) Promotions() []*appliedPromotionResolver {
+	promotions := make([]*appliedPromotionResolver, len(r.p.Promotions))
+	for i := range r.p.Promotions {
+		promotions[i] = &appliedPromotionResolver{&r.p.Promotions[i]}
+	}
+	return promotions
+}
+
+func (r *productResolver) TaxClass() string {
+	return r.p.TaxClass
+}
+
+func (r *productResolver) Tax() *taxAmountResolver {
+	if r.p.Tax == nil {
+		return nil
+	}
+	return &taxAmountResolver{r.p.Tax}
+}
+
+func (r *productResolver) Stock() int32 {
+	return int32(r.p.Stock)
+}
+
+func (r *productResolver) OnHand() int32 {
+	return int32(r.p.OnHand)
+}
+
+func (r *productResolver) Available() int32 {
+	return int32(r.p.Available)
+}
+
+func (r *productResolver) ReorderPoint() int32 {
+	return int32(r.p.ReorderPoint)
+}
+
+func (r *productResolver) ReorderQty() int32 {
+	return int32(r.p.ReorderQty)
+}
+
+// appliedPromotionResolver resolves a promotion applied to a product's price
+type appliedPromotionResolver struct {
+	p *types.AppliedPromotion
+}
+
+func (r *appliedPromotionResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(r.p.ID))
+}
+
+func (r *appliedPromotionResolver) Name() string {
+	return r.p.Name
+}
+
+func (r *appliedPromotionResolver) CouponCode() *string {
+	if r.p.CouponCode == "" {
+		return nil
+	}
+	return &r.p.CouponCode
+}
+
+func (r *appliedPromotionResolver) Discount() float64 {
+	return r.p.Discount
+}
+
+// taxAmountResolver resolves the tax on a product's price
+type taxAmountResolver struct {
+	t *types.TaxAmount
+}
+
+func (r *taxAmountResolver) Region() string {
+	return r.t.Region
+}
+
+func (r *taxAmountResolver) Rate() float64 {
+	return r.t.Rate
+}
+
+func (r *taxAmountResolver) Inclusive() bool {
+	return r.t.Inclusive
+}
+
+func (r *taxAmountResolver) Net() float64 {
+	return r.t.Net
+}
+
+func (r *taxAmountResolver) Tax() float64 {
+	return r.t.Tax
+}
+
+func (r *taxAmountResolver) Gross() float64 {
+	return r.t.Gross
+}
+
+// catFactResolver resolves a cat fact
+type catFactResolver struct {
+	f *types.CatFact
+}
+
+func (r *catFactResolver) Fact() string {
+	return r.f.Fact
+}
+
+// stringValue returns the string s points to, or "" if s is nil
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// intValue returns the integer n points to, or 0 if n is nil
+func intValue(n *int32) int {
+	if n == nil {
+		return 0
+	}
+	return int(*n)
+}
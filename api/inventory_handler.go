@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"go-circleci/types"
+	"net/http"
+)
+
+// requireStock wraps a handler of orders, carts, reservations or stock levels
+// so it only runs while those routes are enabled
+func (s *ApiServer) requireStock(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.stockDisabled != "" {
+			writeJson(w, http.StatusNotImplemented, map[string]string{"error": s.stockDisabled})
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// handleListLocations handles GET /locations requests
+// Returns all warehouses and stores as a JSON array
+func (s *ApiServer) handleListLocations(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	locations, err := s.svc.ListLocations(r.Context())
+	if err != nil {
+		writeJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to retrieve locations"})
+		return
+	}
+
+	writeJson(w, http.StatusOK, locations)
+}
+
+// handleCreateLocation handles POST /locations requests
+// Creates a new location and returns it with HTTP 201 status. Admin only.
+func (s *ApiServer) handleCreateLocation(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	// Parse JSON request body
+	var req types.CreateLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON format"})
+		return
+	}
+
+	location, err := s.svc.CreateLocation(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, err, "failed to create location")
+		return
+	}
+
+	writeJson(w, http.StatusCreated, location)
+}
+
+// handleGetProductStock handles GET /products/{id}/stock requests
+// Returns the product's on-hand stock broken down by location
+func (s *ApiServer) handleGetProductStock(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "product")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	stock, err := s.svc.GetProductStock(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err, "failed to retrieve product stock")
+		return
+	}
+
+	writeJson(w, http.StatusOK, stock)
+}
+
+// handleTransferStock handles POST /stock/transfers requests
+// Moves units between two locations and returns the transfer with HTTP 201 status. Admin only.
+func (s *ApiServer) handleTransferStock(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	// Parse JSON request body
+	var req types.StockTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON format"})
+		return
+	}
+
+	transfer, err := s.svc.TransferStock(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, err, "failed to transfer stock")
+		return
+	}
+
+	writeJson(w, http.StatusCreated, transfer)
+}
+
+// handleGetLowStockReport handles GET /inventory/low-stock requests
+// Returns the products at or below their reorder point, most urgent first
+func (s *ApiServer) handleGetLowStockReport(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	items, err := s.svc.GetLowStockReport(r.Context())
+	if err != nil {
+		writeJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to retrieve low stock report"})
+		return
+	}
+
+	writeJson(w, http.StatusOK, items)
+}
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+// Live inventory connection limits. A client is pinged every wsPingPeriod and
+// dropped if it does not answer within wsPongWait, or if it falls
+// wsSendBuffer messages behind.
+const (
+	wsWriteWait        = 10 * time.Second
+	wsPongWait         = 60 * time.Second
+	wsPingPeriod       = wsPongWait * 9 / 10
+	wsMaxMessageSize   = 4096
+	wsSendBuffer       = 64
+	wsMaxSubscriptions = 1000
+)
+
+// wsUpgrader upgrades live inventory connections. Its default origin check
+// rejects browsers on other sites; scanners send no Origin header.
+var wsUpgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+
+// wsCommand is a message from a client:
+//
+//	{"type":"subscribe","id":"1","product_ids":[1,2]}
+//	{"type":"unsubscribe","id":"2","product_ids":[2]}
+//	{"type":"adjust_stock","id":"3","product_id":1,"delta":-2}
+//
+// The optional id is echoed in the reply so clients can match them up.
+type wsCommand struct {
+	Type       string `json:"type"`
+	ID         string `json:"id,omitempty"`
+	ProductIDs []int  `json:"product_ids,omitempty"`
+	ProductID  int    `json:"product_id,omitempty"`
+	Delta      int    `json:"delta,omitempty"`
+}
+
+// wsMessage is a message to a client: a reply to a command ("subscribed",
+// "unsubscribed", "adjusted" or "error"), or a "stock" or "deleted" update
+// about a subscribed product
+type wsMessage struct {
+	Type       string         `json:"type"`
+	ID         string         `json:"id,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	ProductIDs []int          `json:"product_ids,omitempty"`
+	ProductID  int            `json:"product_id,omitempty"`
+	EventID    int            `json:"event_id,omitempty"`
+	Stock      *int           `json:"stock,omitempty"`
+	Available  *int           `json:"available,omitempty"`
+	Delta      int            `json:"delta,omitempty"`
+	Product    *types.Product `json:"product,omitempty"`
+}
+
+// scannerName returns the name of the scanner whose token a request carries,
+// as "Authorization: Bearer <token>" or the access_token parameter for
+// clients that cannot set headers, and whether it carries one
+func (s *ApiServer) scannerName(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get("access_token")
+	}
+	if token == "" {
+		return "", false
+	}
+
+	if s.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1 {
+		return "admin", true
+	}
+	for scannerToken, name := range s.scannerTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(scannerToken)) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// wsClient is a live inventory connection. Only its write loop writes to the
+// connection; everything else queues messages on send.
+type wsClient struct {
+	conn *websocket.Conn
+	send chan wsMessage
+
+	mu       sync.Mutex
+	products map[int]bool
+	closed   bool
+}
+
+// queue sends a message to the client, or drops the client if it is too far
+// behind to take it
+func (c *wsClient) queue(msg wsMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+
+	select {
+	case c.send <- msg:
+	default:
+		c.closed = true
+		close(c.send)
+	}
+}
+
+// close stops the write loop, which closes the connection
+func (c *wsClient) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
+}
+
+// subscribed reports whether the client is subscribed to a product
+func (c *wsClient) subscribed(productID int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.products[productID]
+}
+
+// handleInventoryWebSocket handles GET /ws/inventory requests
+// Upgrades scanners presenting a scanner or admin token to a WebSocket on
+// which they subscribe to products, receive their stock changes as they
+// happen and adjust their stock
+func (s *ApiServer) handleInventoryWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	if s.adminToken == "" && len(s.scannerTokens) == 0 {
+		writeJson(w, http.StatusForbidden, map[string]string{"error": "live inventory is disabled: no scanner tokens are configured"})
+		return
+	}
+	scanner, ok := s.scannerName(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJson(w, http.StatusUnauthorized, map[string]string{"error": "invalid or missing scanner token"})
+		return
+	}
+
+	// Stock adjustments are audited under the scanner's name
+	ctx, cancel := context.WithCancel(services.WithAuditContext(r.Context(), types.AuditContext{
+		Actor:     scanner,
+		RequestID: w.Header().Get("X-Request-ID"),
+	}))
+	defer cancel()
+
+	stream, err := s.svc.SubscribeProductEvents(ctx, types.ProductEventFilter{
+		Types: []string{types.EventStockChanged, types.EventProductDeleted},
+	}, "")
+	if err != nil {
+		writeServiceError(w, err, "failed to subscribe to product events")
+		return
+	}
+
+	// The upgrader writes its own error response
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	client := &wsClient{conn: conn, send: make(chan wsMessage, wsSendBuffer), products: make(map[int]bool)}
+	go client.writeLoop(cancel)
+	go client.forward(ctx, stream)
+	s.readLoop(ctx, client)
+}
+
+// readLoop runs the client's commands in order until the connection fails or
+// closes, then stops the client
+func (s *ApiServer) readLoop(ctx context.Context, c *wsClient) {
+	defer c.close()
+
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var cmd wsCommand
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			c.queue(wsMessage{Type: "error", Error: "invalid JSON format"})
+			continue
+		}
+		s.runCommand(ctx, c, &cmd)
+	}
+}
+
+// runCommand runs a client command and queues its reply
+func (s *ApiServer) runCommand(ctx context.Context, c *wsClient, cmd *wsCommand) {
+	switch cmd.Type {
+	case "subscribe":
+		c.mu.Lock()
+		tooMany := len(c.products)+len(cmd.ProductIDs) > wsMaxSubscriptions
+		c.mu.Unlock()
+		if tooMany {
+			c.queue(wsMessage{Type: "error", ID: cmd.ID, Error: fmt.Sprintf("a connection can subscribe to at most %d products", wsMaxSubscriptions)})
+			return
+		}
+
+		// Send the current stock first so the client starts from a known state
+		var subscribed []int
+		for _, id := range cmd.ProductIDs {
+			product, err := s.svc.GetProductByID(ctx, id)
+			if err != nil {
+				c.queue(wsMessage{Type: "error", ID: cmd.ID, ProductID: id, Error: err.Error()})
+				continue
+			}
+			c.mu.Lock()
+			c.products[id] = true
+			c.mu.Unlock()
+			subscribed = append(subscribed, id)
+			c.queue(wsMessage{Type: "stock", ProductID: id, Stock: &product.Stock, Available: &product.Available})
+		}
+		c.queue(wsMessage{Type: "subscribed", ID: cmd.ID, ProductIDs: subscribed})
+
+	case "unsubscribe":
+		c.mu.Lock()
+		for _, id := range cmd.ProductIDs {
+			delete(c.products, id)
+		}
+		c.mu.Unlock()
+		c.queue(wsMessage{Type: "unsubscribed", ID: cmd.ID, ProductIDs: cmd.ProductIDs})
+
+	case "adjust_stock":
+		product, err := s.svc.AdjustStock(ctx, cmd.ProductID, cmd.Delta)
+		if err != nil {
+			c.queue(wsMessage{Type: "error", ID: cmd.ID, ProductID: cmd.ProductID, Error: err.Error()})
+			return
+		}
+		c.queue(wsMessage{Type: "adjusted", ID: cmd.ID, ProductID: product.ID, Product: product})
+
+	default:
+		c.queue(wsMessage{Type: "error", ID: cmd.ID, Error: fmt.Sprintf("unknown command type %q", cmd.Type)})
+	}
+}
+
+// forward queues the stock changes and deletions of the products the client
+// is subscribed to. If the client falls too far behind the event stream,
+// the stream ends and so does the client.
+func (c *wsClient) forward(ctx context.Context, stream *types.EventStream) {
+	defer c.close()
+
+	for event := range stream.Events {
+		if !c.subscribed(event.ProductID) {
+			continue
+		}
+
+		switch event.Type {
+		case types.EventStockChanged:
+			var change types.StockChangedPayload
+			if err := json.Unmarshal(event.Payload, &change); err != nil {
+				continue
+			}
+			c.queue(wsMessage{Type: "stock", ProductID: event.ProductID, EventID: event.ID, Stock: &change.Stock, Available: &change.Available, Delta: change.Delta})
+		case types.EventProductDeleted:
+			c.queue(wsMessage{Type: "deleted", ProductID: event.ProductID, EventID: event.ID})
+		}
+	}
+}
+
+// writeLoop writes queued messages and keepalive pings to the connection
+// until the client is stopped, then closes the connection and cancels the
+// client's context, which ends its event subscription
+func (c *wsClient) writeLoop(cancel context.CancelFunc) {
+	ping := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ping.Stop()
+		c.conn.Close()
+		cancel()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client is too slow or the connection closed"))
+				return
+			}
+			if err := c.conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ping.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
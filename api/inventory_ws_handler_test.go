@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"go-circleci/repository"
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+// readWS reads the next message from a live inventory connection
+func readWS(t *testing.T, conn *websocket.Conn) wsMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg wsMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	return msg
+}
+
+func TestHandleInventoryWebSocket(t *testing.T) {
+	repo := repository.NewMemoryProductRepository()
+	productService := services.NewProductService(repo, repository.NewMemoryTxManager(repo))
+	product, err := productService.CreateProduct(context.Background(), &types.CreateProductRequest{Name: "Widget", Stock: 5})
+	if err != nil {
+		t.Fatalf("seed product: %v", err)
+	}
+	broker := services.NewEventBroker(100)
+	s := NewApiServer(services.NewCompositeService(services.CompositeOptions{Product: productService, EventBroker: broker}))
+	s.SetScannerTokens(map[string]string{"dock-token": "dock-1"})
+	server := httptest.NewServer(http.HandlerFunc(s.handleInventoryWebSocket))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	// The upgrade request must carry a scanner token
+	_, res, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil || res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("dial without token: err=%v, want 401", err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"Bearer dock-token"}})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Subscribing sends the current stock first
+	conn.WriteJSON(wsCommand{Type: "subscribe", ID: "1", ProductIDs: []int{product.ID, 99}})
+	if msg := readWS(t, conn); msg.Type != "stock" || msg.ProductID != product.ID || *msg.Stock != 5 {
+		t.Errorf("first message = %+v, want the stock of product %d", msg, product.ID)
+	}
+	if msg := readWS(t, conn); msg.Type != "error" || msg.ProductID != 99 || !strings.Contains(msg.Error, "not found") {
+		t.Errorf("second message = %+v, want product 99 not found", msg)
+	}
+	if msg := readWS(t, conn); msg.Type != "subscribed" || msg.ID != "1" || len(msg.ProductIDs) != 1 {
+		t.Errorf("third message = %+v, want subscribed to one product", msg)
+	}
+
+	// Adjustments go through the product service's validation
+	conn.WriteJSON(wsCommand{Type: "adjust_stock", ID: "2", ProductID: product.ID, Delta: -9})
+	if msg := readWS(t, conn); msg.Type != "error" || msg.ID != "2" || !strings.Contains(msg.Error, "insufficient stock") {
+		t.Errorf("overdrawn adjustment reply = %+v, want insufficient stock", msg)
+	}
+	conn.WriteJSON(wsCommand{Type: "adjust_stock", ID: "3", ProductID: product.ID, Delta: -2})
+	if msg := readWS(t, conn); msg.Type != "adjusted" || msg.Product == nil || msg.Product.Stock != 3 {
+		t.Errorf("adjustment reply = %+v, want stock 3", msg)
+	}
+
+	// Stock changes of subscribed products are pushed as they are published
+	payload, _ := json.Marshal(types.StockChangedPayload{ProductID: product.ID, Previous: 5, Stock: 3, Delta: -2, Available: 1})
+	broker.Publish(context.Background(), &types.Event{ID: 1, Type: types.EventStockChanged, ProductID: 2, Payload: payload})
+	broker.Publish(context.Background(), &types.Event{ID: 2, Type: types.EventStockChanged, ProductID: product.ID, Payload: payload})
+	if msg := readWS(t, conn); msg.Type != "stock" || msg.EventID != 2 || *msg.Stock != 3 || msg.Available == nil || *msg.Available != 1 || msg.Delta != -2 {
+		t.Errorf("pushed message = %+v, want event 2 with stock 3 and 1 available", msg)
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte("{"))
+	if msg := readWS(t, conn); msg.Type != "error" || msg.Error != "invalid JSON format" {
+		t.Errorf("reply to bad JSON = %+v, want invalid JSON format", msg)
+	}
+
+	// Closing the connection ends its event subscription
+	conn.Close()
+	for deadline := time.Now().Add(time.Second); broker.Subscribers() != 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("subscription outlived its connection")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
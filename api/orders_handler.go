@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-circleci/types"
+	"net/http"
+	"time"
+)
+
+// parseTimeParam parses an RFC 3339 timestamp or a YYYY-MM-DD date from a query parameter
+func parseTimeParam(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid %s: must be an RFC 3339 timestamp or YYYY-MM-DD date", name)
+}
+
+// handleListOrders handles GET /orders requests
+// Supports ?status=paid&from=2026-01-01&to=2026-02-01, where to is exclusive
+func (s *ApiServer) handleListOrders(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	from, err := parseTimeParam(r, "from")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	to, err := parseTimeParam(r, "to")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	filter := types.OrderFilter{
+		Status: r.URL.Query().Get("status"),
+		From:   from,
+		To:     to,
+	}
+
+	orders, err := s.svc.ListOrders(r.Context(), filter)
+	if err != nil {
+		writeServiceError(w, err, "failed to retrieve orders")
+		return
+	}
+
+	writeJson(w, http.StatusOK, orders)
+}
+
+// handleCreateOrder handles POST /orders requests
+// Places a pending order, deducting its stock, and returns it with HTTP 201 status
+func (s *ApiServer) handleCreateOrder(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	// Parse JSON request body
+	var req types.CreateOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON format"})
+		return
+	}
+
+	order, err := s.svc.CreateOrder(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, err, "failed to create order")
+		return
+	}
+
+	writeJson(w, http.StatusCreated, order)
+}
+
+// handleGetOrder handles GET /orders/{id} requests
+func (s *ApiServer) handleGetOrder(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "order")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	order, err := s.svc.GetOrder(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err, "failed to retrieve order")
+		return
+	}
+
+	writeJson(w, http.StatusOK, order)
+}
+
+// handleOrderAction handles POST /orders/{id}/{action} requests where action is
+// one of pay, fulfil, cancel or refund
+func (s *ApiServer) handleOrderAction(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "order")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	var order *types.Order
+	switch r.PathValue("action") {
+	case "pay":
+		order, err = s.svc.PayOrder(r.Context(), id)
+	case "fulfil":
+		order, err = s.svc.FulfilOrder(r.Context(), id)
+	case "cancel":
+		order, err = s.svc.CancelOrder(r.Context(), id)
+	case "refund":
+		order, err = s.svc.RefundOrder(r.Context(), id)
+	default:
+		writeJson(w, http.StatusNotFound, map[string]string{"error": "unknown order action"})
+		return
+	}
+	if err != nil {
+		writeServiceError(w, err, "failed to update order")
+		return
+	}
+
+	writeJson(w, http.StatusOK, order)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// handleListOutbox handles GET /admin/outbox requests
+// Supports ?status=pending|delivered|failed&limit=50 and returns the newest events first
+func (s *ApiServer) handleListOutbox(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid limit: must be a positive integer"})
+			return
+		}
+	}
+
+	entries, err := s.svc.ListOutbox(r.Context(), r.URL.Query().Get("status"), limit)
+	if err != nil {
+		writeServiceError(w, err, "failed to retrieve outbox")
+		return
+	}
+
+	writeJson(w, http.StatusOK, entries)
+}
+
+// handleRetryOutboxEvent handles POST /admin/outbox/{id}/retry requests
+// Makes a failed event pending again so the relay delivers it on its next pass
+func (s *ApiServer) handleRetryOutboxEvent(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "outbox event")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := s.svc.RetryOutboxEvent(r.Context(), id); err != nil {
+		writeServiceError(w, err, "failed to retry outbox event")
+		return
+	}
+
+	writeJson(w, http.StatusAccepted, map[string]string{"status": "pending"})
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"go-circleci/types"
+	"net/http"
+)
+
+// handleBatchProducts handles POST /products:batch requests
+// Applies a list of create, update and delete operations. An atomic batch that
+// fails is rolled back and returned with the status of the failing operation;
+// otherwise the per-operation results are returned with HTTP 200 status.
+func (s *ApiServer) handleBatchProducts(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	// Parse JSON request body
+	var req types.ProductBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON format"})
+		return
+	}
+
+	res, err := s.svc.BatchProducts(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, err, "failed to apply product batch")
+		return
+	}
+
+	if !res.Committed {
+		status := http.StatusInternalServerError
+		for i := range res.Results {
+			result := &res.Results[i]
+			if result.Status == types.BatchFailed {
+				status = serviceErrorStatus(result.Error)
+				if status == http.StatusInternalServerError {
+					result.Error = "failed to apply product batch"
+				}
+			}
+		}
+		writeJson(w, status, res)
+		return
+	}
+
+	writeJson(w, http.StatusOK, res)
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-circleci/types"
+)
+
+// sseHeartbeatInterval is how often an idle event stream sends a comment, so
+// proxies keep the connection open and dead clients are noticed
+const sseHeartbeatInterval = 15 * time.Second
+
+// queryList returns the comma-separated values of a query parameter, which
+// may also be repeated
+func queryList(r *http.Request, name string) []string {
+	var values []string
+	for _, param := range r.URL.Query()[name] {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// handleProductEvents handles GET /products/events requests
+// Streams product events as Server-Sent Events. Supports ?product_id=1,2 and
+// ?type=StockChanged,ProductUpdated filters, and resumes after the event in
+// the Last-Event-ID header, or the last_event_id parameter, from the buffer
+// of recent events. A "reset" event tells the client the events it missed
+// are gone and it should reload the products instead.
+func (s *ApiServer) handleProductEvents(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJson(w, http.StatusInternalServerError, map[string]string{"error": "streaming is not supported"})
+		return
+	}
+
+	filter := types.ProductEventFilter{Types: queryList(r, "type")}
+	for _, value := range queryList(r, "product_id") {
+		id, err := parseID(value, "product")
+		if err != nil {
+			writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		filter.ProductIDs = append(filter.ProductIDs, id)
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	stream, err := s.svc.SubscribeProductEvents(r.Context(), filter, lastEventID)
+	if err != nil {
+		writeServiceError(w, err, "failed to subscribe to product events")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if stream.Reset {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range stream.Backlog {
+		if err := writeSSEEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-stream.Events:
+			if !ok {
+				// The client fell too far behind; it reconnects with its last event ID
+				return
+			}
+			if err := writeSSEEvent(w, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeSSEEvent writes an event in the Server-Sent Events format, named by its
+// type and with its ID as the event ID
+func writeSSEEvent(w http.ResponseWriter, event *types.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+// readSSE reads lines from an event stream until one starts with prefix
+func readSSE(t *testing.T, lines *bufio.Scanner, prefix string) string {
+	t.Helper()
+	for lines.Scan() {
+		if strings.HasPrefix(lines.Text(), prefix) {
+			return lines.Text()
+		}
+	}
+	t.Fatalf("stream ended before a line starting with %q: %v", prefix, lines.Err())
+	return ""
+}
+
+func TestHandleProductEventsStreamsAndResumes(t *testing.T) {
+	broker := services.NewEventBroker(100)
+	s := NewApiServer(services.NewCompositeService(services.CompositeOptions{EventBroker: broker}))
+	server := httptest.NewServer(http.HandlerFunc(s.handleProductEvents))
+	defer server.Close()
+
+	publish := func(id, productID int, eventType string) {
+		t.Helper()
+		if err := broker.Publish(context.Background(), &types.Event{ID: id, Type: eventType, ProductID: productID}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	publish(1, 1, types.EventProductCreated)
+	publish(2, 2, types.EventProductCreated)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"?product_id=1", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("response = %d %s, want an event stream", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+
+	// Event 2 is about another product, so only the live event 3 arrives
+	publish(3, 1, types.EventStockChanged)
+	lines := bufio.NewScanner(res.Body)
+	if got := readSSE(t, lines, "id: "); got != "id: 3" {
+		t.Errorf("first event line = %q, want id: 3", got)
+	}
+	if got := readSSE(t, lines, "event: "); got != "event: StockChanged" {
+		t.Errorf("event line = %q, want event: StockChanged", got)
+	}
+
+	// Disconnecting the client ends its subscription
+	res.Body.Close()
+	for deadline := time.Now().Add(time.Second); broker.Subscribers() != 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("subscription outlived its client")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	rec := serve(s.handleProductEvents, http.MethodGet, "/products/events?type=Nope", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid filter status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,491 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-circleci/types"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// importBatchSize is how many validated rows are sent to the service at a time,
+// so an import of any size is processed in bounded memory
+const importBatchSize = 200
+
+// maxXLSXImportSize bounds XLSX uploads, which must be read whole to open the zip archive
+const maxXLSXImportSize = 32 << 20
+
+// productFileColumns are the columns of a product CSV or XLSX file, in export order
+var productFileColumns = []string{"sku", "name", "description", "category", "tax_class", "price", "stock", "reorder_point", "reorder_qty"}
+
+// importFormats maps request content types to import formats
+var importFormats = map[string]string{
+	"text/csv":                "csv",
+	"application/jsonl":       "jsonl",
+	"application/x-ndjson":    "jsonl",
+	"application/x-jsonlines": "jsonl",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": "xlsx",
+}
+
+// rowError is a problem with a single import row, which is reported without stopping the import
+type rowError struct {
+	line int
+	sku  string
+	msg  string
+}
+
+func (e *rowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.msg)
+}
+
+// productRowReader reads import rows one at a time. Next returns a *rowError for
+// a row that cannot be parsed, io.EOF at the end, and any other error if the
+// file cannot be read further.
+type productRowReader interface {
+	Next() (*types.ProductImportRow, error)
+}
+
+// recordRowReader reads rows from a source of string records with a header
+// record naming the columns, as CSV and XLSX files have
+type recordRowReader struct {
+	next    func() (line int, record []string, err error)
+	columns map[string]int
+	names   []string
+}
+
+// newRecordRowReader reads the header record and checks it names known columns
+func newRecordRowReader(next func() (int, []string, error)) (*recordRowReader, error) {
+	_, header, err := next()
+	if err == io.EOF {
+		return nil, errors.New("invalid import file: header row is required")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid import file: %w", err)
+	}
+
+	known := make(map[string]bool, len(productFileColumns))
+	for _, column := range productFileColumns {
+		known[column] = true
+	}
+
+	columns := make(map[string]int, len(header))
+	var names []string
+	for i, name := range header {
+		// Spreadsheet tools often start CSV files with a byte order mark
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if name == "" || name == "id" {
+			// Exported files may carry an ID column, which is ignored in favour of the SKU
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("invalid import file: unknown column %q", name)
+		}
+		if _, ok := columns[name]; !ok {
+			names = append(names, name)
+		}
+		columns[name] = i
+	}
+
+	if _, ok := columns["sku"]; !ok {
+		return nil, errors.New("invalid import file: sku column is required")
+	}
+
+	return &recordRowReader{next: next, columns: columns, names: names}, nil
+}
+
+// Next reads and converts the next non-blank record
+func (r *recordRowReader) Next() (*types.ProductImportRow, error) {
+	for {
+		line, record, err := r.next()
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, &rowError{line: parseErr.StartLine, msg: parseErr.Err.Error()}
+			}
+			return nil, err
+		}
+
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		return r.convert(line, record)
+	}
+}
+
+// convert maps a record onto an import row using the header columns
+func (r *recordRowReader) convert(line int, record []string) (*types.ProductImportRow, error) {
+	field := func(name string) string {
+		i, ok := r.columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row := &types.ProductImportRow{
+		Line:        line,
+		SKU:         field("sku"),
+		Name:        field("name"),
+		Description: field("description"),
+		Category:    field("category"),
+		TaxClass:    field("tax_class"),
+		Columns:     r.names,
+	}
+
+	var err error
+	if row.Price, err = parseFloatField(field("price")); err != nil {
+		return nil, &rowError{line: line, sku: row.SKU, msg: "invalid price: must be a number"}
+	}
+	for _, f := range []struct {
+		name string
+		dest *int
+	}{
+		{"stock", &row.Stock},
+		{"reorder_point", &row.ReorderPoint},
+		{"reorder_qty", &row.ReorderQty},
+	} {
+		if *f.dest, err = parseIntField(field(f.name)); err != nil {
+			return nil, &rowError{line: line, sku: row.SKU, msg: fmt.Sprintf("invalid %s: must be a whole number", f.name)}
+		}
+	}
+
+	return row, nil
+}
+
+// parseFloatField parses an optional numeric field, treating blank as 0
+func parseFloatField(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// parseIntField parses an optional whole number field, treating blank as 0.
+// Spreadsheets may store whole numbers as decimals such as "12.0".
+func parseIntField(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if n, err := strconv.Atoi(value); err == nil {
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f != float64(int(f)) {
+		return 0, errors.New("not a whole number")
+	}
+	return int(f), nil
+}
+
+// newCSVRowReader reads rows from a CSV stream
+func newCSVRowReader(r io.Reader) (productRowReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	return newRecordRowReader(func() (int, []string, error) {
+		record, err := reader.Read()
+		if err != nil {
+			return 0, nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		return line, record, nil
+	})
+}
+
+// newXLSXRowReader reads rows from the first worksheet of an XLSX workbook
+func newXLSXRowReader(r io.Reader) (productRowReader, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxXLSXImportSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxXLSXImportSize {
+		return nil, fmt.Errorf("invalid import file: XLSX files must be at most %d MB; use CSV or JSON Lines for larger imports", maxXLSXImportSize>>20)
+	}
+
+	rows, err := readXLSXRows(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	i := 0
+	return newRecordRowReader(func() (int, []string, error) {
+		if i >= len(rows) {
+			return 0, nil, io.EOF
+		}
+		row := rows[i]
+		i++
+		return row.Line, row.Values, nil
+	})
+}
+
+// jsonlRowReader reads one JSON object per line
+type jsonlRowReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// newJSONLRowReader reads rows from a JSON Lines stream
+func newJSONLRowReader(r io.Reader) (productRowReader, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &jsonlRowReader{scanner: scanner}, nil
+}
+
+// Next decodes the next non-blank line
+func (r *jsonlRowReader) Next() (*types.ProductImportRow, error) {
+	for r.scanner.Scan() {
+		r.line++
+		text := bytes.TrimSpace(r.scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		row := &types.ProductImportRow{}
+		if err := json.Unmarshal(text, row); err != nil {
+			return nil, &rowError{line: r.line, msg: "invalid JSON format"}
+		}
+		// The keys of an object that decoded as a row are the columns it carries
+		var fields map[string]json.RawMessage
+		json.Unmarshal(text, &fields)
+		row.Columns = make([]string, 0, len(fields))
+		for _, column := range productFileColumns {
+			if _, ok := fields[column]; ok {
+				row.Columns = append(row.Columns, column)
+			}
+		}
+		row.Line = r.line
+		return row, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// importFormat picks the import format from ?format= or the request content type
+func importFormat(r *http.Request) (string, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		switch format {
+		case "csv", "jsonl", "xlsx":
+			return format, nil
+		}
+		return "", fmt.Errorf("invalid format %q: must be csv, jsonl or xlsx", format)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if format, ok := importFormats[mediaType]; ok {
+		return format, nil
+	}
+
+	return "", errors.New("import format is required: set ?format=csv|jsonl|xlsx or a matching Content-Type")
+}
+
+// validateImportRow applies the same field checks as POST /products to the
+// columns an import row carries. A row without a name may still update a
+// product; the service rejects it if it would create one.
+func validateImportRow(row *types.ProductImportRow) error {
+	if row.Columns == nil || slices.Contains(row.Columns, "name") {
+		if err := validateProductName(row.Name); err != nil {
+			return err
+		}
+	}
+	if err := validatePrice(row.Price); err != nil {
+		return err
+	}
+	return validateStock(row.Stock)
+}
+
+// handleImportProducts handles POST /products/import requests
+// Streams a CSV, JSON Lines or XLSX file of products, creating or updating each
+// row's product by SKU, and returns a report listing the rows that failed.
+// Only the sku column is required, and a name for rows that create a product;
+// an update keeps the current value of any column the file leaves out. With ?dry_run=true every row is validated
+// but nothing is written.
+func (s *ApiServer) handleImportProducts(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	format, err := importFormat(r)
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid dry_run: must be true or false"})
+			return
+		}
+	}
+
+	var reader productRowReader
+	switch format {
+	case "csv":
+		reader, err = newCSVRowReader(r.Body)
+	case "jsonl":
+		reader, err = newJSONLRowReader(r.Body)
+	case "xlsx":
+		reader, err = newXLSXRowReader(r.Body)
+	}
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	report := &types.ImportReport{DryRun: dryRun, Errors: []types.ImportRowResult{}}
+	fail := func(line int, sku string, msg string) {
+		report.Rows++
+		report.Failed++
+		report.Errors = append(report.Errors, types.ImportRowResult{Line: line, SKU: sku, Action: types.ImportFailed, Error: msg})
+	}
+
+	batch := make([]*types.ProductImportRow, 0, importBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		results, err := s.svc.ImportProducts(r.Context(), batch, dryRun)
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			switch result.Action {
+			case types.ImportCreated:
+				report.Rows++
+				report.Created++
+			case types.ImportUpdated:
+				report.Rows++
+				report.Updated++
+			default:
+				fail(result.Line, result.SKU, result.Error)
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	// A SKU may appear only once per file, so rows cannot silently overwrite each other
+	seen := make(map[string]int)
+	for {
+		row, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *rowError
+		if errors.As(err, &rowErr) {
+			fail(rowErr.line, rowErr.sku, rowErr.msg)
+			continue
+		}
+		if err != nil {
+			writeJson(w, http.StatusBadRequest, map[string]any{"error": "failed to read import file: " + err.Error(), "report": report})
+			return
+		}
+
+		row.SKU = strings.TrimSpace(row.SKU)
+		if err := validateImportRow(row); err != nil {
+			fail(row.Line, row.SKU, err.Error())
+			continue
+		}
+		if row.SKU != "" {
+			if first, ok := seen[row.SKU]; ok {
+				fail(row.Line, row.SKU, fmt.Sprintf("duplicate sku: already imported at line %d", first))
+				continue
+			}
+			seen[row.SKU] = row.Line
+		}
+
+		batch = append(batch, row)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				writeJson(w, http.StatusInternalServerError, map[string]any{"error": "failed to import products", "report": report})
+				return
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		writeJson(w, http.StatusInternalServerError, map[string]any{"error": "failed to import products", "report": report})
+		return
+	}
+
+	writeJson(w, http.StatusOK, report)
+}
+
+// handleExportProducts handles GET /products/export?format=csv|jsonl requests
+// Streams every product as it is read from the database. CSV files use the
+// import columns so they can be edited and imported again.
+func (s *ApiServer) handleExportProducts(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+
+	var write func(product *types.Product) error
+	var done func() error
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="products.csv"`)
+		writer := csv.NewWriter(w)
+		if err := writer.Write(productFileColumns); err != nil {
+			return
+		}
+		write = func(product *types.Product) error {
+			return writer.Write([]string{
+				product.SKU,
+				product.Name,
+				product.Description,
+				product.Category,
+				product.TaxClass,
+				strconv.FormatFloat(product.Price, 'f', -1, 64),
+				strconv.Itoa(product.Stock),
+				strconv.Itoa(product.ReorderPoint),
+				strconv.Itoa(product.ReorderQty),
+			})
+		}
+		done = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	case "jsonl":
+		w.Header().Set("Content-Type", "application/jsonl")
+		w.Header().Set("Content-Disposition", `attachment; filename="products.jsonl"`)
+		encoder := json.NewEncoder(w)
+		write = func(product *types.Product) error {
+			return encoder.Encode(product)
+		}
+		done = func() error { return nil }
+	default:
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid format %q: must be csv or jsonl", format)})
+		return
+	}
+
+	// Headers are sent with the first row, so a failure part way through can
+	// only be signalled by cutting the response short
+	err := s.svc.ExportProducts(r.Context(), write)
+	if err == nil {
+		err = done()
+	}
+	if err != nil {
+		fmt.Printf("product export format=%s err=%v\n", format, err)
+	}
+}
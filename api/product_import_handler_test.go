@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-circleci/types"
+)
+
+func TestHandleImportProductsKeepsMissingColumns(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"csv", "text/csv", "sku,name,price\nW-1,Renamed,12.5\n"},
+		{"jsonl", "application/jsonl", `{"sku":"W-1","name":"Renamed","price":12.5}` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, &types.CreateProductRequest{
+				SKU: "W-1", Name: "Widget", Description: "A widget", Category: "tools", TaxClass: "reduced",
+				Price: 9.99, Stock: 7, ReorderPoint: 2, ReorderQty: 10,
+			})
+
+			for _, target := range []string{"/products/import?dry_run=true", "/products/import"} {
+				req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(tt.body))
+				req.Header.Set("Content-Type", tt.contentType)
+				rec := httptest.NewRecorder()
+				server.handleImportProducts(rec, req)
+				checkResponse(t, rec, http.StatusOK, `"updated":1`)
+			}
+
+			got, err := server.svc.GetProductByID(context.Background(), 1)
+			if err != nil {
+				t.Fatalf("GetProductByID: %v", err)
+			}
+			if got.Name != "Renamed" || got.Price != 12.5 {
+				t.Errorf("imported product = %+v, want the name and price from the file", got)
+			}
+			if got.Description != "A widget" || got.Category != "tools" || got.TaxClass != "reduced" ||
+				got.Stock != 7 || got.ReorderPoint != 2 || got.ReorderQty != 10 {
+				t.Errorf("imported product = %+v, want the columns missing from the file kept", got)
+			}
+		})
+	}
+}
+
+func TestHandleImportProductsUpdatesBySKUWithoutName(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantLine    string
+	}{
+		{"csv", "text/csv", "sku,stock\nW-1,3\nW-2,4\n", "3"},
+		{"jsonl", "application/jsonl", `{"sku":"W-1","stock":3}` + "\n" + `{"sku":"W-2","stock":4}` + "\n", "2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, &types.CreateProductRequest{SKU: "W-1", Name: "Widget", Price: 9.99, Stock: 7})
+
+			// W-1 is updated by SKU; W-2 would be created and has no name
+			for _, target := range []string{"/products/import?dry_run=true", "/products/import"} {
+				req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(tt.body))
+				req.Header.Set("Content-Type", tt.contentType)
+				rec := httptest.NewRecorder()
+				server.handleImportProducts(rec, req)
+				checkResponse(t, rec, http.StatusOK, `"created":0,"updated":1,"failed":1,"errors":[{"line":`+tt.wantLine+`,"sku":"W-2","action":"failed","error":"product name is required"}]`)
+			}
+
+			got, err := server.svc.GetProductByID(context.Background(), 1)
+			if err != nil {
+				t.Fatalf("GetProductByID: %v", err)
+			}
+			if got.Name != "Widget" || got.Price != 9.99 || got.Stock != 3 {
+				t.Errorf("imported product = %+v, want the stock from the file and the name and price kept", got)
+			}
+		})
+	}
+}
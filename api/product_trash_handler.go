@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+)
+
+// handleListDeletedProducts handles GET /products/trash requests
+// Returns the deleted products that can still be restored
+func (s *ApiServer) handleListDeletedProducts(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	products, err := s.svc.ListDeletedProducts(r.Context())
+	if err != nil {
+		writeJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to retrieve deleted products"})
+		return
+	}
+
+	writeJson(w, http.StatusOK, products)
+}
+
+// handleRestoreProduct handles POST /products/{id}/restore requests
+// Takes the product out of the trash and returns it
+func (s *ApiServer) handleRestoreProduct(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "product")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	product, err := s.svc.RestoreProduct(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err, "failed to restore product")
+		return
+	}
+
+	writeJson(w, http.StatusOK, product)
+}
+
+// handlePurgeProduct handles DELETE /admin/products/{id} requests
+// Permanently deletes the product, whether it is in the trash or not
+func (s *ApiServer) handlePurgeProduct(w http.ResponseWriter, r *http.Request) {
+	// Only allow DELETE method
+	if r.Method != http.MethodDelete {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "product")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := s.svc.PurgeProduct(r.Context(), id); err != nil {
+		writeServiceError(w, err, "failed to purge product")
+		return
+	}
+
+	writeJson(w, http.StatusOK, map[string]string{"message": "product purged successfully"})
+}
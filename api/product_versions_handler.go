@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// handleListProductVersions handles GET /products/{id}/versions requests
+// Returns every version of the product, oldest first, with the period it was current
+func (s *ApiServer) handleListProductVersions(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "product")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	versions, err := s.svc.ListProductVersions(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err, "failed to retrieve product versions")
+		return
+	}
+
+	writeJson(w, http.StatusOK, versions)
+}
+
+// handleDiffProductVersions handles GET /products/{id}/versions/diff?from=1&to=3 requests
+// Returns the fields that changed between the two versions
+func (s *ApiServer) handleDiffProductVersions(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "product")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	query := r.URL.Query()
+	from, fromErr := strconv.Atoi(query.Get("from"))
+	to, toErr := strconv.Atoi(query.Get("to"))
+	if fromErr != nil || toErr != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid version: from and to must be version numbers"})
+		return
+	}
+
+	diff, err := s.svc.DiffProductVersions(r.Context(), id, from, to)
+	if err != nil {
+		writeServiceError(w, err, "failed to diff product versions")
+		return
+	}
+
+	writeJson(w, http.StatusOK, diff)
+}
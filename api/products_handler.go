@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"go-circleci/types"
 	"net/http"
 	"strconv"
@@ -36,6 +37,25 @@ func extractIDFromPath(path string) (int, error) {
 	return id, nil
 }
 
+// parseID parses a path value such as the {id} in "/reservations/{id}/confirm"
+// The resource name is used in error messages, e.g. "invalid reservation ID"
+func parseID(idStr string, resource string) (int, error) {
+	if idStr == "" {
+		return 0, fmt.Errorf("%s ID is required", resource)
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s ID format: must be an integer", resource)
+	}
+
+	if id <= 0 {
+		return 0, fmt.Errorf("invalid %s ID: must be greater than 0", resource)
+	}
+
+	return id, nil
+}
+
 // validateProductName validates that a product name is not empty
 func validateProductName(name string) error {
 	if strings.TrimSpace(name) == "" {
@@ -62,6 +82,9 @@ func validateStock(stock int) error {
 
 // handleGetAllProducts handles GET /products requests
 // Returns all products in the database as a JSON array
+// Supports ?customer=jane@example.com&coupon=SAVE10&at=2026-12-24 to price products
+// and ?region=GB to add their gross and net prices, and ?as_of=2026-03-01T00:00:00Z
+// to list the products as they were at that time
 func (s *ApiServer) handleGetAllProducts(w http.ResponseWriter, r *http.Request) {
 	// Only allow GET method
 	if r.Method != http.MethodGet {
@@ -69,8 +92,32 @@ func (s *ApiServer) handleGetAllProducts(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	
-	products, err := s.svc.GetAllProducts(r.Context())
+	// Price products for the customer, date and coupon in the query string
+	ctx, err := pricingContext(r)
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	
+	// List the products as they were at as_of, if given
+	asOf, err := parseTimeParam(r, "as_of")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	
+	var products []*types.Product
+	if asOf.IsZero() {
+		products, err = s.svc.GetAllProducts(ctx)
+	} else {
+		products, err = s.svc.GetAllProductsAsOf(ctx, asOf)
+	}
 	if err != nil {
+		// Check if it's a validation error, such as a region without tax rules
+		if strings.Contains(err.Error(), "invalid") {
+			writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
 		writeJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to retrieve products"})
 		return
 	}
@@ -79,7 +126,7 @@ func (s *ApiServer) handleGetAllProducts(w http.ResponseWriter, r *http.Request)
 }
 
 // handleGetProduct handles GET /products/{id} requests
-// Returns a single product by ID
+// Returns a single product by ID, as it was at ?as_of=2026-03-01T00:00:00Z if given
 func (s *ApiServer) handleGetProduct(w http.ResponseWriter, r *http.Request) {
 	// Only allow GET method
 	if r.Method != http.MethodGet {
@@ -94,8 +141,27 @@ func (s *ApiServer) handleGetProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	// Price the product for the customer, date and coupon in the query string
+	ctx, err := pricingContext(r)
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	
+	// Read the product as it was at as_of, if given
+	asOf, err := parseTimeParam(r, "as_of")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	
 	// Get product from service
-	product, err := s.svc.GetProductByID(r.Context(), id)
+	var product *types.Product
+	if asOf.IsZero() {
+		product, err = s.svc.GetProductByID(ctx, id)
+	} else {
+		product, err = s.svc.GetProductAsOf(ctx, id, asOf)
+	}
 	if err != nil {
 		// Check if it's a not found error
 		if strings.Contains(err.Error(), "not found") {
@@ -134,6 +200,11 @@ func (s *ApiServer) handleCreateProduct(w http.ResponseWriter, r *http.Request)
 	// Create product via service
 	product, err := s.svc.CreateProduct(r.Context(), &req)
 	if err != nil {
+		// Check if it's a conflict with another product's SKU
+		if strings.Contains(err.Error(), "already exists") {
+			writeJson(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
 		// Check if it's a validation error
 		if strings.Contains(err.Error(), "required") || 
 		   strings.Contains(err.Error(), "must be") ||
@@ -181,6 +252,11 @@ func (s *ApiServer) handleUpdateProduct(w http.ResponseWriter, r *http.Request)
 			writeJson(w, http.StatusNotFound, map[string]string{"error": err.Error()})
 			return
 		}
+		// Check if it's a conflict with another product's SKU
+		if strings.Contains(err.Error(), "already exists") {
+			writeJson(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
 		// Check if it's a validation error
 		if strings.Contains(err.Error(), "required") || 
 		   strings.Contains(err.Error(), "must be") ||
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-circleci/repository"
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+// newTestServer returns an ApiServer whose product operations run against an
+// in-memory repository seeded with the given products
+func newTestServer(t *testing.T, seed ...*types.CreateProductRequest) *ApiServer {
+	t.Helper()
+	repo := repository.NewMemoryProductRepository()
+	productService := services.NewProductService(repo, repository.NewMemoryTxManager(repo))
+	for _, req := range seed {
+		if _, err := productService.CreateProduct(context.Background(), req); err != nil {
+			t.Fatalf("seed product: %v", err)
+		}
+	}
+	return NewApiServer(services.NewCompositeService(services.CompositeOptions{Product: productService}))
+}
+
+// failingService fails every product operation with err. Other operations are
+// not implemented and panic if called.
+type failingService struct {
+	services.Service
+	err error
+}
+
+func (s failingService) GetAllProducts(ctx context.Context) ([]*types.Product, error) {
+	return nil, s.err
+}
+
+func (s failingService) GetProductByID(ctx context.Context, id int) (*types.Product, error) {
+	return nil, s.err
+}
+
+func (s failingService) CreateProduct(ctx context.Context, req *types.CreateProductRequest) (*types.Product, error) {
+	return nil, s.err
+}
+
+func (s failingService) UpdateProduct(ctx context.Context, id int, req *types.UpdateProductRequest) (*types.Product, error) {
+	return nil, s.err
+}
+
+func (s failingService) DeleteProduct(ctx context.Context, id int) error {
+	return s.err
+}
+
+// serve runs handler on a request and returns the recorded response
+func serve(handler http.HandlerFunc, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+// checkResponse fails the test unless the response has the wanted status and
+// a JSON body containing want
+func checkResponse(t *testing.T, rec *httptest.ResponseRecorder, wantStatus int, want string) {
+	t.Helper()
+	if rec.Code != wantStatus {
+		t.Errorf("status = %d, want %d (body %s)", rec.Code, wantStatus, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if !json.Valid(rec.Body.Bytes()) {
+		t.Errorf("body is not JSON: %s", rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("body = %s, want it to contain %s", rec.Body, want)
+	}
+}
+
+var widget = &types.CreateProductRequest{Name: "Widget", Description: "A widget", Price: 9.99, Stock: 5, SKU: "W-1"}
+
+func TestHandleGetAllProducts(t *testing.T) {
+	tests := []struct {
+		name       string
+		server     *ApiServer
+		method     string
+		target     string
+		wantStatus int
+		want       string
+	}{
+		{"empty", newTestServer(t), http.MethodGet, "/products", http.StatusOK, `[]`},
+		{"products", newTestServer(t, widget), http.MethodGet, "/products", http.StatusOK, `"name":"Widget"`},
+		{"method not allowed", newTestServer(t), http.MethodPatch, "/products", http.StatusMethodNotAllowed, `"error":"method not allowed"`},
+		{"invalid at", newTestServer(t), http.MethodGet, "/products?at=yesterday", http.StatusBadRequest, `"error":"invalid at`},
+		{"region without taxes", newTestServer(t, widget), http.MethodGet, "/products?region=GB", http.StatusBadRequest, `invalid region \"GB\"`},
+		{"service failure", NewApiServer(failingService{err: errors.New("disk on fire")}), http.MethodGet, "/products", http.StatusInternalServerError, `"error":"failed to retrieve products"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.server.handleGetAllProducts, tt.method, tt.target, "")
+			checkResponse(t, rec, tt.wantStatus, tt.want)
+		})
+	}
+}
+
+func TestHandleGetProduct(t *testing.T) {
+	tests := []struct {
+		name       string
+		server     *ApiServer
+		method     string
+		target     string
+		wantStatus int
+		want       string
+	}{
+		{"found", newTestServer(t, widget), http.MethodGet, "/products/1", http.StatusOK, `"id":1,"sku":"W-1","name":"Widget"`},
+		{"not found", newTestServer(t), http.MethodGet, "/products/42", http.StatusNotFound, `"error":"product with ID 42 not found"`},
+		{"non-numeric ID", newTestServer(t), http.MethodGet, "/products/abc", http.StatusBadRequest, `"error":"invalid product ID format: must be an integer"`},
+		{"zero ID", newTestServer(t), http.MethodGet, "/products/0", http.StatusBadRequest, `"error":"invalid product ID: must be greater than 0"`},
+		{"missing ID", newTestServer(t), http.MethodGet, "/products", http.StatusBadRequest, `"error":"product ID is required"`},
+		{"method not allowed", newTestServer(t), http.MethodPost, "/products/1", http.StatusMethodNotAllowed, `"error":"method not allowed"`},
+		{"service failure", NewApiServer(failingService{err: errors.New("disk on fire")}), http.MethodGet, "/products/1", http.StatusInternalServerError, `"error":"failed to retrieve product"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.server.handleGetProduct, tt.method, tt.target, "")
+			checkResponse(t, rec, tt.wantStatus, tt.want)
+		})
+	}
+}
+
+func TestHandleCreateProduct(t *testing.T) {
+	tests := []struct {
+		name       string
+		server     *ApiServer
+		method     string
+		body       string
+		wantStatus int
+		want       string
+	}{
+		{"created", newTestServer(t), http.MethodPost, `{"name":"Widget","price":9.99,"stock":5}`, http.StatusCreated, `"id":1,"sku":"","name":"Widget"`},
+		{"invalid JSON", newTestServer(t), http.MethodPost, `{"name":`, http.StatusBadRequest, `"error":"invalid JSON format"`},
+		{"missing name", newTestServer(t), http.MethodPost, `{"price":1}`, http.StatusBadRequest, `"error":"product name is required"`},
+		{"negative price", newTestServer(t), http.MethodPost, `{"name":"Widget","price":-1}`, http.StatusBadRequest, `"error":"product price must be greater than or equal to 0"`},
+		{"negative stock", newTestServer(t), http.MethodPost, `{"name":"Widget","stock":-1}`, http.StatusBadRequest, `"error":"product stock must be greater than or equal to 0"`},
+		{"taken SKU", newTestServer(t, widget), http.MethodPost, `{"name":"Other","sku":"W-1"}`, http.StatusConflict, `already exists`},
+		{"method not allowed", newTestServer(t), http.MethodGet, ``, http.StatusMethodNotAllowed, `"error":"method not allowed"`},
+		{"service failure", NewApiServer(failingService{err: errors.New("disk on fire")}), http.MethodPost, `{"name":"Widget"}`, http.StatusInternalServerError, `"error":"failed to create product"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.server.handleCreateProduct, tt.method, "/products", tt.body)
+			checkResponse(t, rec, tt.wantStatus, tt.want)
+		})
+	}
+}
+
+func TestHandleUpdateProduct(t *testing.T) {
+	other := &types.CreateProductRequest{Name: "Other", SKU: "O-1"}
+
+	tests := []struct {
+		name       string
+		server     *ApiServer
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		want       string
+	}{
+		{"updated", newTestServer(t, widget), http.MethodPut, "/products/1", `{"name":"Renamed","price":5,"stock":2}`, http.StatusOK, `"name":"Renamed","description":"","category":"","price":5`},
+		{"not found", newTestServer(t), http.MethodPut, "/products/42", `{"name":"Renamed"}`, http.StatusNotFound, `"error":"product with ID 42 not found"`},
+		{"invalid ID", newTestServer(t), http.MethodPut, "/products/abc", `{"name":"Renamed"}`, http.StatusBadRequest, `"error":"invalid product ID format: must be an integer"`},
+		{"invalid JSON", newTestServer(t, widget), http.MethodPut, "/products/1", `[`, http.StatusBadRequest, `"error":"invalid JSON format"`},
+		{"missing name", newTestServer(t, widget), http.MethodPut, "/products/1", `{"price":1}`, http.StatusBadRequest, `"error":"product name is required"`},
+		{"taken SKU", newTestServer(t, widget, other), http.MethodPut, "/products/1", `{"name":"Widget","sku":"O-1"}`, http.StatusConflict, `already exists`},
+		{"method not allowed", newTestServer(t), http.MethodPost, "/products/1", `{}`, http.StatusMethodNotAllowed, `"error":"method not allowed"`},
+		{"service failure", NewApiServer(failingService{err: errors.New("disk on fire")}), http.MethodPut, "/products/1", `{"name":"Widget"}`, http.StatusInternalServerError, `"error":"failed to update product"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.server.handleUpdateProduct, tt.method, tt.target, tt.body)
+			checkResponse(t, rec, tt.wantStatus, tt.want)
+		})
+	}
+}
+
+func TestHandleDeleteProduct(t *testing.T) {
+	tests := []struct {
+		name       string
+		server     *ApiServer
+		method     string
+		target     string
+		wantStatus int
+		want       string
+	}{
+		{"deleted", newTestServer(t, widget), http.MethodDelete, "/products/1", http.StatusOK, `"message":"product deleted successfully"`},
+		{"not found", newTestServer(t), http.MethodDelete, "/products/42", http.StatusNotFound, `"error":"product with ID 42 not found"`},
+		{"invalid ID", newTestServer(t), http.MethodDelete, "/products/-3", http.StatusBadRequest, `"error":"invalid product ID: must be greater than 0"`},
+		{"method not allowed", newTestServer(t), http.MethodGet, "/products/1", http.StatusMethodNotAllowed, `"error":"method not allowed"`},
+		{"service failure", NewApiServer(failingService{err: errors.New("disk on fire")}), http.MethodDelete, "/products/1", http.StatusInternalServerError, `"error":"failed to delete product"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.server.handleDeleteProduct, tt.method, tt.target, "")
+			checkResponse(t, rec, tt.wantStatus, tt.want)
+		})
+	}
+}
+
+func TestHandleDeleteProductRemovesProduct(t *testing.T) {
+	server := newTestServer(t, widget)
+
+	checkResponse(t, serve(server.handleDeleteProduct, http.MethodDelete, "/products/1", ""), http.StatusOK, `deleted`)
+	checkResponse(t, serve(server.handleGetProduct, http.MethodGet, "/products/1", ""), http.StatusNotFound, `not found`)
+}
+
+func TestValidators(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr string
+	}{
+		{"name", validateProductName("Widget"), ""},
+		{"blank name", validateProductName("   "), "product name is required"},
+		{"price", validatePrice(0), ""},
+		{"negative price", validatePrice(-0.5), "product price must be greater than or equal to 0"},
+		{"stock", validateStock(0), ""},
+		{"negative stock", validateStock(-1), "product stock must be greater than or equal to 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == "" && tt.err != nil || tt.wantErr != "" && (tt.err == nil || tt.err.Error() != tt.wantErr) {
+				t.Errorf("error = %v, want %q", tt.err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandleProductTrash(t *testing.T) {
+	server := newTestServer(t, widget)
+	server.SetAdminToken("s3cret")
+	purge := server.requireAdmin(server.handlePurgeProduct)
+
+	restore := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/products/"+id+"/restore", nil)
+		req.SetPathValue("id", id)
+		rec := httptest.NewRecorder()
+		server.handleRestoreProduct(rec, req)
+		return rec
+	}
+
+	checkResponse(t, serve(server.handleListDeletedProducts, http.MethodGet, "/products/trash", ""), http.StatusOK, `[]`)
+	checkResponse(t, restore("1"), http.StatusNotFound, `"error":"product with ID 1 not found in trash"`)
+	checkResponse(t, serve(server.handleDeleteProduct, http.MethodDelete, "/products/1", ""), http.StatusOK, `deleted`)
+	checkResponse(t, serve(server.handleListDeletedProducts, http.MethodGet, "/products/trash", ""), http.StatusOK, `"deleted_at":`)
+	checkResponse(t, restore("abc"), http.StatusBadRequest, `"error":"invalid product ID format: must be an integer"`)
+	checkResponse(t, restore("1"), http.StatusOK, `"name":"Widget"`)
+	checkResponse(t, serve(server.handleGetProduct, http.MethodGet, "/products/1", ""), http.StatusOK, `"name":"Widget"`)
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/products/1", nil)
+	req.SetPathValue("id", "1")
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	purge(rec, req)
+	checkResponse(t, rec, http.StatusOK, `"message":"product purged successfully"`)
+	checkResponse(t, serve(server.handleListDeletedProducts, http.MethodGet, "/products/trash", ""), http.StatusOK, `[]`)
+	checkResponse(t, serve(server.handleGetProduct, http.MethodGet, "/products/1", ""), http.StatusNotFound, `not found`)
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"go-circleci/services"
+	"go-circleci/types"
+	"net/http"
+)
+
+// pricingContext returns the request context carrying the pricing context given
+// by the customer, coupon, at and region query parameters. The customer is not
+// authenticated, so any caller naming a customer gets the promotions targeted
+// at them.
+func pricingContext(r *http.Request) (context.Context, error) {
+	at, err := parseTimeParam(r, "at")
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.URL.Query()
+	return services.WithPricingContext(r.Context(), types.PricingContext{
+		At:       at,
+		Customer: query.Get("customer"),
+		Coupon:   query.Get("coupon"),
+		Region:   query.Get("region"),
+	}), nil
+}
+
+// handleListPromotions handles GET /promotions requests
+// Returns every promotion with its coupon code. Admin only.
+func (s *ApiServer) handleListPromotions(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	promotions, err := s.svc.ListPromotions(r.Context())
+	if err != nil {
+		writeJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to retrieve promotions"})
+		return
+	}
+
+	writeJson(w, http.StatusOK, promotions)
+}
+
+// handleCreatePromotion handles POST /promotions requests
+// Creates a new promotion or coupon and returns it with HTTP 201 status. Admin only.
+func (s *ApiServer) handleCreatePromotion(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	// Parse JSON request body
+	var req types.CreatePromotionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON format"})
+		return
+	}
+
+	promotion, err := s.svc.CreatePromotion(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, err, "failed to create promotion")
+		return
+	}
+
+	writeJson(w, http.StatusCreated, promotion)
+}
+
+// handleGetPromotion handles GET /promotions/{id} requests. Admin only.
+func (s *ApiServer) handleGetPromotion(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "promotion")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	promotion, err := s.svc.GetPromotion(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err, "failed to retrieve promotion")
+		return
+	}
+
+	writeJson(w, http.StatusOK, promotion)
+}
+
+// handleDeletePromotion handles DELETE /promotions/{id} requests. Admin only.
+func (s *ApiServer) handleDeletePromotion(w http.ResponseWriter, r *http.Request) {
+	// Only allow DELETE method
+	if r.Method != http.MethodDelete {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "promotion")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := s.svc.DeletePromotion(r.Context(), id); err != nil {
+		writeServiceError(w, err, "failed to delete promotion")
+		return
+	}
+
+	writeJson(w, http.StatusOK, map[string]string{"message": "promotion deleted successfully"})
+}
+
+// handleValidateCoupon handles POST /coupons/validate requests
+// Returns whether the coupon can be used, with the reason if it cannot
+func (s *ApiServer) handleValidateCoupon(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	// Parse JSON request body
+	var req types.ValidateCouponRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON format"})
+		return
+	}
+
+	validation, err := s.svc.ValidateCoupon(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, err, "failed to validate coupon")
+		return
+	}
+
+	writeJson(w, http.StatusOK, validation)
+}
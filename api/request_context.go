@@ -0,0 +1,101 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+// maxContextHeaderLength bounds the request IDs and actors accepted from clients
+const maxContextHeaderLength = 128
+
+// reservedActors are the actors the server records changes under itself, which
+// a proxy may not claim for its users
+var reservedActors = []string{"admin", "system"}
+
+// withRequestContext wraps a handler so every request carries a request ID,
+// echoed in the X-Request-ID response header, and the actor it is made by,
+// under which the changes it makes are audited
+func (s *ApiServer) withRequestContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		actor, err := s.actor(r)
+		if err != nil {
+			writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		ctx := services.WithAuditContext(r.Context(), types.AuditContext{
+			Actor:     actor,
+			RequestID: requestID,
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// actor returns who a request is made by: "admin" for requests carrying the
+// admin token, otherwise the user named in the X-Actor header if the request
+// comes from a trusted proxy, otherwise "anonymous". A proxy naming one of the
+// reserved actors is an error.
+func (s *ApiServer) actor(r *http.Request) (string, error) {
+	if s.isAdmin(r) {
+		return "admin", nil
+	}
+	actor := r.Header.Get("X-Actor")
+	if actor == "" || len(actor) > maxContextHeaderLength || !s.fromTrustedProxy(r) {
+		return "anonymous", nil
+	}
+	if slices.Contains(reservedActors, strings.ToLower(actor)) {
+		return "", fmt.Errorf("invalid X-Actor: %q is reserved", actor)
+	}
+	return actor, nil
+}
+
+// fromTrustedProxy reports whether a request was sent from one of the trusted
+// proxy networks
+func (s *ApiServer) fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	return slices.ContainsFunc(s.trustedProxies, func(network netip.Prefix) bool { return network.Contains(addr) })
+}
+
+// validRequestID reports whether a client request ID is short and printable
+// enough to be logged and stored as is
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxContextHeaderLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go-circleci/repository"
+	"go-circleci/repository/repotest"
+	"go-circleci/services"
+)
+
+// newAuditedTestServer returns an ApiServer whose product operations run
+// against an empty SQLite database and are recorded in its audit log
+func newAuditedTestServer(t *testing.T) *ApiServer {
+	t.Helper()
+	db := repotest.OpenSQLite(t)
+	auditService := services.NewAuditService(repository.NewSQLiteAuditRepository(db))
+	productService := services.NewProductService(repository.NewSQLiteProductRepository(db), repository.NewSQLTxManager(db))
+	productService.SetAudit(auditService)
+	return NewApiServer(services.NewCompositeService(services.CompositeOptions{Product: productService, Audit: auditService}))
+}
+
+func TestWithRequestContextAuditsActorAndRequestID(t *testing.T) {
+	server := newAuditedTestServer(t)
+	server.SetAdminToken("s3cret")
+	server.SetTrustedProxies([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		requestID     string
+		actor         string
+		authorization string
+		wantActor     string
+	}{
+		{"proxy user", "10.0.0.5:4000", "req-1", "alice", "", "alice"},
+		{"admin token", "10.0.0.5:4000", "req-2", "alice", "Bearer s3cret", "admin"},
+		{"anonymous with bad request ID", "10.0.0.5:4000", "bad id", "", "", "anonymous"},
+		{"actor from untrusted address", "192.0.2.1:4000", "req-4", "alice", "", "anonymous"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(`{"name":"Widget","price":1,"stock":1}`))
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Request-ID", tt.requestID)
+			req.Header.Set("X-Actor", tt.actor)
+			req.Header.Set("Authorization", tt.authorization)
+			rec := httptest.NewRecorder()
+			server.withRequestContext(http.HandlerFunc(server.handleCreateProduct)).ServeHTTP(rec, req)
+			checkResponse(t, rec, http.StatusCreated, `"name":"Widget"`)
+
+			requestID := rec.Header().Get("X-Request-ID")
+			if wantClientID := validRequestID(tt.requestID); requestID == "" || (requestID == tt.requestID) != wantClientID {
+				t.Errorf("X-Request-ID = %q, want the client's ID %q only if it is valid", requestID, tt.requestID)
+			}
+
+			req = httptest.NewRequest(http.MethodGet, "/products/"+strconv.Itoa(i+1)+"/history", nil)
+			req.SetPathValue("id", strconv.Itoa(i+1))
+			rec = httptest.NewRecorder()
+			server.handleGetProductHistory(rec, req)
+			checkResponse(t, rec, http.StatusOK, `"actor":"`+tt.wantActor+`","request_id":"`+requestID+`","operation":"create"`)
+		})
+	}
+}
+
+func TestWithRequestContextRejectsReservedActors(t *testing.T) {
+	server := newAuditedTestServer(t)
+	server.SetTrustedProxies([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+
+	for _, actor := range []string{"admin", "System"} {
+		t.Run(actor, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(`{"name":"Widget","price":1,"stock":1}`))
+			req.RemoteAddr = "10.0.0.5:4000"
+			req.Header.Set("X-Actor", actor)
+			rec := httptest.NewRecorder()
+			server.withRequestContext(http.HandlerFunc(server.handleCreateProduct)).ServeHTTP(rec, req)
+			checkResponse(t, rec, http.StatusBadRequest, "is reserved")
+		})
+	}
+}
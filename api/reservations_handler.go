@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"go-circleci/types"
+	"net/http"
+)
+
+// handleCreateReservation handles POST /reservations requests
+// Holds stock for a checkout and returns the reservation with HTTP 201 status
+func (s *ApiServer) handleCreateReservation(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	// Parse JSON request body
+	var req types.CreateReservationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON format"})
+		return
+	}
+
+	reservation, err := s.svc.CreateReservation(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, err, "failed to create reservation")
+		return
+	}
+
+	writeJson(w, http.StatusCreated, reservation)
+}
+
+// handleGetReservation handles GET /reservations/{id} requests
+func (s *ApiServer) handleGetReservation(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "reservation")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	reservation, err := s.svc.GetReservation(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err, "failed to retrieve reservation")
+		return
+	}
+
+	writeJson(w, http.StatusOK, reservation)
+}
+
+// handleConfirmReservation handles POST /reservations/{id}/confirm requests
+// Deducts the reserved units from on-hand stock
+func (s *ApiServer) handleConfirmReservation(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "reservation")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	reservation, err := s.svc.ConfirmReservation(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err, "failed to confirm reservation")
+		return
+	}
+
+	writeJson(w, http.StatusOK, reservation)
+}
+
+// handleReleaseReservation handles POST /reservations/{id}/release requests
+// Returns the reserved units to available stock
+func (s *ApiServer) handleReleaseReservation(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "reservation")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	reservation, err := s.svc.ReleaseReservation(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err, "failed to release reservation")
+		return
+	}
+
+	writeJson(w, http.StatusOK, reservation)
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"go-circleci/types"
+	"net/http"
+)
+
+// handleListTaxRules handles GET /tax/rules requests
+func (s *ApiServer) handleListTaxRules(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	rules, err := s.svc.ListTaxRules(r.Context())
+	if err != nil {
+		writeJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to retrieve tax rules"})
+		return
+	}
+
+	writeJson(w, http.StatusOK, rules)
+}
+
+// handleCreateTaxRule handles POST /tax/rules requests
+// Creates the tax rule for a region and tax class and returns it with HTTP 201 status. Admin only.
+func (s *ApiServer) handleCreateTaxRule(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	// Parse JSON request body
+	var req types.CreateTaxRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON format"})
+		return
+	}
+
+	rule, err := s.svc.CreateTaxRule(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, err, "failed to create tax rule")
+		return
+	}
+
+	writeJson(w, http.StatusCreated, rule)
+}
+
+// handleDeleteTaxRule handles DELETE /tax/rules/{id} requests. Admin only.
+func (s *ApiServer) handleDeleteTaxRule(w http.ResponseWriter, r *http.Request) {
+	// Only allow DELETE method
+	if r.Method != http.MethodDelete {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "tax rule")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := s.svc.DeleteTaxRule(r.Context(), id); err != nil {
+		writeServiceError(w, err, "failed to delete tax rule")
+		return
+	}
+
+	writeJson(w, http.StatusOK, map[string]string{"message": "tax rule deleted successfully"})
+}
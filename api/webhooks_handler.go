@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"go-circleci/types"
+)
+
+// handleListWebhooks handles GET /webhooks requests
+// Returns every subscription without its secret
+func (s *ApiServer) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	webhooks, err := s.svc.ListWebhooks(r.Context())
+	if err != nil {
+		writeJson(w, http.StatusInternalServerError, map[string]string{"error": "failed to retrieve webhooks"})
+		return
+	}
+
+	writeJson(w, http.StatusOK, webhooks)
+}
+
+// handleCreateWebhook handles POST /webhooks requests
+// Subscribes a URL to events and returns the subscription, with its signing
+// secret, with HTTP 201 status
+func (s *ApiServer) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	// Parse JSON request body
+	var req types.CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON format"})
+		return
+	}
+
+	webhook, err := s.svc.CreateWebhook(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, err, "failed to create webhook")
+		return
+	}
+
+	writeJson(w, http.StatusCreated, webhook)
+}
+
+// handleGetWebhook handles GET /webhooks/{id} requests
+func (s *ApiServer) handleGetWebhook(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "webhook")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	webhook, err := s.svc.GetWebhook(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err, "failed to retrieve webhook")
+		return
+	}
+
+	writeJson(w, http.StatusOK, webhook)
+}
+
+// handleUpdateWebhook handles PUT /webhooks/{id} requests
+// Replaces the subscription's URL and event filter, enables or disables it,
+// and rotates its secret if a new one is given
+func (s *ApiServer) handleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	// Only allow PUT method
+	if r.Method != http.MethodPut {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "webhook")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Parse JSON request body
+	var req types.UpdateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON format"})
+		return
+	}
+
+	webhook, err := s.svc.UpdateWebhook(r.Context(), id, &req)
+	if err != nil {
+		writeServiceError(w, err, "failed to update webhook")
+		return
+	}
+
+	writeJson(w, http.StatusOK, webhook)
+}
+
+// handleDeleteWebhook handles DELETE /webhooks/{id} requests
+func (s *ApiServer) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	// Only allow DELETE method
+	if r.Method != http.MethodDelete {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "webhook")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := s.svc.DeleteWebhook(r.Context(), id); err != nil {
+		writeServiceError(w, err, "failed to delete webhook")
+		return
+	}
+
+	writeJson(w, http.StatusOK, map[string]string{"message": "webhook deleted successfully"})
+}
+
+// handleListWebhookDeliveries handles GET /webhooks/{id}/deliveries requests
+// Supports ?limit=50 and returns the newest deliveries first, with their response codes
+func (s *ApiServer) handleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "webhook")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid limit: must be a positive integer"})
+			return
+		}
+	}
+
+	deliveries, err := s.svc.ListWebhookDeliveries(r.Context(), id, limit)
+	if err != nil {
+		writeServiceError(w, err, "failed to retrieve webhook deliveries")
+		return
+	}
+
+	writeJson(w, http.StatusOK, deliveries)
+}
+
+// handleRedeliverWebhook handles POST /webhooks/{id}/deliveries/{deliveryID}/redeliver requests
+// Queues the delivery's payload to be sent again and returns the new delivery with HTTP 202 status
+func (s *ApiServer) handleRedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	id, err := parseID(r.PathValue("id"), "webhook")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	deliveryID, err := parseID(r.PathValue("deliveryID"), "delivery")
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	delivery, err := s.svc.RedeliverWebhook(r.Context(), id, deliveryID)
+	if err != nil {
+		writeServiceError(w, err, "failed to redeliver webhook")
+		return
+	}
+
+	writeJson(w, http.StatusAccepted, delivery)
+}
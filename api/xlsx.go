@@ -0,0 +1,202 @@
+package api
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// xlsxWorkbook lists the worksheets of a workbook in tab order
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+// xlsxRelationships maps the relationship IDs of a workbook to its parts
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxSharedStrings is the table of strings that cells refer to by index
+type xlsxSharedStrings struct {
+	Items []struct {
+		Text string `xml:"t"`
+		Runs []struct {
+			Text string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"si"`
+}
+
+// xlsxWorksheet is the cell data of a worksheet
+type xlsxWorksheet struct {
+	Rows []struct {
+		Number int `xml:"r,attr"`
+		Cells  []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline struct {
+				Text string `xml:"t"`
+			} `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// xlsxRow is a worksheet row with its 1-based row number
+type xlsxRow struct {
+	Line   int
+	Values []string
+}
+
+// readXLSXRows reads the cell values of the first worksheet of an XLSX workbook.
+// Only the parts needed to read plain values are parsed; styles, formulas and
+// dates are returned as their stored text.
+func readXLSXRows(r io.ReaderAt, size int64) ([]xlsxRow, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.New("invalid XLSX file: not a zip archive")
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := xlsxFirstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared xlsxSharedStrings
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodeXLSXPart(f, &shared); err != nil {
+			return nil, err
+		}
+	}
+	strs := make([]string, len(shared.Items))
+	for i, item := range shared.Items {
+		if len(item.Runs) == 0 {
+			strs[i] = item.Text
+			continue
+		}
+		// Rich text is split into runs that are joined back together
+		var text strings.Builder
+		for _, run := range item.Runs {
+			text.WriteString(run.Text)
+		}
+		strs[i] = text.String()
+	}
+
+	var sheet xlsxWorksheet
+	if err := decodeXLSXPart(files[sheetPath], &sheet); err != nil {
+		return nil, err
+	}
+
+	rows := make([]xlsxRow, 0, len(sheet.Rows))
+	for i, row := range sheet.Rows {
+		line := row.Number
+		if line == 0 {
+			line = i + 1
+		}
+
+		var values []string
+		for j, cell := range row.Cells {
+			col := j
+			if cell.Ref != "" {
+				col = xlsxColumnIndex(cell.Ref)
+			}
+			for len(values) <= col {
+				values = append(values, "")
+			}
+
+			switch cell.Type {
+			case "s":
+				index, err := strconv.Atoi(cell.Value)
+				if err != nil || index < 0 || index >= len(strs) {
+					return nil, fmt.Errorf("invalid XLSX file: bad shared string in cell %s", cell.Ref)
+				}
+				values[col] = strs[index]
+			case "inlineStr":
+				values[col] = cell.Inline.Text
+			default:
+				values[col] = cell.Value
+			}
+		}
+
+		rows = append(rows, xlsxRow{Line: line, Values: values})
+	}
+
+	return rows, nil
+}
+
+// xlsxFirstSheetPath finds the archive path of the first worksheet
+func xlsxFirstSheetPath(files map[string]*zip.File) (string, error) {
+	var workbook xlsxWorkbook
+	var rels xlsxRelationships
+	if f, ok := files["xl/workbook.xml"]; ok {
+		if err := decodeXLSXPart(f, &workbook); err != nil {
+			return "", err
+		}
+	}
+	if f, ok := files["xl/_rels/workbook.xml.rels"]; ok {
+		if err := decodeXLSXPart(f, &rels); err != nil {
+			return "", err
+		}
+	}
+
+	if len(workbook.Sheets) > 0 {
+		for _, rel := range rels.Relationships {
+			if rel.ID != workbook.Sheets[0].RelID {
+				continue
+			}
+			target := strings.TrimPrefix(rel.Target, "/")
+			if !strings.HasPrefix(target, "xl/") {
+				target = path.Join("xl", target)
+			}
+			if _, ok := files[target]; ok {
+				return target, nil
+			}
+		}
+	}
+
+	if _, ok := files["xl/worksheets/sheet1.xml"]; ok {
+		return "xl/worksheets/sheet1.xml", nil
+	}
+
+	return "", errors.New("invalid XLSX file: no worksheet found")
+}
+
+// decodeXLSXPart decodes an XML part of the archive into v
+func decodeXLSXPart(f *zip.File, v any) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("invalid XLSX file: %w", err)
+	}
+	defer rc.Close()
+
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("invalid XLSX file: %s: %w", f.Name, err)
+	}
+	return nil
+}
+
+// xlsxColumnIndex converts the column letters of a cell reference such as "AB12" to a 0-based index
+func xlsxColumnIndex(ref string) int {
+	col := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		col = col*26 + int(ch-'A'+1)
+	}
+	return col - 1
+}
@@ -0,0 +1,183 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	catalogv1 "go-circleci/proto/catalog/v1"
+	"go-circleci/types"
+)
+
+// listBatchSize is the number of products ListProducts reads and prices at a time
+const listBatchSize = 100
+
+// GetProduct handles the GetProduct call
+func (s *Server) GetProduct(ctx context.Context, req *catalogv1.GetProductRequest) (*catalogv1.Product, error) {
+	product, err := s.svc.GetProductByID(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, statusError(err, "failed to retrieve product")
+	}
+	return toProto(product), nil
+}
+
+// ListProducts handles the ListProducts call. The catalog is streamed to find
+// the matching products, which are then read, priced and sent in batches, so
+// neither the catalog nor the response is held in memory at once.
+func (s *Server) ListProducts(req *catalogv1.ListProductsRequest, stream grpc.ServerStreamingServer[catalogv1.Product]) error {
+	ctx := stream.Context()
+
+	var ids []int
+	err := s.svc.ExportProducts(ctx, func(product *types.Product) error {
+		if matches(req, product) {
+			ids = append(ids, product.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return statusError(err, "failed to retrieve products")
+	}
+
+	for len(ids) > 0 {
+		batch := ids[:min(len(ids), listBatchSize)]
+		ids = ids[len(batch):]
+
+		products, err := s.svc.GetProductsByIDs(ctx, batch)
+		if err != nil {
+			return statusError(err, "failed to retrieve products")
+		}
+		for _, product := range products {
+			if err := stream.Send(toProto(product)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CreateProduct handles the CreateProduct call
+func (s *Server) CreateProduct(ctx context.Context, req *catalogv1.CreateProductRequest) (*catalogv1.Product, error) {
+	product, err := s.svc.CreateProduct(ctx, &types.CreateProductRequest{
+		SKU:          req.GetSku(),
+		Name:         req.GetName(),
+		Description:  req.GetDescription(),
+		Category:     req.GetCategory(),
+		TaxClass:     req.GetTaxClass(),
+		Price:        req.GetPrice(),
+		Stock:        int(req.GetStock()),
+		ReorderPoint: int(req.GetReorderPoint()),
+		ReorderQty:   int(req.GetReorderQty()),
+	})
+	if err != nil {
+		return nil, statusError(err, "failed to create product")
+	}
+	return toProto(product), nil
+}
+
+// UpdateProduct handles the UpdateProduct call
+func (s *Server) UpdateProduct(ctx context.Context, req *catalogv1.UpdateProductRequest) (*catalogv1.Product, error) {
+	product, err := s.svc.UpdateProduct(ctx, int(req.GetId()), &types.UpdateProductRequest{
+		SKU:          req.GetSku(),
+		Name:         req.GetName(),
+		Description:  req.GetDescription(),
+		Category:     req.GetCategory(),
+		TaxClass:     req.GetTaxClass(),
+		Price:        req.GetPrice(),
+		Stock:        int(req.GetStock()),
+		ReorderPoint: int(req.GetReorderPoint()),
+		ReorderQty:   int(req.GetReorderQty()),
+	})
+	if err != nil {
+		return nil, statusError(err, "failed to update product")
+	}
+	return toProto(product), nil
+}
+
+// DeleteProduct handles the DeleteProduct call
+func (s *Server) DeleteProduct(ctx context.Context, req *catalogv1.DeleteProductRequest) (*emptypb.Empty, error) {
+	if err := s.svc.DeleteProduct(ctx, int(req.GetId())); err != nil {
+		return nil, statusError(err, "failed to delete product")
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// matches reports whether a product passes the filter of a ListProducts call
+func matches(req *catalogv1.ListProductsRequest, product *types.Product) bool {
+	if category := req.GetCategory(); category != "" && !strings.EqualFold(product.Category, category) {
+		return false
+	}
+	if search := strings.ToLower(req.GetSearch()); search != "" &&
+		!strings.Contains(strings.ToLower(product.Name), search) &&
+		!strings.Contains(strings.ToLower(product.Description), search) &&
+		!strings.Contains(strings.ToLower(product.SKU), search) {
+		return false
+	}
+	if req.GetInStockOnly() && product.Available <= 0 {
+		return false
+	}
+	return true
+}
+
+// toProto converts a product to its protobuf message
+func toProto(product *types.Product) *catalogv1.Product {
+	promotions := make([]*catalogv1.AppliedPromotion, len(product.Promotions))
+	for i, promotion := range product.Promotions {
+		promotions[i] = &catalogv1.AppliedPromotion{
+			Id:         int64(promotion.ID),
+			Name:       promotion.Name,
+			CouponCode: promotion.CouponCode,
+			Discount:   promotion.Discount,
+		}
+	}
+
+	return &catalogv1.Product{
+		Id:             int64(product.ID),
+		Sku:            product.SKU,
+		Name:           product.Name,
+		Description:    product.Description,
+		Category:       product.Category,
+		TaxClass:       product.TaxClass,
+		Price:          product.Price,
+		EffectivePrice: product.EffectivePrice,
+		Promotions:     promotions,
+		Stock:          int32(product.Stock),
+		OnHand:         int32(product.OnHand),
+		Available:      int32(product.Available),
+		ReorderPoint:   int32(product.ReorderPoint),
+		ReorderQty:     int32(product.ReorderQty),
+	}
+}
+
+// statusError maps a service error to a gRPC status by its message, the way the
+// REST API picks an HTTP status, hiding the message of internal errors behind
+// fallback
+func statusError(err error, fallback string) error {
+	msg := err.Error()
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, msg)
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, msg)
+	case strings.Contains(msg, "not found"):
+		return status.Error(codes.NotFound, msg)
+	case strings.Contains(msg, "already exists"):
+		return status.Error(codes.AlreadyExists, msg)
+	case strings.Contains(msg, "concurrently"):
+		return status.Error(codes.Aborted, msg)
+	case strings.Contains(msg, "insufficient stock") ||
+		strings.Contains(msg, "not active") ||
+		strings.Contains(msg, "cannot be"):
+		return status.Error(codes.FailedPrecondition, msg)
+	case strings.Contains(msg, "required") ||
+		strings.Contains(msg, "must be") ||
+		strings.Contains(msg, "invalid"):
+		return status.Error(codes.InvalidArgument, msg)
+	default:
+		return status.Error(codes.Internal, fallback)
+	}
+}
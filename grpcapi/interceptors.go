@@ -0,0 +1,130 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+// maxRequestIDLength bounds the request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// logUnary logs every unary call with its status code and duration
+func logUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("gRPC %s code=%s err=%v took=%v\n", info.FullMethod, status.Code(err), err, time.Since(start))
+	}(time.Now())
+
+	return handler(ctx, req)
+}
+
+// logStream logs every streaming call with its status code and duration
+func logStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func(start time.Time) {
+		fmt.Printf("gRPC %s code=%s err=%v took=%v\n", info.FullMethod, status.Code(err), err, time.Since(start))
+	}(time.Now())
+
+	return handler(srv, stream)
+}
+
+// authenticateUnary runs unary calls in the audit context of the caller their
+// token belongs to, rejecting calls without a valid token
+func (s *Server) authenticateUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := s.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authenticateStream runs streaming calls in the audit context of the caller
+// their token belongs to, rejecting calls without a valid token
+func (s *Server) authenticateStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+}
+
+// contextStream is a server stream carrying a different context
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate checks the bearer token in the "authorization" metadata of a
+// call and returns its context carrying the caller as the audit actor and the
+// request ID from "x-request-id", which is generated if missing and echoed in
+// the response header. Health checks and reflection need no token.
+func (s *Server) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if strings.HasPrefix(method, "/grpc.health.v1.Health/") || strings.HasPrefix(method, "/grpc.reflection.") {
+		return ctx, nil
+	}
+	if s.adminToken == "" && len(s.clientTokens) == 0 {
+		return nil, status.Error(codes.PermissionDenied, "gRPC calls are disabled: no tokens are configured")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	actor, ok := s.caller(first(md, "authorization"))
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid or missing token")
+	}
+
+	requestID := first(md, "x-request-id")
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		requestID = newRequestID()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs("x-request-id", requestID))
+
+	return services.WithAuditContext(ctx, types.AuditContext{Actor: actor, RequestID: requestID}), nil
+}
+
+// caller returns the name of the caller an "authorization" value belongs to
+func (s *Server) caller(authorization string) (string, bool) {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+
+	if s.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1 {
+		return "admin", true
+	}
+	for clientToken, name := range s.clientTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(clientToken)) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// first returns the first value of a metadata key, or "" if there is none
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// newRequestID returns a random request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
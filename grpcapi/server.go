@@ -0,0 +1,71 @@
+// Package grpcapi serves the product catalog over gRPC, next to the REST API
+// and backed by the same services
+package grpcapi
+
+import (
+	"fmt"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	catalogv1 "go-circleci/proto/catalog/v1"
+	"go-circleci/services"
+)
+
+// Server implements the ProductCatalog gRPC service on top of a services.Service
+type Server struct {
+	catalogv1.UnimplementedProductCatalogServer
+	svc          services.Service
+	adminToken   string
+	clientTokens map[string]string
+}
+
+func NewServer(svc services.Service) *Server {
+	return &Server{svc: svc}
+}
+
+// SetAdminToken sets the admin token, which is accepted on every call and
+// audited as "admin"
+func (s *Server) SetAdminToken(token string) {
+	s.adminToken = token
+}
+
+// SetClientTokens sets the bearer tokens that client services present, mapped
+// to the names the changes they make are audited under. Calls are rejected
+// until a client token or the admin token is set.
+func (s *Server) SetClientTokens(tokens map[string]string) {
+	s.clientTokens = tokens
+}
+
+// GRPCServer returns a gRPC server serving the catalog together with the
+// standard health checking and reflection services. Catalog calls are logged
+// and must carry a token; health checks and reflection are open.
+func (s *Server) GRPCServer() *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(logUnary, s.authenticateUnary),
+		grpc.ChainStreamInterceptor(logStream, s.authenticateStream),
+	)
+	catalogv1.RegisterProductCatalogServer(server, s)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(catalogv1.ProductCatalog_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	reflection.Register(server)
+	return server
+}
+
+// Start serves gRPC on listenAddress until the listener fails
+func (s *Server) Start(listenAddress string) error {
+	listener, err := net.Listen("tcp", listenAddress)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("gRPC server listening on %s\n", listenAddress)
+
+	return s.GRPCServer().Serve(listener)
+}
@@ -0,0 +1,255 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	catalogv1 "go-circleci/proto/catalog/v1"
+	"go-circleci/repository"
+	"go-circleci/services"
+)
+
+// dial serves server over an in-memory listener and returns a connection to it
+func dial(t *testing.T, server *Server) *grpc.ClientConn {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := server.GRPCServer()
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// newTestServer returns a server over an in-memory product repository that
+// accepts the token "t0ken" of the client "billing"
+func newTestServer() *Server {
+	repo := repository.NewMemoryProductRepository()
+	productService := services.NewProductService(repo, repository.NewMemoryTxManager(repo))
+	server := NewServer(services.NewCompositeService(services.CompositeOptions{Product: productService}))
+	server.SetClientTokens(map[string]string{"t0ken": "billing"})
+	return server
+}
+
+// authorized returns a context carrying the token of the test client
+func authorized() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer t0ken")
+}
+
+// checkCode fails the test unless err has the wanted status code
+func checkCode(t *testing.T, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Errorf("code = %s (err %v), want %s", got, err, want)
+	}
+}
+
+func TestProductCatalogCRUD(t *testing.T) {
+	client := catalogv1.NewProductCatalogClient(dial(t, newTestServer()))
+	ctx := authorized()
+
+	created, err := client.CreateProduct(ctx, &catalogv1.CreateProductRequest{Sku: "W-1", Name: "Widget", Price: 9.99, Stock: 5})
+	checkCode(t, err, codes.OK)
+	if created.GetId() != 1 || created.GetName() != "Widget" || created.GetAvailable() != 5 || created.GetEffectivePrice() != 9.99 {
+		t.Errorf("created = %v", created)
+	}
+
+	got, err := client.GetProduct(ctx, &catalogv1.GetProductRequest{Id: created.GetId()})
+	checkCode(t, err, codes.OK)
+	if got.GetSku() != "W-1" {
+		t.Errorf("GetProduct = %v, want the created product", got)
+	}
+
+	updated, err := client.UpdateProduct(ctx, &catalogv1.UpdateProductRequest{Id: created.GetId(), Sku: "W-1", Name: "Renamed", Price: 5, Stock: 2})
+	checkCode(t, err, codes.OK)
+	if updated.GetName() != "Renamed" || updated.GetStock() != 2 {
+		t.Errorf("updated = %v", updated)
+	}
+
+	_, err = client.DeleteProduct(ctx, &catalogv1.DeleteProductRequest{Id: created.GetId()})
+	checkCode(t, err, codes.OK)
+	_, err = client.GetProduct(ctx, &catalogv1.GetProductRequest{Id: created.GetId()})
+	checkCode(t, err, codes.NotFound)
+}
+
+func TestProductCatalogErrorCodes(t *testing.T) {
+	client := catalogv1.NewProductCatalogClient(dial(t, newTestServer()))
+	ctx := authorized()
+	if _, err := client.CreateProduct(ctx, &catalogv1.CreateProductRequest{Sku: "W-1", Name: "Widget"}); err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"missing product", func() error {
+			_, err := client.GetProduct(ctx, &catalogv1.GetProductRequest{Id: 42})
+			return err
+		}, codes.NotFound},
+		{"invalid ID", func() error {
+			_, err := client.GetProduct(ctx, &catalogv1.GetProductRequest{Id: 0})
+			return err
+		}, codes.InvalidArgument},
+		{"missing name", func() error {
+			_, err := client.CreateProduct(ctx, &catalogv1.CreateProductRequest{Price: 1})
+			return err
+		}, codes.InvalidArgument},
+		{"taken SKU", func() error {
+			_, err := client.CreateProduct(ctx, &catalogv1.CreateProductRequest{Sku: "W-1", Name: "Other"})
+			return err
+		}, codes.AlreadyExists},
+		{"update missing", func() error {
+			_, err := client.UpdateProduct(ctx, &catalogv1.UpdateProductRequest{Id: 42, Name: "Renamed"})
+			return err
+		}, codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkCode(t, tt.call(), tt.want)
+		})
+	}
+}
+
+func TestStatusErrorHidesInternalErrors(t *testing.T) {
+	err := statusError(errors.New("disk on fire"), "failed to retrieve product")
+	checkCode(t, err, codes.Internal)
+	if msg := status.Convert(err).Message(); msg != "failed to retrieve product" {
+		t.Errorf("message = %q, want the fallback", msg)
+	}
+}
+
+func TestListProductsStreams(t *testing.T) {
+	client := catalogv1.NewProductCatalogClient(dial(t, newTestServer()))
+	ctx := authorized()
+
+	// More products than fit in one batch, every third out of stock
+	for i := range listBatchSize + 20 {
+		_, err := client.CreateProduct(ctx, &catalogv1.CreateProductRequest{Name: "Widget", Category: "tools", Price: 1, Stock: int32(i % 3)})
+		if err != nil {
+			t.Fatalf("CreateProduct: %v", err)
+		}
+	}
+	if _, err := client.CreateProduct(ctx, &catalogv1.CreateProductRequest{Name: "Gadget", Category: "toys", Stock: 1}); err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+
+	stream, err := client.ListProducts(ctx, &catalogv1.ListProductsRequest{Category: "TOOLS", InStockOnly: true})
+	if err != nil {
+		t.Fatalf("ListProducts: %v", err)
+	}
+	var ids []int64
+	for {
+		product, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if product.GetCategory() != "tools" || product.GetAvailable() == 0 {
+			t.Errorf("streamed %v, want only tools in stock", product)
+		}
+		ids = append(ids, product.GetId())
+	}
+
+	if len(ids) != 80 {
+		t.Fatalf("streamed %d products, want 80", len(ids))
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Fatalf("products streamed out of ID order: %v", ids)
+		}
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("missing token", func(t *testing.T) {
+		client := catalogv1.NewProductCatalogClient(dial(t, newTestServer()))
+		_, err := client.GetProduct(ctx, &catalogv1.GetProductRequest{Id: 1})
+		checkCode(t, err, codes.Unauthenticated)
+	})
+
+	t.Run("wrong token", func(t *testing.T) {
+		client := catalogv1.NewProductCatalogClient(dial(t, newTestServer()))
+		_, err := client.GetProduct(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer nope"), &catalogv1.GetProductRequest{Id: 1})
+		checkCode(t, err, codes.Unauthenticated)
+	})
+
+	t.Run("streaming call", func(t *testing.T) {
+		client := catalogv1.NewProductCatalogClient(dial(t, newTestServer()))
+		stream, err := client.ListProducts(ctx, &catalogv1.ListProductsRequest{})
+		if err == nil {
+			_, err = stream.Recv()
+		}
+		checkCode(t, err, codes.Unauthenticated)
+	})
+
+	t.Run("admin token", func(t *testing.T) {
+		server := NewServer(newTestServer().svc)
+		server.SetAdminToken("s3cret")
+		client := catalogv1.NewProductCatalogClient(dial(t, server))
+		_, err := client.GetProduct(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer s3cret"), &catalogv1.GetProductRequest{Id: 1})
+		checkCode(t, err, codes.NotFound)
+	})
+
+	t.Run("no tokens configured", func(t *testing.T) {
+		client := catalogv1.NewProductCatalogClient(dial(t, NewServer(newTestServer().svc)))
+		_, err := client.GetProduct(ctx, &catalogv1.GetProductRequest{Id: 1})
+		checkCode(t, err, codes.PermissionDenied)
+	})
+}
+
+func TestHealthAndReflection(t *testing.T) {
+	conn := dial(t, newTestServer())
+	ctx := context.Background()
+
+	// Neither needs a token
+	res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: catalogv1.ProductCatalog_ServiceDesc.ServiceName})
+	if err != nil || res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("health check = %v, %v, want SERVING", res, err)
+	}
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatalf("ServerReflectionInfo: %v", err)
+	}
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{}})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	reply, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	found := false
+	for _, service := range reply.GetListServicesResponse().GetService() {
+		found = found || service.GetName() == "catalog.v1.ProductCatalog"
+	}
+	if !found {
+		t.Errorf("reflection services = %v, want catalog.v1.ProductCatalog", reply.GetListServicesResponse().GetService())
+	}
+}
@@ -18,7 +18,11 @@ func NewLoggingService(next services.Service) services.Service {
 
 func (s *LoggingService) GetCatFact(context context.Context) (fact *types.CatFact, err error) {
 	defer func(start time.Time) {
-		fmt.Printf("fact=%s err=%v took=%v", fact.Fact, err, time.Since(start))
+		text := ""
+		if fact != nil {
+			text = fact.Fact
+		}
+		fmt.Printf("GetCatFact fact=%s err=%v took=%v\n", text, err, time.Since(start))
 	}(time.Now())
 
 	return s.next.GetCatFact(context)
@@ -40,6 +44,26 @@ func (s *LoggingService) GetProductByID(ctx context.Context, id int) (product *t
 	return s.next.GetProductByID(ctx, id)
 }
 
+func (s *LoggingService) GetProductsByIDs(ctx context.Context, ids []int) (products []*types.Product, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("GetProductsByIDs ids=%v found=%d err=%v took=%v\n", ids, len(products), err, time.Since(start))
+	}(time.Now())
+
+	return s.next.GetProductsByIDs(ctx, ids)
+}
+
+func (s *LoggingService) ListProductsPage(ctx context.Context, filter types.ProductFilter, after, limit int) (page *types.ProductPage, err error) {
+	defer func(start time.Time) {
+		count := 0
+		if page != nil {
+			count = len(page.Products)
+		}
+		fmt.Printf("ListProductsPage category=%s search=%s after=%d limit=%d count=%d err=%v took=%v\n", filter.Category, filter.Search, after, limit, count, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.ListProductsPage(ctx, filter, after, limit)
+}
+
 func (s *LoggingService) CreateProduct(ctx context.Context, req *types.CreateProductRequest) (product *types.Product, err error) {
 	defer func(start time.Time) {
 		fmt.Printf("CreateProduct name=%s err=%v took=%v\n", req.Name, err, time.Since(start))
@@ -63,3 +87,477 @@ func (s *LoggingService) DeleteProduct(ctx context.Context, id int) (err error)
 
 	return s.next.DeleteProduct(ctx, id)
 }
+
+func (s *LoggingService) AdjustStock(ctx context.Context, id, delta int) (product *types.Product, err error) {
+	defer func(start time.Time) {
+		stock := 0
+		if product != nil {
+			stock = product.Stock
+		}
+		fmt.Printf("AdjustStock id=%d delta=%d stock=%d err=%v took=%v\n", id, delta, stock, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.AdjustStock(ctx, id, delta)
+}
+
+func (s *LoggingService) ListDeletedProducts(ctx context.Context) (products []*types.Product, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("ListDeletedProducts count=%d err=%v took=%v\n", len(products), err, time.Since(start))
+	}(time.Now())
+
+	return s.next.ListDeletedProducts(ctx)
+}
+
+func (s *LoggingService) RestoreProduct(ctx context.Context, id int) (product *types.Product, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("RestoreProduct id=%d err=%v took=%v\n", id, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.RestoreProduct(ctx, id)
+}
+
+func (s *LoggingService) PurgeProduct(ctx context.Context, id int) (err error) {
+	defer func(start time.Time) {
+		fmt.Printf("PurgeProduct id=%d err=%v took=%v\n", id, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.PurgeProduct(ctx, id)
+}
+
+func (s *LoggingService) GetProductAsOf(ctx context.Context, id int, at time.Time) (product *types.Product, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("GetProductAsOf id=%d as_of=%s err=%v took=%v\n", id, at.Format(time.RFC3339), err, time.Since(start))
+	}(time.Now())
+
+	return s.next.GetProductAsOf(ctx, id, at)
+}
+
+func (s *LoggingService) GetAllProductsAsOf(ctx context.Context, at time.Time) (products []*types.Product, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("GetAllProductsAsOf as_of=%s count=%d err=%v took=%v\n", at.Format(time.RFC3339), len(products), err, time.Since(start))
+	}(time.Now())
+
+	return s.next.GetAllProductsAsOf(ctx, at)
+}
+
+func (s *LoggingService) ListProductVersions(ctx context.Context, id int) (versions []*types.ProductVersion, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("ListProductVersions id=%d count=%d err=%v took=%v\n", id, len(versions), err, time.Since(start))
+	}(time.Now())
+
+	return s.next.ListProductVersions(ctx, id)
+}
+
+func (s *LoggingService) DiffProductVersions(ctx context.Context, id, from, to int) (diff *types.ProductVersionDiff, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("DiffProductVersions id=%d from=%d to=%d err=%v took=%v\n", id, from, to, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.DiffProductVersions(ctx, id, from, to)
+}
+
+func (s *LoggingService) BatchProducts(ctx context.Context, req *types.ProductBatchRequest) (res *types.ProductBatchResponse, err error) {
+	defer func(start time.Time) {
+		committed := res != nil && res.Committed
+		fmt.Printf("BatchProducts mode=%s operations=%d committed=%t err=%v took=%v\n", req.Mode, len(req.Operations), committed, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.BatchProducts(ctx, req)
+}
+
+func (s *LoggingService) ImportProducts(ctx context.Context, rows []*types.ProductImportRow, dryRun bool) (results []types.ImportRowResult, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("ImportProducts rows=%d dry_run=%t err=%v took=%v\n", len(rows), dryRun, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.ImportProducts(ctx, rows, dryRun)
+}
+
+func (s *LoggingService) ExportProducts(ctx context.Context, fn func(product *types.Product) error) (err error) {
+	count := 0
+	defer func(start time.Time) {
+		fmt.Printf("ExportProducts count=%d err=%v took=%v\n", count, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.ExportProducts(ctx, func(product *types.Product) error {
+		count++
+		return fn(product)
+	})
+}
+
+func (s *LoggingService) CreateReservation(ctx context.Context, req *types.CreateReservationRequest) (reservation *types.Reservation, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("CreateReservation product_id=%d quantity=%d reference=%s err=%v took=%v\n", req.ProductID, req.Quantity, req.Reference, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.CreateReservation(ctx, req)
+}
+
+func (s *LoggingService) GetReservation(ctx context.Context, id int) (reservation *types.Reservation, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("GetReservation id=%d err=%v took=%v\n", id, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.GetReservation(ctx, id)
+}
+
+func (s *LoggingService) ConfirmReservation(ctx context.Context, id int) (reservation *types.Reservation, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("ConfirmReservation id=%d err=%v took=%v\n", id, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.ConfirmReservation(ctx, id)
+}
+
+func (s *LoggingService) ReleaseReservation(ctx context.Context, id int) (reservation *types.Reservation, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("ReleaseReservation id=%d err=%v took=%v\n", id, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.ReleaseReservation(ctx, id)
+}
+
+func (s *LoggingService) ListLocations(ctx context.Context) (locations []*types.Location, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("ListLocations count=%d err=%v took=%v\n", len(locations), err, time.Since(start))
+	}(time.Now())
+
+	return s.next.ListLocations(ctx)
+}
+
+func (s *LoggingService) CreateLocation(ctx context.Context, req *types.CreateLocationRequest) (location *types.Location, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("CreateLocation code=%s err=%v took=%v\n", req.Code, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.CreateLocation(ctx, req)
+}
+
+func (s *LoggingService) GetProductStock(ctx context.Context, productID int) (stock *types.ProductStock, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("GetProductStock product_id=%d err=%v took=%v\n", productID, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.GetProductStock(ctx, productID)
+}
+
+func (s *LoggingService) TransferStock(ctx context.Context, req *types.StockTransferRequest) (transfer *types.StockTransfer, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("TransferStock product_id=%d from=%d to=%d quantity=%d err=%v took=%v\n", req.ProductID, req.FromLocationID, req.ToLocationID, req.Quantity, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.TransferStock(ctx, req)
+}
+
+func (s *LoggingService) GetLowStockReport(ctx context.Context) (items []*types.LowStockItem, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("GetLowStockReport count=%d err=%v took=%v\n", len(items), err, time.Since(start))
+	}(time.Now())
+
+	return s.next.GetLowStockReport(ctx)
+}
+
+func (s *LoggingService) CreateOrder(ctx context.Context, req *types.CreateOrderRequest) (order *types.Order, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("CreateOrder customer=%s lines=%d err=%v took=%v\n", req.Customer, len(req.Lines), err, time.Since(start))
+	}(time.Now())
+
+	return s.next.CreateOrder(ctx, req)
+}
+
+func (s *LoggingService) GetOrder(ctx context.Context, id int) (order *types.Order, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("GetOrder id=%d err=%v took=%v\n", id, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.GetOrder(ctx, id)
+}
+
+func (s *LoggingService) ListOrders(ctx context.Context, filter types.OrderFilter) (orders []*types.Order, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("ListOrders status=%s count=%d err=%v took=%v\n", filter.Status, len(orders), err, time.Since(start))
+	}(time.Now())
+
+	return s.next.ListOrders(ctx, filter)
+}
+
+func (s *LoggingService) PayOrder(ctx context.Context, id int) (order *types.Order, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("PayOrder id=%d err=%v took=%v\n", id, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.PayOrder(ctx, id)
+}
+
+func (s *LoggingService) FulfilOrder(ctx context.Context, id int) (order *types.Order, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("FulfilOrder id=%d err=%v took=%v\n", id, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.FulfilOrder(ctx, id)
+}
+
+func (s *LoggingService) CancelOrder(ctx context.Context, id int) (order *types.Order, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("CancelOrder id=%d err=%v took=%v\n", id, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.CancelOrder(ctx, id)
+}
+
+func (s *LoggingService) RefundOrder(ctx context.Context, id int) (order *types.Order, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("RefundOrder id=%d err=%v took=%v\n", id, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.RefundOrder(ctx, id)
+}
+
+func (s *LoggingService) CreateCart(ctx context.Context, req *types.CreateCartRequest) (cart *types.Cart, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("CreateCart customer=%s err=%v took=%v\n", req.Customer, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.CreateCart(ctx, req)
+}
+
+func (s *LoggingService) GetCart(ctx context.Context, id int) (cart *types.Cart, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("GetCart id=%d err=%v took=%v\n", id, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.GetCart(ctx, id)
+}
+
+func (s *LoggingService) AddCartLine(ctx context.Context, cartID int, req *types.CartLineRequest) (cart *types.Cart, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("AddCartLine cart_id=%d product_id=%d quantity=%d err=%v took=%v\n", cartID, req.ProductID, req.Quantity, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.AddCartLine(ctx, cartID, req)
+}
+
+func (s *LoggingService) UpdateCartLine(ctx context.Context, cartID int, req *types.CartLineRequest) (cart *types.Cart, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("UpdateCartLine cart_id=%d product_id=%d quantity=%d err=%v took=%v\n", cartID, req.ProductID, req.Quantity, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.UpdateCartLine(ctx, cartID, req)
+}
+
+func (s *LoggingService) RemoveCartLine(ctx context.Context, cartID int, productID int) (cart *types.Cart, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("RemoveCartLine cart_id=%d product_id=%d err=%v took=%v\n", cartID, productID, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.RemoveCartLine(ctx, cartID, productID)
+}
+
+func (s *LoggingService) CheckoutCart(ctx context.Context, cartID int) (order *types.Order, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("CheckoutCart cart_id=%d err=%v took=%v\n", cartID, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.CheckoutCart(ctx, cartID)
+}
+
+func (s *LoggingService) ListPromotions(ctx context.Context) (promotions []*types.Promotion, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("ListPromotions count=%d err=%v took=%v\n", len(promotions), err, time.Since(start))
+	}(time.Now())
+
+	return s.next.ListPromotions(ctx)
+}
+
+func (s *LoggingService) GetPromotion(ctx context.Context, id int) (promotion *types.Promotion, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("GetPromotion id=%d err=%v took=%v\n", id, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.GetPromotion(ctx, id)
+}
+
+func (s *LoggingService) CreatePromotion(ctx context.Context, req *types.CreatePromotionRequest) (promotion *types.Promotion, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("CreatePromotion name=%s kind=%s value=%.2f coupon_code=%s err=%v took=%v\n", req.Name, req.Kind, req.Value, req.CouponCode, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.CreatePromotion(ctx, req)
+}
+
+func (s *LoggingService) DeletePromotion(ctx context.Context, id int) (err error) {
+	defer func(start time.Time) {
+		fmt.Printf("DeletePromotion id=%d err=%v took=%v\n", id, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.DeletePromotion(ctx, id)
+}
+
+func (s *LoggingService) ValidateCoupon(ctx context.Context, req *types.ValidateCouponRequest) (validation *types.CouponValidation, err error) {
+	defer func(start time.Time) {
+		valid := validation != nil && validation.Valid
+		fmt.Printf("ValidateCoupon code=%s product_id=%d valid=%t err=%v took=%v\n", req.Code, req.ProductID, valid, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.ValidateCoupon(ctx, req)
+}
+
+func (s *LoggingService) ListTaxRules(ctx context.Context) (rules []*types.TaxRule, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("ListTaxRules count=%d err=%v took=%v\n", len(rules), err, time.Since(start))
+	}(time.Now())
+
+	return s.next.ListTaxRules(ctx)
+}
+
+func (s *LoggingService) CreateTaxRule(ctx context.Context, req *types.CreateTaxRuleRequest) (rule *types.TaxRule, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("CreateTaxRule region=%s tax_class=%s rate=%.2f inclusive=%t err=%v took=%v\n", req.Region, req.TaxClass, req.Rate, req.Inclusive, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.CreateTaxRule(ctx, req)
+}
+
+func (s *LoggingService) DeleteTaxRule(ctx context.Context, id int) (err error) {
+	defer func(start time.Time) {
+		fmt.Printf("DeleteTaxRule id=%d err=%v took=%v\n", id, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.DeleteTaxRule(ctx, id)
+}
+
+func (s *LoggingService) CreateBackup(ctx context.Context) (backup *types.Backup, err error) {
+	defer func(start time.Time) {
+		name := ""
+		if backup != nil {
+			name = backup.Name
+		}
+		fmt.Printf("CreateBackup name=%s err=%v took=%v\n", name, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.CreateBackup(ctx)
+}
+
+func (s *LoggingService) ListBackups(ctx context.Context) (backups []*types.Backup, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("ListBackups count=%d err=%v took=%v\n", len(backups), err, time.Since(start))
+	}(time.Now())
+
+	return s.next.ListBackups(ctx)
+}
+
+func (s *LoggingService) ListAuditEntries(ctx context.Context, filter types.AuditFilter) (entries []*types.AuditEntry, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("ListAuditEntries product_id=%d actor=%s operation=%s count=%d err=%v took=%v\n", filter.ProductID, filter.Actor, filter.Operation, len(entries), err, time.Since(start))
+	}(time.Now())
+
+	return s.next.ListAuditEntries(ctx, filter)
+}
+
+func (s *LoggingService) GetProductHistory(ctx context.Context, productID int) (entries []*types.AuditEntry, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("GetProductHistory product_id=%d count=%d err=%v took=%v\n", productID, len(entries), err, time.Since(start))
+	}(time.Now())
+
+	return s.next.GetProductHistory(ctx, productID)
+}
+
+func (s *LoggingService) VerifyAuditLog(ctx context.Context) (result *types.AuditVerification, err error) {
+	defer func(start time.Time) {
+		valid := false
+		if result != nil {
+			valid = result.Valid
+		}
+		fmt.Printf("VerifyAuditLog valid=%t err=%v took=%v\n", valid, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.VerifyAuditLog(ctx)
+}
+
+func (s *LoggingService) ListOutbox(ctx context.Context, status string, limit int) (entries []*types.OutboxEntry, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("ListOutbox status=%s limit=%d count=%d err=%v took=%v\n", status, limit, len(entries), err, time.Since(start))
+	}(time.Now())
+
+	return s.next.ListOutbox(ctx, status, limit)
+}
+
+func (s *LoggingService) RetryOutboxEvent(ctx context.Context, id int) (err error) {
+	defer func(start time.Time) {
+		fmt.Printf("RetryOutboxEvent id=%d err=%v took=%v\n", id, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.RetryOutboxEvent(ctx, id)
+}
+
+func (s *LoggingService) ListWebhooks(ctx context.Context) (webhooks []*types.WebhookSubscription, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("ListWebhooks count=%d err=%v took=%v\n", len(webhooks), err, time.Since(start))
+	}(time.Now())
+
+	return s.next.ListWebhooks(ctx)
+}
+
+func (s *LoggingService) GetWebhook(ctx context.Context, id int) (webhook *types.WebhookSubscription, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("GetWebhook id=%d err=%v took=%v\n", id, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.GetWebhook(ctx, id)
+}
+
+func (s *LoggingService) CreateWebhook(ctx context.Context, req *types.CreateWebhookRequest) (webhook *types.WebhookSubscription, err error) {
+	defer func(start time.Time) {
+		id := 0
+		if webhook != nil {
+			id = webhook.ID
+		}
+		fmt.Printf("CreateWebhook id=%d url=%s events=%v err=%v took=%v\n", id, req.URL, req.Events, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.CreateWebhook(ctx, req)
+}
+
+func (s *LoggingService) UpdateWebhook(ctx context.Context, id int, req *types.UpdateWebhookRequest) (webhook *types.WebhookSubscription, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("UpdateWebhook id=%d url=%s events=%v active=%t err=%v took=%v\n", id, req.URL, req.Events, req.Active, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.UpdateWebhook(ctx, id, req)
+}
+
+func (s *LoggingService) DeleteWebhook(ctx context.Context, id int) (err error) {
+	defer func(start time.Time) {
+		fmt.Printf("DeleteWebhook id=%d err=%v took=%v\n", id, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.DeleteWebhook(ctx, id)
+}
+
+func (s *LoggingService) ListWebhookDeliveries(ctx context.Context, id, limit int) (deliveries []*types.WebhookDelivery, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("ListWebhookDeliveries id=%d limit=%d count=%d err=%v took=%v\n", id, limit, len(deliveries), err, time.Since(start))
+	}(time.Now())
+
+	return s.next.ListWebhookDeliveries(ctx, id, limit)
+}
+
+func (s *LoggingService) RedeliverWebhook(ctx context.Context, id, deliveryID int) (delivery *types.WebhookDelivery, err error) {
+	defer func(start time.Time) {
+		fmt.Printf("RedeliverWebhook id=%d delivery_id=%d err=%v took=%v\n", id, deliveryID, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.RedeliverWebhook(ctx, id, deliveryID)
+}
+
+func (s *LoggingService) SubscribeProductEvents(ctx context.Context, filter types.ProductEventFilter, lastEventID string) (stream *types.EventStream, err error) {
+	defer func(start time.Time) {
+		backlog, reset := 0, false
+		if stream != nil {
+			backlog, reset = len(stream.Backlog), stream.Reset
+		}
+		fmt.Printf("SubscribeProductEvents product_ids=%v types=%v last_event_id=%s backlog=%d reset=%t err=%v took=%v\n", filter.ProductIDs, filter.Types, lastEventID, backlog, reset, err, time.Since(start))
+	}(time.Now())
+
+	return s.next.SubscribeProductEvents(ctx, filter, lastEventID)
+}
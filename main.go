@@ -1,43 +1,489 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"expvar"
+	"flag"
+	"fmt"
 	"go-circleci/api"
+	"go-circleci/grpcapi"
 	"go-circleci/logger"
+	"go-circleci/migrations"
 	"go-circleci/repository"
 	"go-circleci/services"
+	"go-circleci/types"
 	"log"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func main() {
-	// Initialize SQLite database connection
-	db, err := services.InitDatabase("file:./app.db")
+	storage := flag.String("storage", "sqlite", "product storage: sqlite, or memory for an ephemeral demo")
+	seed := flag.String("seed", "", "JSON file with an array of products to create at startup with --storage=memory")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [backup | restore <file>]\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Without a command the API server is started. backup writes a backup of the\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "database to BACKUP_DIR; restore replaces the database with a backup and\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "must be run while the server is stopped.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *storage != "sqlite" && *storage != "memory" {
+		log.Fatalf("Invalid storage %q: must be sqlite or memory", *storage)
+	}
+
+	// Initialize the SQLite database connection, to the DSN in DATABASE_URL if it is set
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		dsn = "file:./app.db?_time_format=sqlite"
+	}
+	if *storage == "memory" {
+		// Features other than the product catalogue still need tables, which go in a
+		// throwaway database. Orders, carts, reservations and stock levels look
+		// products up in SQL, so they cannot see the in-memory products and their
+		// routes are disabled below.
+		dir, err := os.MkdirTemp("", "go-circleci-demo")
+		if err != nil {
+			log.Fatalf("Failed to create demo database: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		dsn = "file:" + filepath.Join(dir, "demo.db") + "?_time_format=sqlite"
+	}
+	sqliteDB, err := services.OpenSQLite(dsn, sqliteOptions())
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
-	defer db.Close()
+	defer sqliteDB.Close()
+	db := sqliteDB.Write
+
+	settings, err := sqliteDB.Settings(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	log.Printf("SQLite settings: %s", settings)
+
+	// Create backup service instance, which also serves the backup and restore commands
+	backupService, err := services.NewBackupService(sqliteDB, backupOptions())
+	if err != nil {
+		log.Fatalf("Failed to configure backups: %v", err)
+	}
+	if flag.NArg() > 0 {
+		if *storage == "memory" {
+			log.Fatalf("The %s command requires --storage=sqlite", flag.Arg(0))
+		}
+		if err := runCommand(backupService, flag.Args()); err != nil {
+			log.Fatalf("%s failed: %v", flag.Arg(0), err)
+		}
+		return
+	}
+	if interval := os.Getenv("BACKUP_INTERVAL"); interval != "" && *storage == "sqlite" {
+		every, err := time.ParseDuration(interval)
+		if err != nil || every <= 0 {
+			log.Fatalf("Invalid BACKUP_INTERVAL %q: must be a positive duration", interval)
+		}
+		go backupService.RunScheduler(context.Background(), every)
+	}
 
 	// Create products table on startup
 	// if err := services.CreateProductsTable(db); err != nil {
 	// 	log.Fatalf("Failed to create products table: %v", err)
 	// }
 
+	// Create the transaction manager shared by services that span several repository calls
+	var txManager repository.TxManager = repository.NewSQLTxManager(db)
+
 	// Create product repository instance
-	productRepo := repository.NewSQLiteProductRepository(db)
+	sqliteProductRepo := repository.NewSQLiteProductRepository(db)
+	sqliteProductRepo.SetReadDB(sqliteDB.Read)
+	var productRepo repository.ProductRepository = sqliteProductRepo
+	var cachedProductRepo *repository.CachedProductRepository
+	if *storage == "sqlite" {
+		// Serve product reads from a cache, which orders and reservations
+		// invalidate as they move stock
+		cachedProductRepo = repository.NewCachedProductRepository(productRepo, repository.NewLRUProductCache(10000, productCacheTTL()))
+		expvar.Publish("product_cache", expvar.Func(func() any { return cachedProductRepo.Stats() }))
+		productRepo = cachedProductRepo
+	}
+	if *storage == "memory" {
+		if err := migrations.Up(db, migrations.SQLite, "."); err != nil {
+			log.Fatalf("Failed to create demo database: %v", err)
+		}
+		memoryProductRepo := repository.NewMemoryProductRepository()
+		productRepo = memoryProductRepo
+		txManager = repository.NewMemoryTxManager(memoryProductRepo)
+		log.Printf("Storing products in memory; they are lost on exit")
+	}
 
 	// Create product service instance
-	productService := services.NewProductService(productRepo)
+	productService := services.NewProductService(productRepo, txManager)
+
+	// Record every product change in the hash-chained audit log
+	auditService := services.NewAuditService(repository.NewSQLiteAuditRepository(db))
+	productService.SetAudit(auditService)
+
+	// Call partners back on product events through their webhook subscriptions
+	webhookService := services.NewWebhookService(repository.NewSQLiteWebhookRepository(db), services.DefaultWebhookOptions())
+	go webhookService.RunDispatcher(context.Background(), time.Second)
+
+	// Stream product events to live clients, keeping the latest for clients resuming after a reconnect
+	eventBroker := services.NewEventBroker(1000)
+
+	// Publish product events through the transactional outbox, relaying them in the background
+	outboxService := services.NewOutboxService(repository.NewSQLiteOutboxRepository(db), services.DefaultOutboxOptions(), eventPublishers()...)
+	outboxService.AddPublisher(webhookService)
+	outboxService.AddPublisher(eventBroker)
+	productService.SetOutbox(outboxService)
+	go outboxService.RunRelay(context.Background(), time.Second)
+	if *seed != "" {
+		if *storage != "memory" {
+			log.Fatalf("Failed to seed products: --seed requires --storage=memory")
+		}
+		if err := seedProducts(productService, *seed); err != nil {
+			log.Fatalf("Failed to seed products: %v", err)
+		}
+	}
+
+	// Permanently delete products that have been in the trash for longer than the retention
+	if retention := productTrashRetention(); retention > 0 {
+		go productService.RunPurger(context.Background(), time.Hour, retention)
+	}
+
+	// Create reservation service instance and expire stale reservations in the background
+	reservationRepo := repository.NewSQLiteReservationRepository(db)
+	reservationService := services.NewReservationService(reservationRepo)
+	go reservationService.RunSweeper(context.Background(), time.Minute)
+
+	// Create inventory service instance for per-location stock
+	inventoryRepo := repository.NewSQLiteInventoryRepository(db)
+	inventoryService := services.NewInventoryService(inventoryRepo)
+
+	// Create low stock service instance, checking after every product change and periodically
+	lowStockRepo := repository.NewSQLiteLowStockRepository(db)
+	lowStockService := services.NewLowStockService(lowStockRepo, productRepo, alertSinks()...)
+	productService.AddStockObserver(lowStockService)
+	go lowStockService.Run(context.Background(), 5*time.Minute)
+
+	// Create order service instance, deducting stock through the product
+	// repository; orders write the SQLite products table whatever the storage
+	var orderProductRepo repository.ProductRepository = sqliteProductRepo
+	if cachedProductRepo != nil {
+		orderProductRepo = cachedProductRepo
+	}
+	orderRepo := repository.NewSQLiteOrderRepository(db, orderProductRepo)
+	orderRepo.SetReadDB(sqliteDB.Read)
+	orderService := services.NewOrderService(orderRepo, productRepo)
+
+	// Record the stock moved by orders and reservations as StockChanged events;
+	// these write the SQLite products table whatever the storage
+	stockEvents := services.NewStockEvents(outboxService, sqliteProductRepo, repository.NewSQLTxManager(db))
+	stockEvents.SetCache(cachedProductRepo)
+	orderService.SetStockEvents(stockEvents)
+	reservationService.SetStockEvents(stockEvents)
+
+	// Create cart service instance, placing orders at checkout, and expire abandoned carts in the background
+	cartRepo := repository.NewSQLiteCartRepository(db, orderRepo)
+	cartService := services.NewCartService(cartRepo, productRepo, orderService)
+	go cartService.RunSweeper(context.Background(), 10*time.Minute)
+
+	// Create promotion service instance, pricing products, orders and carts at their effective price
+	promotionRepo := repository.NewSQLitePromotionRepository(db)
+	promotionService := services.NewPromotionService(promotionRepo, productRepo)
+	productService.SetPromotions(promotionService)
+	orderService.SetPromotions(promotionService)
+	cartService.SetPromotions(promotionService)
+
+	// Create tax service instance, adding tax to products and orders priced for a region
+	taxRepo := repository.NewSQLiteTaxRuleRepository(db)
+	taxService := services.NewTaxService(taxRepo)
+	productService.SetTaxes(taxService)
+	orderService.SetTaxes(taxService)
 
 	// Create cat fact service instance
 	catFactService := services.NewCatFactService("https://catfact.ninja/fact")
 
-	// Create composite service that supports both CatFact and Product operations
-	compositeService := services.NewCompositeService(catFactService.(*services.CatFactService), productService)
+	// Create composite service delegating to every service
+	compositeService := services.NewCompositeService(services.CompositeOptions{
+		CatFact:     catFactService,
+		Product:     productService,
+		Reservation: reservationService,
+		Inventory:   inventoryService,
+		LowStock:    lowStockService,
+		Order:       orderService,
+		Cart:        cartService,
+		Promotion:   promotionService,
+		Tax:         taxService,
+		Backup:      backupService,
+		Audit:       auditService,
+		Outbox:      outboxService,
+		Webhook:     webhookService,
+		EventBroker: eventBroker,
+	})
 
 	// Wrap with logging
 	service := logger.NewLoggingService(compositeService)
 
 	// Pass composite service to API server
 	apiServer := api.NewApiServer(service)
+	apiServer.SetAdminToken(os.Getenv("ADMIN_TOKEN"))
+	apiServer.SetScannerTokens(namedTokens("SCANNER_TOKENS"))
+	apiServer.SetTrustedProxies(trustedProxies())
+	if *storage == "memory" {
+		apiServer.SetStockDisabled("orders, carts, reservations and stock levels are not available with --storage=memory; use --storage=sqlite")
+	}
+
+	// Serve the product catalog over gRPC too, if GRPC_ADDR is set
+	if addr := os.Getenv("GRPC_ADDR"); addr != "" {
+		grpcServer := grpcapi.NewServer(service)
+		grpcServer.SetAdminToken(os.Getenv("ADMIN_TOKEN"))
+		grpcServer.SetClientTokens(namedTokens("GRPC_TOKENS"))
+		go func() {
+			log.Fatal(grpcServer.Start(addr))
+		}()
+	}
 
 	log.Fatal(apiServer.Start(":5000"))
 }
+
+// alertSinks builds the low stock alert sinks from the environment. Alerts are
+// always logged; ALERT_WEBHOOK_URL adds a webhook and ALERT_SMTP_ADDR together
+// with ALERT_EMAIL_TO adds email delivery.
+func alertSinks() []services.AlertSink {
+	sinks := []services.AlertSink{services.LogAlertSink{}}
+
+	if url := os.Getenv("ALERT_WEBHOOK_URL"); url != "" {
+		sinks = append(sinks, services.NewWebhookAlertSink(url))
+	}
+
+	if addr, to := os.Getenv("ALERT_SMTP_ADDR"), os.Getenv("ALERT_EMAIL_TO"); addr != "" && to != "" {
+		from := os.Getenv("ALERT_EMAIL_FROM")
+		if from == "" {
+			from = "inventory@localhost"
+		}
+		sinks = append(sinks, services.NewEmailAlertSink(addr, from, strings.Split(to, ",")))
+	}
+
+	return sinks
+}
+
+// eventPublishers builds the outbox publishers from the environment. Events
+// are always logged, and EVENT_WEBHOOK_URL adds a webhook. No NATS or Kafka
+// client is linked in yet, so EVENT_NATS_PREFIX and EVENT_KAFKA_TOPIC add
+// publishers on in-process stand-ins, whose events never leave the server.
+func eventPublishers() []services.Publisher {
+	publishers := []services.Publisher{services.LogPublisher{}}
+
+	if url := os.Getenv("EVENT_WEBHOOK_URL"); url != "" {
+		publishers = append(publishers, services.NewWebhookPublisher(url))
+	}
+	if prefix := os.Getenv("EVENT_NATS_PREFIX"); prefix != "" {
+		log.Printf("Publishing events to an in-process NATS stand-in under %s.>; they do not reach a NATS server", prefix)
+		publishers = append(publishers, services.NewNATSPublisher(services.NewInProcessNATS(), prefix))
+	}
+	if topic := os.Getenv("EVENT_KAFKA_TOPIC"); topic != "" {
+		log.Printf("Publishing events to an in-process Kafka stand-in on topic %s; they do not reach a Kafka cluster", topic)
+		publishers = append(publishers, services.NewKafkaPublisher(services.NewInProcessKafka(1000), topic))
+	}
+
+	return publishers
+}
+
+// namedTokens reads bearer tokens mapped to the names of their holders from an
+// environment variable holding a comma-separated list of name:token pairs, such
+// as SCANNER_TOKENS="dock-1:s3cret,dock-2:t0ken" for the warehouse scanners or
+// GRPC_TOKENS="billing:s3cret" for the services calling the gRPC API
+func namedTokens(env string) map[string]string {
+	tokens := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(env), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, token, ok := strings.Cut(pair, ":")
+		if !ok || name == "" || token == "" {
+			log.Fatalf("Invalid %s entry %q: must be name:token", env, pair)
+		}
+		tokens[token] = name
+	}
+	return tokens
+}
+
+// trustedProxies returns the networks in TRUSTED_PROXIES, a comma-separated
+// list of addresses or CIDR prefixes such as "10.0.0.5,192.168.0.0/24", of the
+// authenticating proxies whose X-Actor header is trusted
+func trustedProxies() []netip.Prefix {
+	var networks []netip.Prefix
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			networks = append(networks, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		network, err := netip.ParsePrefix(entry)
+		if err != nil {
+			log.Fatalf("Invalid TRUSTED_PROXIES entry %q: must be an address or a CIDR prefix", entry)
+		}
+		networks = append(networks, network.Masked())
+	}
+	return networks
+}
+
+// sqliteOptions returns the SQLite pragmas and pool limits, starting from the
+// defaults and overridden by SQLITE_JOURNAL_MODE, SQLITE_SYNCHRONOUS,
+// SQLITE_BUSY_TIMEOUT (e.g. "5s"), SQLITE_FOREIGN_KEYS, SQLITE_CACHE_SIZE
+// and SQLITE_MAX_READ_CONNS
+func sqliteOptions() services.SQLiteOptions {
+	opts := services.DefaultSQLiteOptions()
+
+	if value := os.Getenv("SQLITE_JOURNAL_MODE"); value != "" {
+		opts.JournalMode = value
+	}
+	if value := os.Getenv("SQLITE_SYNCHRONOUS"); value != "" {
+		opts.Synchronous = value
+	}
+	if value := os.Getenv("SQLITE_BUSY_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid SQLITE_BUSY_TIMEOUT %q: %v", value, err)
+		}
+		opts.BusyTimeout = timeout
+	}
+	if value := os.Getenv("SQLITE_FOREIGN_KEYS"); value != "" {
+		foreignKeys, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Fatalf("Invalid SQLITE_FOREIGN_KEYS %q: %v", value, err)
+		}
+		opts.ForeignKeys = foreignKeys
+	}
+	if value := os.Getenv("SQLITE_CACHE_SIZE"); value != "" {
+		cacheSize, err := strconv.Atoi(value)
+		if err != nil {
+			log.Fatalf("Invalid SQLITE_CACHE_SIZE %q: %v", value, err)
+		}
+		opts.CacheSize = cacheSize
+	}
+	if value := os.Getenv("SQLITE_MAX_READ_CONNS"); value != "" {
+		conns, err := strconv.Atoi(value)
+		if err != nil || conns < 1 {
+			log.Fatalf("Invalid SQLITE_MAX_READ_CONNS %q: must be a positive integer", value)
+		}
+		opts.MaxReadConns = conns
+	}
+
+	return opts
+}
+
+// backupOptions returns where and how backups are stored: in BACKUP_DIR, or
+// ./backups, keeping the newest BACKUP_RETAIN backups, or 7. BACKUP_COMPRESS
+// gzips them and BACKUP_KEY, 32 base64-encoded bytes, encrypts them.
+func backupOptions() services.BackupOptions {
+	opts := services.BackupOptions{Dir: os.Getenv("BACKUP_DIR"), Retain: 7}
+	if opts.Dir == "" {
+		opts.Dir = "./backups"
+	}
+
+	if value := os.Getenv("BACKUP_RETAIN"); value != "" {
+		retain, err := strconv.Atoi(value)
+		if err != nil || retain < 0 {
+			log.Fatalf("Invalid BACKUP_RETAIN %q: must be a non-negative integer", value)
+		}
+		opts.Retain = retain
+	}
+	if value := os.Getenv("BACKUP_COMPRESS"); value != "" {
+		compress, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Fatalf("Invalid BACKUP_COMPRESS %q: %v", value, err)
+		}
+		opts.Compress = compress
+	}
+	if value := os.Getenv("BACKUP_KEY"); value != "" {
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(key) != 32 {
+			log.Fatalf("Invalid BACKUP_KEY: must be 32 base64-encoded bytes")
+		}
+		opts.Key = key
+	}
+
+	return opts
+}
+
+// runCommand runs the backup or restore command given on the command line
+func runCommand(backupService *services.BackupService, args []string) error {
+	ctx := context.Background()
+
+	switch {
+	case args[0] == "backup" && len(args) == 1:
+		backup, err := backupService.CreateBackup(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("Wrote backup %s (%d bytes)", backup.Name, backup.Size)
+		return nil
+	case args[0] == "restore" && len(args) == 2:
+		if err := backupService.RestoreBackup(ctx, args[1]); err != nil {
+			return err
+		}
+		log.Printf("Restored the database from %s", args[1])
+		return nil
+	default:
+		flag.Usage()
+		return fmt.Errorf("invalid command %q", strings.Join(args, " "))
+	}
+}
+
+// productTrashRetention returns how long deleted products stay in the trash
+// before they are purged, from PRODUCT_TRASH_RETENTION if it is set, e.g.
+// "168h", or 30 days otherwise. Zero keeps them until they are purged by hand.
+func productTrashRetention() time.Duration {
+	if value := os.Getenv("PRODUCT_TRASH_RETENTION"); value != "" {
+		retention, err := time.ParseDuration(value)
+		if err != nil || retention < 0 {
+			log.Fatalf("Invalid PRODUCT_TRASH_RETENTION %q: must be a non-negative duration", value)
+		}
+		return retention
+	}
+	return 30 * 24 * time.Hour
+}
+
+// productCacheTTL returns how long product reads are cached, from
+// PRODUCT_CACHE_TTL if it is set, e.g. "30s", or 10 seconds otherwise
+func productCacheTTL() time.Duration {
+	if value := os.Getenv("PRODUCT_CACHE_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid PRODUCT_CACHE_TTL %q: %v", value, err)
+		}
+		return ttl
+	}
+	return 10 * time.Second
+}
+
+// seedProducts creates the products in a JSON file, which holds an array of
+// product create requests, validating each like the API does
+func seedProducts(productService *services.ProductService, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var reqs []*types.CreateProductRequest
+	if err := json.Unmarshal(data, &reqs); err != nil {
+		return fmt.Errorf("invalid seed file: %w", err)
+	}
+
+	for i, req := range reqs {
+		if _, err := productService.CreateProduct(context.Background(), req); err != nil {
+			return fmt.Errorf("product %d: %w", i, err)
+		}
+	}
+
+	log.Printf("Seeded %d products from %s", len(reqs), path)
+	return nil
+}
@@ -0,0 +1,57 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS locations (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  code TEXT NOT NULL UNIQUE,
+  name TEXT NOT NULL,
+  kind TEXT NOT NULL DEFAULT 'warehouse'
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+INSERT INTO locations (id, code, name, kind) VALUES (1, 'MAIN', 'Main warehouse', 'warehouse');
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS stock_levels (
+  location_id INTEGER NOT NULL REFERENCES locations(id),
+  product_id INTEGER NOT NULL REFERENCES products(id),
+  quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+  PRIMARY KEY (location_id, product_id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS stock_movements (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  product_id INTEGER NOT NULL REFERENCES products(id),
+  location_id INTEGER NOT NULL REFERENCES locations(id),
+  quantity INTEGER NOT NULL,
+  kind TEXT NOT NULL,
+  reference TEXT NOT NULL DEFAULT '',
+  transfer_id INTEGER,
+  created_at DATETIME NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_stock_movements_product ON stock_movements (product_id, created_at);
+-- +goose StatementEnd
+
+-- Existing on-hand stock starts out in the main warehouse
+-- +goose StatementBegin
+INSERT INTO stock_levels (location_id, product_id, quantity) SELECT 1, id, stock FROM products;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS stock_movements;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS stock_levels;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS locations;
+-- +goose StatementEnd
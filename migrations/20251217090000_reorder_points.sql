@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE products ADD COLUMN reorder_point INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE products ADD COLUMN reorder_qty INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- One row per product currently below its reorder point, so an alert fires once per crossing
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS low_stock_alerts (
+  product_id INTEGER PRIMARY KEY REFERENCES products(id),
+  available INTEGER NOT NULL,
+  reorder_point INTEGER NOT NULL,
+  alerted_at DATETIME NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS low_stock_alerts;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE products DROP COLUMN reorder_qty;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE products DROP COLUMN reorder_point;
+-- +goose StatementEnd
@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE products ADD COLUMN category TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE orders ADD COLUMN coupon TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- An empty category, customer or coupon code means the promotion is not limited by it
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS promotions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL,
+  kind TEXT NOT NULL,
+  value REAL NOT NULL,
+  product_id INTEGER REFERENCES products(id),
+  category TEXT NOT NULL DEFAULT '',
+  customer TEXT NOT NULL DEFAULT '',
+  coupon_code TEXT NOT NULL DEFAULT '',
+  max_uses INTEGER NOT NULL DEFAULT 0,
+  uses INTEGER NOT NULL DEFAULT 0,
+  starts_at DATETIME,
+  ends_at DATETIME,
+  created_at DATETIME NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS idx_promotions_coupon_code ON promotions (coupon_code) WHERE coupon_code != '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS promotions;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE orders DROP COLUMN coupon;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE products DROP COLUMN category;
+-- +goose StatementEnd
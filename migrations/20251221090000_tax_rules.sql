@@ -0,0 +1,57 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE products ADD COLUMN tax_class TEXT NOT NULL DEFAULT 'standard';
+-- +goose StatementEnd
+
+-- Rates are percentages, e.g. 20 for 20% VAT
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tax_rules (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  region TEXT NOT NULL,
+  tax_class TEXT NOT NULL,
+  rate REAL NOT NULL,
+  inclusive INTEGER NOT NULL DEFAULT 0,
+  UNIQUE (region, tax_class)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE orders ADD COLUMN region TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE orders ADD COLUMN tax REAL NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE order_lines ADD COLUMN tax_rate REAL NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE order_lines ADD COLUMN tax REAL NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE order_lines DROP COLUMN tax;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE order_lines DROP COLUMN tax_rate;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE orders DROP COLUMN tax;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE orders DROP COLUMN region;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS tax_rules;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE products DROP COLUMN tax_class;
+-- +goose StatementEnd
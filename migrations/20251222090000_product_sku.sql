@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE products ADD COLUMN sku TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- Products created before SKUs existed keep an empty SKU, which is not unique
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_sku ON products (sku) WHERE sku != '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_products_sku;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE products DROP COLUMN sku;
+-- +goose StatementEnd
@@ -0,0 +1,46 @@
+-- +goose Up
+-- Deleted products stay in the table, marked with the time they were deleted,
+-- until they are restored or purged
+-- +goose StatementBegin
+ALTER TABLE products ADD COLUMN deleted_at DATETIME;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_products_deleted_at ON products (deleted_at) WHERE deleted_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- Only products outside the trash hold their SKU, so it can be reused once a
+-- product is deleted
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_products_sku;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_sku ON products (sku) WHERE sku != '' AND deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- Deleted products are purged so the SKU can be unique across all products again
+-- +goose StatementBegin
+DELETE FROM stock_levels WHERE product_id IN (SELECT id FROM products WHERE deleted_at IS NOT NULL);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DELETE FROM products WHERE deleted_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_products_sku;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_sku ON products (sku) WHERE sku != '';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_products_deleted_at;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE products DROP COLUMN deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- Entries are chained: hash covers the entry and the hash of the one before it,
+-- so editing or removing an entry breaks every hash after it
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS audit_log (
+  id INTEGER PRIMARY KEY,
+  created_at DATETIME NOT NULL,
+  actor TEXT NOT NULL,
+  request_id TEXT NOT NULL DEFAULT '',
+  operation TEXT NOT NULL,
+  product_id INTEGER NOT NULL,
+  before TEXT,
+  after TEXT,
+  diff TEXT NOT NULL,
+  prev_hash TEXT NOT NULL,
+  hash TEXT NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_audit_log_product ON audit_log (product_id, id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_log;
+-- +goose StatementEnd
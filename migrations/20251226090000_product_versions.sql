@@ -0,0 +1,37 @@
+-- +goose Up
+-- Every change to a product closes its open version and starts a new one, so
+-- valid_from <= t < valid_to selects the version current at time t. Stock is
+-- not versioned: the stock movement ledger records every change to it.
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS product_versions (
+  product_id INTEGER NOT NULL,
+  version INTEGER NOT NULL,
+  sku TEXT NOT NULL DEFAULT '',
+  name TEXT NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  category TEXT NOT NULL DEFAULT '',
+  tax_class TEXT NOT NULL DEFAULT 'standard',
+  price REAL NOT NULL,
+  reorder_point INTEGER NOT NULL DEFAULT 0,
+  reorder_qty INTEGER NOT NULL DEFAULT 0,
+  valid_from DATETIME NOT NULL,
+  valid_to DATETIME,
+  PRIMARY KEY (product_id, version)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_product_versions_valid ON product_versions (valid_from, valid_to);
+-- +goose StatementEnd
+
+-- History starts now for existing products outside the trash
+-- +goose StatementBegin
+INSERT INTO product_versions (product_id, version, sku, name, description, category, tax_class, price, reorder_point, reorder_qty, valid_from)
+SELECT id, 1, sku, name, description, category, tax_class, price, reorder_point, reorder_qty, strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+FROM products WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS product_versions;
+-- +goose StatementEnd
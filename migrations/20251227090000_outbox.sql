@@ -0,0 +1,40 @@
+-- +goose Up
+-- Domain events are written here in the same transaction as the change they
+-- describe, and delivered afterwards by the relay
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS outbox (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  type TEXT NOT NULL,
+  product_id INTEGER NOT NULL,
+  payload TEXT NOT NULL,
+  occurred_at DATETIME NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  next_attempt_at DATETIME NOT NULL,
+  delivered_at DATETIME
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_outbox_due ON outbox (status, next_attempt_at);
+-- +goose StatementEnd
+
+-- Each publisher an event reached, so a retry only goes to the ones it did not
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS outbox_deliveries (
+  event_id INTEGER NOT NULL REFERENCES outbox(id),
+  publisher TEXT NOT NULL,
+  delivered_at DATETIME NOT NULL,
+  PRIMARY KEY (event_id, publisher)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS outbox_deliveries;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS outbox;
+-- +goose StatementEnd
@@ -0,0 +1,53 @@
+-- +goose Up
+-- Partner callbacks: events is a comma-separated filter of event types, empty
+-- for every event. A subscription is disabled after repeated failures.
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  url TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  events TEXT NOT NULL DEFAULT '',
+  active INTEGER NOT NULL DEFAULT 1,
+  consecutive_failures INTEGER NOT NULL DEFAULT 0,
+  disabled_at DATETIME,
+  created_at DATETIME NOT NULL
+);
+-- +goose StatementEnd
+
+-- One row per event sent to a subscription, and per redelivery of it. payload
+-- is the exact body that is signed and sent.
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  subscription_id INTEGER NOT NULL REFERENCES webhook_subscriptions(id),
+  event_id INTEGER NOT NULL,
+  event_type TEXT NOT NULL,
+  payload TEXT NOT NULL,
+  redelivery_of INTEGER REFERENCES webhook_deliveries(id),
+  status TEXT NOT NULL DEFAULT 'pending',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  response_code INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  next_attempt_at DATETIME NOT NULL,
+  created_at DATETIME NOT NULL,
+  delivered_at DATETIME
+);
+-- +goose StatementEnd
+
+-- An event relayed again from the outbox is only enqueued once per subscription
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_event ON webhook_deliveries (subscription_id, event_id) WHERE redelivery_of IS NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS webhook_deliveries;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS webhook_subscriptions;
+-- +goose StatementEnd
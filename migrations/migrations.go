@@ -0,0 +1,44 @@
+// Package migrations embeds the goose SQL migrations, so the server and tests
+// can create a schema without the goose CLI
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// SQLite holds the SQLite migrations
+//
+//go:embed *.sql
+var SQLite embed.FS
+
+// Up applies the up section of every migration in dir of fsys, in file name
+// order. It does not track applied versions, so it is meant for new databases.
+func Up(db *sql.DB, fsys fs.FS, dir string) error {
+	files, err := fs.Glob(fsys, dir+"/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+
+		up, _, _ := strings.Cut(string(content), "-- +goose Down")
+		for _, block := range strings.Split(up, "-- +goose StatementBegin")[1:] {
+			statement, _, _ := strings.Cut(block, "-- +goose StatementEnd")
+			if _, err := db.Exec(statement); err != nil {
+				return fmt.Errorf("failed to apply migration %s: %w", file, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,5 @@
+// Package catalogv1 holds the protobuf messages and gRPC service of the product
+// catalog, generated from catalog.proto
+package catalogv1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative catalog/v1/catalog.proto
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-circleci/types"
+)
+
+// AuditRepository defines the interface for the append-only audit log
+type AuditRepository interface {
+	Append(ctx context.Context, entry *types.AuditEntry) error
+	List(ctx context.Context, filter types.AuditFilter) ([]*types.AuditEntry, error)
+	Verify(ctx context.Context) (*types.AuditVerification, error)
+}
+
+// SQLiteAuditRepository implements AuditRepository using SQLite. Entries form a
+// hash chain, so changing or removing an entry is detected by Verify; only
+// removing the newest entries is not, as nothing comes after them.
+type SQLiteAuditRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteAuditRepository creates a new SQLite audit repository
+func NewSQLiteAuditRepository(db *sql.DB) *SQLiteAuditRepository {
+	return &SQLiteAuditRepository{db: db}
+}
+
+// auditHash returns the hash of an entry, which covers every field of the
+// entry as stored and the hash of the entry before it
+func auditHash(id int, createdAt time.Time, actor, requestID, operation string, productID int, before, after sql.NullString, diff, prevHash string) string {
+	fields, _ := json.Marshal([]any{
+		id, createdAt.UTC().Format(time.RFC3339Nano), actor, requestID, operation, productID,
+		before.String, before.Valid, after.String, after.Valid, diff, prevHash,
+	})
+	sum := sha256.Sum256(fields)
+	return hex.EncodeToString(sum[:])
+}
+
+// nullJSON stores an absent snapshot as NULL
+func nullJSON(raw json.RawMessage) sql.NullString {
+	return sql.NullString{String: string(raw), Valid: len(raw) > 0}
+}
+
+// Append adds an entry to the end of the log, setting its ID and hashes. It
+// runs in the transaction carried by ctx, so an entry is only kept if the
+// change it records is committed.
+func (r *SQLiteAuditRepository) Append(ctx context.Context, entry *types.AuditEntry) error {
+	diff, err := json.Marshal(entry.Diff)
+	if err != nil {
+		return err
+	}
+	// Timestamps are hashed as they read back from the database
+	entry.CreatedAt = entry.CreatedAt.UTC().Truncate(time.Microsecond)
+
+	return inTx(ctx, r.db, func(ctx context.Context, tx *sql.Tx) error {
+		var lastID int
+		var prevHash string
+		err := tx.QueryRowContext(ctx, `SELECT id, hash FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&lastID, &prevHash)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		id := lastID + 1
+		before, after := nullJSON(entry.Before), nullJSON(entry.After)
+		hash := auditHash(id, entry.CreatedAt, entry.Actor, entry.RequestID, entry.Operation, entry.ProductID, before, after, string(diff), prevHash)
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO audit_log (id, created_at, actor, request_id, operation, product_id, before, after, diff, prev_hash, hash)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, entry.CreatedAt, entry.Actor, entry.RequestID, entry.Operation, entry.ProductID, before, after, string(diff), prevHash, hash,
+		)
+		if err != nil {
+			return err
+		}
+
+		entry.ID = id
+		entry.PrevHash = prevHash
+		entry.Hash = hash
+		return nil
+	})
+}
+
+// auditColumns selects an audit entry row
+const auditColumns = `id, created_at, actor, request_id, operation, product_id, before, after, diff, prev_hash, hash`
+
+// auditRow is an audit entry row as stored
+type auditRow struct {
+	entry  types.AuditEntry
+	before sql.NullString
+	after  sql.NullString
+	diff   string
+}
+
+// scanAuditRow scans a row selected with auditColumns
+func scanAuditRow(row rowScanner) (*auditRow, error) {
+	r := &auditRow{}
+	e := &r.entry
+	err := row.Scan(&e.ID, &e.CreatedAt, &e.Actor, &e.RequestID, &e.Operation, &e.ProductID, &r.before, &r.after, &r.diff, &e.PrevHash, &e.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.before.Valid {
+		e.Before = json.RawMessage(r.before.String)
+	}
+	if r.after.Valid {
+		e.After = json.RawMessage(r.after.String)
+	}
+	if err := json.Unmarshal([]byte(r.diff), &e.Diff); err != nil {
+		return nil, fmt.Errorf("audit entry %d: invalid diff: %w", e.ID, err)
+	}
+	return r, nil
+}
+
+// List retrieves the entries matching filter, newest first, up to filter.Limit
+// entries if it is set
+func (r *SQLiteAuditRepository) List(ctx context.Context, filter types.AuditFilter) ([]*types.AuditEntry, error) {
+	var conditions []string
+	var args []any
+	if filter.ProductID != 0 {
+		conditions = append(conditions, `product_id = ?`)
+		args = append(args, filter.ProductID)
+	}
+	if filter.Actor != "" {
+		conditions = append(conditions, `actor = ?`)
+		args = append(args, filter.Actor)
+	}
+	if filter.Operation != "" {
+		conditions = append(conditions, `operation = ?`)
+		args = append(args, filter.Operation)
+	}
+	if filter.RequestID != "" {
+		conditions = append(conditions, `request_id = ?`)
+		args = append(args, filter.RequestID)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, `created_at >= ?`)
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, `created_at < ?`)
+		args = append(args, filter.To.UTC())
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+	query += ` ORDER BY id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*types.AuditEntry{}
+	for rows.Next() {
+		row, err := scanAuditRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &row.entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// Verify walks the log from the oldest entry and checks that every entry links
+// to the one before it and still matches its hash
+func (r *SQLiteAuditRepository) Verify(ctx context.Context) (*types.AuditVerification, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `SELECT `+auditColumns+` FROM audit_log ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := &types.AuditVerification{Valid: true}
+	prevID, prevHash := 0, ""
+	for rows.Next() {
+		row, err := scanAuditRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		e := &row.entry
+		result.Entries++
+
+		var problem string
+		switch {
+		case e.ID != prevID+1:
+			problem = fmt.Sprintf("entries %d to %d are missing", prevID+1, e.ID-1)
+		case e.PrevHash != prevHash:
+			problem = "entry does not link to the entry before it"
+		case e.Hash != auditHash(e.ID, e.CreatedAt, e.Actor, e.RequestID, e.Operation, e.ProductID, row.before, row.after, row.diff, e.PrevHash):
+			problem = "entry does not match its hash"
+		}
+		if problem != "" {
+			result.Valid = false
+			result.BrokenAt = e.ID
+			result.Error = problem
+			return result, nil
+		}
+
+		prevID, prevHash = e.ID, e.Hash
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,81 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go-circleci/repository"
+	"go-circleci/repository/repotest"
+	"go-circleci/types"
+)
+
+func TestSQLiteAuditRepositoryHashChain(t *testing.T) {
+	ctx := context.Background()
+	db := repotest.OpenSQLite(t)
+	repo := repository.NewSQLiteAuditRepository(db)
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 123456789, time.UTC)
+	for i, op := range []string{types.AuditCreate, types.AuditUpdate, types.AuditDelete} {
+		entry := &types.AuditEntry{
+			CreatedAt: start.Add(time.Duration(i) * time.Minute),
+			Actor:     "alice",
+			RequestID: "req-1",
+			Operation: op,
+			ProductID: 7,
+			After:     json.RawMessage(`{"name":"Widget"}`),
+			Diff:      []types.FieldChange{{Field: "name", From: nil, To: "Widget"}},
+		}
+		if err := repo.Append(ctx, entry); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		if entry.ID != i+1 || entry.Hash == "" || (i > 0) != (entry.PrevHash != "") {
+			t.Fatalf("Append set ID=%d PrevHash=%q Hash=%q", entry.ID, entry.PrevHash, entry.Hash)
+		}
+	}
+	if err := repo.Append(ctx, &types.AuditEntry{CreatedAt: start, Actor: "bob", Operation: types.AuditCreate, ProductID: 8}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	entries, err := repo.List(ctx, types.AuditFilter{ProductID: 7, Limit: 2})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Operation != types.AuditDelete || entries[1].Operation != types.AuditUpdate {
+		t.Fatalf("List = %+v, want the newest two entries of product 7", entries)
+	}
+	if len(entries[0].Diff) != 1 || entries[0].Diff[0].To != "Widget" || string(entries[0].After) != `{"name":"Widget"}` || entries[0].Before != nil {
+		t.Errorf("List entry = %+v, want its snapshots and diff as appended", entries[0])
+	}
+
+	entries, err = repo.List(ctx, types.AuditFilter{Actor: "bob"})
+	if err != nil || len(entries) != 1 || entries[0].ProductID != 8 {
+		t.Fatalf("List by actor = %+v, %v, want the entry by bob", entries, err)
+	}
+	entries, err = repo.List(ctx, types.AuditFilter{From: start.Add(time.Minute).Truncate(time.Second), To: start.Add(2 * time.Minute).Truncate(time.Second)})
+	if err != nil || len(entries) != 1 || entries[0].Operation != types.AuditUpdate {
+		t.Fatalf("List by time = %+v, %v, want the update", entries, err)
+	}
+
+	result, err := repo.Verify(ctx)
+	if err != nil || !result.Valid || result.Entries != 4 {
+		t.Fatalf("Verify = %+v, %v, want 4 valid entries", result, err)
+	}
+
+	if _, err := db.Exec(`UPDATE audit_log SET actor = 'mallory' WHERE id = 2`); err != nil {
+		t.Fatal(err)
+	}
+	result, err = repo.Verify(ctx)
+	if err != nil || result.Valid || result.BrokenAt != 2 {
+		t.Fatalf("Verify after tampering = %+v, %v, want entry 2 reported", result, err)
+	}
+
+	if _, err := db.Exec(`DELETE FROM audit_log WHERE id = 2`); err != nil {
+		t.Fatal(err)
+	}
+	result, err = repo.Verify(ctx)
+	if err != nil || result.Valid || result.BrokenAt != 3 {
+		t.Fatalf("Verify after removal = %+v, %v, want entry 3 reported", result, err)
+	}
+}
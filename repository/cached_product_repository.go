@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"go-circleci/types"
+)
+
+// CacheStats counts the lookups of a CachedProductRepository
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// CachedProductRepository is a read-through cache in front of another
+// ProductRepository. GetByID is served from the cache, and concurrent misses for
+// the same product share a single read. Writes through the repository invalidate
+// the product they change, and Invalidate drops products that other
+// repositories change, such as the stock reservations hold. A hold that expires
+// frees its units without a write, which shows up once the cached entry
+// expires. Other reads are passed through, as are all reads inside a
+// transaction, which may see changes that are not yet committed.
+type CachedProductRepository struct {
+	next  ProductRepository
+	cache ProductCache
+	group *singleflight.Group
+	stats *cacheCounters
+}
+
+// cacheCounters holds the counters behind CacheStats
+type cacheCounters struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// NewCachedProductRepository creates a repository that caches the products of next in cache
+func NewCachedProductRepository(next ProductRepository, cache ProductCache) *CachedProductRepository {
+	return &CachedProductRepository{next: next, cache: cache, group: &singleflight.Group{}, stats: &cacheCounters{}}
+}
+
+// Stats returns the number of cache hits and misses so far
+func (r *CachedProductRepository) Stats() CacheStats {
+	return CacheStats{Hits: r.stats.hits.Load(), Misses: r.stats.misses.Load()}
+}
+
+// inTransaction reports whether ctx carries a transaction of a TxManager
+func inTransaction(ctx context.Context) bool {
+	if ctx.Value(txKey{}) != nil {
+		return true
+	}
+	return ctx.Value(memoryTxKey{}) != nil
+}
+
+// GetAll retrieves all products from the underlying repository
+func (r *CachedProductRepository) GetAll(ctx context.Context) ([]*types.Product, error) {
+	return r.next.GetAll(ctx)
+}
+
+// GetByID retrieves a single product by its ID, from the cache if it is there
+func (r *CachedProductRepository) GetByID(ctx context.Context, id int) (*types.Product, error) {
+	if inTransaction(ctx) {
+		return r.next.GetByID(ctx, id)
+	}
+
+	if product, ok := r.cache.Get(ctx, id); ok {
+		r.stats.hits.Add(1)
+		return product, nil
+	}
+	r.stats.misses.Add(1)
+
+	// The read is shared, so one caller giving up must not fail the others
+	shared, err, _ := r.group.Do(strconv.Itoa(id), func() (any, error) {
+		product, err := r.next.GetByID(context.WithoutCancel(ctx), id)
+		if err != nil {
+			return nil, err
+		}
+		r.cache.Set(ctx, product)
+		return product, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Every caller gets its own copy, which the service layer prices in place
+	return cloneProduct(shared.(*types.Product)), nil
+}
+
+// GetByIDs retrieves the products with the given IDs in ID order, taking those
+// that are cached from the cache and reading the rest in one batch
+func (r *CachedProductRepository) GetByIDs(ctx context.Context, ids []int) ([]*types.Product, error) {
+	if inTransaction(ctx) {
+		return r.next.GetByIDs(ctx, ids)
+	}
+
+	var products []*types.Product
+	var missing []int
+	for _, id := range ids {
+		if product, ok := r.cache.Get(ctx, id); ok {
+			r.stats.hits.Add(1)
+			products = append(products, product)
+			continue
+		}
+		r.stats.misses.Add(1)
+		missing = append(missing, id)
+	}
+
+	if len(missing) > 0 {
+		read, err := r.next.GetByIDs(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, product := range read {
+			r.cache.Set(ctx, product)
+			products = append(products, cloneProduct(product))
+		}
+	}
+
+	slices.SortFunc(products, func(a, b *types.Product) int { return a.ID - b.ID })
+	return slices.CompactFunc(products, func(a, b *types.Product) bool { return a.ID == b.ID }), nil
+}
+
+// GetBySKU retrieves a single product by its SKU from the underlying repository
+func (r *CachedProductRepository) GetBySKU(ctx context.Context, sku string) (*types.Product, error) {
+	return r.next.GetBySKU(ctx, sku)
+}
+
+// ForEach calls fn for every product of the underlying repository
+func (r *CachedProductRepository) ForEach(ctx context.Context, fn func(product *types.Product) error) error {
+	return r.next.ForEach(ctx, fn)
+}
+
+// ListPage retrieves a page of the products matching a filter from the underlying repository
+func (r *CachedProductRepository) ListPage(ctx context.Context, filter types.ProductFilter, after, limit int) (*types.ProductPage, error) {
+	return r.next.ListPage(ctx, filter, after, limit)
+}
+
+// Create inserts a new product. The product is not cached until it is read.
+func (r *CachedProductRepository) Create(ctx context.Context, product *types.Product) error {
+	return r.next.Create(ctx, product)
+}
+
+// Update modifies an existing product and invalidates its cached copy
+func (r *CachedProductRepository) Update(ctx context.Context, product *types.Product) error {
+	defer r.invalidate(ctx, product.ID)()
+	return r.next.Update(ctx, product)
+}
+
+// Delete moves a product to the trash and invalidates its cached copy
+func (r *CachedProductRepository) Delete(ctx context.Context, id int) error {
+	defer r.invalidate(ctx, id)()
+	return r.next.Delete(ctx, id)
+}
+
+// ListDeleted retrieves the products in the trash from the underlying repository
+func (r *CachedProductRepository) ListDeleted(ctx context.Context) ([]*types.Product, error) {
+	return r.next.ListDeleted(ctx)
+}
+
+// Restore takes a product out of the trash and invalidates its cached copy
+func (r *CachedProductRepository) Restore(ctx context.Context, id int) error {
+	defer r.invalidate(ctx, id)()
+	return r.next.Restore(ctx, id)
+}
+
+// Purge permanently removes a product and invalidates its cached copy
+func (r *CachedProductRepository) Purge(ctx context.Context, id int) error {
+	defer r.invalidate(ctx, id)()
+	return r.next.Purge(ctx, id)
+}
+
+// PurgeDeleted permanently removes the products trashed before the given time.
+// Products in the trash are never cached, so nothing needs invalidating.
+func (r *CachedProductRepository) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	return r.next.PurgeDeleted(ctx, before)
+}
+
+// AdjustStock changes a product's stock and invalidates its cached copy
+func (r *CachedProductRepository) AdjustStock(ctx context.Context, id int, delta int, kind string, reference string) error {
+	defer r.invalidate(ctx, id)()
+	return r.next.AdjustStock(ctx, id, delta, kind, reference)
+}
+
+// GetAsOf retrieves a product as it was at the given time from the underlying
+// repository. Past states are not cached.
+func (r *CachedProductRepository) GetAsOf(ctx context.Context, id int, at time.Time) (*types.Product, error) {
+	return r.next.GetAsOf(ctx, id, at)
+}
+
+// GetAllAsOf retrieves the products as they were at the given time from the underlying repository
+func (r *CachedProductRepository) GetAllAsOf(ctx context.Context, at time.Time) ([]*types.Product, error) {
+	return r.next.GetAllAsOf(ctx, at)
+}
+
+// ListVersions retrieves the versions of a product from the underlying repository
+func (r *CachedProductRepository) ListVersions(ctx context.Context, id int) ([]*types.ProductVersion, error) {
+	return r.next.ListVersions(ctx, id)
+}
+
+// Invalidate removes products changed outside this repository from the cache,
+// now and again once the transaction in ctx commits
+func (r *CachedProductRepository) Invalidate(ctx context.Context, ids ...int) {
+	for _, id := range ids {
+		r.invalidate(ctx, id)()
+	}
+}
+
+// invalidate removes a product from the cache before a write and returns a
+// function removing it again once the write is committed, in case a
+// concurrent read cached the old row before the new one became visible
+func (r *CachedProductRepository) invalidate(ctx context.Context, id int) func() {
+	r.cache.Delete(ctx, id)
+	return func() {
+		AfterCommit(ctx, func() { r.cache.Delete(context.WithoutCancel(ctx), id) })
+	}
+}
@@ -0,0 +1,213 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-circleci/repository"
+	"go-circleci/repository/repotest"
+	"go-circleci/types"
+)
+
+// countingRepository counts GetByID calls, holding each one until release is closed
+type countingRepository struct {
+	repository.ProductRepository
+	calls   atomic.Int64
+	release chan struct{}
+}
+
+func (r *countingRepository) GetByID(ctx context.Context, id int) (*types.Product, error) {
+	r.calls.Add(1)
+	if r.release != nil {
+		<-r.release
+	}
+	return r.ProductRepository.GetByID(ctx, id)
+}
+
+// newCachedRepository returns a cached repository over a memory repository holding one product
+func newCachedRepository(t *testing.T, cache repository.ProductCache) (*repository.CachedProductRepository, *countingRepository, *types.Product) {
+	t.Helper()
+	backing := &countingRepository{ProductRepository: repository.NewMemoryProductRepository()}
+	product := &types.Product{Name: "Widget", Price: 5, Stock: 10}
+	if err := backing.Create(context.Background(), product); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return repository.NewCachedProductRepository(backing, cache), backing, product
+}
+
+func TestCachedProductRepositoryReadThrough(t *testing.T) {
+	ctx := context.Background()
+	repo, backing, product := newCachedRepository(t, repository.NewLRUProductCache(10, time.Minute))
+
+	for range 3 {
+		got, err := repo.GetByID(ctx, product.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		// Callers price products in place, which must not reach the cache
+		got.EffectivePrice = 1
+		got.Name = "Changed by caller"
+	}
+
+	if calls := backing.calls.Load(); calls != 1 {
+		t.Errorf("backing repository read %d times, want 1", calls)
+	}
+	if stats := repo.Stats(); stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("Stats = %+v, want 2 hits and 1 miss", stats)
+	}
+
+	got, _ := repo.GetByID(ctx, product.ID)
+	if got.Name != "Widget" || got.EffectivePrice != 5 {
+		t.Errorf("cached product = %+v, want it unchanged by callers", got)
+	}
+
+	if _, err := repo.GetByID(ctx, 999); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetByID of a missing product = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestCachedProductRepositoryInvalidation(t *testing.T) {
+	ctx := context.Background()
+	repo, _, product := newCachedRepository(t, repository.NewLRUProductCache(10, time.Minute))
+
+	read := func() *types.Product {
+		t.Helper()
+		got, err := repo.GetByID(ctx, product.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		return got
+	}
+
+	read()
+	product.Name = "Renamed"
+	if err := repo.Update(ctx, product); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := read(); got.Name != "Renamed" {
+		t.Errorf("Name after Update = %q, want Renamed", got.Name)
+	}
+
+	if err := repo.AdjustStock(ctx, product.ID, -4, types.MovementAdjustment, ""); err != nil {
+		t.Fatalf("AdjustStock: %v", err)
+	}
+	if got := read(); got.Stock != 6 {
+		t.Errorf("Stock after AdjustStock = %d, want 6", got.Stock)
+	}
+
+	if err := repo.Delete(ctx, product.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, product.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetByID after Delete = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestCachedProductRepositoryInvalidatesAfterCommit(t *testing.T) {
+	ctx := context.Background()
+	db := repotest.OpenSQLite(t)
+	repo := repository.NewCachedProductRepository(repository.NewSQLiteProductRepository(db), repository.NewLRUProductCache(10, time.Minute))
+	product := &types.Product{Name: "Widget", Price: 5, Stock: 10}
+	if err := repo.Create(ctx, product); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	err := repository.NewSQLTxManager(db).InTx(ctx, func(txCtx context.Context) error {
+		product.Name = "Renamed"
+		if err := repo.Update(txCtx, product); err != nil {
+			return err
+		}
+		// A read outside the transaction caches the row as it was before
+		got, err := repo.GetByID(ctx, product.ID)
+		if err != nil || got.Name != "Widget" {
+			t.Errorf("GetByID during the transaction = %+v, %v, want the committed row", got, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("InTx: %v", err)
+	}
+
+	got, err := repo.GetByID(ctx, product.ID)
+	if err != nil || got.Name != "Renamed" {
+		t.Errorf("GetByID after commit = %+v, %v, want the renamed product", got, err)
+	}
+}
+
+func TestCachedProductRepositoryCollapsesConcurrentMisses(t *testing.T) {
+	repo, backing, product := newCachedRepository(t, repository.NewLRUProductCache(10, time.Minute))
+	backing.release = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.GetByID(context.Background(), product.ID); err != nil {
+				t.Errorf("GetByID: %v", err)
+			}
+		}()
+	}
+
+	// Let the first read finish only once every goroutine has missed the cache
+	for repo.Stats().Misses < 20 {
+		time.Sleep(time.Millisecond)
+	}
+	close(backing.release)
+	wg.Wait()
+
+	if calls := backing.calls.Load(); calls != 1 {
+		t.Errorf("backing repository read %d times for 20 concurrent misses, want 1", calls)
+	}
+}
+
+func TestLRUProductCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("evicts least recently used", func(t *testing.T) {
+		cache := repository.NewLRUProductCache(2, time.Minute)
+		cache.Set(ctx, &types.Product{ID: 1})
+		cache.Set(ctx, &types.Product{ID: 2})
+		cache.Get(ctx, 1)
+		cache.Set(ctx, &types.Product{ID: 3})
+
+		if _, ok := cache.Get(ctx, 2); ok {
+			t.Error("product 2 is still cached, want it evicted as least recently used")
+		}
+		for _, id := range []int{1, 3} {
+			if _, ok := cache.Get(ctx, id); !ok {
+				t.Errorf("product %d was evicted", id)
+			}
+		}
+		if cache.Len() != 2 {
+			t.Errorf("Len = %d, want 2", cache.Len())
+		}
+	})
+
+	t.Run("expires entries", func(t *testing.T) {
+		cache := repository.NewLRUProductCache(2, 10*time.Millisecond)
+		cache.Set(ctx, &types.Product{ID: 1})
+		if _, ok := cache.Get(ctx, 1); !ok {
+			t.Fatal("product 1 is not cached")
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		if _, ok := cache.Get(ctx, 1); ok {
+			t.Error("product 1 is still cached after its TTL")
+		}
+	})
+
+	t.Run("deletes entries", func(t *testing.T) {
+		cache := repository.NewLRUProductCache(2, time.Minute)
+		cache.Set(ctx, &types.Product{ID: 1})
+		cache.Delete(ctx, 1)
+		if _, ok := cache.Get(ctx, 1); ok {
+			t.Error("product 1 is still cached after Delete")
+		}
+	})
+}
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+
+	"go-circleci/types"
+)
+
+// ErrCartNotOpen is returned when changing or checking out a cart that is checked out or expired
+var ErrCartNotOpen = errors.New("cart is not open")
+
+// CartRepository defines the interface for shopping cart data access operations
+type CartRepository interface {
+	GetByID(ctx context.Context, id int) (*types.Cart, error)
+	Create(ctx context.Context, cart *types.Cart) error
+	SetLine(ctx context.Context, cartID int, line *types.CartLine, expiresAt time.Time, now time.Time) error
+	RemoveLine(ctx context.Context, cartID int, productID int, expiresAt time.Time, now time.Time) error
+	Checkout(ctx context.Context, cartID int, order *types.Order, now time.Time) error
+	ExpireStale(ctx context.Context, now time.Time) (int, error)
+}
+
+// SQLiteCartRepository implements CartRepository using SQLite, stored alongside products
+type SQLiteCartRepository struct {
+	db     *sql.DB
+	orders *SQLiteOrderRepository
+}
+
+// NewSQLiteCartRepository creates a new SQLite cart repository that places
+// orders at checkout through the given order repository
+func NewSQLiteCartRepository(db *sql.DB, orders *SQLiteOrderRepository) *SQLiteCartRepository {
+	return &SQLiteCartRepository{db: db, orders: orders}
+}
+
+// GetByID retrieves a cart with its lines priced at the current product prices
+func (r *SQLiteCartRepository) GetByID(ctx context.Context, id int) (*types.Cart, error) {
+	cart := &types.Cart{}
+	var orderID sql.NullInt64
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		`SELECT id, status, customer, order_id, expires_at, created_at, updated_at FROM carts WHERE id = ?`, id,
+	).Scan(&cart.ID, &cart.Status, &cart.Customer, &orderID, &cart.ExpiresAt, &cart.CreatedAt, &cart.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if orderID.Valid {
+		id := int(orderID.Int64)
+		cart.OrderID = &id
+	}
+
+	// Lines whose product has since been deleted, or moved to the trash, are
+	// kept with a zero price and no availability
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT l.product_id, COALESCE(p.name, ''), COALESCE(p.price, 0), l.added_price, l.quantity,
+			COALESCE((SELECT `+availableStock("?")+` FROM products WHERE products.id = l.product_id AND `+notDeleted+`), 0)
+		FROM cart_lines l LEFT JOIN products p ON p.id = l.product_id AND p.`+notDeleted+`
+		WHERE l.cart_id = ?
+		ORDER BY l.rowid`, time.Now().UTC(), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cart.Lines = []types.CartLine{}
+	for rows.Next() {
+		var line types.CartLine
+		err := rows.Scan(&line.ProductID, &line.ProductName, &line.UnitPrice, &line.AddedPrice, &line.Quantity, &line.Available)
+		if err != nil {
+			return nil, err
+		}
+		line.PriceChanged = line.UnitPrice != line.AddedPrice
+		line.LineTotal = math.Round(line.UnitPrice*float64(line.Quantity)*100) / 100
+		cart.Total += line.LineTotal
+		cart.Lines = append(cart.Lines, line)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	cart.Total = math.Round(cart.Total*100) / 100
+	return cart, nil
+}
+
+// Create inserts a new open cart and sets its generated ID
+func (r *SQLiteCartRepository) Create(ctx context.Context, cart *types.Cart) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		`INSERT INTO carts (status, customer, expires_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		cart.Status, cart.Customer, cart.ExpiresAt, cart.CreatedAt, cart.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	cart.ID = int(id)
+	return nil
+}
+
+// SetLine sets the quantity of a product in an open cart, adding the line if
+// needed, and extends the cart's expiry
+func (r *SQLiteCartRepository) SetLine(ctx context.Context, cartID int, line *types.CartLine, expiresAt time.Time, now time.Time) error {
+	return inTx(ctx, r.db, func(ctx context.Context, tx *sql.Tx) error {
+		if err := touchCart(ctx, tx, cartID, expiresAt, now); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO cart_lines (cart_id, product_id, quantity, added_price) VALUES (?, ?, ?, ?)
+			ON CONFLICT (cart_id, product_id) DO UPDATE SET quantity = excluded.quantity, added_price = excluded.added_price`,
+			cartID, line.ProductID, line.Quantity, line.AddedPrice,
+		)
+		return err
+	})
+}
+
+// RemoveLine removes a product from an open cart and extends the cart's expiry
+func (r *SQLiteCartRepository) RemoveLine(ctx context.Context, cartID int, productID int, expiresAt time.Time, now time.Time) error {
+	return inTx(ctx, r.db, func(ctx context.Context, tx *sql.Tx) error {
+		if err := touchCart(ctx, tx, cartID, expiresAt, now); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, `DELETE FROM cart_lines WHERE cart_id = ? AND product_id = ?`, cartID, productID)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return sql.ErrNoRows
+		}
+
+		return nil
+	})
+}
+
+// Checkout places the order built from a cart and marks the cart checked out in
+// one transaction, so a cart converts to exactly one order or not at all
+func (r *SQLiteCartRepository) Checkout(ctx context.Context, cartID int, order *types.Order, now time.Time) error {
+	return inTx(ctx, r.db, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			`UPDATE carts SET status = ?, updated_at = ? WHERE id = ? AND status = ? AND expires_at > ?`,
+			types.CartCheckedOut, now, cartID, types.CartOpen, now,
+		)
+		if err != nil {
+			return err
+		}
+
+		if err := checkCartUpdated(result); err != nil {
+			return err
+		}
+
+		if err := r.orders.Create(ctx, order); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE carts SET order_id = ? WHERE id = ?`, order.ID, cartID)
+		return err
+	})
+}
+
+// ExpireStale marks every open cart past its expiry as expired and returns the number affected
+func (r *SQLiteCartRepository) ExpireStale(ctx context.Context, now time.Time) (int, error) {
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		`UPDATE carts SET status = ?, updated_at = ? WHERE status = ? AND expires_at <= ?`,
+		types.CartExpired, now, types.CartOpen, now,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rowsAffected), nil
+}
+
+// touchCart extends an open, unexpired cart's expiry, failing with ErrCartNotOpen otherwise
+func touchCart(ctx context.Context, tx *sql.Tx, cartID int, expiresAt time.Time, now time.Time) error {
+	result, err := tx.ExecContext(ctx,
+		`UPDATE carts SET expires_at = ?, updated_at = ? WHERE id = ? AND status = ? AND expires_at > ?`,
+		expiresAt, now, cartID, types.CartOpen, now,
+	)
+	if err != nil {
+		return err
+	}
+
+	return checkCartUpdated(result)
+}
+
+// checkCartUpdated turns a conditional cart update that matched no rows into ErrCartNotOpen
+func checkCartUpdated(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrCartNotOpen
+	}
+
+	return nil
+}
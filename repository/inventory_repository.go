@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"go-circleci/types"
+)
+
+// ErrLocationCodeTaken is returned when creating a location whose code already exists
+var ErrLocationCodeTaken = errors.New("location code already exists")
+
+// InventoryRepository defines the interface for per-location stock data access operations
+type InventoryRepository interface {
+	ListLocations(ctx context.Context) ([]*types.Location, error)
+	GetLocation(ctx context.Context, id int) (*types.Location, error)
+	CreateLocation(ctx context.Context, location *types.Location) error
+	GetProductStock(ctx context.Context, productID int) (*types.ProductStock, error)
+	Transfer(ctx context.Context, transfer *types.StockTransfer) error
+}
+
+// SQLiteInventoryRepository implements InventoryRepository using SQLite
+type SQLiteInventoryRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteInventoryRepository creates a new SQLite inventory repository
+func NewSQLiteInventoryRepository(db *sql.DB) *SQLiteInventoryRepository {
+	return &SQLiteInventoryRepository{db: db}
+}
+
+// ListLocations retrieves all locations ordered by ID
+func (r *SQLiteInventoryRepository) ListLocations(ctx context.Context) ([]*types.Location, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `SELECT id, code, name, kind FROM locations ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locations []*types.Location
+	for rows.Next() {
+		location := &types.Location{}
+		if err := rows.Scan(&location.ID, &location.Code, &location.Name, &location.Kind); err != nil {
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return locations, nil
+}
+
+// GetLocation retrieves a single location by its ID
+func (r *SQLiteInventoryRepository) GetLocation(ctx context.Context, id int) (*types.Location, error) {
+	location := &types.Location{}
+	err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT id, code, name, kind FROM locations WHERE id = ?`, id).Scan(
+		&location.ID,
+		&location.Code,
+		&location.Name,
+		&location.Kind,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return location, nil
+}
+
+// CreateLocation inserts a new location and sets its generated ID
+func (r *SQLiteInventoryRepository) CreateLocation(ctx context.Context, location *types.Location) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		`INSERT INTO locations (code, name, kind) VALUES (?, ?, ?)`,
+		location.Code, location.Name, location.Kind,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrLocationCodeTaken
+		}
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	location.ID = int(id)
+	return nil
+}
+
+// GetProductStock returns the stock of a product at every location that has
+// held it. Products in the trash are reported as missing.
+func (r *SQLiteInventoryRepository) GetProductStock(ctx context.Context, productID int) (*types.ProductStock, error) {
+	stock := &types.ProductStock{ProductID: productID, Locations: []types.LocationStock{}}
+	if err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT stock FROM products WHERE id = ? AND `+notDeleted, productID).Scan(&stock.Total); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT l.id, l.code, l.name, s.quantity
+		FROM stock_levels s JOIN locations l ON l.id = s.location_id
+		WHERE s.product_id = ?
+		ORDER BY l.id`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var level types.LocationStock
+		if err := rows.Scan(&level.LocationID, &level.Code, &level.Name, &level.Quantity); err != nil {
+			return nil, err
+		}
+		stock.Locations = append(stock.Locations, level)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stock, nil
+}
+
+// Transfer moves units between two locations by writing a transfer_out and a
+// transfer_in ledger entry in one transaction. The product's aggregate stock is unchanged.
+func (r *SQLiteInventoryRepository) Transfer(ctx context.Context, transfer *types.StockTransfer) error {
+	return inTx(ctx, r.db, func(ctx context.Context, tx *sql.Tx) error {
+		out := &types.StockMovement{
+			ProductID:  transfer.ProductID,
+			LocationID: transfer.FromLocationID,
+			Quantity:   -transfer.Quantity,
+			Kind:       types.MovementTransferOut,
+			Reference:  transfer.Reference,
+			CreatedAt:  transfer.CreatedAt,
+		}
+		if err := recordMovement(ctx, tx, out); err != nil {
+			return err
+		}
+
+		// The outgoing entry's ID identifies the transfer on both sides of the pair
+		transferID := out.ID
+		in := &types.StockMovement{
+			ProductID:  transfer.ProductID,
+			LocationID: transfer.ToLocationID,
+			Quantity:   transfer.Quantity,
+			Kind:       types.MovementTransferIn,
+			Reference:  transfer.Reference,
+			TransferID: &transferID,
+			CreatedAt:  transfer.CreatedAt,
+		}
+		if err := recordMovement(ctx, tx, in); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE stock_movements SET transfer_id = ? WHERE id = ?`, transferID, transferID); err != nil {
+			return err
+		}
+
+		transfer.ID = transferID
+		return nil
+	})
+}
+
+// recordMovement applies a ledger entry to the stock level at its location and
+// appends it to stock_movements. It fails with ErrInsufficientStock instead of
+// letting a location's stock go negative. The product's aggregate stock is left
+// to the caller.
+func recordMovement(ctx context.Context, q DBTX, movement *types.StockMovement) error {
+	result, err := q.ExecContext(ctx, `
+		UPDATE stock_levels SET quantity = quantity + ?
+		WHERE location_id = ? AND product_id = ? AND quantity + ? >= 0`,
+		movement.Quantity, movement.LocationID, movement.ProductID, movement.Quantity,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		if movement.Quantity < 0 {
+			return ErrInsufficientStock
+		}
+		_, err := q.ExecContext(ctx,
+			`INSERT INTO stock_levels (location_id, product_id, quantity) VALUES (?, ?, ?)`,
+			movement.LocationID, movement.ProductID, movement.Quantity,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	result, err = q.ExecContext(ctx, `
+		INSERT INTO stock_movements (product_id, location_id, quantity, kind, reference, transfer_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		movement.ProductID, movement.LocationID, movement.Quantity, movement.Kind, movement.Reference,
+		movement.TransferID, movement.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	movement.ID = int(id)
+	return nil
+}
+
+// restockRemoved returns quantity units to a product in the trash, which
+// AdjustStock treats as missing, booking them into the main warehouse. Units of
+// a product that has been purged have nowhere to go and are dropped.
+func restockRemoved(ctx context.Context, q DBTX, productID, quantity int, kind, reference string, now time.Time) error {
+	result, err := q.ExecContext(ctx, `UPDATE products SET stock = stock + ? WHERE id = ?`, quantity, productID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		return err
+	}
+
+	return recordMovement(ctx, q, &types.StockMovement{
+		ProductID:  productID,
+		LocationID: types.DefaultLocationID,
+		Quantity:   quantity,
+		Kind:       kind,
+		Reference:  reference,
+		CreatedAt:  now,
+	})
+}
+
+// deductStock removes quantity units of a product, draining locations in ID
+// order so the main warehouse is used first, and lowers the product's aggregate
+// stock to match. It fails with ErrInsufficientStock if the locations hold too few units.
+func deductStock(ctx context.Context, q DBTX, productID, quantity int, kind, reference string, now time.Time) error {
+	rows, err := q.QueryContext(ctx,
+		`SELECT location_id, quantity FROM stock_levels WHERE product_id = ? AND quantity > 0 ORDER BY location_id`,
+		productID,
+	)
+	if err != nil {
+		return err
+	}
+
+	var levels []types.LocationStock
+	for rows.Next() {
+		var level types.LocationStock
+		if err := rows.Scan(&level.LocationID, &level.Quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		levels = append(levels, level)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	remaining := quantity
+	for _, level := range levels {
+		if remaining == 0 {
+			break
+		}
+		take := min(level.Quantity, remaining)
+		err := recordMovement(ctx, q, &types.StockMovement{
+			ProductID:  productID,
+			LocationID: level.LocationID,
+			Quantity:   -take,
+			Kind:       kind,
+			Reference:  reference,
+			CreatedAt:  now,
+		})
+		if err != nil {
+			return err
+		}
+		remaining -= take
+	}
+
+	if remaining > 0 {
+		return ErrInsufficientStock
+	}
+
+	_, err = q.ExecContext(ctx, `UPDATE products SET stock = stock - ? WHERE id = ?`, quantity, productID)
+	return err
+}
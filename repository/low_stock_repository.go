@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go-circleci/types"
+)
+
+// LowStockRepository defines the interface for low-stock reporting and alert de-duplication
+type LowStockRepository interface {
+	ListLowStock(ctx context.Context) ([]*types.LowStockItem, error)
+	Alerted(ctx context.Context, productID int) (bool, error)
+	MarkAlerted(ctx context.Context, product *types.Product, now time.Time) (bool, error)
+	ClearAlert(ctx context.Context, productID int) error
+}
+
+// SQLiteLowStockRepository implements LowStockRepository using SQLite
+type SQLiteLowStockRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteLowStockRepository creates a new SQLite low-stock repository
+func NewSQLiteLowStockRepository(db *sql.DB) *SQLiteLowStockRepository {
+	return &SQLiteLowStockRepository{db: db}
+}
+
+// ListLowStock retrieves every product outside the trash with a reorder point whose available stock is at or below it
+func (r *SQLiteLowStockRepository) ListLowStock(ctx context.Context) ([]*types.LowStockItem, error) {
+	query := `
+		WITH p AS (
+			SELECT id, name, stock AS on_hand, ` + availableStock("?") + ` AS available, reorder_point, reorder_qty
+			FROM products
+			WHERE ` + notDeleted + `
+		)
+		SELECT p.id, p.name, p.on_hand, p.available, p.reorder_point, p.reorder_qty, a.alerted_at
+		FROM p LEFT JOIN low_stock_alerts a ON a.product_id = p.id
+		WHERE p.reorder_point > 0 AND p.available <= p.reorder_point
+		ORDER BY p.available - p.reorder_point, p.id`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*types.LowStockItem
+	for rows.Next() {
+		item := &types.LowStockItem{}
+		var alertedAt sql.NullTime
+		err := rows.Scan(&item.ProductID, &item.Name, &item.OnHand, &item.Available, &item.ReorderPoint, &item.ReorderQty, &alertedAt)
+		if err != nil {
+			return nil, err
+		}
+		if alertedAt.Valid {
+			item.AlertedAt = &alertedAt.Time
+		}
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Alerted reports whether an alert has already been delivered for a product's
+// current crossing of its reorder point
+func (r *SQLiteLowStockRepository) Alerted(ctx context.Context, productID int) (bool, error) {
+	var alerted bool
+	err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM low_stock_alerts WHERE product_id = ?)`, productID).Scan(&alerted)
+	return alerted, err
+}
+
+// MarkAlerted records that a product has crossed its reorder point. It returns
+// false if the product was already marked, meaning an alert has already fired
+// for the current crossing.
+func (r *SQLiteLowStockRepository) MarkAlerted(ctx context.Context, product *types.Product, now time.Time) (bool, error) {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO low_stock_alerts (product_id, available, reorder_point, alerted_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (product_id) DO NOTHING`,
+		product.ID, product.Available, product.ReorderPoint, now,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// ClearAlert re-arms alerting for a product once it is back above its reorder point
+func (r *SQLiteLowStockRepository) ClearAlert(ctx context.Context, productID int) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM low_stock_alerts WHERE product_id = ?`, productID)
+	return err
+}
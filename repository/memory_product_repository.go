@@ -0,0 +1,574 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-circleci/types"
+)
+
+// MemoryProductRepository implements ProductRepository in memory, for tests and
+// ephemeral demo mode. It follows the semantics of SQLiteProductRepository with
+// a single location and no reservations, so on-hand and available stock always
+// equal the product's stock. It is safe for concurrent use.
+type MemoryProductRepository struct {
+	// txMu is held for the whole of a transaction, and for every operation
+	// outside one, so a transaction never sees or rolls back another's changes
+	txMu sync.Mutex
+
+	mu       sync.Mutex
+	products map[int]*types.Product
+	nextID   int
+	history  memoryHistory
+}
+
+// memoryHistory holds the versions of products and the changes to their
+// stock, which stand in for the stock movement ledger
+type memoryHistory struct {
+	versions map[int][]types.ProductVersion
+	stock    map[int][]stockChange
+}
+
+// stockChange is a change to a product's stock at a point in time
+type stockChange struct {
+	at    time.Time
+	delta int
+}
+
+// clone returns a copy of the history that later changes do not affect
+func (h memoryHistory) clone() memoryHistory {
+	c := memoryHistory{
+		versions: make(map[int][]types.ProductVersion, len(h.versions)),
+		stock:    make(map[int][]stockChange, len(h.stock)),
+	}
+	for id, versions := range h.versions {
+		c.versions[id] = append([]types.ProductVersion(nil), versions...)
+	}
+	for id, changes := range h.stock {
+		c.stock[id] = append([]stockChange(nil), changes...)
+	}
+	return c
+}
+
+// NewMemoryProductRepository creates a new, empty in-memory product repository
+func NewMemoryProductRepository() *MemoryProductRepository {
+	return &MemoryProductRepository{
+		products: make(map[int]*types.Product),
+		nextID:   1,
+		history:  memoryHistory{versions: make(map[int][]types.ProductVersion), stock: make(map[int][]stockChange)},
+	}
+}
+
+// lock serializes an operation with transactions, unless ctx carries a
+// transaction of this repository, which already holds the lock
+func (r *MemoryProductRepository) lock(ctx context.Context) func() {
+	if state, ok := ctx.Value(memoryTxKey{}).(*memoryTxState); ok && state.repo == r {
+		r.mu.Lock()
+		return r.mu.Unlock
+	}
+
+	r.txMu.Lock()
+	r.mu.Lock()
+	return func() {
+		r.mu.Unlock()
+		r.txMu.Unlock()
+	}
+}
+
+// load returns a copy of a stored product as the SQLite repository would scan it
+func load(stored *types.Product) *types.Product {
+	product := *stored
+	product.OnHand = product.Stock
+	product.Available = product.Stock
+	product.EffectivePrice = product.Price
+	product.Promotions = []types.AppliedPromotion{}
+	product.Tax = nil
+	if stored.DeletedAt != nil {
+		deletedAt := *stored.DeletedAt
+		product.DeletedAt = &deletedAt
+	}
+	return &product
+}
+
+// live returns the stored product with the given ID unless it is missing or in the trash
+func (r *MemoryProductRepository) live(id int) (*types.Product, bool) {
+	product, ok := r.products[id]
+	if !ok || product.DeletedAt != nil {
+		return nil, false
+	}
+	return product, true
+}
+
+// recordVersion closes the open version of a product at now and, unless the
+// product is in the trash or purged, starts a new version holding its current
+// catalog fields. The caller holds mu.
+func (r *MemoryProductRepository) recordVersion(id int, now time.Time) {
+	versions := r.history.versions[id]
+	if n := len(versions); n > 0 && versions[n-1].ValidTo == nil {
+		validTo := now
+		versions[n-1].ValidTo = &validTo
+	}
+
+	if product, ok := r.live(id); ok {
+		versions = append(versions, types.ProductVersion{
+			ProductID:    id,
+			Version:      len(versions) + 1,
+			SKU:          product.SKU,
+			Name:         product.Name,
+			Description:  product.Description,
+			Category:     product.Category,
+			TaxClass:     product.TaxClass,
+			Price:        product.Price,
+			ReorderPoint: product.ReorderPoint,
+			ReorderQty:   product.ReorderQty,
+			ValidFrom:    now,
+		})
+	}
+	r.history.versions[id] = versions
+}
+
+// recordStock records a change to a product's stock. The caller holds mu.
+func (r *MemoryProductRepository) recordStock(id int, now time.Time, delta int) {
+	if delta != 0 {
+		r.history.stock[id] = append(r.history.stock[id], stockChange{at: now, delta: delta})
+	}
+}
+
+// skuTaken reports whether a product outside the trash other than id has the
+// given non-empty SKU
+func (r *MemoryProductRepository) skuTaken(sku string, id int) bool {
+	if sku == "" {
+		return false
+	}
+	for _, product := range r.products {
+		if product.SKU == sku && product.ID != id && product.DeletedAt == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAll retrieves all products not in the trash in ID order
+func (r *MemoryProductRepository) GetAll(ctx context.Context) ([]*types.Product, error) {
+	var products []*types.Product
+	err := r.ForEach(ctx, func(product *types.Product) error {
+		products = append(products, product)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+// GetByID retrieves a single product by its ID, unless it is in the trash
+func (r *MemoryProductRepository) GetByID(ctx context.Context, id int) (*types.Product, error) {
+	defer r.lock(ctx)()
+
+	product, ok := r.live(id)
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return load(product), nil
+}
+
+// GetByIDs retrieves the products with the given IDs in ID order. IDs of
+// missing products and products in the trash are skipped.
+func (r *MemoryProductRepository) GetByIDs(ctx context.Context, ids []int) ([]*types.Product, error) {
+	defer r.lock(ctx)()
+
+	sorted := slices.Clone(ids)
+	slices.Sort(sorted)
+
+	var products []*types.Product
+	for _, id := range slices.Compact(sorted) {
+		if product, ok := r.live(id); ok {
+			products = append(products, load(product))
+		}
+	}
+	return products, nil
+}
+
+// GetBySKU retrieves a single product by its SKU, unless it is in the trash
+func (r *MemoryProductRepository) GetBySKU(ctx context.Context, sku string) (*types.Product, error) {
+	defer r.lock(ctx)()
+
+	if sku != "" {
+		for _, product := range r.products {
+			if product.SKU == sku && product.DeletedAt == nil {
+				return load(product), nil
+			}
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// ForEach calls fn for every product not in the trash in ID order. It works on
+// a snapshot taken when it is called, so fn may use the repository. It stops at
+// the first error fn returns.
+func (r *MemoryProductRepository) ForEach(ctx context.Context, fn func(product *types.Product) error) error {
+	unlock := r.lock(ctx)
+	products := make([]*types.Product, 0, len(r.products))
+	for _, product := range r.products {
+		if product.DeletedAt == nil {
+			products = append(products, load(product))
+		}
+	}
+	unlock()
+
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+	for _, product := range products {
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListPage retrieves the products filter matches with IDs above after, at most
+// limit of them in ID order, and counts the products it matches on every page
+func (r *MemoryProductRepository) ListPage(ctx context.Context, filter types.ProductFilter, after, limit int) (*types.ProductPage, error) {
+	page := &types.ProductPage{}
+	err := r.ForEach(ctx, func(product *types.Product) error {
+		if !matchesFilter(filter, product) {
+			return nil
+		}
+		page.Total++
+		if product.ID > after && len(page.Products) < limit {
+			page.Products = append(page.Products, product)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// matchesFilter reports whether a product passes filter
+func matchesFilter(filter types.ProductFilter, product *types.Product) bool {
+	if filter.Category != "" && !strings.EqualFold(product.Category, filter.Category) {
+		return false
+	}
+	if filter.Search != "" {
+		search := strings.ToLower(filter.Search)
+		if !strings.Contains(strings.ToLower(product.Name), search) &&
+			!strings.Contains(strings.ToLower(product.Description), search) &&
+			!strings.Contains(strings.ToLower(product.SKU), search) {
+			return false
+		}
+	}
+	if filter.MinPrice != nil && product.Price < *filter.MinPrice {
+		return false
+	}
+	if filter.MaxPrice != nil && product.Price > *filter.MaxPrice {
+		return false
+	}
+	if filter.InStock != nil && (product.Available > 0) != *filter.InStock {
+		return false
+	}
+	return true
+}
+
+// Create stores a new product and sets its generated ID
+func (r *MemoryProductRepository) Create(ctx context.Context, product *types.Product) error {
+	defer r.lock(ctx)()
+
+	if r.skuTaken(product.SKU, 0) {
+		return ErrSKUTaken
+	}
+
+	product.ID = r.nextID
+	r.nextID++
+	r.products[product.ID] = load(product)
+
+	now := time.Now().UTC()
+	r.recordStock(product.ID, now, product.Stock)
+	r.recordVersion(product.ID, now)
+
+	product.OnHand = product.Stock
+	product.Available = product.Stock
+	product.EffectivePrice = product.Price
+	product.Promotions = []types.AppliedPromotion{}
+	return nil
+}
+
+// Update replaces an existing product that is not in the trash
+func (r *MemoryProductRepository) Update(ctx context.Context, product *types.Product) error {
+	defer r.lock(ctx)()
+
+	current, ok := r.live(product.ID)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if r.skuTaken(product.SKU, product.ID) {
+		return ErrSKUTaken
+	}
+
+	updated := load(product)
+	updated.DeletedAt = nil
+	r.products[product.ID] = updated
+
+	now := time.Now().UTC()
+	r.recordStock(product.ID, now, product.Stock-current.Stock)
+	r.recordVersion(product.ID, now)
+	return nil
+}
+
+// Delete moves a product to the trash by its ID. It can be restored as it was
+// until it is purged; its SKU is free for other products meanwhile.
+func (r *MemoryProductRepository) Delete(ctx context.Context, id int) error {
+	defer r.lock(ctx)()
+
+	product, ok := r.live(id)
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	now := time.Now().UTC()
+	product.DeletedAt = &now
+	r.recordVersion(id, now)
+	return nil
+}
+
+// ListDeleted retrieves the products in the trash, most recently deleted first
+func (r *MemoryProductRepository) ListDeleted(ctx context.Context) ([]*types.Product, error) {
+	defer r.lock(ctx)()
+
+	var products []*types.Product
+	for _, product := range r.products {
+		if product.DeletedAt != nil {
+			products = append(products, load(product))
+		}
+	}
+
+	sort.Slice(products, func(i, j int) bool {
+		if !products[i].DeletedAt.Equal(*products[j].DeletedAt) {
+			return products[i].DeletedAt.After(*products[j].DeletedAt)
+		}
+		return products[i].ID < products[j].ID
+	})
+	return products, nil
+}
+
+// Restore takes a product out of the trash by its ID, failing with ErrSKUTaken
+// if another product has taken its SKU in the meantime
+func (r *MemoryProductRepository) Restore(ctx context.Context, id int) error {
+	defer r.lock(ctx)()
+
+	product, ok := r.products[id]
+	if !ok || product.DeletedAt == nil {
+		return sql.ErrNoRows
+	}
+	if r.skuTaken(product.SKU, id) {
+		return ErrSKUTaken
+	}
+
+	product.DeletedAt = nil
+	r.recordVersion(id, time.Now().UTC())
+	return nil
+}
+
+// Purge permanently removes a product, in the trash or not, by its ID
+func (r *MemoryProductRepository) Purge(ctx context.Context, id int) error {
+	defer r.lock(ctx)()
+
+	if _, ok := r.products[id]; !ok {
+		return sql.ErrNoRows
+	}
+
+	delete(r.products, id)
+	r.recordVersion(id, time.Now().UTC())
+	return nil
+}
+
+// PurgeDeleted permanently removes the products that were moved to the trash
+// before the given time and returns how many were removed
+func (r *MemoryProductRepository) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	defer r.lock(ctx)()
+
+	purged := 0
+	for id, product := range r.products {
+		if product.DeletedAt != nil && product.DeletedAt.Before(before) {
+			delete(r.products, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// AdjustStock changes a product's stock by delta. Removing more units than the
+// product has fails with a *StockError.
+func (r *MemoryProductRepository) AdjustStock(ctx context.Context, id int, delta int, kind string, reference string) error {
+	defer r.lock(ctx)()
+
+	product, ok := r.live(id)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if product.Stock+delta < 0 {
+		return &StockError{ProductID: id}
+	}
+
+	product.Stock += delta
+	product.OnHand = product.Stock
+	product.Available = product.Stock
+	r.recordStock(id, time.Now().UTC(), delta)
+	return nil
+}
+
+// asOf returns a product as it was at the given time, if it existed outside
+// the trash then. The caller holds mu.
+func (r *MemoryProductRepository) asOf(id int, at time.Time) (*types.Product, bool) {
+	for _, version := range r.history.versions[id] {
+		if version.ValidFrom.After(at) || (version.ValidTo != nil && !version.ValidTo.After(at)) {
+			continue
+		}
+
+		// Take the changes since from the current stock, or add up the
+		// changes until then for a purged product
+		stock := 0
+		current, exists := r.products[id]
+		if exists {
+			stock = current.Stock
+		}
+		for _, change := range r.history.stock[id] {
+			if change.at.After(at) && exists {
+				stock -= change.delta
+			} else if !change.at.After(at) && !exists {
+				stock += change.delta
+			}
+		}
+
+		return &types.Product{
+			ID:             id,
+			SKU:            version.SKU,
+			Name:           version.Name,
+			Description:    version.Description,
+			Category:       version.Category,
+			TaxClass:       version.TaxClass,
+			Price:          version.Price,
+			EffectivePrice: version.Price,
+			Promotions:     []types.AppliedPromotion{},
+			Stock:          stock,
+			OnHand:         stock,
+			Available:      stock,
+			ReorderPoint:   version.ReorderPoint,
+			ReorderQty:     version.ReorderQty,
+		}, true
+	}
+	return nil, false
+}
+
+// GetAsOf retrieves a product as it was at the given time, failing with
+// sql.ErrNoRows if it did not exist or was in the trash then
+func (r *MemoryProductRepository) GetAsOf(ctx context.Context, id int, at time.Time) (*types.Product, error) {
+	defer r.lock(ctx)()
+
+	product, ok := r.asOf(id, at)
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return product, nil
+}
+
+// GetAllAsOf retrieves the products that existed outside the trash at the
+// given time as they were then, in ID order
+func (r *MemoryProductRepository) GetAllAsOf(ctx context.Context, at time.Time) ([]*types.Product, error) {
+	defer r.lock(ctx)()
+
+	products := []*types.Product{}
+	for id := range r.history.versions {
+		if product, ok := r.asOf(id, at); ok {
+			products = append(products, product)
+		}
+	}
+
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+	return products, nil
+}
+
+// ListVersions retrieves every version of a product, oldest first. It is empty
+// for a product that never existed.
+func (r *MemoryProductRepository) ListVersions(ctx context.Context, id int) ([]*types.ProductVersion, error) {
+	defer r.lock(ctx)()
+
+	versions := []*types.ProductVersion{}
+	for _, version := range r.history.versions[id] {
+		version := version
+		if version.ValidTo != nil {
+			validTo := *version.ValidTo
+			version.ValidTo = &validTo
+		}
+		versions = append(versions, &version)
+	}
+	return versions, nil
+}
+
+// memoryTxKey is the context key under which the current in-memory transaction is stored
+type memoryTxKey struct{}
+
+// memoryTxState is the in-memory transaction carried by a context together
+// with the functions to run once it commits
+type memoryTxState struct {
+	repo  *MemoryProductRepository
+	hooks *commitHooks
+}
+
+// MemoryTxManager implements TxManager for a MemoryProductRepository by
+// snapshotting its products and their history and restoring them when a
+// transaction fails
+type MemoryTxManager struct {
+	repo *MemoryProductRepository
+}
+
+// NewMemoryTxManager creates a new transaction manager for repo
+func NewMemoryTxManager(repo *MemoryProductRepository) *MemoryTxManager {
+	return &MemoryTxManager{repo: repo}
+}
+
+// InTx runs fn in a transaction, keeping its changes if it returns nil and
+// discarding them otherwise. Nested calls behave like savepoints: a failed
+// nested transaction only discards its own changes.
+func (m *MemoryTxManager) InTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	r := m.repo
+	if state, ok := ctx.Value(memoryTxKey{}).(*memoryTxState); !ok || state.repo != r {
+		state = &memoryTxState{repo: r, hooks: &commitHooks{}}
+		// The commit hooks run once the transaction has let go of the lock
+		defer func() {
+			if err == nil {
+				state.hooks.run()
+			}
+		}()
+		r.txMu.Lock()
+		defer r.txMu.Unlock()
+		ctx = context.WithValue(ctx, memoryTxKey{}, state)
+	}
+
+	r.mu.Lock()
+	products := make(map[int]*types.Product, len(r.products))
+	for id, product := range r.products {
+		products[id] = load(product)
+	}
+	nextID := r.nextID
+	history := r.history.clone()
+	r.mu.Unlock()
+
+	if err := fn(ctx); err != nil {
+		r.mu.Lock()
+		r.products = products
+		r.nextID = nextID
+		r.history = history
+		r.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,289 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"go-circleci/types"
+)
+
+// ErrOrderStatusChanged is returned when an order's status no longer matches the
+// status a transition was validated against
+var ErrOrderStatusChanged = errors.New("order status changed concurrently")
+
+// OrderRepository defines the interface for order data access operations
+type OrderRepository interface {
+	GetByID(ctx context.Context, id int) (*types.Order, error)
+	List(ctx context.Context, filter types.OrderFilter) ([]*types.Order, error)
+	Create(ctx context.Context, order *types.Order) error
+	Transition(ctx context.Context, id int, from string, to string, restock bool, now time.Time) error
+}
+
+// SQLiteOrderRepository implements OrderRepository using SQLite
+type SQLiteOrderRepository struct {
+	db       *sql.DB
+	read     *sql.DB
+	products ProductRepository
+}
+
+// NewSQLiteOrderRepository creates a new SQLite order repository that adjusts
+// stock through the given product repository
+func NewSQLiteOrderRepository(db *sql.DB, products ProductRepository) *SQLiteOrderRepository {
+	return &SQLiteOrderRepository{db: db, products: products}
+}
+
+// SetReadDB sets a separate pool for reads outside a transaction, such as the
+// read pool of a services.SQLiteDatabase. Without one, reads use the database.
+func (r *SQLiteOrderRepository) SetReadDB(read *sql.DB) {
+	r.read = read
+}
+
+// reader returns the connection for a read: the transaction carried by ctx,
+// or the read pool otherwise
+func (r *SQLiteOrderRepository) reader(ctx context.Context) DBTX {
+	if r.read != nil && txFromContext(ctx) == nil {
+		return r.read
+	}
+	return conn(ctx, r.db)
+}
+
+// GetByID retrieves a single order and its lines by the order ID
+func (r *SQLiteOrderRepository) GetByID(ctx context.Context, id int) (*types.Order, error) {
+	query := `SELECT id, status, customer, coupon, region, tax, total, created_at, updated_at FROM orders WHERE id = ?`
+
+	order, err := scanOrder(r.reader(ctx).QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadLines(ctx, []*types.Order{order}); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// List retrieves the orders matching filter, newest first
+func (r *SQLiteOrderRepository) List(ctx context.Context, filter types.OrderFilter) ([]*types.Order, error) {
+	var conditions []string
+	var args []any
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.To.UTC())
+	}
+
+	query := `SELECT id, status, customer, coupon, region, tax, total, created_at, updated_at FROM orders`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []*types.Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadLines(ctx, orders); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// Create inserts an order with its lines and deducts each line's quantity from
+// stock in the same transaction, so either the whole order is placed or nothing
+// changes. It fails with a *StockError if any product is short, and with
+// ErrCouponExhausted if the order's coupon has no uses left.
+func (r *SQLiteOrderRepository) Create(ctx context.Context, order *types.Order) error {
+	return inTx(ctx, r.db, func(ctx context.Context, tx *sql.Tx) error {
+		if order.Coupon != "" {
+			if err := redeemCoupon(ctx, tx, order.Coupon); err != nil {
+				return err
+			}
+		}
+
+		result, err := tx.ExecContext(ctx,
+			`INSERT INTO orders (status, customer, coupon, region, tax, total, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			order.Status, order.Customer, order.Coupon, order.Region, order.Tax, order.Total, order.CreatedAt, order.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		orderID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		reference := fmt.Sprintf("order:%d", orderID)
+		for i := range order.Lines {
+			line := &order.Lines[i]
+			if err := r.products.AdjustStock(ctx, line.ProductID, -line.Quantity, types.MovementSale, reference); err != nil {
+				return err
+			}
+
+			result, err := tx.ExecContext(ctx, `
+				INSERT INTO order_lines (order_id, product_id, product_name, unit_price, quantity, line_total, tax_rate, tax)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				orderID, line.ProductID, line.ProductName, line.UnitPrice, line.Quantity, line.LineTotal, line.TaxRate, line.Tax,
+			)
+			if err != nil {
+				return err
+			}
+
+			lineID, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+			line.ID = int(lineID)
+		}
+
+		order.ID = int(orderID)
+		return nil
+	})
+}
+
+// Transition moves an order from one status to another. When restock is set the
+// order's lines are returned to stock in the same transaction, including those
+// of products in the trash; lines of purged products are not restocked. It
+// fails with ErrOrderStatusChanged if the order is no longer in the from status.
+func (r *SQLiteOrderRepository) Transition(ctx context.Context, id int, from string, to string, restock bool, now time.Time) error {
+	return inTx(ctx, r.db, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			`UPDATE orders SET status = ?, updated_at = ? WHERE id = ? AND status = ?`,
+			to, now, id, from,
+		)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			var exists int
+			if err := tx.QueryRowContext(ctx, `SELECT 1 FROM orders WHERE id = ?`, id).Scan(&exists); err != nil {
+				return err
+			}
+			return ErrOrderStatusChanged
+		}
+
+		if !restock {
+			return nil
+		}
+
+		rows, err := tx.QueryContext(ctx, `SELECT product_id, quantity FROM order_lines WHERE order_id = ?`, id)
+		if err != nil {
+			return err
+		}
+
+		var lines []types.OrderLine
+		for rows.Next() {
+			var line types.OrderLine
+			if err := rows.Scan(&line.ProductID, &line.Quantity); err != nil {
+				rows.Close()
+				return err
+			}
+			lines = append(lines, line)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		reference := fmt.Sprintf("order:%d", id)
+		for _, line := range lines {
+			err := r.products.AdjustStock(ctx, line.ProductID, line.Quantity, types.MovementAdjustment, reference)
+			if errors.Is(err, sql.ErrNoRows) {
+				// The product went to the trash or was purged after the order was placed
+				err = restockRemoved(ctx, tx, line.ProductID, line.Quantity, types.MovementAdjustment, reference, now)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// loadLines fills in the lines of the given orders with a single query
+func (r *SQLiteOrderRepository) loadLines(ctx context.Context, orders []*types.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*types.Order, len(orders))
+	placeholders := make([]string, len(orders))
+	args := make([]any, len(orders))
+	for i, order := range orders {
+		order.Lines = []types.OrderLine{}
+		byID[order.ID] = order
+		placeholders[i] = "?"
+		args[i] = order.ID
+	}
+
+	query := `
+		SELECT id, order_id, product_id, product_name, unit_price, quantity, line_total, tax_rate, tax
+		FROM order_lines WHERE order_id IN (` + strings.Join(placeholders, ", ") + `)
+		ORDER BY id`
+
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line types.OrderLine
+		var orderID int
+		err := rows.Scan(&line.ID, &orderID, &line.ProductID, &line.ProductName, &line.UnitPrice, &line.Quantity, &line.LineTotal, &line.TaxRate, &line.Tax)
+		if err != nil {
+			return err
+		}
+		order := byID[orderID]
+		order.Lines = append(order.Lines, line)
+	}
+
+	return rows.Err()
+}
+
+// scanOrder scans an order row without its lines
+func scanOrder(row rowScanner) (*types.Order, error) {
+	order := &types.Order{}
+	err := row.Scan(&order.ID, &order.Status, &order.Customer, &order.Coupon, &order.Region, &order.Tax, &order.Total, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	order.Net = math.Round((order.Total-order.Tax)*100) / 100
+	return order, nil
+}
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"go-circleci/types"
+)
+
+// OutboxRepository defines the interface for the transactional outbox of domain events
+type OutboxRepository interface {
+	Append(ctx context.Context, event *types.Event) error
+	ListDue(ctx context.Context, now time.Time, limit int) ([]*types.OutboxEntry, error)
+	List(ctx context.Context, status string, limit int) ([]*types.OutboxEntry, error)
+	RecordDelivery(ctx context.Context, id int, publisher string, at time.Time) error
+	UpdateStatus(ctx context.Context, entry *types.OutboxEntry) error
+	Requeue(ctx context.Context, id int, at time.Time) error
+}
+
+// SQLiteOutboxRepository implements OutboxRepository using SQLite
+type SQLiteOutboxRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteOutboxRepository creates a new SQLite outbox repository
+func NewSQLiteOutboxRepository(db *sql.DB) *SQLiteOutboxRepository {
+	return &SQLiteOutboxRepository{db: db}
+}
+
+// outboxColumns selects an outbox row together with the publishers it reached
+const outboxColumns = `id, type, product_id, payload, occurred_at, status, attempts, last_error, next_attempt_at, delivered_at,
+	COALESCE((SELECT GROUP_CONCAT(d.publisher, ',') FROM outbox_deliveries d WHERE d.event_id = outbox.id), '')`
+
+// scanOutboxEntry scans a row selected with outboxColumns
+func scanOutboxEntry(row rowScanner) (*types.OutboxEntry, error) {
+	entry := &types.OutboxEntry{}
+	var payload, publishers string
+	var deliveredAt sql.NullTime
+	err := row.Scan(
+		&entry.ID,
+		&entry.Type,
+		&entry.ProductID,
+		&payload,
+		&entry.OccurredAt,
+		&entry.Status,
+		&entry.Attempts,
+		&entry.LastError,
+		&entry.NextAttemptAt,
+		&deliveredAt,
+		&publishers,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Payload = []byte(payload)
+	if deliveredAt.Valid {
+		entry.DeliveredAt = &deliveredAt.Time
+	}
+	entry.Publishers = []string{}
+	if publishers != "" {
+		entry.Publishers = strings.Split(publishers, ",")
+	}
+	return entry, nil
+}
+
+// Append adds a pending event to the outbox and sets its ID. It runs in the
+// transaction carried by ctx, so the event is only kept if the change it
+// describes is committed.
+func (r *SQLiteOutboxRepository) Append(ctx context.Context, event *types.Event) error {
+	event.OccurredAt = event.OccurredAt.UTC()
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		`INSERT INTO outbox (type, product_id, payload, occurred_at, status, next_attempt_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		event.Type, event.ProductID, string(event.Payload), event.OccurredAt, types.OutboxPending, event.OccurredAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	event.ID = int(id)
+	return nil
+}
+
+// ListDue retrieves up to limit pending events whose next attempt is due at
+// now, oldest first, so events about a product are delivered in order. An
+// event waiting to be retried holds back the later events about its product.
+func (r *SQLiteOutboxRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*types.OutboxEntry, error) {
+	return r.list(ctx, `SELECT `+outboxColumns+` FROM outbox
+		WHERE status = ?1 AND next_attempt_at <= ?2
+			AND NOT EXISTS (SELECT 1 FROM outbox e WHERE e.product_id = outbox.product_id AND e.id < outbox.id
+				AND e.status = ?1 AND e.next_attempt_at > ?2)
+		ORDER BY id LIMIT ?3`,
+		types.OutboxPending, now.UTC(), limit)
+}
+
+// List retrieves up to limit events, newest first, with the given status or
+// any status if it is empty
+func (r *SQLiteOutboxRepository) List(ctx context.Context, status string, limit int) ([]*types.OutboxEntry, error) {
+	if status == "" {
+		return r.list(ctx, `SELECT `+outboxColumns+` FROM outbox ORDER BY id DESC LIMIT ?`, limit)
+	}
+	return r.list(ctx, `SELECT `+outboxColumns+` FROM outbox WHERE status = ? ORDER BY id DESC LIMIT ?`, status, limit)
+}
+
+// list retrieves the outbox entries a query selects with outboxColumns
+func (r *SQLiteOutboxRepository) list(ctx context.Context, query string, args ...any) ([]*types.OutboxEntry, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*types.OutboxEntry{}
+	for rows.Next() {
+		entry, err := scanOutboxEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// RecordDelivery records that an event reached a publisher. Recording the same
+// delivery again keeps the first.
+func (r *SQLiteOutboxRepository) RecordDelivery(ctx context.Context, id int, publisher string, at time.Time) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		`INSERT INTO outbox_deliveries (event_id, publisher, delivered_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`,
+		id, publisher, at.UTC(),
+	)
+	return err
+}
+
+// UpdateStatus stores the delivery status of an event: its status, attempts,
+// last error, next attempt and delivery time
+func (r *SQLiteOutboxRepository) UpdateStatus(ctx context.Context, entry *types.OutboxEntry) error {
+	var deliveredAt *time.Time
+	if entry.DeliveredAt != nil {
+		at := entry.DeliveredAt.UTC()
+		deliveredAt = &at
+	}
+
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		`UPDATE outbox SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ?, delivered_at = ? WHERE id = ?`,
+		entry.Status, entry.Attempts, entry.LastError, entry.NextAttemptAt.UTC(), deliveredAt, entry.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Requeue makes a failed event pending again with a fresh set of attempts,
+// due at the given time. It fails with sql.ErrNoRows unless the event failed.
+func (r *SQLiteOutboxRepository) Requeue(ctx context.Context, id int, at time.Time) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		`UPDATE outbox SET status = ?, attempts = 0, next_attempt_at = ? WHERE id = ? AND status = ?`,
+		types.OutboxPending, at.UTC(), id, types.OutboxFailed,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
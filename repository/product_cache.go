@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"go-circleci/types"
+)
+
+// ProductCache stores products by ID for CachedProductRepository. Implementations
+// must be safe for concurrent use and must not share products with callers, so
+// a cached product cannot be changed through a pointer handed out earlier.
+// LRUProductCache keeps products in process; a shared cache such as Redis can
+// implement the same interface.
+type ProductCache interface {
+	Get(ctx context.Context, id int) (*types.Product, bool)
+	Set(ctx context.Context, product *types.Product)
+	Delete(ctx context.Context, id int)
+}
+
+// cloneProduct copies a product as it was read from storage, before the service
+// layer adds promotions and tax
+func cloneProduct(product *types.Product) *types.Product {
+	clone := *product
+	clone.EffectivePrice = clone.Price
+	clone.Promotions = []types.AppliedPromotion{}
+	clone.Tax = nil
+	return &clone
+}
+
+// lruEntry is a cached product and the time it expires
+type lruEntry struct {
+	product   *types.Product
+	expiresAt time.Time
+}
+
+// LRUProductCache is an in-process ProductCache holding up to a fixed number of
+// products, evicting the least recently used first. Entries expire after a TTL
+// so changes made without going through the cache, such as stock taken by
+// orders or held by reservations, show up within that time.
+type LRUProductCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List
+	entries  map[int]*list.Element
+	now      func() time.Time
+}
+
+// NewLRUProductCache creates a cache of up to capacity products that expire after ttl
+func NewLRUProductCache(capacity int, ttl time.Duration) *LRUProductCache {
+	return &LRUProductCache{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[int]*list.Element),
+		now:      time.Now,
+	}
+}
+
+// Get returns a copy of the cached product with the given ID, if it has not expired
+func (c *LRUProductCache) Get(ctx context.Context, id int) (*types.Product, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, id)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return cloneProduct(entry.product), true
+}
+
+// Set caches a copy of product, evicting the least recently used product if the cache is full
+func (c *LRUProductCache) Set(ctx context.Context, product *types.Product) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &lruEntry{product: cloneProduct(product), expiresAt: c.now().Add(c.ttl)}
+	if elem, ok := c.entries[product.ID]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[product.ID] = c.order.PushFront(entry)
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).product.ID)
+	}
+}
+
+// Delete removes the product with the given ID from the cache
+func (c *LRUProductCache) Delete(ctx context.Context, id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[id]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, id)
+	}
+}
+
+// Len returns the number of cached products, including expired ones not yet removed
+func (c *LRUProductCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
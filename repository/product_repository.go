@@ -3,22 +3,130 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"strings"
+	"time"
 
 	"go-circleci/types"
 )
 
+// ErrSKUTaken is returned when creating or updating a product with another product's SKU
+var ErrSKUTaken = errors.New("product sku already exists")
+
 // ProductRepository defines the interface for product data access operations
 type ProductRepository interface {
 	GetAll(ctx context.Context) ([]*types.Product, error)
 	GetByID(ctx context.Context, id int) (*types.Product, error)
+	GetByIDs(ctx context.Context, ids []int) ([]*types.Product, error)
+	GetBySKU(ctx context.Context, sku string) (*types.Product, error)
+	ForEach(ctx context.Context, fn func(product *types.Product) error) error
+	ListPage(ctx context.Context, filter types.ProductFilter, after, limit int) (*types.ProductPage, error)
 	Create(ctx context.Context, product *types.Product) error
 	Update(ctx context.Context, product *types.Product) error
 	Delete(ctx context.Context, id int) error
+	ListDeleted(ctx context.Context) ([]*types.Product, error)
+	Restore(ctx context.Context, id int) error
+	Purge(ctx context.Context, id int) error
+	PurgeDeleted(ctx context.Context, before time.Time) (int, error)
+	AdjustStock(ctx context.Context, id int, delta int, kind string, reference string) error
+	GetAsOf(ctx context.Context, id int, at time.Time) (*types.Product, error)
+	GetAllAsOf(ctx context.Context, at time.Time) ([]*types.Product, error)
+	ListVersions(ctx context.Context, id int) ([]*types.ProductVersion, error)
+}
+
+// availableStock computes a product's available stock, which is the on-hand
+// stock minus the units held by active reservations that have not expired.
+// The current time is bound to the placeholder now, so a hold stops counting
+// as soon as it expires rather than when the expirer next runs.
+func availableStock(now string) string {
+	return `stock - COALESCE((SELECT SUM(r.quantity) FROM reservations r WHERE r.product_id = products.id AND r.status = 'active' AND r.expires_at > ` + now + `), 0)`
+}
+
+// productColumns selects a product row together with its available stock, with
+// the current time bound to the placeholder now
+func productColumns(now string) string {
+	return `id, sku, name, description, category, tax_class, price, stock, ` + availableStock(now) + `, reorder_point, reorder_qty, deleted_at`
+}
+
+// notDeleted restricts a query on products to those not in the trash
+const notDeleted = `deleted_at IS NULL`
+
+// likeEscaper escapes the wildcards of a LIKE pattern, for use with ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// productFilterConditions returns the conditions selecting the products outside
+// the trash that filter matches, with available stock computed at now. bind
+// records an argument and returns its placeholder, and is called in the order
+// the placeholders appear.
+func productFilterConditions(filter types.ProductFilter, now time.Time, bind func(arg any) string) string {
+	conditions := []string{notDeleted}
+	if filter.Category != "" {
+		conditions = append(conditions, `LOWER(category) = LOWER(`+bind(filter.Category)+`)`)
+	}
+	if filter.Search != "" {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(filter.Search)) + "%"
+		var matches []string
+		for _, column := range []string{"name", "description", "sku"} {
+			matches = append(matches, `LOWER(`+column+`) LIKE `+bind(pattern)+` ESCAPE '\'`)
+		}
+		conditions = append(conditions, `(`+strings.Join(matches, ` OR `)+`)`)
+	}
+	if filter.MinPrice != nil {
+		conditions = append(conditions, `price >= `+bind(*filter.MinPrice))
+	}
+	if filter.MaxPrice != nil {
+		conditions = append(conditions, `price <= `+bind(*filter.MaxPrice))
+	}
+	if filter.InStock != nil {
+		comparison := ` <= 0`
+		if *filter.InStock {
+			comparison = ` > 0`
+		}
+		conditions = append(conditions, `(`+availableStock(bind(now))+`)`+comparison)
+	}
+	return strings.Join(conditions, ` AND `)
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanProduct scans a row selected with productColumns into a product
+func scanProduct(row rowScanner) (*types.Product, error) {
+	product := &types.Product{}
+	var deletedAt sql.NullTime
+	err := row.Scan(
+		&product.ID,
+		&product.SKU,
+		&product.Name,
+		&product.Description,
+		&product.Category,
+		&product.TaxClass,
+		&product.Price,
+		&product.Stock,
+		&product.Available,
+		&product.ReorderPoint,
+		&product.ReorderQty,
+		&deletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		product.DeletedAt = &deletedAt.Time
+	}
+	product.OnHand = product.Stock
+	// Prices are list prices until promotions are applied by the service layer
+	product.EffectivePrice = product.Price
+	product.Promotions = []types.AppliedPromotion{}
+	return product, nil
 }
 
 // SQLiteProductRepository implements ProductRepository using SQLite
 type SQLiteProductRepository struct {
-	db *sql.DB
+	db   *sql.DB
+	read *sql.DB
 }
 
 // NewSQLiteProductRepository creates a new SQLite product repository
@@ -26,11 +134,34 @@ func NewSQLiteProductRepository(db *sql.DB) *SQLiteProductRepository {
 	return &SQLiteProductRepository{db: db}
 }
 
-// GetAll retrieves all products from the database
+// SetReadDB sets a separate pool for reads outside a transaction, such as the
+// read pool of a services.SQLiteDatabase. Without one, reads use the database.
+func (r *SQLiteProductRepository) SetReadDB(read *sql.DB) {
+	r.read = read
+}
+
+// reader returns the connection for a read: the transaction carried by ctx,
+// or the read pool otherwise
+func (r *SQLiteProductRepository) reader(ctx context.Context) DBTX {
+	if r.read != nil && txFromContext(ctx) == nil {
+		return r.read
+	}
+	return conn(ctx, r.db)
+}
+
+// GetAll retrieves all products from the database, except those in the trash
 func (r *SQLiteProductRepository) GetAll(ctx context.Context) ([]*types.Product, error) {
-	query := `SELECT id, name, description, price, stock FROM products`
-	
-	rows, err := r.db.QueryContext(ctx, query)
+	return r.list(ctx, `SELECT `+productColumns("?")+` FROM products WHERE `+notDeleted, time.Now().UTC())
+}
+
+// ListDeleted retrieves the products in the trash, most recently deleted first
+func (r *SQLiteProductRepository) ListDeleted(ctx context.Context) ([]*types.Product, error) {
+	return r.list(ctx, `SELECT `+productColumns("?")+` FROM products WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id`, time.Now().UTC())
+}
+
+// list retrieves the products a query selects with productColumns
+func (r *SQLiteProductRepository) list(ctx context.Context, query string, args ...any) ([]*types.Product, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -38,8 +169,7 @@ func (r *SQLiteProductRepository) GetAll(ctx context.Context) ([]*types.Product,
 
 	var products []*types.Product
 	for rows.Next() {
-		product := &types.Product{}
-		err := rows.Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Stock)
+		product, err := scanProduct(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -53,18 +183,11 @@ func (r *SQLiteProductRepository) GetAll(ctx context.Context) ([]*types.Product,
 	return products, nil
 }
 
-// GetByID retrieves a single product by its ID
+// GetByID retrieves a single product by its ID, unless it is in the trash
 func (r *SQLiteProductRepository) GetByID(ctx context.Context, id int) (*types.Product, error) {
-	query := `SELECT id, name, description, price, stock FROM products WHERE id = ?`
+	query := `SELECT ` + productColumns("?") + ` FROM products WHERE id = ? AND ` + notDeleted
 	
-	product := &types.Product{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&product.ID,
-		&product.Name,
-		&product.Description,
-		&product.Price,
-		&product.Stock,
-	)
+	product, err := scanProduct(r.reader(ctx).QueryRowContext(ctx, query, time.Now().UTC(), id))
 	
 	if err == sql.ErrNoRows {
 		return nil, sql.ErrNoRows
@@ -76,62 +199,289 @@ func (r *SQLiteProductRepository) GetByID(ctx context.Context, id int) (*types.P
 	return product, nil
 }
 
-// Create inserts a new product into the database and sets its generated ID
-func (r *SQLiteProductRepository) Create(ctx context.Context, product *types.Product) error {
-	query := `INSERT INTO products (name, description, price, stock) VALUES (?, ?, ?, ?)`
-	
-	result, err := r.db.ExecContext(ctx, query, product.Name, product.Description, product.Price, product.Stock)
-	if err != nil {
-		return err
+// GetByIDs retrieves the products with the given IDs in a single query, in ID
+// order. IDs of missing products and products in the trash are skipped.
+func (r *SQLiteProductRepository) GetByIDs(ctx context.Context, ids []int) ([]*types.Product, error) {
+	if len(ids) == 0 {
+		return nil, nil
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return err
+	args := []any{time.Now().UTC()}
+	for _, id := range ids {
+		args = append(args, id)
 	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
 
-	product.ID = int(id)
-	return nil
+	return r.list(ctx, `SELECT `+productColumns("?")+` FROM products WHERE id IN (`+placeholders+`) AND `+notDeleted+` ORDER BY id`, args...)
 }
 
-// Update modifies an existing product in the database
-func (r *SQLiteProductRepository) Update(ctx context.Context, product *types.Product) error {
-	query := `UPDATE products SET name = ?, description = ?, price = ?, stock = ? WHERE id = ?`
-	
-	result, err := r.db.ExecContext(ctx, query, product.Name, product.Description, product.Price, product.Stock, product.ID)
-	if err != nil {
-		return err
-	}
+// GetBySKU retrieves a single product by its SKU, unless it is in the trash
+func (r *SQLiteProductRepository) GetBySKU(ctx context.Context, sku string) (*types.Product, error) {
+	query := `SELECT ` + productColumns("?") + ` FROM products WHERE sku = ? AND sku != '' AND ` + notDeleted
 
-	rowsAffected, err := result.RowsAffected()
+	return scanProduct(r.reader(ctx).QueryRowContext(ctx, query, time.Now().UTC(), sku))
+}
+
+// ForEach calls fn for every product not in the trash in ID order, reading rows
+// as it goes so the whole table is never held in memory. It stops at the first
+// error fn returns.
+func (r *SQLiteProductRepository) ForEach(ctx context.Context, fn func(product *types.Product) error) error {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT `+productColumns("?")+` FROM products WHERE `+notDeleted+` ORDER BY id`, time.Now().UTC())
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(product); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return rows.Err()
 }
 
-// Delete removes a product from the database by its ID
-func (r *SQLiteProductRepository) Delete(ctx context.Context, id int) error {
-	query := `DELETE FROM products WHERE id = ?`
-	
-	result, err := r.db.ExecContext(ctx, query, id)
-	if err != nil {
-		return err
+// ListPage retrieves the products filter matches with IDs above after, at most
+// limit of them in ID order, and counts the products it matches on every page
+func (r *SQLiteProductRepository) ListPage(ctx context.Context, filter types.ProductFilter, after, limit int) (*types.ProductPage, error) {
+	now := time.Now().UTC()
+	var args []any
+	bind := func(arg any) string {
+		args = append(args, arg)
+		return "?"
+	}
+
+	page := &types.ProductPage{}
+	query := `SELECT COUNT(*) FROM products WHERE ` + productFilterConditions(filter, now, bind)
+	if err := r.reader(ctx).QueryRowContext(ctx, query, args...).Scan(&page.Total); err != nil {
+		return nil, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	args = nil
+	query = `SELECT ` + productColumns(bind(now)) + ` FROM products WHERE ` + productFilterConditions(filter, now, bind) +
+		` AND id > ` + bind(after) + ` ORDER BY id LIMIT ` + bind(limit)
+	products, err := r.list(ctx, query, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	page.Products = products
+	return page, nil
+}
+
+// Create inserts a new product into the database and sets its generated ID.
+// Its initial stock is booked into the main warehouse.
+func (r *SQLiteProductRepository) Create(ctx context.Context, product *types.Product) error {
+	return inTx(ctx, r.db, func(ctx context.Context, tx *sql.Tx) error {
+		query := `INSERT INTO products (sku, name, description, category, tax_class, price, stock, reorder_point, reorder_qty) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+		result, err := tx.ExecContext(ctx, query, product.SKU, product.Name, product.Description, product.Category, product.TaxClass, product.Price, product.Stock, product.ReorderPoint, product.ReorderQty)
+		if err != nil {
+			return skuError(err)
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		err = recordMovement(ctx, tx, &types.StockMovement{
+			ProductID:  int(id),
+			LocationID: types.DefaultLocationID,
+			Quantity:   product.Stock,
+			Kind:       types.MovementInitial,
+			CreatedAt:  now,
+		})
+		if err != nil {
+			return err
+		}
+		if err := recordVersion(ctx, tx, int(id), now); err != nil {
+			return err
+		}
+
+		product.ID = int(id)
+		product.OnHand = product.Stock
+		product.Available = product.Stock
+		product.EffectivePrice = product.Price
+		product.Promotions = []types.AppliedPromotion{}
+		return nil
+	})
+}
+
+// Update modifies an existing product in the database, failing with
+// sql.ErrNoRows for a product in the trash. A change to the aggregate
+// stock is booked against the main warehouse, failing with ErrInsufficientStock
+// if the new stock is lower than the units held at other locations.
+func (r *SQLiteProductRepository) Update(ctx context.Context, product *types.Product) error {
+	return inTx(ctx, r.db, func(ctx context.Context, tx *sql.Tx) error {
+		var current int
+		if err := tx.QueryRowContext(ctx, `SELECT stock FROM products WHERE id = ? AND `+notDeleted, product.ID).Scan(&current); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		if delta := product.Stock - current; delta != 0 {
+			err := recordMovement(ctx, tx, &types.StockMovement{
+				ProductID:  product.ID,
+				LocationID: types.DefaultLocationID,
+				Quantity:   delta,
+				Kind:       types.MovementAdjustment,
+				CreatedAt:  now,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		query := `UPDATE products SET sku = ?, name = ?, description = ?, category = ?, tax_class = ?, price = ?, stock = ?, reorder_point = ?, reorder_qty = ? WHERE id = ?`
+
+		if _, err := tx.ExecContext(ctx, query, product.SKU, product.Name, product.Description, product.Category, product.TaxClass, product.Price, product.Stock, product.ReorderPoint, product.ReorderQty, product.ID); err != nil {
+			return skuError(err)
+		}
+		return recordVersion(ctx, tx, product.ID, now)
+	})
+}
+
+// skuError maps a unique constraint violation on the SKU to ErrSKUTaken
+func skuError(err error) error {
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return ErrSKUTaken
 	}
+	return err
+}
 
-	return nil
+// Delete moves a product to the trash by its ID. It keeps its stock levels, so
+// it can be restored as it was until it is purged; its SKU is free for other
+// products meanwhile.
+func (r *SQLiteProductRepository) Delete(ctx context.Context, id int) error {
+	now := time.Now().UTC()
+	return r.setDeletedAt(ctx, id, &now, notDeleted)
+}
+
+// Restore takes a product out of the trash by its ID, failing with ErrSKUTaken
+// if another product has taken its SKU in the meantime
+func (r *SQLiteProductRepository) Restore(ctx context.Context, id int) error {
+	return skuError(r.setDeletedAt(ctx, id, nil, `deleted_at IS NOT NULL`))
+}
+
+// setDeletedAt sets deleted_at of the product with the given ID if it matches
+// condition, failing with sql.ErrNoRows otherwise. Moving a product to the
+// trash closes its open version and taking it out starts a new one.
+func (r *SQLiteProductRepository) setDeletedAt(ctx context.Context, id int, deletedAt *time.Time, condition string) error {
+	return inTx(ctx, r.db, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `UPDATE products SET deleted_at = ? WHERE id = ? AND `+condition, deletedAt, id)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return sql.ErrNoRows
+		}
+
+		now := time.Now().UTC()
+		if deletedAt != nil {
+			now = *deletedAt
+		}
+		return recordVersion(ctx, tx, id, now)
+	})
+}
+
+// Purge permanently removes a product, in the trash or not, and its
+// per-location stock levels from the database by its ID. The stock movement
+// ledger and the product's versions are kept for history.
+func (r *SQLiteProductRepository) Purge(ctx context.Context, id int) error {
+	return inTx(ctx, r.db, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM products WHERE id = ?`, id)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return sql.ErrNoRows
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM stock_levels WHERE product_id = ?`, id); err != nil {
+			return err
+		}
+		return recordVersion(ctx, tx, id, time.Now().UTC())
+	})
+}
+
+// PurgeDeleted permanently removes the products that were moved to the trash
+// before the given time, like Purge, and returns how many were removed
+func (r *SQLiteProductRepository) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	var purged int
+	err := inTx(ctx, r.db, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM stock_levels WHERE product_id IN (SELECT id FROM products WHERE deleted_at < ?)`, before.UTC())
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, `DELETE FROM products WHERE deleted_at < ?`, before.UTC())
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		purged = int(rowsAffected)
+		return err
+	})
+	return purged, err
+}
+
+// AdjustStock changes a product's on-hand stock by delta and records it in the
+// stock ledger. Removed units are taken from locations in ID order and added
+// units go to the main warehouse. Removing more units than are available after
+// active reservations fails with a *StockError.
+func (r *SQLiteProductRepository) AdjustStock(ctx context.Context, id int, delta int, kind string, reference string) error {
+	return inTx(ctx, r.db, func(ctx context.Context, tx *sql.Tx) error {
+		now := time.Now().UTC()
+		var available int
+		err := tx.QueryRowContext(ctx, `SELECT `+availableStock("?")+` FROM products WHERE id = ? AND `+notDeleted, now, id).Scan(&available)
+		if err != nil {
+			return err
+		}
+
+		if delta < 0 {
+			if available < -delta {
+				return &StockError{ProductID: id}
+			}
+			if err := deductStock(ctx, tx, id, -delta, kind, reference, now); err != nil {
+				if err == ErrInsufficientStock {
+					return &StockError{ProductID: id}
+				}
+				return err
+			}
+			return nil
+		}
+
+		err = recordMovement(ctx, tx, &types.StockMovement{
+			ProductID:  id,
+			LocationID: types.DefaultLocationID,
+			Quantity:   delta,
+			Kind:       kind,
+			Reference:  reference,
+			CreatedAt:  now,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE products SET stock = stock + ? WHERE id = ?`, delta, id)
+		return err
+	})
 }
@@ -0,0 +1,73 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go-circleci/repository"
+	"go-circleci/repository/repotest"
+	"go-circleci/types"
+)
+
+func TestSQLiteProductRepositoryConformance(t *testing.T) {
+	repotest.RunProductRepositoryContract(t, func(t *testing.T) repotest.ProductStore {
+		db := repotest.OpenSQLite(t)
+		return repotest.ProductStore{Repo: repository.NewSQLiteProductRepository(db), Tx: repository.NewSQLTxManager(db)}
+	})
+}
+
+func TestMemoryProductRepositoryConformance(t *testing.T) {
+	repotest.RunProductRepositoryContract(t, func(t *testing.T) repotest.ProductStore {
+		repo := repository.NewMemoryProductRepository()
+		return repotest.ProductStore{Repo: repo, Tx: repository.NewMemoryTxManager(repo)}
+	})
+}
+
+func TestCachedProductRepositoryConformance(t *testing.T) {
+	repotest.RunProductRepositoryContract(t, func(t *testing.T) repotest.ProductStore {
+		db := repotest.OpenSQLite(t)
+		cache := repository.NewLRUProductCache(100, time.Minute)
+		return repotest.ProductStore{Repo: repository.NewCachedProductRepository(repository.NewSQLiteProductRepository(db), cache), Tx: repository.NewSQLTxManager(db)}
+	})
+}
+
+func TestMemoryProductRepositoryConcurrentUse(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryProductRepository()
+	tx := repository.NewMemoryTxManager(repo)
+	product := &types.Product{Name: "Widget", Stock: 100}
+	if err := repo.Create(ctx, product); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%2 == 0 {
+				repo.AdjustStock(ctx, product.ID, -1, types.MovementAdjustment, "")
+				return
+			}
+			// Failed transactions must not undo the adjustments running beside them
+			tx.InTx(ctx, func(ctx context.Context) error {
+				if err := repo.AdjustStock(ctx, product.ID, -1, types.MovementAdjustment, ""); err != nil {
+					return err
+				}
+				return errors.New("roll back")
+			})
+		}()
+	}
+	wg.Wait()
+
+	got, err := repo.GetByID(ctx, product.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Stock != 75 {
+		t.Errorf("stock after 25 committed adjustments = %d, want 75", got.Stock)
+	}
+}
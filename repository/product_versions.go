@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go-circleci/types"
+)
+
+// productVersionColumns selects a product version row
+const productVersionColumns = `product_id, version, sku, name, description, category, tax_class, price, reorder_point, reorder_qty, valid_from, valid_to`
+
+// scanProductVersion scans a row selected with productVersionColumns
+func scanProductVersion(row rowScanner) (*types.ProductVersion, error) {
+	version := &types.ProductVersion{}
+	var validTo sql.NullTime
+	err := row.Scan(
+		&version.ProductID,
+		&version.Version,
+		&version.SKU,
+		&version.Name,
+		&version.Description,
+		&version.Category,
+		&version.TaxClass,
+		&version.Price,
+		&version.ReorderPoint,
+		&version.ReorderQty,
+		&version.ValidFrom,
+		&validTo,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if validTo.Valid {
+		version.ValidTo = &validTo.Time
+	}
+	return version, nil
+}
+
+// scanProductAsOf scans a row selected with productAsOfColumns into a product
+// as it was at the time. Nothing was reserved or discounted back then as far as
+// the repository knows, so its available stock is its on-hand stock.
+func scanProductAsOf(row rowScanner) (*types.Product, error) {
+	product := &types.Product{}
+	err := row.Scan(
+		&product.ID,
+		&product.SKU,
+		&product.Name,
+		&product.Description,
+		&product.Category,
+		&product.TaxClass,
+		&product.Price,
+		&product.Stock,
+		&product.ReorderPoint,
+		&product.ReorderQty,
+	)
+	if err != nil {
+		return nil, err
+	}
+	product.OnHand = product.Stock
+	product.Available = product.Stock
+	product.EffectivePrice = product.Price
+	product.Promotions = []types.AppliedPromotion{}
+	return product, nil
+}
+
+// productAsOfColumns selects the product a version describes together with its
+// stock at the time ?1: its current stock less the movements since, or for a
+// purged product the movements up to then
+const productAsOfColumns = `v.product_id, v.sku, v.name, v.description, v.category, v.tax_class, v.price,
+	COALESCE(p.stock, (SELECT COALESCE(SUM(m.quantity), 0) FROM stock_movements m WHERE m.product_id = v.product_id))
+	- COALESCE((SELECT SUM(m.quantity) FROM stock_movements m WHERE m.product_id = v.product_id AND m.created_at > ?1), 0),
+	v.reorder_point, v.reorder_qty`
+
+// productAsOfFrom joins the versions current at the time ?1 to their products
+const productAsOfFrom = ` FROM product_versions v LEFT JOIN products p ON p.id = v.product_id
+	WHERE v.valid_from <= ?1 AND (v.valid_to IS NULL OR v.valid_to > ?1)`
+
+// recordVersion closes the open version of a product at now and, unless the
+// product is in the trash or purged, starts a new version holding its current
+// catalog fields
+func recordVersion(ctx context.Context, q DBTX, productID int, now time.Time) error {
+	_, err := q.ExecContext(ctx, `UPDATE product_versions SET valid_to = ? WHERE product_id = ? AND valid_to IS NULL`, now, productID)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO product_versions (product_id, version, sku, name, description, category, tax_class, price, reorder_point, reorder_qty, valid_from)
+		SELECT id, (SELECT COALESCE(MAX(version), 0) + 1 FROM product_versions WHERE product_id = products.id),
+		sku, name, description, category, tax_class, price, reorder_point, reorder_qty, ?
+		FROM products WHERE id = ? AND `+notDeleted,
+		now, productID,
+	)
+	return err
+}
+
+// GetAsOf retrieves a product as it was at the given time, failing with
+// sql.ErrNoRows if it did not exist or was in the trash then
+func (r *SQLiteProductRepository) GetAsOf(ctx context.Context, id int, at time.Time) (*types.Product, error) {
+	query := `SELECT ` + productAsOfColumns + productAsOfFrom + ` AND v.product_id = ?2`
+	return scanProductAsOf(r.reader(ctx).QueryRowContext(ctx, query, at.UTC(), id))
+}
+
+// GetAllAsOf retrieves the products that existed outside the trash at the
+// given time as they were then, in ID order
+func (r *SQLiteProductRepository) GetAllAsOf(ctx context.Context, at time.Time) ([]*types.Product, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT `+productAsOfColumns+productAsOfFrom+` ORDER BY v.product_id`, at.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []*types.Product{}
+	for rows.Next() {
+		product, err := scanProductAsOf(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+
+	return products, rows.Err()
+}
+
+// ListVersions retrieves every version of a product, oldest first. It is empty
+// for a product that never existed.
+func (r *SQLiteProductRepository) ListVersions(ctx context.Context, id int) ([]*types.ProductVersion, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT `+productVersionColumns+` FROM product_versions WHERE product_id = ? ORDER BY version`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []*types.ProductVersion{}
+	for rows.Next() {
+		version, err := scanProductVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"go-circleci/types"
+)
+
+// ErrCouponCodeTaken is returned when creating a promotion whose coupon code already exists
+var ErrCouponCodeTaken = errors.New("coupon code already exists")
+
+// ErrCouponExhausted is returned when redeeming a coupon that has reached its usage limit
+var ErrCouponExhausted = errors.New("coupon usage limit reached")
+
+// PromotionRepository defines the interface for promotion data access operations
+type PromotionRepository interface {
+	List(ctx context.Context) ([]*types.Promotion, error)
+	ListCurrent(ctx context.Context, at time.Time) ([]*types.Promotion, error)
+	GetByID(ctx context.Context, id int) (*types.Promotion, error)
+	GetByCouponCode(ctx context.Context, code string) (*types.Promotion, error)
+	Create(ctx context.Context, promotion *types.Promotion) error
+	Delete(ctx context.Context, id int) error
+}
+
+// promotionColumns selects a promotion row for scanPromotion
+const promotionColumns = `id, name, kind, value, product_id, category, customer, coupon_code, max_uses, uses, starts_at, ends_at, created_at`
+
+// SQLitePromotionRepository implements PromotionRepository using SQLite
+type SQLitePromotionRepository struct {
+	db *sql.DB
+}
+
+// NewSQLitePromotionRepository creates a new SQLite promotion repository
+func NewSQLitePromotionRepository(db *sql.DB) *SQLitePromotionRepository {
+	return &SQLitePromotionRepository{db: db}
+}
+
+// List retrieves every promotion, newest first
+func (r *SQLitePromotionRepository) List(ctx context.Context) ([]*types.Promotion, error) {
+	return r.query(ctx, `SELECT `+promotionColumns+` FROM promotions ORDER BY id DESC`)
+}
+
+// ListCurrent retrieves the promotions running at the given time that have uses left
+func (r *SQLitePromotionRepository) ListCurrent(ctx context.Context, at time.Time) ([]*types.Promotion, error) {
+	return r.query(ctx, `
+		SELECT `+promotionColumns+` FROM promotions
+		WHERE (starts_at IS NULL OR starts_at <= ?)
+			AND (ends_at IS NULL OR ends_at > ?)
+			AND (max_uses = 0 OR uses < max_uses)
+		ORDER BY id`, at, at)
+}
+
+// GetByID retrieves a single promotion by its ID
+func (r *SQLitePromotionRepository) GetByID(ctx context.Context, id int) (*types.Promotion, error) {
+	return scanPromotion(conn(ctx, r.db).QueryRowContext(ctx, `SELECT `+promotionColumns+` FROM promotions WHERE id = ?`, id))
+}
+
+// GetByCouponCode retrieves the promotion unlocked by a coupon code
+func (r *SQLitePromotionRepository) GetByCouponCode(ctx context.Context, code string) (*types.Promotion, error) {
+	return scanPromotion(conn(ctx, r.db).QueryRowContext(ctx, `SELECT `+promotionColumns+` FROM promotions WHERE coupon_code = ?`, code))
+}
+
+// Create inserts a new promotion and sets its generated ID
+func (r *SQLitePromotionRepository) Create(ctx context.Context, promotion *types.Promotion) error {
+	var productID sql.NullInt64
+	if promotion.ProductID != nil {
+		productID = sql.NullInt64{Int64: int64(*promotion.ProductID), Valid: true}
+	}
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO promotions (name, kind, value, product_id, category, customer, coupon_code, max_uses, starts_at, ends_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		promotion.Name, promotion.Kind, promotion.Value, productID, promotion.Category, promotion.Customer,
+		promotion.CouponCode, promotion.MaxUses, nullTime(promotion.StartsAt), nullTime(promotion.EndsAt), promotion.CreatedAt,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrCouponCodeTaken
+		}
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	promotion.ID = int(id)
+	return nil
+}
+
+// Delete removes a promotion by its ID
+func (r *SQLitePromotionRepository) Delete(ctx context.Context, id int) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM promotions WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// query runs a promotion query and scans every row
+func (r *SQLitePromotionRepository) query(ctx context.Context, query string, args ...any) ([]*types.Promotion, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promotions []*types.Promotion
+	for rows.Next() {
+		promotion, err := scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, promotion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return promotions, nil
+}
+
+// redeemCoupon counts one use of a coupon within tx, failing with
+// ErrCouponExhausted if it has no uses left
+func redeemCoupon(ctx context.Context, tx *sql.Tx, code string) error {
+	result, err := tx.ExecContext(ctx,
+		`UPDATE promotions SET uses = uses + 1 WHERE coupon_code = ? AND (max_uses = 0 OR uses < max_uses)`, code,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrCouponExhausted
+	}
+
+	return nil
+}
+
+// scanPromotion scans a row selected with promotionColumns into a promotion
+func scanPromotion(row rowScanner) (*types.Promotion, error) {
+	promotion := &types.Promotion{}
+	var productID sql.NullInt64
+	var startsAt, endsAt sql.NullTime
+	err := row.Scan(
+		&promotion.ID,
+		&promotion.Name,
+		&promotion.Kind,
+		&promotion.Value,
+		&productID,
+		&promotion.Category,
+		&promotion.Customer,
+		&promotion.CouponCode,
+		&promotion.MaxUses,
+		&promotion.Uses,
+		&startsAt,
+		&endsAt,
+		&promotion.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if productID.Valid {
+		id := int(productID.Int64)
+		promotion.ProductID = &id
+	}
+	if startsAt.Valid {
+		promotion.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		promotion.EndsAt = &endsAt.Time
+	}
+
+	return promotion, nil
+}
+
+// nullTime converts an optional time into a nullable column value
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
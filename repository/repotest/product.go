@@ -0,0 +1,517 @@
+// Package repotest holds tests shared by the implementations of the repository
+// interfaces, so every storage backend is held to the same contract
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-circleci/migrations"
+	"go-circleci/repository"
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+// OpenMigrated opens dsn, closing it when the test ends, and applies the up
+// migrations in dir of fsys
+func OpenMigrated(t *testing.T, dsn string, fsys fs.FS, dir string) *sql.DB {
+	t.Helper()
+
+	db, err := services.InitDatabase(dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := migrations.Up(db, fsys, dir); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	return db
+}
+
+// SQLiteDSN returns the DSN of a SQLite database file in a temporary
+// directory of the test
+func SQLiteDSN(t *testing.T) string {
+	return "file:" + filepath.Join(t.TempDir(), "test.db") + "?_time_format=sqlite"
+}
+
+// OpenSQLite opens an empty SQLite database for the test, migrated to the
+// latest schema
+func OpenSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	return OpenMigrated(t, SQLiteDSN(t), migrations.SQLite, ".")
+}
+
+// ProductStore is a ProductRepository under test together with the transaction
+// manager for its storage
+type ProductStore struct {
+	Repo repository.ProductRepository
+	Tx   repository.TxManager
+}
+
+// RunProductRepositoryContract checks the behaviour every ProductRepository must
+// share. open is called once per subtest and must return an empty store.
+func RunProductRepositoryContract(t *testing.T, open func(t *testing.T) ProductStore) {
+	ctx := context.Background()
+
+	newProduct := func(sku string, stock int) *types.Product {
+		return &types.Product{SKU: sku, Name: "Widget " + sku, Description: "A widget", Category: "tools", TaxClass: types.DefaultTaxClass, Price: 9.99, Stock: stock, ReorderPoint: 2, ReorderQty: 10}
+	}
+
+	mustCreate := func(t *testing.T, repo repository.ProductRepository, product *types.Product) *types.Product {
+		t.Helper()
+		if err := repo.Create(ctx, product); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		return product
+	}
+
+	t.Run("create and get", func(t *testing.T) {
+		store := open(t)
+		created := mustCreate(t, store.Repo, newProduct("W-1", 5))
+		if created.ID <= 0 {
+			t.Fatalf("Create set ID %d, want a positive ID", created.ID)
+		}
+
+		got, err := store.Repo.GetByID(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.SKU != "W-1" || got.Name != "Widget W-1" || got.Description != "A widget" || got.Category != "tools" ||
+			got.TaxClass != types.DefaultTaxClass || got.Price != 9.99 || got.ReorderPoint != 2 || got.ReorderQty != 10 {
+			t.Errorf("GetByID = %+v, want the created fields", got)
+		}
+		if got.Stock != 5 || got.OnHand != 5 || got.Available != 5 {
+			t.Errorf("GetByID stock = %d/%d/%d, want 5/5/5", got.Stock, got.OnHand, got.Available)
+		}
+		if got.EffectivePrice != got.Price {
+			t.Errorf("EffectivePrice = %v, want the list price %v", got.EffectivePrice, got.Price)
+		}
+
+		bySKU, err := store.Repo.GetBySKU(ctx, "W-1")
+		if err != nil || bySKU.ID != created.ID {
+			t.Errorf("GetBySKU = %v, %v, want product %d", bySKU, err, created.ID)
+		}
+	})
+
+	t.Run("get by ids", func(t *testing.T) {
+		store := open(t)
+		first := mustCreate(t, store.Repo, newProduct("", 1))
+		second := mustCreate(t, store.Repo, newProduct("", 2))
+		trashed := mustCreate(t, store.Repo, newProduct("", 3))
+		if err := store.Repo.Delete(ctx, trashed.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+
+		got, err := store.Repo.GetByIDs(ctx, []int{second.ID, 999, trashed.ID, first.ID, second.ID})
+		if err != nil {
+			t.Fatalf("GetByIDs: %v", err)
+		}
+		if len(got) != 2 || got[0].ID != first.ID || got[1].ID != second.ID {
+			t.Fatalf("GetByIDs = %+v, want products %d and %d", got, first.ID, second.ID)
+		}
+		if got[1].Stock != 2 || got[1].Available != 2 {
+			t.Errorf("GetByIDs stock = %d/%d, want 2/2", got[1].Stock, got[1].Available)
+		}
+
+		if got, err := store.Repo.GetByIDs(ctx, nil); err != nil || len(got) != 0 {
+			t.Errorf("GetByIDs of no IDs = %v, %v, want none", got, err)
+		}
+	})
+
+	t.Run("missing products", func(t *testing.T) {
+		store := open(t)
+		mustCreate(t, store.Repo, newProduct("", 1))
+
+		if _, err := store.Repo.GetByID(ctx, 999); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetByID of a missing product = %v, want sql.ErrNoRows", err)
+		}
+		if _, err := store.Repo.GetBySKU(ctx, "NOPE"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetBySKU of a missing SKU = %v, want sql.ErrNoRows", err)
+		}
+		if _, err := store.Repo.GetBySKU(ctx, ""); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetBySKU of the empty SKU = %v, want sql.ErrNoRows", err)
+		}
+		if err := store.Repo.Update(ctx, &types.Product{ID: 999, Name: "x"}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Update of a missing product = %v, want sql.ErrNoRows", err)
+		}
+		if err := store.Repo.Delete(ctx, 999); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Delete of a missing product = %v, want sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("sku uniqueness", func(t *testing.T) {
+		store := open(t)
+		mustCreate(t, store.Repo, newProduct("", 1))
+		mustCreate(t, store.Repo, newProduct("", 1))
+		first := mustCreate(t, store.Repo, newProduct("DUP", 1))
+		second := mustCreate(t, store.Repo, newProduct("OTHER", 1))
+
+		if err := store.Repo.Create(ctx, newProduct("DUP", 1)); !errors.Is(err, repository.ErrSKUTaken) {
+			t.Errorf("Create with a taken SKU = %v, want ErrSKUTaken", err)
+		}
+
+		second.SKU = first.SKU
+		if err := store.Repo.Update(ctx, second); !errors.Is(err, repository.ErrSKUTaken) {
+			t.Errorf("Update to a taken SKU = %v, want ErrSKUTaken", err)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		store := open(t)
+		product := mustCreate(t, store.Repo, newProduct("U-1", 5))
+
+		product.Name = "Renamed"
+		product.Price = 12.5
+		product.Stock = 8
+		product.Category = "garden"
+		if err := store.Repo.Update(ctx, product); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+
+		got, err := store.Repo.GetByID(ctx, product.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.Name != "Renamed" || got.Price != 12.5 || got.Category != "garden" || got.Stock != 8 || got.Available != 8 {
+			t.Errorf("GetByID after Update = %+v", got)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		store := open(t)
+		product := mustCreate(t, store.Repo, newProduct("D-1", 5))
+
+		if err := store.Repo.Delete(ctx, product.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := store.Repo.GetByID(ctx, product.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetByID after Delete = %v, want sql.ErrNoRows", err)
+		}
+
+		// The SKU of a deleted product can be reused
+		mustCreate(t, store.Repo, newProduct("D-1", 1))
+	})
+
+	t.Run("trash", func(t *testing.T) {
+		store := open(t)
+		product := mustCreate(t, store.Repo, newProduct("T-1", 5))
+		kept := mustCreate(t, store.Repo, newProduct("T-2", 1))
+
+		if err := store.Repo.Restore(ctx, product.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Restore of a product outside the trash = %v, want sql.ErrNoRows", err)
+		}
+		if err := store.Repo.Delete(ctx, product.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if err := store.Repo.Delete(ctx, product.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Delete of a product in the trash = %v, want sql.ErrNoRows", err)
+		}
+
+		// Products in the trash are hidden from every read and cannot be changed
+		if _, err := store.Repo.GetBySKU(ctx, "T-1"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetBySKU of a deleted product = %v, want sql.ErrNoRows", err)
+		}
+		if all, err := store.Repo.GetAll(ctx); err != nil || len(all) != 1 || all[0].ID != kept.ID {
+			t.Errorf("GetAll = %v, %v, want only product %d", all, err, kept.ID)
+		}
+		if err := store.Repo.AdjustStock(ctx, product.ID, 1, types.MovementAdjustment, ""); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("AdjustStock of a deleted product = %v, want sql.ErrNoRows", err)
+		}
+		if err := store.Repo.Update(ctx, product); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Update of a deleted product = %v, want sql.ErrNoRows", err)
+		}
+
+		deleted, err := store.Repo.ListDeleted(ctx)
+		if err != nil || len(deleted) != 1 || deleted[0].ID != product.ID || deleted[0].DeletedAt == nil {
+			t.Fatalf("ListDeleted = %v, %v, want product %d with its deletion time", deleted, err, product.ID)
+		}
+
+		if err := store.Repo.Restore(ctx, product.ID); err != nil {
+			t.Fatalf("Restore: %v", err)
+		}
+		got, err := store.Repo.GetByID(ctx, product.ID)
+		if err != nil || got.DeletedAt != nil || got.Stock != 5 || got.Available != 5 {
+			t.Errorf("GetByID after Restore = %+v, %v, want the product with its stock", got, err)
+		}
+
+		// A product cannot come back while another product holds its SKU
+		if err := store.Repo.Delete(ctx, product.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		taker := mustCreate(t, store.Repo, newProduct("T-1", 1))
+		if err := store.Repo.Restore(ctx, product.ID); !errors.Is(err, repository.ErrSKUTaken) {
+			t.Errorf("Restore with its SKU taken = %v, want ErrSKUTaken", err)
+		}
+		if err := store.Repo.Purge(ctx, taker.ID); err != nil {
+			t.Fatalf("Purge: %v", err)
+		}
+		if err := store.Repo.Purge(ctx, taker.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Purge of a purged product = %v, want sql.ErrNoRows", err)
+		}
+
+		// Only products deleted before the cutoff are purged
+		if n, err := store.Repo.PurgeDeleted(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+			t.Errorf("PurgeDeleted an hour ago = %d, %v, want nothing purged", n, err)
+		}
+		if n, err := store.Repo.PurgeDeleted(ctx, time.Now().Add(time.Second)); err != nil || n != 1 {
+			t.Errorf("PurgeDeleted now = %d, %v, want 1", n, err)
+		}
+		if err := store.Repo.Restore(ctx, product.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Restore of a purged product = %v, want sql.ErrNoRows", err)
+		}
+		if deleted, err := store.Repo.ListDeleted(ctx); err != nil || len(deleted) != 0 {
+			t.Errorf("ListDeleted after the purge = %v, %v, want none", deleted, err)
+		}
+	})
+
+	t.Run("versions", func(t *testing.T) {
+		store := open(t)
+
+		// tick returns a time strictly between the changes around it
+		tick := func() time.Time {
+			time.Sleep(2 * time.Millisecond)
+			at := time.Now()
+			time.Sleep(2 * time.Millisecond)
+			return at
+		}
+
+		beforeCreate := tick()
+		product := mustCreate(t, store.Repo, newProduct("V-1", 5))
+		other := mustCreate(t, store.Repo, newProduct("V-2", 1))
+		created := tick()
+
+		product.Price = 12.5
+		if err := store.Repo.Update(ctx, product); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if err := store.Repo.AdjustStock(ctx, product.ID, -2, types.MovementSale, "order 1"); err != nil {
+			t.Fatalf("AdjustStock: %v", err)
+		}
+		updated := tick()
+
+		if err := store.Repo.Delete(ctx, product.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		deleted := tick()
+
+		if err := store.Repo.Restore(ctx, product.ID); err != nil {
+			t.Fatalf("Restore: %v", err)
+		}
+		if err := store.Repo.Purge(ctx, other.ID); err != nil {
+			t.Fatalf("Purge: %v", err)
+		}
+
+		if _, err := store.Repo.GetAsOf(ctx, product.ID, beforeCreate); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetAsOf before it was created = %v, want sql.ErrNoRows", err)
+		}
+		if got, err := store.Repo.GetAsOf(ctx, product.ID, created); err != nil || got.Price != 9.99 || got.Stock != 5 || got.Name != "Widget V-1" {
+			t.Errorf("GetAsOf after Create = %+v, %v, want the original price and stock", got, err)
+		}
+		if got, err := store.Repo.GetAsOf(ctx, product.ID, updated); err != nil || got.Price != 12.5 || got.Stock != 3 || got.Available != 3 {
+			t.Errorf("GetAsOf after Update = %+v, %v, want the new price and the stock after the sale", got, err)
+		}
+		if _, err := store.Repo.GetAsOf(ctx, product.ID, deleted); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetAsOf while in the trash = %v, want sql.ErrNoRows", err)
+		}
+
+		// A purged product is still there in the past
+		if all, err := store.Repo.GetAllAsOf(ctx, created); err != nil || len(all) != 2 || all[0].ID != product.ID || all[1].ID != other.ID || all[1].Stock != 1 {
+			t.Errorf("GetAllAsOf after Create = %+v, %v, want both products", all, err)
+		}
+		if all, err := store.Repo.GetAllAsOf(ctx, time.Now()); err != nil || len(all) != 1 || all[0].ID != product.ID {
+			t.Errorf("GetAllAsOf now = %+v, %v, want only product %d", all, err, product.ID)
+		}
+
+		versions, err := store.Repo.ListVersions(ctx, product.ID)
+		if err != nil || len(versions) != 3 {
+			t.Fatalf("ListVersions = %v, %v, want created, updated and restored versions", versions, err)
+		}
+		for i, version := range versions {
+			if version.Version != i+1 || version.ProductID != product.ID {
+				t.Errorf("version %d = %+v, want version %d of product %d", i, version, i+1, product.ID)
+			}
+		}
+		if versions[0].Price != 9.99 || versions[0].ValidTo == nil || !versions[0].ValidTo.Equal(versions[1].ValidFrom) {
+			t.Errorf("first version = %+v, want the original price, ending where the second starts", versions[0])
+		}
+		if versions[1].Price != 12.5 || versions[1].ValidTo == nil || versions[1].ValidTo.After(deleted) {
+			t.Errorf("second version = %+v, want the new price, ending at the deletion", versions[1])
+		}
+		if versions[2].ValidTo != nil || versions[2].ValidFrom.Before(deleted) {
+			t.Errorf("third version = %+v, want it open from the restore", versions[2])
+		}
+
+		if versions, err := store.Repo.ListVersions(ctx, other.ID); err != nil || len(versions) != 1 || versions[0].ValidTo == nil {
+			t.Errorf("ListVersions of a purged product = %v, %v, want its closed version", versions, err)
+		}
+		if versions, err := store.Repo.ListVersions(ctx, 999); err != nil || len(versions) != 0 {
+			t.Errorf("ListVersions of a missing product = %v, %v, want none", versions, err)
+		}
+	})
+
+	t.Run("adjust stock", func(t *testing.T) {
+		store := open(t)
+		product := mustCreate(t, store.Repo, newProduct("S-1", 5))
+
+		if err := store.Repo.AdjustStock(ctx, product.ID, 3, types.MovementAdjustment, "restock"); err != nil {
+			t.Fatalf("AdjustStock(+3): %v", err)
+		}
+		if err := store.Repo.AdjustStock(ctx, product.ID, -6, types.MovementAdjustment, "shrinkage"); err != nil {
+			t.Fatalf("AdjustStock(-6): %v", err)
+		}
+
+		var stockErr *repository.StockError
+		if err := store.Repo.AdjustStock(ctx, product.ID, -3, types.MovementAdjustment, "too many"); !errors.As(err, &stockErr) {
+			t.Errorf("AdjustStock beyond the available stock = %v, want a *StockError", err)
+		}
+		if err := store.Repo.AdjustStock(ctx, 999, 1, types.MovementAdjustment, ""); err == nil {
+			t.Error("AdjustStock of a missing product succeeded")
+		}
+
+		got, err := store.Repo.GetByID(ctx, product.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.Stock != 2 || got.Available != 2 {
+			t.Errorf("stock after adjustments = %d (available %d), want 2", got.Stock, got.Available)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		store := open(t)
+		if products, err := store.Repo.GetAll(ctx); err != nil || len(products) != 0 {
+			t.Fatalf("GetAll of an empty store = %v, %v", products, err)
+		}
+
+		var ids []int
+		for i := range 3 {
+			ids = append(ids, mustCreate(t, store.Repo, newProduct(fmt.Sprintf("L-%d", i), i)).ID)
+		}
+
+		products, err := store.Repo.GetAll(ctx)
+		if err != nil {
+			t.Fatalf("GetAll: %v", err)
+		}
+		if len(products) != 3 {
+			t.Fatalf("GetAll returned %d products, want 3", len(products))
+		}
+
+		var seen []int
+		err = store.Repo.ForEach(ctx, func(product *types.Product) error {
+			seen = append(seen, product.ID)
+			return nil
+		})
+		if err != nil || fmt.Sprint(seen) != fmt.Sprint(ids) {
+			t.Errorf("ForEach visited %v, %v, want %v in ID order", seen, err, ids)
+		}
+
+		stop := errors.New("stop")
+		calls := 0
+		err = store.Repo.ForEach(ctx, func(product *types.Product) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Errorf("ForEach after an error = %v with %d calls, want stop after 1", err, calls)
+		}
+	})
+
+	t.Run("list page", func(t *testing.T) {
+		store := open(t)
+		hammer := mustCreate(t, store.Repo, &types.Product{SKU: "H-1", Name: "Claw Hammer", Category: "Tools", Price: 20, Stock: 3})
+		saw := mustCreate(t, store.Repo, &types.Product{SKU: "S-1", Name: "Saw", Description: "Cuts like a hammer", Category: "tools", Price: 35})
+		mustCreate(t, store.Repo, &types.Product{SKU: "P-1", Name: "Paint", Category: "decor", Price: 12, Stock: 8})
+		trashed := mustCreate(t, store.Repo, &types.Product{SKU: "H-2", Name: "Hammer", Category: "tools", Price: 25, Stock: 1})
+		if err := store.Repo.Delete(ctx, trashed.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		// LIKE wildcards in a search match literally
+		mustCreate(t, store.Repo, &types.Product{SKU: "X_1", Name: "100% Wool", Category: "decor", Price: 40})
+
+		price := func(p float64) *float64 { return &p }
+		inStock, outOfStock := true, false
+		cases := []struct {
+			name   string
+			filter types.ProductFilter
+			after  int
+			limit  int
+			want   []int
+			total  int
+		}{
+			{"everything", types.ProductFilter{}, 0, 10, nil, 4},
+			{"category ignores case", types.ProductFilter{Category: "TOOLS"}, 0, 10, []int{hammer.ID, saw.ID}, 2},
+			{"search name, description and sku", types.ProductFilter{Search: "hammer"}, 0, 10, []int{hammer.ID, saw.ID}, 2},
+			{"search is literal", types.ProductFilter{Search: "%"}, 0, 10, nil, 1},
+			{"price range", types.ProductFilter{MinPrice: price(15), MaxPrice: price(35)}, 0, 10, []int{hammer.ID, saw.ID}, 2},
+			{"in stock", types.ProductFilter{InStock: &inStock}, 0, 10, nil, 2},
+			{"out of stock", types.ProductFilter{Category: "tools", InStock: &outOfStock}, 0, 10, []int{saw.ID}, 1},
+			{"limit", types.ProductFilter{Category: "tools"}, 0, 1, []int{hammer.ID}, 2},
+			{"after", types.ProductFilter{Category: "tools"}, hammer.ID, 10, []int{saw.ID}, 2},
+		}
+		for _, tc := range cases {
+			page, err := store.Repo.ListPage(ctx, tc.filter, tc.after, tc.limit)
+			if err != nil {
+				t.Fatalf("%s: ListPage: %v", tc.name, err)
+			}
+			var got []int
+			for _, product := range page.Products {
+				got = append(got, product.ID)
+			}
+			if page.Total != tc.total || (tc.want != nil && fmt.Sprint(got) != fmt.Sprint(tc.want)) || (tc.want == nil && len(got) != tc.total) {
+				t.Errorf("%s: ListPage = %v of %d, want %v of %d", tc.name, got, page.Total, tc.want, tc.total)
+			}
+		}
+	})
+
+	t.Run("transactions", func(t *testing.T) {
+		store := open(t)
+		boom := errors.New("boom")
+
+		err := store.Tx.InTx(ctx, func(ctx context.Context) error {
+			if err := store.Repo.Create(ctx, newProduct("T-1", 1)); err != nil {
+				return err
+			}
+			return boom
+		})
+		if !errors.Is(err, boom) {
+			t.Fatalf("InTx = %v, want boom", err)
+		}
+		if _, err := store.Repo.GetBySKU(ctx, "T-1"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("product created in a rolled back transaction: %v", err)
+		}
+
+		err = store.Tx.InTx(ctx, func(ctx context.Context) error {
+			if err := store.Repo.Create(ctx, newProduct("T-2", 1)); err != nil {
+				return err
+			}
+			// A failed nested transaction only rolls back its own changes
+			nested := store.Tx.InTx(ctx, func(ctx context.Context) error {
+				if err := store.Repo.Create(ctx, newProduct("T-3", 1)); err != nil {
+					return err
+				}
+				return boom
+			})
+			if !errors.Is(nested, boom) {
+				return fmt.Errorf("nested InTx = %v, want boom", nested)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("InTx: %v", err)
+		}
+		if _, err := store.Repo.GetBySKU(ctx, "T-2"); err != nil {
+			t.Errorf("product of the committed transaction: %v", err)
+		}
+		if _, err := store.Repo.GetBySKU(ctx, "T-3"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("product of the rolled back savepoint: %v", err)
+		}
+	})
+}
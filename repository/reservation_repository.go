@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-circleci/types"
+)
+
+var (
+	// ErrInsufficientStock is returned when a product does not have enough available units
+	ErrInsufficientStock = errors.New("insufficient stock")
+
+	// ErrReservationNotActive is returned when confirming or releasing a reservation that is no longer active
+	ErrReservationNotActive = errors.New("reservation is not active")
+)
+
+// StockError reports which product is short of stock. It matches
+// ErrInsufficientStock with errors.Is.
+type StockError struct {
+	ProductID int
+}
+
+func (e *StockError) Error() string {
+	return fmt.Sprintf("insufficient stock for product %d", e.ProductID)
+}
+
+// Is reports whether target is ErrInsufficientStock
+func (e *StockError) Is(target error) bool {
+	return target == ErrInsufficientStock
+}
+
+// ReservationRepository defines the interface for stock reservation data access operations
+type ReservationRepository interface {
+	GetByID(ctx context.Context, id int) (*types.Reservation, error)
+	Create(ctx context.Context, reservation *types.Reservation) error
+	Confirm(ctx context.Context, id int, now time.Time) error
+	Release(ctx context.Context, id int) error
+	ExpireStale(ctx context.Context, now time.Time) (int, error)
+}
+
+// SQLiteReservationRepository implements ReservationRepository using SQLite
+type SQLiteReservationRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteReservationRepository creates a new SQLite reservation repository
+func NewSQLiteReservationRepository(db *sql.DB) *SQLiteReservationRepository {
+	return &SQLiteReservationRepository{db: db}
+}
+
+// GetByID retrieves a single reservation by its ID
+func (r *SQLiteReservationRepository) GetByID(ctx context.Context, id int) (*types.Reservation, error) {
+	query := `SELECT id, product_id, quantity, reference, status, expires_at, created_at FROM reservations WHERE id = ?`
+
+	reservation := &types.Reservation{}
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
+		&reservation.ID,
+		&reservation.ProductID,
+		&reservation.Quantity,
+		&reservation.Reference,
+		&reservation.Status,
+		&reservation.ExpiresAt,
+		&reservation.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// Create inserts an active reservation if the product has enough available
+// stock, failing with sql.ErrNoRows if it is missing or in the trash. The
+// availability check and the insert happen in a single statement so two
+// concurrent checkouts cannot both reserve the last unit.
+func (r *SQLiteReservationRepository) Create(ctx context.Context, reservation *types.Reservation) error {
+	query := `
+		INSERT INTO reservations (product_id, quantity, reference, status, expires_at, created_at)
+		SELECT ?, ?, ?, ?, ?, ?
+		WHERE (SELECT stock FROM products WHERE id = ? AND ` + notDeleted + `)
+			- COALESCE((SELECT SUM(quantity) FROM reservations WHERE product_id = ? AND status = ? AND expires_at > ?), 0) >= ?`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query,
+		reservation.ProductID, reservation.Quantity, reservation.Reference, types.ReservationActive,
+		reservation.ExpiresAt, reservation.CreatedAt,
+		reservation.ProductID, reservation.ProductID, types.ReservationActive, reservation.CreatedAt, reservation.Quantity,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		// Nothing was inserted: either the product does not exist or it is short of stock
+		var exists int
+		err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT 1 FROM products WHERE id = ? AND `+notDeleted, reservation.ProductID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		return ErrInsufficientStock
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	reservation.ID = int(id)
+	reservation.Status = types.ReservationActive
+	return nil
+}
+
+// Confirm marks an active, unexpired reservation as confirmed and deducts its
+// quantity from the product's on-hand stock and location levels in the same transaction
+func (r *SQLiteReservationRepository) Confirm(ctx context.Context, id int, now time.Time) error {
+	return inTx(ctx, r.db, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			`UPDATE reservations SET status = ? WHERE id = ? AND status = ? AND expires_at > ?`,
+			types.ReservationConfirmed, id, types.ReservationActive, now,
+		)
+		if err != nil {
+			return err
+		}
+
+		if err := r.checkTransition(ctx, tx, result, id); err != nil {
+			return err
+		}
+
+		var productID, quantity int
+		err = tx.QueryRowContext(ctx, `SELECT product_id, quantity FROM reservations WHERE id = ?`, id).Scan(&productID, &quantity)
+		if err != nil {
+			return err
+		}
+
+		return deductStock(ctx, tx, productID, quantity, types.MovementSale, fmt.Sprintf("reservation:%d", id), now)
+	})
+}
+
+// Release marks an active reservation as released, returning its units to available stock
+func (r *SQLiteReservationRepository) Release(ctx context.Context, id int) error {
+	return inTx(ctx, r.db, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			`UPDATE reservations SET status = ? WHERE id = ? AND status = ?`,
+			types.ReservationReleased, id, types.ReservationActive,
+		)
+		if err != nil {
+			return err
+		}
+
+		return r.checkTransition(ctx, tx, result, id)
+	})
+}
+
+// ExpireStale marks every active reservation that expired before now as expired
+// and returns the number of reservations affected
+func (r *SQLiteReservationRepository) ExpireStale(ctx context.Context, now time.Time) (int, error) {
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		`UPDATE reservations SET status = ? WHERE status = ? AND expires_at <= ?`,
+		types.ReservationExpired, types.ReservationActive, now,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rowsAffected), nil
+}
+
+// checkTransition turns a status update that matched no rows into either
+// sql.ErrNoRows or ErrReservationNotActive
+func (r *SQLiteReservationRepository) checkTransition(ctx context.Context, tx *sql.Tx, result sql.Result, id int) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	var exists int
+	if err := tx.QueryRowContext(ctx, `SELECT 1 FROM reservations WHERE id = ?`, id).Scan(&exists); err != nil {
+		return err
+	}
+
+	return ErrReservationNotActive
+}
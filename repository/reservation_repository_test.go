@@ -0,0 +1,87 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"go-circleci/repository"
+	"go-circleci/repository/repotest"
+	"go-circleci/types"
+)
+
+func TestSQLiteReservationRepositoryIgnoresExpiredHolds(t *testing.T) {
+	ctx := context.Background()
+	db := repotest.OpenSQLite(t)
+	products := repository.NewSQLiteProductRepository(db)
+	reservations := repository.NewSQLiteReservationRepository(db)
+
+	product := &types.Product{Name: "Widget", Price: 5, Stock: 5}
+	if err := products.Create(ctx, product); err != nil {
+		t.Fatalf("Create product: %v", err)
+	}
+
+	// A hold that has expired but that the expirer has not marked yet
+	now := time.Now().UTC()
+	expired := &types.Reservation{ProductID: product.ID, Quantity: 5, ExpiresAt: now.Add(-time.Minute), CreatedAt: now.Add(-time.Hour)}
+	if err := reservations.Create(ctx, expired); err != nil {
+		t.Fatalf("Create expired reservation: %v", err)
+	}
+
+	got, err := products.GetByID(ctx, product.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Available != 5 {
+		t.Errorf("Available = %d, want 5 with the only hold expired", got.Available)
+	}
+
+	active := &types.Reservation{ProductID: product.ID, Quantity: 5, ExpiresAt: now.Add(time.Hour), CreatedAt: now}
+	if err := reservations.Create(ctx, active); err != nil {
+		t.Fatalf("Create reservation over an expired hold: %v", err)
+	}
+	more := &types.Reservation{ProductID: product.ID, Quantity: 1, ExpiresAt: now.Add(time.Hour), CreatedAt: now}
+	if err := reservations.Create(ctx, more); !errors.Is(err, repository.ErrInsufficientStock) {
+		t.Errorf("Create beyond the active hold = %v, want ErrInsufficientStock", err)
+	}
+}
+
+func TestSQLiteRepositoriesTreatTrashedProductsAsMissing(t *testing.T) {
+	ctx := context.Background()
+	db := repotest.OpenSQLite(t)
+	products := repository.NewSQLiteProductRepository(db)
+
+	product := &types.Product{Name: "Widget", Price: 5, Stock: 5}
+	if err := products.Create(ctx, product); err != nil {
+		t.Fatalf("Create product: %v", err)
+	}
+	now := time.Now().UTC()
+	carts := repository.NewSQLiteCartRepository(db, repository.NewSQLiteOrderRepository(db, products))
+	cart := &types.Cart{Status: types.CartOpen, ExpiresAt: now.Add(time.Hour), CreatedAt: now, UpdatedAt: now}
+	if err := carts.Create(ctx, cart); err != nil {
+		t.Fatalf("Create cart: %v", err)
+	}
+	if err := carts.SetLine(ctx, cart.ID, &types.CartLine{ProductID: product.ID, Quantity: 1, AddedPrice: 5}, now.Add(time.Hour), now); err != nil {
+		t.Fatalf("SetLine: %v", err)
+	}
+	if err := products.Delete(ctx, product.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	reservation := &types.Reservation{ProductID: product.ID, Quantity: 1, ExpiresAt: now.Add(time.Hour), CreatedAt: now}
+	if err := repository.NewSQLiteReservationRepository(db).Create(ctx, reservation); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("reserving a trashed product = %v, want sql.ErrNoRows", err)
+	}
+	if _, err := repository.NewSQLiteInventoryRepository(db).GetProductStock(ctx, product.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("stock of a trashed product = %v, want sql.ErrNoRows", err)
+	}
+	got, err := carts.GetByID(ctx, cart.ID)
+	if err != nil {
+		t.Fatalf("GetByID cart: %v", err)
+	}
+	if line := got.Lines[0]; line.ProductName != "" || line.UnitPrice != 0 || line.Available != 0 {
+		t.Errorf("cart line of a trashed product = %+v, want no name, price or availability", line)
+	}
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"go-circleci/types"
+)
+
+// ErrTaxRuleExists is returned when creating a second rule for the same region and tax class
+var ErrTaxRuleExists = errors.New("tax rule already exists")
+
+// TaxRuleRepository defines the interface for tax rule data access operations
+type TaxRuleRepository interface {
+	List(ctx context.Context) ([]*types.TaxRule, error)
+	ListByRegion(ctx context.Context, region string) ([]*types.TaxRule, error)
+	Create(ctx context.Context, rule *types.TaxRule) error
+	Delete(ctx context.Context, id int) error
+}
+
+// SQLiteTaxRuleRepository implements TaxRuleRepository using SQLite
+type SQLiteTaxRuleRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteTaxRuleRepository creates a new SQLite tax rule repository
+func NewSQLiteTaxRuleRepository(db *sql.DB) *SQLiteTaxRuleRepository {
+	return &SQLiteTaxRuleRepository{db: db}
+}
+
+// List retrieves every tax rule ordered by region and tax class
+func (r *SQLiteTaxRuleRepository) List(ctx context.Context) ([]*types.TaxRule, error) {
+	return r.query(ctx, `SELECT id, region, tax_class, rate, inclusive FROM tax_rules ORDER BY region, tax_class`)
+}
+
+// ListByRegion retrieves the tax rules of a single region
+func (r *SQLiteTaxRuleRepository) ListByRegion(ctx context.Context, region string) ([]*types.TaxRule, error) {
+	return r.query(ctx, `SELECT id, region, tax_class, rate, inclusive FROM tax_rules WHERE region = ? ORDER BY tax_class`, region)
+}
+
+// Create inserts a new tax rule and sets its generated ID
+func (r *SQLiteTaxRuleRepository) Create(ctx context.Context, rule *types.TaxRule) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		`INSERT INTO tax_rules (region, tax_class, rate, inclusive) VALUES (?, ?, ?, ?)`,
+		rule.Region, rule.TaxClass, rule.Rate, rule.Inclusive,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrTaxRuleExists
+		}
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	rule.ID = int(id)
+	return nil
+}
+
+// Delete removes a tax rule by its ID
+func (r *SQLiteTaxRuleRepository) Delete(ctx context.Context, id int) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM tax_rules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// query runs a tax rule query and scans every row
+func (r *SQLiteTaxRuleRepository) query(ctx context.Context, query string, args ...any) ([]*types.TaxRule, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*types.TaxRule
+	for rows.Next() {
+		rule := &types.TaxRule{}
+		if err := rows.Scan(&rule.ID, &rule.Region, &rule.TaxClass, &rule.Rate, &rule.Inclusive); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
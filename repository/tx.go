@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// DBTX is the subset of *sql.DB and *sql.Tx used by the SQLite repositories,
+// so the same query helpers can run inside or outside a transaction
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// TxManager runs a function atomically. Repositories called with the context
+// passed to fn take part in the same transaction, so changes spanning several
+// repositories are committed or rolled back together.
+type TxManager interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// txKey is the context key under which the current transaction is stored
+type txKey struct{}
+
+// txState is the transaction carried by a context together with its savepoint
+// depth and the functions to run once it commits
+type txState struct {
+	tx    *sql.Tx
+	depth int
+	hooks *commitHooks
+}
+
+// commitHooks collects the functions to run once a transaction commits
+type commitHooks struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+// add registers fn to run on commit
+func (h *commitHooks) add(fn func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.fns = append(h.fns, fn)
+}
+
+// run runs the registered functions in the order they were added
+func (h *commitHooks) run() {
+	h.mu.Lock()
+	fns := h.fns
+	h.fns = nil
+	h.mu.Unlock()
+
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// AfterCommit runs fn once the transaction carried by ctx, of a SQLTxManager
+// or a MemoryTxManager, has committed, or straight away if ctx carries none.
+// fn is dropped if the transaction rolls back. Work that must not see or act
+// on uncommitted changes, such as invalidating a cache, belongs here.
+func AfterCommit(ctx context.Context, fn func()) {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		state.hooks.add(fn)
+		return
+	}
+	if state, ok := ctx.Value(memoryTxKey{}).(*memoryTxState); ok {
+		state.hooks.add(fn)
+		return
+	}
+	fn()
+}
+
+// txFromContext returns the transaction carried by ctx, or nil if there is none
+func txFromContext(ctx context.Context) *sql.Tx {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx
+	}
+	return nil
+}
+
+// conn returns the transaction carried by ctx, or db if there is none
+func conn(ctx context.Context, db *sql.DB) DBTX {
+	if tx := txFromContext(ctx); tx != nil {
+		return tx
+	}
+	return db
+}
+
+// inTx runs fn inside the transaction carried by ctx if there is one, or
+// inside a new transaction otherwise, committing if fn returns nil and
+// rolling back otherwise. Repositories called with the context passed to fn
+// take part in the transaction.
+func inTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	if tx := txFromContext(ctx); tx != nil {
+		return fn(ctx, tx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	state := &txState{tx: tx, hooks: &commitHooks{}}
+	if err := fn(context.WithValue(ctx, txKey{}, state), tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	state.hooks.run()
+	return nil
+}
+
+// SQLTxManager implements TxManager on top of a *sql.DB
+type SQLTxManager struct {
+	db         *sql.DB
+	maxRetries int
+	backoff    time.Duration
+}
+
+// NewSQLTxManager creates a new transaction manager for db
+func NewSQLTxManager(db *sql.DB) *SQLTxManager {
+	return &SQLTxManager{db: db, maxRetries: 5, backoff: 10 * time.Millisecond}
+}
+
+// InTx runs fn in a transaction, committing if it returns nil and rolling back
+// otherwise. Called with a context that already carries a transaction, it runs
+// fn in a savepoint instead, so only fn's changes are rolled back on failure.
+// A top-level transaction that fails because the database is busy is retried
+// with exponential backoff, so fn may run more than once.
+func (m *SQLTxManager) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return m.savepoint(ctx, state, fn)
+	}
+
+	backoff := m.backoff
+	for attempt := 0; ; attempt++ {
+		err := m.run(ctx, fn)
+		if err == nil || !isBusy(err) || attempt == m.maxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// run runs fn in a new top-level transaction, running its commit hooks once
+// it has committed
+func (m *SQLTxManager) run(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	state := &txState{tx: tx, hooks: &commitHooks{}}
+	if err := fn(context.WithValue(ctx, txKey{}, state)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	state.hooks.run()
+	return nil
+}
+
+// savepoint runs fn in a savepoint nested inside the transaction of state
+func (m *SQLTxManager) savepoint(ctx context.Context, state *txState, fn func(ctx context.Context) error) error {
+	nested := &txState{tx: state.tx, depth: state.depth + 1, hooks: state.hooks}
+	name := fmt.Sprintf("sp_%d", nested.depth)
+
+	if _, err := state.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, nested)); err != nil {
+		// Rolling back to a savepoint keeps it open, so it is released as well
+		if _, rbErr := state.tx.ExecContext(ctx, "ROLLBACK TO "+name); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		if _, relErr := state.tx.ExecContext(ctx, "RELEASE "+name); relErr != nil {
+			return errors.Join(err, relErr)
+		}
+		return err
+	}
+
+	_, err := state.tx.ExecContext(ctx, "RELEASE "+name)
+	return err
+}
+
+// isBusy reports whether err means the database was locked by another connection
+func isBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		// Extended result codes keep the primary code in the low byte
+		code := sqliteErr.Code() & 0xff
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+
+	return strings.Contains(err.Error(), "database is locked")
+}
@@ -0,0 +1,332 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"go-circleci/types"
+)
+
+// WebhookRepository defines the interface for webhook subscriptions and their delivery log
+type WebhookRepository interface {
+	ListSubscriptions(ctx context.Context) ([]*types.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id int) (*types.WebhookSubscription, error)
+	CreateSubscription(ctx context.Context, sub *types.WebhookSubscription) error
+	UpdateSubscription(ctx context.Context, sub *types.WebhookSubscription) error
+	DeleteSubscription(ctx context.Context, id int) error
+	RecordResult(ctx context.Context, id int, succeeded bool, disableAfter int, at time.Time) (active bool, err error)
+	Enqueue(ctx context.Context, event *types.Event, payload []byte, at time.Time) (int, error)
+	ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*types.WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, subscriptionID, limit int) ([]*types.WebhookDelivery, error)
+	GetDelivery(ctx context.Context, id int) (*types.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *types.WebhookDelivery) error
+	Redeliver(ctx context.Context, id int, at time.Time) (*types.WebhookDelivery, error)
+}
+
+// SQLiteWebhookRepository implements WebhookRepository using SQLite
+type SQLiteWebhookRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteWebhookRepository creates a new SQLite webhook repository
+func NewSQLiteWebhookRepository(db *sql.DB) *SQLiteWebhookRepository {
+	return &SQLiteWebhookRepository{db: db}
+}
+
+// webhookSubscriptionColumns selects a subscription row for scanWebhookSubscription
+const webhookSubscriptionColumns = `id, url, secret, events, active, consecutive_failures, disabled_at, created_at`
+
+// scanWebhookSubscription scans a row selected with webhookSubscriptionColumns
+func scanWebhookSubscription(row rowScanner) (*types.WebhookSubscription, error) {
+	sub := &types.WebhookSubscription{}
+	var events string
+	var disabledAt sql.NullTime
+	err := row.Scan(&sub.ID, &sub.URL, &sub.Secret, &events, &sub.Active, &sub.ConsecutiveFailures, &disabledAt, &sub.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	sub.Events = []string{}
+	if events != "" {
+		sub.Events = strings.Split(events, ",")
+	}
+	if disabledAt.Valid {
+		sub.DisabledAt = &disabledAt.Time
+	}
+	return sub, nil
+}
+
+// ListSubscriptions retrieves every subscription, oldest first
+func (r *SQLiteWebhookRepository) ListSubscriptions(ctx context.Context) ([]*types.WebhookSubscription, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `SELECT `+webhookSubscriptionColumns+` FROM webhook_subscriptions ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []*types.WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, rows.Err()
+}
+
+// GetSubscription retrieves a single subscription by its ID
+func (r *SQLiteWebhookRepository) GetSubscription(ctx context.Context, id int) (*types.WebhookSubscription, error) {
+	return scanWebhookSubscription(conn(ctx, r.db).QueryRowContext(ctx,
+		`SELECT `+webhookSubscriptionColumns+` FROM webhook_subscriptions WHERE id = ?`, id))
+}
+
+// CreateSubscription inserts a new subscription and sets its generated ID
+func (r *SQLiteWebhookRepository) CreateSubscription(ctx context.Context, sub *types.WebhookSubscription) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO webhook_subscriptions (url, secret, events, active, consecutive_failures, disabled_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		sub.URL, sub.Secret, strings.Join(sub.Events, ","), sub.Active, sub.ConsecutiveFailures, nullTime(sub.DisabledAt), sub.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	sub.ID = int(id)
+	return nil
+}
+
+// UpdateSubscription stores a subscription's URL, secret, event filter and state
+func (r *SQLiteWebhookRepository) UpdateSubscription(ctx context.Context, sub *types.WebhookSubscription) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE webhook_subscriptions
+		SET url = ?, secret = ?, events = ?, active = ?, consecutive_failures = ?, disabled_at = ?
+		WHERE id = ?`,
+		sub.URL, sub.Secret, strings.Join(sub.Events, ","), sub.Active, sub.ConsecutiveFailures, nullTime(sub.DisabledAt), sub.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteSubscription removes a subscription and its delivery log
+func (r *SQLiteWebhookRepository) DeleteSubscription(ctx context.Context, id int) error {
+	return inTx(ctx, r.db, func(ctx context.Context, tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE subscription_id = ?`, id); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = ?`, id)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// RecordResult records the outcome of an attempt against a subscription's
+// streak of failures: success resets it, and a failure that makes it reach
+// disableAfter disables the subscription. It returns whether the subscription
+// is still active.
+func (r *SQLiteWebhookRepository) RecordResult(ctx context.Context, id int, succeeded bool, disableAfter int, at time.Time) (bool, error) {
+	var active bool
+	err := conn(ctx, r.db).QueryRowContext(ctx, `
+		UPDATE webhook_subscriptions SET
+			consecutive_failures = CASE WHEN ?1 THEN 0 ELSE consecutive_failures + 1 END,
+			active = CASE WHEN NOT ?1 AND consecutive_failures + 1 >= ?2 THEN 0 ELSE active END,
+			disabled_at = CASE WHEN active AND NOT ?1 AND consecutive_failures + 1 >= ?2 THEN ?3 ELSE disabled_at END
+		WHERE id = ?4
+		RETURNING active`,
+		succeeded, disableAfter, at.UTC(), id,
+	).Scan(&active)
+	return active, err
+}
+
+// Enqueue adds a pending delivery of an event to every active subscription
+// whose filter matches it and returns how many were added. An event is only
+// enqueued once per subscription, however often it is relayed.
+func (r *SQLiteWebhookRepository) Enqueue(ctx context.Context, event *types.Event, payload []byte, at time.Time) (int, error) {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, status, next_attempt_at, created_at)
+		SELECT id, ?1, ?2, ?3, ?4, ?5, ?5 FROM webhook_subscriptions
+		WHERE active AND (events = '' OR ',' || events || ',' LIKE '%,' || ?2 || ',%')
+		ON CONFLICT DO NOTHING`,
+		event.ID, event.Type, string(payload), types.WebhookPending, at.UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// webhookDeliveryColumns selects a delivery row joined with its subscription as s
+const webhookDeliveryColumns = `d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.redelivery_of, d.status, d.attempts,
+	d.response_code, d.last_error, d.next_attempt_at, d.created_at, d.delivered_at, s.url, s.secret`
+
+// scanWebhookDelivery scans a row selected with webhookDeliveryColumns
+func scanWebhookDelivery(row rowScanner) (*types.WebhookDelivery, error) {
+	delivery := &types.WebhookDelivery{}
+	var payload string
+	var redeliveryOf sql.NullInt64
+	var deliveredAt sql.NullTime
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.SubscriptionID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&payload,
+		&redeliveryOf,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.ResponseCode,
+		&delivery.LastError,
+		&delivery.NextAttemptAt,
+		&delivery.CreatedAt,
+		&deliveredAt,
+		&delivery.URL,
+		&delivery.Secret,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Payload = []byte(payload)
+	if redeliveryOf.Valid {
+		id := int(redeliveryOf.Int64)
+		delivery.RedeliveryOf = &id
+	}
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return delivery, nil
+}
+
+// ListDueDeliveries retrieves up to limit pending deliveries to active
+// subscriptions whose next attempt is due at now, oldest first
+func (r *SQLiteWebhookRepository) ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*types.WebhookDelivery, error) {
+	return r.listDeliveries(ctx, `
+		SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.status = ? AND d.next_attempt_at <= ? AND s.active
+		ORDER BY d.id LIMIT ?`,
+		types.WebhookPending, now.UTC(), limit)
+}
+
+// ListDeliveries retrieves up to limit deliveries to a subscription, newest first
+func (r *SQLiteWebhookRepository) ListDeliveries(ctx context.Context, subscriptionID, limit int) ([]*types.WebhookDelivery, error) {
+	return r.listDeliveries(ctx, `
+		SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.subscription_id = ?
+		ORDER BY d.id DESC LIMIT ?`,
+		subscriptionID, limit)
+}
+
+// listDeliveries retrieves the deliveries a query selects with webhookDeliveryColumns
+func (r *SQLiteWebhookRepository) listDeliveries(ctx context.Context, query string, args ...any) ([]*types.WebhookDelivery, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*types.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// GetDelivery retrieves a single delivery by its ID
+func (r *SQLiteWebhookRepository) GetDelivery(ctx context.Context, id int) (*types.WebhookDelivery, error) {
+	return scanWebhookDelivery(conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.id = ?`, id))
+}
+
+// UpdateDelivery stores the outcome of a delivery's latest attempt
+func (r *SQLiteWebhookRepository) UpdateDelivery(ctx context.Context, delivery *types.WebhookDelivery) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, response_code = ?, last_error = ?, next_attempt_at = ?, delivered_at = ?
+		WHERE id = ?`,
+		delivery.Status, delivery.Attempts, delivery.ResponseCode, delivery.LastError,
+		delivery.NextAttemptAt.UTC(), nullTime(delivery.DeliveredAt), delivery.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Redeliver adds a pending copy of a delivery, with the same payload and a
+// fresh set of attempts, due at the given time, and returns it
+func (r *SQLiteWebhookRepository) Redeliver(ctx context.Context, id int, at time.Time) (*types.WebhookDelivery, error) {
+	var redelivery *types.WebhookDelivery
+	err := inTx(ctx, r.db, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, redelivery_of, status, next_attempt_at, created_at)
+			SELECT subscription_id, event_id, event_type, payload, id, ?1, ?2, ?2 FROM webhook_deliveries WHERE id = ?3`,
+			types.WebhookPending, at.UTC(), id,
+		)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return sql.ErrNoRows
+		}
+
+		newID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		redelivery, err = scanWebhookDelivery(tx.QueryRowContext(ctx, `
+			SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id
+			WHERE d.id = ?`, newID))
+		return err
+	})
+	return redelivery, err
+}
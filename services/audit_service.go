@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"go-circleci/repository"
+	"go-circleci/types"
+)
+
+// Audit log listings return at most maxAuditEntries entries, and
+// defaultAuditEntries unless a limit is given
+const (
+	defaultAuditEntries = 100
+	maxAuditEntries     = 1000
+)
+
+// systemActor is recorded for changes made outside a request, such as the trash purger
+const systemActor = "system"
+
+// auditContextKey is the context key for the audit context of a request
+type auditContextKey struct{}
+
+// WithAuditContext returns a copy of ctx carrying the actor and request ID
+// that changes made with it are recorded under
+func WithAuditContext(ctx context.Context, ac types.AuditContext) context.Context {
+	return context.WithValue(ctx, auditContextKey{}, ac)
+}
+
+// auditContextFrom returns the audit context carried by ctx, if any
+func auditContextFrom(ctx context.Context) types.AuditContext {
+	ac, _ := ctx.Value(auditContextKey{}).(types.AuditContext)
+	return ac
+}
+
+// productSnapshot is the state of a product recorded in the audit log: its
+// stored fields, without the prices and stock figures derived from promotions,
+// taxes and reservations
+type productSnapshot struct {
+	ID           int        `json:"id"`
+	SKU          string     `json:"sku"`
+	Name         string     `json:"name"`
+	Description  string     `json:"description"`
+	Category     string     `json:"category"`
+	TaxClass     string     `json:"tax_class"`
+	Price        float64    `json:"price"`
+	Stock        int        `json:"stock"`
+	ReorderPoint int        `json:"reorder_point"`
+	ReorderQty   int        `json:"reorder_qty"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+}
+
+// snapshot returns the JSON snapshot of product, or nil if there is no product
+func snapshot(product *types.Product) (json.RawMessage, error) {
+	if product == nil {
+		return nil, nil
+	}
+	return json.Marshal(productSnapshot{
+		ID:           product.ID,
+		SKU:          product.SKU,
+		Name:         product.Name,
+		Description:  product.Description,
+		Category:     product.Category,
+		TaxClass:     product.TaxClass,
+		Price:        product.Price,
+		Stock:        product.Stock,
+		ReorderPoint: product.ReorderPoint,
+		ReorderQty:   product.ReorderQty,
+		DeletedAt:    product.DeletedAt,
+	})
+}
+
+// diffSnapshots returns the fields that differ between two snapshots in field
+// order. A field missing from one side, as every field is for a creation, is null there.
+func diffSnapshots(before, after json.RawMessage) ([]types.FieldChange, error) {
+	fields := func(raw json.RawMessage) (map[string]any, error) {
+		m := map[string]any{}
+		if raw == nil {
+			return m, nil
+		}
+		return m, json.Unmarshal(raw, &m)
+	}
+
+	from, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(from)+len(to))
+	for name := range from {
+		names = append(names, name)
+	}
+	for name := range to {
+		if _, ok := from[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []types.FieldChange{}
+	for _, name := range names {
+		if !reflect.DeepEqual(from[name], to[name]) {
+			changes = append(changes, types.FieldChange{Field: name, From: from[name], To: to[name]})
+		}
+	}
+	return changes, nil
+}
+
+// AuditService records product changes in a tamper-evident audit log and
+// answers questions about who changed what and when
+type AuditService struct {
+	repo repository.AuditRepository
+	now  func() time.Time
+}
+
+// NewAuditService creates a new AuditService with the given repository
+func NewAuditService(repo repository.AuditRepository) *AuditService {
+	return &AuditService{repo: repo, now: time.Now}
+}
+
+// RecordProductChange appends an entry for an operation on a product, with
+// the product before and after it, under the actor and request ID carried by
+// ctx. before is nil for a creation and after for a purge.
+func (s *AuditService) RecordProductChange(ctx context.Context, operation string, productID int, before, after *types.Product) error {
+	beforeJSON, err := snapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := snapshot(after)
+	if err != nil {
+		return err
+	}
+	diff, err := diffSnapshots(beforeJSON, afterJSON)
+	if err != nil {
+		return err
+	}
+
+	ac := auditContextFrom(ctx)
+	if ac.Actor == "" {
+		ac.Actor = systemActor
+	}
+
+	err = s.repo.Append(ctx, &types.AuditEntry{
+		CreatedAt: s.now(),
+		Actor:     ac.Actor,
+		RequestID: ac.RequestID,
+		Operation: operation,
+		ProductID: productID,
+		Before:    beforeJSON,
+		After:     afterJSON,
+		Diff:      diff,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListAuditEntries retrieves the audit entries matching filter, newest first
+func (s *AuditService) ListAuditEntries(ctx context.Context, filter types.AuditFilter) ([]*types.AuditEntry, error) {
+	if filter.Limit < 0 || filter.Limit > maxAuditEntries {
+		return nil, fmt.Errorf("audit limit must be between 1 and %d", maxAuditEntries)
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuditEntries
+	}
+	if filter.ProductID < 0 {
+		return nil, errors.New("invalid product ID: must be greater than 0")
+	}
+
+	switch filter.Operation {
+	case "", types.AuditCreate, types.AuditUpdate, types.AuditDelete, types.AuditRestore, types.AuditPurge:
+	default:
+		return nil, fmt.Errorf("invalid audit operation %q", filter.Operation)
+	}
+
+	entries, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	return entries, nil
+}
+
+// GetProductHistory retrieves every audit entry of a product, newest first
+func (s *AuditService) GetProductHistory(ctx context.Context, productID int) ([]*types.AuditEntry, error) {
+	if productID <= 0 {
+		return nil, errors.New("invalid product ID: must be greater than 0")
+	}
+
+	entries, err := s.repo.List(ctx, types.AuditFilter{ProductID: productID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product history: %w", err)
+	}
+	return entries, nil
+}
+
+// VerifyAuditLog checks that no audit entry has been changed or removed since it was written
+func (s *AuditService) VerifyAuditLog(ctx context.Context) (*types.AuditVerification, error) {
+	result, err := s.repo.Verify(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify audit log: %w", err)
+	}
+	return result, nil
+}
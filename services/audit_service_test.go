@@ -0,0 +1,69 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+func TestProductServiceAuditsChanges(t *testing.T) {
+	s := newSQLServices(t, sqlOptions{})
+	svc, audit := s.product, s.audit
+	ctx := services.WithAuditContext(context.Background(), types.AuditContext{Actor: "alice", RequestID: "req-1"})
+
+	product, err := svc.CreateProduct(ctx, &types.CreateProductRequest{Name: "Widget", Price: 9.99, Stock: 5})
+	checkErr(t, err, "")
+	_, err = svc.UpdateProduct(ctx, product.ID, &types.UpdateProductRequest{Name: "Widget", Price: 12.5, Stock: 5})
+	checkErr(t, err, "")
+	checkErr(t, svc.DeleteProduct(ctx, product.ID), "")
+	_, err = svc.RestoreProduct(context.Background(), product.ID)
+	checkErr(t, err, "")
+
+	// A failed change leaves no entry
+	_, err = svc.UpdateProduct(ctx, product.ID, &types.UpdateProductRequest{Name: "Widget", Price: -1})
+	checkErr(t, err, "must be greater than or equal to 0")
+
+	history, err := audit.GetProductHistory(context.Background(), product.ID)
+	checkErr(t, err, "")
+	var ops []string
+	for _, entry := range history {
+		ops = append(ops, entry.Operation)
+	}
+	if len(ops) != 4 || ops[0] != types.AuditRestore || ops[1] != types.AuditDelete || ops[2] != types.AuditUpdate || ops[3] != types.AuditCreate {
+		t.Fatalf("history operations = %v, want restore, delete, update, create", ops)
+	}
+
+	update := history[2]
+	if update.Actor != "alice" || update.RequestID != "req-1" || update.Before == nil || update.After == nil {
+		t.Errorf("update entry = %+v, want the actor, request ID and both snapshots", update)
+	}
+	if len(update.Diff) != 1 || update.Diff[0].Field != "price" || update.Diff[0].From != 9.99 || update.Diff[0].To != 12.5 {
+		t.Errorf("update diff = %+v, want only the price change", update.Diff)
+	}
+	if restore := history[0]; restore.Actor != "system" || len(restore.Diff) != 1 || restore.Diff[0].Field != "deleted_at" {
+		t.Errorf("restore entry = %+v, want a system change of deleted_at", restore)
+	}
+	if create := history[3]; create.Before != nil || len(create.Diff) == 0 {
+		t.Errorf("create entry = %+v, want no before snapshot and every field in the diff", create)
+	}
+
+	checkErr(t, svc.PurgeProduct(ctx, product.ID), "")
+	entries, err := audit.ListAuditEntries(context.Background(), types.AuditFilter{Operation: types.AuditPurge})
+	checkErr(t, err, "")
+	if len(entries) != 1 || entries[0].After != nil || entries[0].Before == nil {
+		t.Errorf("purge entries = %+v, want one with only a before snapshot", entries)
+	}
+
+	result, err := audit.VerifyAuditLog(context.Background())
+	checkErr(t, err, "")
+	if !result.Valid || result.Entries != 5 {
+		t.Errorf("VerifyAuditLog = %+v, want 5 valid entries", result)
+	}
+
+	_, err = audit.ListAuditEntries(context.Background(), types.AuditFilter{Operation: "rename"})
+	checkErr(t, err, "invalid audit operation")
+	_, err = audit.ListAuditEntries(context.Background(), types.AuditFilter{Limit: 5000})
+	checkErr(t, err, "audit limit must be between 1 and 1000")
+}
@@ -0,0 +1,606 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"modernc.org/sqlite"
+
+	"go-circleci/types"
+)
+
+// Backup files are named backup-<UTC timestamp>.db, followed by .gz when they
+// are compressed and .enc when they are encrypted
+const (
+	backupPrefix     = "backup-"
+	backupTimeFormat = "20060102T150405.000Z"
+	backupExt        = ".db"
+	compressedExt    = ".gz"
+	encryptedExt     = ".enc"
+)
+
+// backupMagic starts every encrypted backup, followed by the base GCM nonce and
+// the sealed chunks
+var backupMagic = []byte("GCBK2")
+
+// BackupOptions configures where backups are written and how they are stored
+type BackupOptions struct {
+	// Dir is the directory holding the backup files
+	Dir string
+	// Retain is the number of backups kept; older ones are removed after each
+	// backup. Zero keeps every backup.
+	Retain int
+	// Compress gzips backup files
+	Compress bool
+	// Key encrypts backup files with AES-256-GCM when it is set. It must be 32 bytes.
+	Key []byte
+}
+
+// BackupService takes consistent online backups of a SQLite database and
+// restores them. Backups are read through the read pool and restores written
+// through the write pool.
+type BackupService struct {
+	db   *SQLiteDatabase
+	opts BackupOptions
+	now  func() time.Time
+
+	// mu serializes backups, so retention never removes a backup being written
+	mu sync.Mutex
+}
+
+// NewBackupService creates a new backup service for db
+func NewBackupService(db *SQLiteDatabase, opts BackupOptions) (*BackupService, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("backup directory is required")
+	}
+	if opts.Retain < 0 {
+		return nil, errors.New("backup retention must be greater than or equal to 0")
+	}
+	if opts.Key != nil && len(opts.Key) != 32 {
+		return nil, fmt.Errorf("backup key must be 32 bytes, got %d", len(opts.Key))
+	}
+	return &BackupService{db: db, opts: opts, now: time.Now}, nil
+}
+
+// CreateBackup writes a backup of the database with SQLite's online backup API,
+// which copies a consistent snapshot in a single read transaction, so the
+// database stays in use and, in WAL mode, writers are not blocked. The copy is
+// checked with PRAGMA integrity_check before it is compressed, encrypted and kept.
+func (s *BackupService) CreateBackup(ctx context.Context) (*types.Backup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.opts.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	createdAt := s.now().UTC()
+	name := backupPrefix + createdAt.Format(backupTimeFormat) + backupExt
+	if s.opts.Compress {
+		name += compressedExt
+	}
+	if s.opts.Key != nil {
+		name += encryptedExt
+	}
+	path := filepath.Join(s.opts.Dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("backup %s already exists", name)
+	}
+
+	// The snapshot goes to a hidden file first so a failed backup never looks like a good one
+	tmp := filepath.Join(s.opts.Dir, "."+name+".tmp")
+	os.Remove(tmp)
+	defer os.Remove(tmp)
+
+	if err := s.copyTo(ctx, tmp); err != nil {
+		return nil, fmt.Errorf("failed to copy database: %w", err)
+	}
+	if err := checkIntegrity(ctx, tmp); err != nil {
+		return nil, err
+	}
+	if err := s.encode(tmp, path); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.prune(); err != nil {
+		fmt.Printf("backup retention err=%v\n", err)
+	}
+
+	return &types.Backup{
+		Name:       name,
+		Size:       info.Size(),
+		CreatedAt:  createdAt,
+		Compressed: s.opts.Compress,
+		Encrypted:  s.opts.Key != nil,
+	}, nil
+}
+
+// ListBackups returns the backups in the backup directory, newest first
+func (s *BackupService) ListBackups(ctx context.Context) ([]*types.Backup, error) {
+	entries, err := os.ReadDir(s.opts.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []*types.Backup{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []*types.Backup{}
+	for _, entry := range entries {
+		backup, ok := parseBackupName(entry.Name())
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backup.Size = info.Size()
+		backups = append(backups, backup)
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// RestoreBackup replaces the contents of the database with a backup file,
+// which may be a path or the name of a backup in the backup directory. The
+// backup is decrypted and decompressed into a temporary file and must pass
+// PRAGMA integrity_check before SQLite's backup API copies it over the
+// database, so a damaged backup leaves the database untouched. Caches in front
+// of the database are not cleared, so restore while the server is stopped.
+func (s *BackupService) RestoreBackup(ctx context.Context, file string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := file
+	if !strings.ContainsRune(file, filepath.Separator) {
+		path = filepath.Join(s.opts.Dir, file)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("backup %s not found", file)
+	}
+
+	tmp, err := os.CreateTemp(s.opts.Dir, ".restore-*.db")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := s.decode(path, tmp.Name()); err != nil {
+		return err
+	}
+	if err := checkIntegrity(ctx, tmp.Name()); err != nil {
+		return err
+	}
+
+	if err := withBackupConn(ctx, s.db.Write, func(conn sqliteBackupConn) (*sqlite.Backup, error) {
+		return conn.NewRestore(tmp.Name())
+	}); err != nil {
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+	return nil
+}
+
+// sqliteBackupConn is the part of a modernc.org/sqlite connection that runs the backup API
+type sqliteBackupConn interface {
+	NewBackup(dstUri string) (*sqlite.Backup, error)
+	NewRestore(srcUri string) (*sqlite.Backup, error)
+}
+
+// copyTo copies the database to a new file at path
+func (s *BackupService) copyTo(ctx context.Context, path string) error {
+	return withBackupConn(ctx, s.db.Read, func(conn sqliteBackupConn) (*sqlite.Backup, error) {
+		return conn.NewBackup(path)
+	})
+}
+
+// withBackupConn starts a backup or restore on a connection of db and copies
+// every page in one step
+func withBackupConn(ctx context.Context, db *sql.DB, start func(conn sqliteBackupConn) (*sqlite.Backup, error)) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		backupConn, ok := driverConn.(sqliteBackupConn)
+		if !ok {
+			return errors.New("backups require a SQLite database")
+		}
+		backup, err := start(backupConn)
+		if err != nil {
+			return err
+		}
+		_, stepErr := backup.Step(-1)
+		if err := backup.Finish(); stepErr == nil {
+			stepErr = err
+		}
+		return stepErr
+	})
+}
+
+// RunScheduler takes a backup every interval until ctx is cancelled
+func (s *BackupService) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			backup, err := s.CreateBackup(ctx)
+			if err != nil {
+				fmt.Printf("backup scheduler err=%v\n", err)
+				continue
+			}
+			fmt.Printf("backup scheduler name=%s size=%d\n", backup.Name, backup.Size)
+		}
+	}
+}
+
+// prune removes all but the newest Retain backups
+func (s *BackupService) prune() error {
+	if s.opts.Retain == 0 {
+		return nil
+	}
+
+	backups, err := s.ListBackups(context.Background())
+	if err != nil {
+		return err
+	}
+	for i := s.opts.Retain; i < len(backups); i++ {
+		if err := os.Remove(filepath.Join(s.opts.Dir, backups[i].Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encode writes the database copy at src to dst, compressed and encrypted as
+// configured. The copy is streamed, so memory use does not grow with the database.
+func (s *BackupService) encode(src, dst string) error {
+	if !s.opts.Compress && s.opts.Key == nil {
+		return os.Rename(src, dst)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	// Writers are closed innermost first, flushing each into the next
+	var w io.Writer = out
+	var closers []io.Closer
+	if s.opts.Key != nil {
+		gcm, err := newGCM(s.opts.Key)
+		if err != nil {
+			return err
+		}
+		sw, err := newSealWriter(out, gcm)
+		if err != nil {
+			return err
+		}
+		w = sw
+		closers = append(closers, sw)
+	}
+	if s.opts.Compress {
+		zw := gzip.NewWriter(w)
+		w = zw
+		closers = append(closers, zw)
+	}
+
+	if _, err := io.Copy(w, in); err != nil {
+		return err
+	}
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].Close(); err != nil {
+			return err
+		}
+	}
+	return out.Close()
+}
+
+// decode writes the database in the backup file src to dst, decrypting and
+// decompressing it according to its extensions as it is streamed
+func (s *BackupService) decode(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var r io.Reader = in
+	name := src
+	if strings.HasSuffix(name, encryptedExt) {
+		if s.opts.Key == nil {
+			return errors.New("backup key is required to restore an encrypted backup")
+		}
+		gcm, err := newGCM(s.opts.Key)
+		if err != nil {
+			return err
+		}
+		if r, err = newOpenReader(in, gcm); err != nil {
+			return err
+		}
+		name = strings.TrimSuffix(name, encryptedExt)
+	}
+
+	if strings.HasSuffix(name, compressedExt) {
+		zr, err := gzip.NewReader(r)
+		if errors.Is(err, errBackupDecryption) {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("invalid backup: %w", err)
+		}
+		r = zr
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, r); errors.Is(err, errBackupDecryption) {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("invalid backup: %w", err)
+	}
+	return out.Close()
+}
+
+// backupChunkSize is the size of the plaintext chunks an encrypted backup is
+// sealed in. Each chunk is sealed on its own, with a nonce derived from the
+// file's base nonce and the chunk's index, and the last chunk is marked in its
+// additional data so a backup cut short at a chunk boundary fails to open.
+const backupChunkSize = 64 << 10
+
+// errBackupDecryption is returned when a chunk of an encrypted backup fails to open
+var errBackupDecryption = errors.New("invalid backup: decryption failed, wrong key or damaged file")
+
+// chunkNonce returns the nonce of chunk index under base
+func chunkNonce(base []byte, index uint64) []byte {
+	nonce := append([]byte{}, base...)
+	tail := nonce[len(nonce)-8:]
+	binary.BigEndian.PutUint64(tail, binary.BigEndian.Uint64(tail)^index)
+	return nonce
+}
+
+// chunkAD returns the additional data of a chunk, marking whether it is the last
+func chunkAD(last bool) []byte {
+	if last {
+		return append(append([]byte{}, backupMagic...), 1)
+	}
+	return append(append([]byte{}, backupMagic...), 0)
+}
+
+// sealWriter encrypts what is written to it as a stream of sealed chunks,
+// after a header of backupMagic and the base nonce. Close seals the last chunk.
+type sealWriter struct {
+	w     io.Writer
+	gcm   cipher.AEAD
+	nonce []byte
+	index uint64
+	buf   []byte
+}
+
+// newSealWriter writes the header of an encrypted backup to w and returns a
+// writer sealing the backup's chunks to it
+func newSealWriter(w io.Writer, gcm cipher.AEAD) (*sealWriter, error) {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(append([]byte{}, backupMagic...), nonce...)); err != nil {
+		return nil, err
+	}
+	return &sealWriter{w: w, gcm: gcm, nonce: nonce, buf: make([]byte, 0, backupChunkSize)}, nil
+}
+
+// Write buffers p, sealing every full chunk except the latest, which may be the last
+func (w *sealWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if len(w.buf) == backupChunkSize {
+			if err := w.seal(false); err != nil {
+				return 0, err
+			}
+		}
+		take := min(len(p), backupChunkSize-len(w.buf))
+		w.buf = append(w.buf, p[:take]...)
+		p = p[take:]
+	}
+	return n, nil
+}
+
+// Close seals the buffered data as the last chunk
+func (w *sealWriter) Close() error {
+	return w.seal(true)
+}
+
+// seal writes the buffered data as one sealed chunk
+func (w *sealWriter) seal(last bool) error {
+	sealed := w.gcm.Seal(nil, chunkNonce(w.nonce, w.index), w.buf, chunkAD(last))
+	if _, err := w.w.Write(sealed); err != nil {
+		return err
+	}
+	w.index++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// openReader decrypts a stream written by a sealWriter
+type openReader struct {
+	r     *bufio.Reader
+	gcm   cipher.AEAD
+	nonce []byte
+	index uint64
+	chunk []byte
+	plain []byte
+	done  bool
+}
+
+// newOpenReader reads the header of an encrypted backup from r and returns a
+// reader of its decrypted contents
+func newOpenReader(r io.Reader, gcm cipher.AEAD) (*openReader, error) {
+	header := make([]byte, len(backupMagic)+gcm.NonceSize())
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.Equal(header[:len(backupMagic)], backupMagic) {
+		return nil, errors.New("invalid backup: not an encrypted backup file")
+	}
+	return &openReader{
+		r:     bufio.NewReader(r),
+		gcm:   gcm,
+		nonce: header[len(backupMagic):],
+		chunk: make([]byte, backupChunkSize+gcm.Overhead()),
+	}, nil
+}
+
+// Read returns decrypted data, opening the next chunk when the current one is used up
+func (r *openReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// open reads and decrypts the next chunk. A chunk is the last if it is short
+// or nothing follows it.
+func (r *openReader) open() error {
+	n, err := io.ReadFull(r.r, r.chunk)
+	last := err == io.ErrUnexpectedEOF || err == io.EOF
+	if err != nil && !last {
+		return err
+	}
+	if !last {
+		if _, err := r.r.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	plain, err := r.gcm.Open(r.chunk[:0], chunkNonce(r.nonce, r.index), r.chunk[:n], chunkAD(last))
+	if err != nil {
+		return errBackupDecryption
+	}
+	r.index++
+	r.plain = plain
+	r.done = last
+	return nil
+}
+
+// newGCM returns AES-256-GCM for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// checkIntegrity runs PRAGMA integrity_check on the database file at path. It
+// first switches the file to rollback journal mode, as a copy of a WAL
+// database is otherwise unusable without its -wal and -shm files.
+func checkIntegrity(ctx context.Context, path string) error {
+	db, err := sql.Open(DriverSQLite, "file:"+path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, `PRAGMA journal_mode = DELETE`); err != nil {
+		return fmt.Errorf("invalid backup: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, `PRAGMA integrity_check`)
+	if err != nil {
+		return fmt.Errorf("invalid backup: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return err
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("invalid backup: %w", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid backup: integrity check failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// parseBackupName returns the backup a file name describes, if it is a backup file name
+func parseBackupName(name string) (*types.Backup, bool) {
+	if !strings.HasPrefix(name, backupPrefix) {
+		return nil, false
+	}
+
+	backup := &types.Backup{Name: name}
+	rest := strings.TrimPrefix(name, backupPrefix)
+	if strings.HasSuffix(rest, encryptedExt) {
+		backup.Encrypted = true
+		rest = strings.TrimSuffix(rest, encryptedExt)
+	}
+	if strings.HasSuffix(rest, compressedExt) {
+		backup.Compressed = true
+		rest = strings.TrimSuffix(rest, compressedExt)
+	}
+	if !strings.HasSuffix(rest, backupExt) {
+		return nil, false
+	}
+
+	createdAt, err := time.Parse(backupTimeFormat, strings.TrimSuffix(rest, backupExt))
+	if err != nil {
+		return nil, false
+	}
+	backup.CreatedAt = createdAt
+	return backup, true
+}
@@ -0,0 +1,178 @@
+package services_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-circleci/repository/repotest"
+	"go-circleci/services"
+)
+
+// openBackupDB opens a SQLite database in a temporary directory holding one
+// table with a single row
+func openBackupDB(t *testing.T) *services.SQLiteDatabase {
+	t.Helper()
+	dsn := repotest.SQLiteDSN(t)
+	db, err := services.OpenSQLite(dsn, services.DefaultSQLiteOptions())
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Write.Exec(`CREATE TABLE items (name TEXT); INSERT INTO items (name) VALUES ('original')`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	return db
+}
+
+// itemNames returns the names in the items table
+func itemNames(t *testing.T, db *services.SQLiteDatabase) []string {
+	t.Helper()
+	rows, err := db.Read.Query(`SELECT name FROM items ORDER BY rowid`)
+	if err != nil {
+		t.Fatalf("query items: %v", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("scan item: %v", err)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestBackupServiceBackupAndRestore(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+
+	tests := []struct {
+		name string
+		opts services.BackupOptions
+	}{
+		{"plain", services.BackupOptions{}},
+		{"compressed", services.BackupOptions{Compress: true}},
+		{"encrypted", services.BackupOptions{Key: key}},
+		{"compressed and encrypted", services.BackupOptions{Compress: true, Key: key}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := openBackupDB(t)
+			tt.opts.Dir = t.TempDir()
+			svc, err := services.NewBackupService(db, tt.opts)
+			checkErr(t, err, "")
+
+			backup, err := svc.CreateBackup(ctx)
+			checkErr(t, err, "")
+			if backup.Compressed != tt.opts.Compress || backup.Encrypted != (tt.opts.Key != nil) || backup.Size == 0 {
+				t.Errorf("CreateBackup = %+v, want a non-empty backup stored as configured", backup)
+			}
+
+			if _, err := db.Write.Exec(`INSERT INTO items (name) VALUES ('after backup')`); err != nil {
+				t.Fatalf("insert: %v", err)
+			}
+
+			checkErr(t, svc.RestoreBackup(ctx, backup.Name), "")
+			if names := itemNames(t, db); len(names) != 1 || names[0] != "original" {
+				t.Errorf("items after restore = %v, want [original]", names)
+			}
+		})
+	}
+}
+
+func TestBackupServiceRetention(t *testing.T) {
+	ctx := context.Background()
+	db := openBackupDB(t)
+	dir := t.TempDir()
+	svc, err := services.NewBackupService(db, services.BackupOptions{Dir: dir, Retain: 2})
+	checkErr(t, err, "")
+
+	var names []string
+	for i := 0; i < 4; i++ {
+		backup, err := svc.CreateBackup(ctx)
+		checkErr(t, err, "")
+		names = append(names, backup.Name)
+	}
+
+	backups, err := svc.ListBackups(ctx)
+	checkErr(t, err, "")
+	if len(backups) != 2 || backups[0].Name != names[3] || backups[1].Name != names[2] {
+		t.Errorf("ListBackups = %v, want the newest two of %v", backups, names)
+	}
+}
+
+func TestBackupServiceRejectsBadBackups(t *testing.T) {
+	ctx := context.Background()
+	db := openBackupDB(t)
+	dir := t.TempDir()
+	key := make([]byte, 32)
+	svc, err := services.NewBackupService(db, services.BackupOptions{Dir: dir, Key: key})
+	checkErr(t, err, "")
+
+	backup, err := svc.CreateBackup(ctx)
+	checkErr(t, err, "")
+
+	wrongKey, err := services.NewBackupService(db, services.BackupOptions{Dir: dir, Key: append(make([]byte, 31), 1)})
+	checkErr(t, err, "")
+	checkErr(t, wrongKey.RestoreBackup(ctx, backup.Name), "decryption failed")
+
+	noKey, err := services.NewBackupService(db, services.BackupOptions{Dir: dir})
+	checkErr(t, err, "")
+	checkErr(t, noKey.RestoreBackup(ctx, backup.Name), "backup key is required")
+
+	garbage := filepath.Join(dir, "garbage.db")
+	if err := os.WriteFile(garbage, []byte("not a database, just some bytes"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	checkErr(t, noKey.RestoreBackup(ctx, garbage), "invalid backup")
+	checkErr(t, noKey.RestoreBackup(ctx, "missing.db"), "backup missing.db not found")
+
+	if names := itemNames(t, db); len(names) != 1 || names[0] != "original" {
+		t.Errorf("items after failed restores = %v, want [original]", names)
+	}
+
+	_, err = services.NewBackupService(db, services.BackupOptions{Dir: dir, Key: []byte("short")})
+	checkErr(t, err, "backup key must be 32 bytes")
+}
+
+func TestBackupServiceRoundTripsLargeEncryptedBackups(t *testing.T) {
+	ctx := context.Background()
+	db := openBackupDB(t)
+	dir := t.TempDir()
+	svc, err := services.NewBackupService(db, services.BackupOptions{Dir: dir, Key: make([]byte, 32)})
+	checkErr(t, err, "")
+
+	// Spread the database over several encrypted chunks
+	if _, err := db.Write.Exec(`INSERT INTO items (name) VALUES (hex(randomblob(200000)))`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	backup, err := svc.CreateBackup(ctx)
+	checkErr(t, err, "")
+	if _, err := db.Write.Exec(`DELETE FROM items WHERE rowid > 1`); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	checkErr(t, svc.RestoreBackup(ctx, backup.Name), "")
+	if names := itemNames(t, db); len(names) != 2 || len(names[1]) != 400000 {
+		t.Errorf("restored %d items, want the original and the large one", len(names))
+	}
+
+	// A backup cut short after a whole chunk, past the 5-byte magic and
+	// 12-byte nonce, fails to open rather than restoring a partial database
+	data, err := os.ReadFile(filepath.Join(dir, backup.Name))
+	checkErr(t, err, "")
+	truncated := filepath.Join(dir, "truncated.db.enc")
+	if err := os.WriteFile(truncated, data[:5+12+64<<10+16], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	checkErr(t, svc.RestoreBackup(ctx, truncated), "decryption failed")
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-circleci/repository"
+	"go-circleci/types"
+)
+
+// DefaultCartTTL is how long a cart stays open after its last change
+const DefaultCartTTL = 24 * time.Hour
+
+// CartService manages server-side shopping carts and their conversion to orders
+type CartService struct {
+	repo       repository.CartRepository
+	products   repository.ProductRepository
+	orders     *OrderService
+	promotions *PromotionService
+	ttl        time.Duration
+	now        func() time.Time
+}
+
+// NewCartService creates a new CartService that checks stock against products
+// and places orders through the given OrderService
+func NewCartService(repo repository.CartRepository, products repository.ProductRepository, orders *OrderService) *CartService {
+	return &CartService{
+		repo:     repo,
+		products: products,
+		orders:   orders,
+		ttl:      DefaultCartTTL,
+		now:      func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// SetPromotions sets the PromotionService used to price cart lines for the cart's
+// customer. Without one, lines are priced at list price.
+func (s *CartService) SetPromotions(promotions *PromotionService) {
+	s.promotions = promotions
+}
+
+// CreateCart opens a new empty cart
+func (s *CartService) CreateCart(ctx context.Context, req *types.CreateCartRequest) (*types.Cart, error) {
+	now := s.now()
+	cart := &types.Cart{
+		Status:    types.CartOpen,
+		Customer:  req.Customer,
+		Lines:     []types.CartLine{},
+		ExpiresAt: now.Add(s.ttl),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := s.repo.Create(ctx, cart); err != nil {
+		return nil, fmt.Errorf("failed to create cart: %w", err)
+	}
+
+	return cart, nil
+}
+
+// GetCart retrieves a cart priced at the current effective prices
+func (s *CartService) GetCart(ctx context.Context, id int) (*types.Cart, error) {
+	// Validate ID
+	if id <= 0 {
+		return nil, errors.New("invalid cart ID: must be greater than 0")
+	}
+
+	cart, err := s.repo.GetByID(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("cart with ID %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cart: %w", err)
+	}
+
+	if err := s.reprice(ctx, cart); err != nil {
+		return nil, err
+	}
+
+	return cart, nil
+}
+
+// reprice replaces the list prices of a cart's lines with the effective prices for its customer
+func (s *CartService) reprice(ctx context.Context, cart *types.Cart) error {
+	if s.promotions == nil {
+		return nil
+	}
+
+	pricingCtx := WithPricingContext(ctx, types.PricingContext{At: s.now(), Customer: cart.Customer})
+	cart.Total = 0
+	for i := range cart.Lines {
+		line := &cart.Lines[i]
+		product, err := s.products.GetByID(ctx, line.ProductID)
+		if err == nil {
+			err = s.promotions.ApplyPricing(pricingCtx, product)
+			if err != nil {
+				return err
+			}
+			line.UnitPrice = product.EffectivePrice
+			line.PriceChanged = line.UnitPrice != line.AddedPrice
+			line.LineTotal = roundCents(line.UnitPrice * float64(line.Quantity))
+		} else if err != sql.ErrNoRows {
+			return fmt.Errorf("failed to get product: %w", err)
+		}
+		cart.Total += line.LineTotal
+	}
+	cart.Total = roundCents(cart.Total)
+
+	return nil
+}
+
+// AddCartLine adds units of a product to a cart, on top of any already in it
+func (s *CartService) AddCartLine(ctx context.Context, cartID int, req *types.CartLineRequest) (*types.Cart, error) {
+	if req.Quantity <= 0 {
+		return nil, errors.New("cart line quantity must be greater than 0")
+	}
+
+	cart, err := s.openCart(ctx, cartID)
+	if err != nil {
+		return nil, err
+	}
+
+	quantity := req.Quantity
+	for _, line := range cart.Lines {
+		if line.ProductID == req.ProductID {
+			quantity += line.Quantity
+		}
+	}
+
+	return s.setLine(ctx, cart, req.ProductID, quantity)
+}
+
+// UpdateCartLine sets the quantity of a product in a cart, removing it at zero
+func (s *CartService) UpdateCartLine(ctx context.Context, cartID int, req *types.CartLineRequest) (*types.Cart, error) {
+	if req.Quantity < 0 {
+		return nil, errors.New("cart line quantity must be greater than or equal to 0")
+	}
+
+	if req.Quantity == 0 {
+		return s.RemoveCartLine(ctx, cartID, req.ProductID)
+	}
+
+	cart, err := s.openCart(ctx, cartID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.setLine(ctx, cart, req.ProductID, req.Quantity)
+}
+
+// RemoveCartLine removes a product from a cart
+func (s *CartService) RemoveCartLine(ctx context.Context, cartID int, productID int) (*types.Cart, error) {
+	if productID <= 0 {
+		return nil, errors.New("invalid product ID: must be greater than 0")
+	}
+
+	if _, err := s.openCart(ctx, cartID); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	err := s.repo.RemoveLine(ctx, cartID, productID, now.Add(s.ttl), now)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("product %d not found in cart %d", productID, cartID)
+	}
+	if err != nil {
+		return nil, s.mutationError(cartID, err)
+	}
+
+	return s.GetCart(ctx, cartID)
+}
+
+// CheckoutCart converts an open cart into a pending order atomically, returning the order
+func (s *CartService) CheckoutCart(ctx context.Context, cartID int) (*types.Order, error) {
+	cart, err := s.openCart(ctx, cartID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cart.Lines) == 0 {
+		return nil, fmt.Errorf("cart %d is empty: lines are required to check out", cartID)
+	}
+
+	req := &types.CreateOrderRequest{Customer: cart.Customer}
+	for _, line := range cart.Lines {
+		req.Lines = append(req.Lines, types.CreateOrderLineRequest{ProductID: line.ProductID, Quantity: line.Quantity})
+	}
+
+	order, err := s.orders.prepareOrder(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.orders.stock.track(ctx, orderProductIDs(order), func(ctx context.Context) error {
+		return s.repo.Checkout(ctx, cartID, order, s.now())
+	})
+	if err == repository.ErrCartNotOpen {
+		return nil, fmt.Errorf("cart %d is not active: it was checked out or has expired", cartID)
+	} else if err != nil {
+		return nil, createOrderError(err)
+	}
+
+	return order, nil
+}
+
+// ExpireStaleCarts expires every open cart past its expiry time
+func (s *CartService) ExpireStaleCarts(ctx context.Context) (int, error) {
+	n, err := s.repo.ExpireStale(ctx, s.now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire carts: %w", err)
+	}
+	return n, nil
+}
+
+// RunSweeper expires stale carts every interval until ctx is cancelled
+func (s *CartService) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.ExpireStaleCarts(ctx)
+			if err != nil {
+				fmt.Printf("cart sweeper err=%v\n", err)
+				continue
+			}
+			if n > 0 {
+				fmt.Printf("cart sweeper expired=%d\n", n)
+			}
+		}
+	}
+}
+
+// openCart retrieves a cart and checks that it can still be changed
+func (s *CartService) openCart(ctx context.Context, cartID int) (*types.Cart, error) {
+	cart, err := s.GetCart(ctx, cartID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cart.Status != types.CartOpen || !cart.ExpiresAt.After(s.now()) {
+		return nil, fmt.Errorf("cart %d is not active: it was checked out or has expired", cartID)
+	}
+
+	return cart, nil
+}
+
+// setLine checks the product has enough available stock and stores the line at its current effective price
+func (s *CartService) setLine(ctx context.Context, cart *types.Cart, productID int, quantity int) (*types.Cart, error) {
+	if productID <= 0 {
+		return nil, errors.New("invalid product ID: must be greater than 0")
+	}
+
+	product, err := s.products.GetByID(ctx, productID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("product with ID %d not found", productID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+
+	if product.Available < quantity {
+		return nil, fmt.Errorf("insufficient stock for product %d: %d available", productID, product.Available)
+	}
+
+	now := s.now()
+	if s.promotions != nil {
+		pricingCtx := WithPricingContext(ctx, types.PricingContext{At: now, Customer: cart.Customer})
+		if err := s.promotions.ApplyPricing(pricingCtx, product); err != nil {
+			return nil, err
+		}
+	}
+
+	line := &types.CartLine{
+		ProductID:  productID,
+		Quantity:   quantity,
+		AddedPrice: product.EffectivePrice,
+	}
+	if err := s.repo.SetLine(ctx, cart.ID, line, now.Add(s.ttl), now); err != nil {
+		return nil, s.mutationError(cart.ID, err)
+	}
+
+	return s.GetCart(ctx, cart.ID)
+}
+
+// mutationError maps repository errors from changing a cart into service errors
+func (s *CartService) mutationError(cartID int, err error) error {
+	if err == repository.ErrCartNotOpen {
+		return fmt.Errorf("cart %d is not active: it was checked out or has expired", cartID)
+	}
+	return fmt.Errorf("failed to update cart: %w", err)
+}
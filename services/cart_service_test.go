@@ -0,0 +1,139 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"go-circleci/repository"
+	"go-circleci/repository/repotest"
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+// cartServices is a CartService over an empty SQLite database together with
+// the repository and promotions its carts are stocked and priced from
+type cartServices struct {
+	carts      *services.CartService
+	products   repository.ProductRepository
+	promotions *services.PromotionService
+}
+
+// newCartServices returns a CartService over an empty SQLite database, placing
+// orders and pricing lines through SQLite-backed order and promotion services
+func newCartServices(t *testing.T) cartServices {
+	t.Helper()
+	db := repotest.OpenSQLite(t)
+	products := repository.NewSQLiteProductRepository(db)
+	orderRepo := repository.NewSQLiteOrderRepository(db, products)
+	orders := services.NewOrderService(orderRepo, products)
+	s := cartServices{
+		carts:      services.NewCartService(repository.NewSQLiteCartRepository(db, orderRepo), products, orders),
+		products:   products,
+		promotions: services.NewPromotionService(repository.NewSQLitePromotionRepository(db), products),
+	}
+	s.carts.SetPromotions(s.promotions)
+	orders.SetPromotions(s.promotions)
+	return s
+}
+
+func TestCartServiceCheckoutCart(t *testing.T) {
+	tests := []struct {
+		name      string
+		quantity  int
+		before    func(t *testing.T, ctx context.Context, s cartServices)
+		wantErr   string
+		wantTotal float64
+		wantStock int
+	}{
+		{"places a pending order", 3, nil, "", 7.5, 7},
+		{"prices at checkout", 4, func(t *testing.T, ctx context.Context, s cartServices) {
+			_, err := s.promotions.CreatePromotion(ctx, &types.CreatePromotionRequest{Name: "Sale", Kind: types.PromotionPercentage, Value: 20})
+			checkErr(t, err, "")
+		}, "", 8, 6},
+		{"empty cart", 0, nil, "cart 1 is empty: lines are required to check out", 0, 10},
+		{"stock sold since added", 5, func(t *testing.T, ctx context.Context, s cartServices) {
+			checkErr(t, s.products.AdjustStock(ctx, 1, -8, types.MovementSale, "till"), "")
+		}, "insufficient stock for product 1", 0, 2},
+		{"checked out already", 1, func(t *testing.T, ctx context.Context, s cartServices) {
+			_, err := s.carts.CheckoutCart(ctx, 1)
+			checkErr(t, err, "")
+		}, "cart 1 is not active: it was checked out or has expired", 0, 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newCartServices(t)
+			checkErr(t, s.products.Create(ctx, &types.Product{Name: "Widget", Price: 2.5, Stock: 10}), "")
+			cart, err := s.carts.CreateCart(ctx, &types.CreateCartRequest{Customer: "alice"})
+			checkErr(t, err, "")
+			if tt.quantity > 0 {
+				_, err := s.carts.AddCartLine(ctx, cart.ID, &types.CartLineRequest{ProductID: 1, Quantity: tt.quantity})
+				checkErr(t, err, "")
+			}
+			if tt.before != nil {
+				tt.before(t, ctx, s)
+			}
+
+			order, err := s.carts.CheckoutCart(ctx, cart.ID)
+			checkErr(t, err, tt.wantErr)
+			if err == nil {
+				if order.Status != types.OrderPending || order.Customer != "alice" {
+					t.Errorf("order is %s for %q, want pending for alice", order.Status, order.Customer)
+				}
+				if order.Total != tt.wantTotal {
+					t.Errorf("order total = %v, want %v", order.Total, tt.wantTotal)
+				}
+				got, err := s.carts.GetCart(ctx, cart.ID)
+				checkErr(t, err, "")
+				if got.Status != types.CartCheckedOut || got.OrderID == nil || *got.OrderID != order.ID {
+					t.Errorf("cart is %s with order %v, want checked out with order %d", got.Status, got.OrderID, order.ID)
+				}
+			}
+			checkStock(t, s.products, 1, tt.wantStock)
+		})
+	}
+}
+
+func TestCartServiceReprice(t *testing.T) {
+	tests := []struct {
+		name        string
+		promotion   *types.CreatePromotionRequest
+		wantPrice   float64
+		wantChanged bool
+		wantTotal   float64
+	}{
+		{"no promotion", nil, 10, false, 20},
+		{"percentage promotion since added", &types.CreatePromotionRequest{Name: "Sale", Kind: types.PromotionPercentage, Value: 25}, 7.5, true, 15},
+		{"fixed promotion for the customer", &types.CreatePromotionRequest{Name: "Loyalty", Kind: types.PromotionFixed, Value: 3, Customer: "alice"}, 7, true, 14},
+		{"promotion for another customer", &types.CreatePromotionRequest{Name: "Loyalty", Kind: types.PromotionFixed, Value: 3, Customer: "bob"}, 10, false, 20},
+		{"coupon promotion", &types.CreatePromotionRequest{Name: "Voucher", Kind: types.PromotionPercentage, Value: 50, CouponCode: "HALF"}, 10, false, 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newCartServices(t)
+			checkErr(t, s.products.Create(ctx, &types.Product{Name: "Widget", Price: 10, Stock: 10}), "")
+			cart, err := s.carts.CreateCart(ctx, &types.CreateCartRequest{Customer: "alice"})
+			checkErr(t, err, "")
+			_, err = s.carts.AddCartLine(ctx, cart.ID, &types.CartLineRequest{ProductID: 1, Quantity: 2})
+			checkErr(t, err, "")
+			if tt.promotion != nil {
+				_, err := s.promotions.CreatePromotion(ctx, tt.promotion)
+				checkErr(t, err, "")
+			}
+
+			got, err := s.carts.GetCart(ctx, cart.ID)
+			checkErr(t, err, "")
+			line := got.Lines[0]
+			if line.AddedPrice != 10 || line.UnitPrice != tt.wantPrice || line.PriceChanged != tt.wantChanged {
+				t.Errorf("line added at %v is priced %v (changed %v), want added at 10 and priced %v (changed %v)",
+					line.AddedPrice, line.UnitPrice, line.PriceChanged, tt.wantPrice, tt.wantChanged)
+			}
+			if got.Total != tt.wantTotal {
+				t.Errorf("cart total = %v, want %v", got.Total, tt.wantTotal)
+			}
+		})
+	}
+}
@@ -3,19 +3,63 @@ package services
 import (
 	"context"
 	"go-circleci/types"
+	"time"
 )
 
-// CompositeService wraps both CatFactService and ProductService to implement the full Service interface
+// CompositeService wraps the CatFact, Product, Reservation, Inventory, LowStock, Order, Cart, Promotion, Tax, Backup, Audit, Outbox, Webhook and event stream services to implement the full Service interface
 type CompositeService struct {
-	catFactService *CatFactService
-	productService *ProductService
+	catFactService     *CatFactService
+	productService     *ProductService
+	reservationService *ReservationService
+	inventoryService   *InventoryService
+	lowStockService    *LowStockService
+	orderService       *OrderService
+	cartService        *CartService
+	promotionService   *PromotionService
+	taxService         *TaxService
+	backupService      *BackupService
+	auditService       *AuditService
+	outboxService      *OutboxService
+	webhookService     *WebhookService
+	eventBroker        *EventBroker
 }
 
-// NewCompositeService creates a new CompositeService with both CatFact and Product capabilities
-func NewCompositeService(catFactService *CatFactService, productService *ProductService) Service {
+// CompositeOptions are the services a CompositeService delegates to. Services
+// left nil are not available, and calling their operations panics.
+type CompositeOptions struct {
+	CatFact     *CatFactService
+	Product     *ProductService
+	Reservation *ReservationService
+	Inventory   *InventoryService
+	LowStock    *LowStockService
+	Order       *OrderService
+	Cart        *CartService
+	Promotion   *PromotionService
+	Tax         *TaxService
+	Backup      *BackupService
+	Audit       *AuditService
+	Outbox      *OutboxService
+	Webhook     *WebhookService
+	EventBroker *EventBroker
+}
+
+// NewCompositeService creates a new CompositeService delegating to the given services
+func NewCompositeService(opts CompositeOptions) Service {
 	return &CompositeService{
-		catFactService: catFactService,
-		productService: productService,
+		catFactService:     opts.CatFact,
+		productService:     opts.Product,
+		reservationService: opts.Reservation,
+		inventoryService:   opts.Inventory,
+		lowStockService:    opts.LowStock,
+		orderService:       opts.Order,
+		cartService:        opts.Cart,
+		promotionService:   opts.Promotion,
+		taxService:         opts.Tax,
+		backupService:      opts.Backup,
+		auditService:       opts.Audit,
+		outboxService:      opts.Outbox,
+		webhookService:     opts.Webhook,
+		eventBroker:        opts.EventBroker,
 	}
 }
 
@@ -34,6 +78,16 @@ func (s *CompositeService) GetProductByID(ctx context.Context, id int) (*types.P
 	return s.productService.GetProductByID(ctx, id)
 }
 
+// GetProductsByIDs delegates to the ProductService
+func (s *CompositeService) GetProductsByIDs(ctx context.Context, ids []int) ([]*types.Product, error) {
+	return s.productService.GetProductsByIDs(ctx, ids)
+}
+
+// ListProductsPage delegates to the ProductService
+func (s *CompositeService) ListProductsPage(ctx context.Context, filter types.ProductFilter, after, limit int) (*types.ProductPage, error) {
+	return s.productService.ListProductsPage(ctx, filter, after, limit)
+}
+
 // CreateProduct delegates to the ProductService
 func (s *CompositeService) CreateProduct(ctx context.Context, req *types.CreateProductRequest) (*types.Product, error) {
 	return s.productService.CreateProduct(ctx, req)
@@ -48,3 +102,283 @@ func (s *CompositeService) UpdateProduct(ctx context.Context, id int, req *types
 func (s *CompositeService) DeleteProduct(ctx context.Context, id int) error {
 	return s.productService.DeleteProduct(ctx, id)
 }
+
+// AdjustStock delegates to the ProductService
+func (s *CompositeService) AdjustStock(ctx context.Context, id, delta int) (*types.Product, error) {
+	return s.productService.AdjustStock(ctx, id, delta)
+}
+
+// ListDeletedProducts delegates to the ProductService
+func (s *CompositeService) ListDeletedProducts(ctx context.Context) ([]*types.Product, error) {
+	return s.productService.ListDeletedProducts(ctx)
+}
+
+// RestoreProduct delegates to the ProductService
+func (s *CompositeService) RestoreProduct(ctx context.Context, id int) (*types.Product, error) {
+	return s.productService.RestoreProduct(ctx, id)
+}
+
+// PurgeProduct delegates to the ProductService
+func (s *CompositeService) PurgeProduct(ctx context.Context, id int) error {
+	return s.productService.PurgeProduct(ctx, id)
+}
+
+// GetProductAsOf delegates to the ProductService
+func (s *CompositeService) GetProductAsOf(ctx context.Context, id int, at time.Time) (*types.Product, error) {
+	return s.productService.GetProductAsOf(ctx, id, at)
+}
+
+// GetAllProductsAsOf delegates to the ProductService
+func (s *CompositeService) GetAllProductsAsOf(ctx context.Context, at time.Time) ([]*types.Product, error) {
+	return s.productService.GetAllProductsAsOf(ctx, at)
+}
+
+// ListProductVersions delegates to the ProductService
+func (s *CompositeService) ListProductVersions(ctx context.Context, id int) ([]*types.ProductVersion, error) {
+	return s.productService.ListProductVersions(ctx, id)
+}
+
+// DiffProductVersions delegates to the ProductService
+func (s *CompositeService) DiffProductVersions(ctx context.Context, id, from, to int) (*types.ProductVersionDiff, error) {
+	return s.productService.DiffProductVersions(ctx, id, from, to)
+}
+
+// BatchProducts delegates to the ProductService
+func (s *CompositeService) BatchProducts(ctx context.Context, req *types.ProductBatchRequest) (*types.ProductBatchResponse, error) {
+	return s.productService.BatchProducts(ctx, req)
+}
+
+// ImportProducts delegates to the ProductService
+func (s *CompositeService) ImportProducts(ctx context.Context, rows []*types.ProductImportRow, dryRun bool) ([]types.ImportRowResult, error) {
+	return s.productService.ImportProducts(ctx, rows, dryRun)
+}
+
+// ExportProducts delegates to the ProductService
+func (s *CompositeService) ExportProducts(ctx context.Context, fn func(product *types.Product) error) error {
+	return s.productService.ExportProducts(ctx, fn)
+}
+
+// CreateReservation delegates to the ReservationService
+func (s *CompositeService) CreateReservation(ctx context.Context, req *types.CreateReservationRequest) (*types.Reservation, error) {
+	return s.reservationService.CreateReservation(ctx, req)
+}
+
+// GetReservation delegates to the ReservationService
+func (s *CompositeService) GetReservation(ctx context.Context, id int) (*types.Reservation, error) {
+	return s.reservationService.GetReservation(ctx, id)
+}
+
+// ConfirmReservation delegates to the ReservationService
+func (s *CompositeService) ConfirmReservation(ctx context.Context, id int) (*types.Reservation, error) {
+	return s.reservationService.ConfirmReservation(ctx, id)
+}
+
+// ReleaseReservation delegates to the ReservationService
+func (s *CompositeService) ReleaseReservation(ctx context.Context, id int) (*types.Reservation, error) {
+	return s.reservationService.ReleaseReservation(ctx, id)
+}
+
+// ListLocations delegates to the InventoryService
+func (s *CompositeService) ListLocations(ctx context.Context) ([]*types.Location, error) {
+	return s.inventoryService.ListLocations(ctx)
+}
+
+// CreateLocation delegates to the InventoryService
+func (s *CompositeService) CreateLocation(ctx context.Context, req *types.CreateLocationRequest) (*types.Location, error) {
+	return s.inventoryService.CreateLocation(ctx, req)
+}
+
+// GetProductStock delegates to the InventoryService
+func (s *CompositeService) GetProductStock(ctx context.Context, productID int) (*types.ProductStock, error) {
+	return s.inventoryService.GetProductStock(ctx, productID)
+}
+
+// TransferStock delegates to the InventoryService
+func (s *CompositeService) TransferStock(ctx context.Context, req *types.StockTransferRequest) (*types.StockTransfer, error) {
+	return s.inventoryService.TransferStock(ctx, req)
+}
+
+// GetLowStockReport delegates to the LowStockService
+func (s *CompositeService) GetLowStockReport(ctx context.Context) ([]*types.LowStockItem, error) {
+	return s.lowStockService.GetLowStockReport(ctx)
+}
+
+// CreateOrder delegates to the OrderService
+func (s *CompositeService) CreateOrder(ctx context.Context, req *types.CreateOrderRequest) (*types.Order, error) {
+	return s.orderService.CreateOrder(ctx, req)
+}
+
+// GetOrder delegates to the OrderService
+func (s *CompositeService) GetOrder(ctx context.Context, id int) (*types.Order, error) {
+	return s.orderService.GetOrder(ctx, id)
+}
+
+// ListOrders delegates to the OrderService
+func (s *CompositeService) ListOrders(ctx context.Context, filter types.OrderFilter) ([]*types.Order, error) {
+	return s.orderService.ListOrders(ctx, filter)
+}
+
+// PayOrder delegates to the OrderService
+func (s *CompositeService) PayOrder(ctx context.Context, id int) (*types.Order, error) {
+	return s.orderService.PayOrder(ctx, id)
+}
+
+// FulfilOrder delegates to the OrderService
+func (s *CompositeService) FulfilOrder(ctx context.Context, id int) (*types.Order, error) {
+	return s.orderService.FulfilOrder(ctx, id)
+}
+
+// CancelOrder delegates to the OrderService
+func (s *CompositeService) CancelOrder(ctx context.Context, id int) (*types.Order, error) {
+	return s.orderService.CancelOrder(ctx, id)
+}
+
+// RefundOrder delegates to the OrderService
+func (s *CompositeService) RefundOrder(ctx context.Context, id int) (*types.Order, error) {
+	return s.orderService.RefundOrder(ctx, id)
+}
+
+// CreateCart delegates to the CartService
+func (s *CompositeService) CreateCart(ctx context.Context, req *types.CreateCartRequest) (*types.Cart, error) {
+	return s.cartService.CreateCart(ctx, req)
+}
+
+// GetCart delegates to the CartService
+func (s *CompositeService) GetCart(ctx context.Context, id int) (*types.Cart, error) {
+	return s.cartService.GetCart(ctx, id)
+}
+
+// AddCartLine delegates to the CartService
+func (s *CompositeService) AddCartLine(ctx context.Context, cartID int, req *types.CartLineRequest) (*types.Cart, error) {
+	return s.cartService.AddCartLine(ctx, cartID, req)
+}
+
+// UpdateCartLine delegates to the CartService
+func (s *CompositeService) UpdateCartLine(ctx context.Context, cartID int, req *types.CartLineRequest) (*types.Cart, error) {
+	return s.cartService.UpdateCartLine(ctx, cartID, req)
+}
+
+// RemoveCartLine delegates to the CartService
+func (s *CompositeService) RemoveCartLine(ctx context.Context, cartID int, productID int) (*types.Cart, error) {
+	return s.cartService.RemoveCartLine(ctx, cartID, productID)
+}
+
+// CheckoutCart delegates to the CartService
+func (s *CompositeService) CheckoutCart(ctx context.Context, cartID int) (*types.Order, error) {
+	return s.cartService.CheckoutCart(ctx, cartID)
+}
+
+// ListPromotions delegates to the PromotionService
+func (s *CompositeService) ListPromotions(ctx context.Context) ([]*types.Promotion, error) {
+	return s.promotionService.ListPromotions(ctx)
+}
+
+// GetPromotion delegates to the PromotionService
+func (s *CompositeService) GetPromotion(ctx context.Context, id int) (*types.Promotion, error) {
+	return s.promotionService.GetPromotion(ctx, id)
+}
+
+// CreatePromotion delegates to the PromotionService
+func (s *CompositeService) CreatePromotion(ctx context.Context, req *types.CreatePromotionRequest) (*types.Promotion, error) {
+	return s.promotionService.CreatePromotion(ctx, req)
+}
+
+// DeletePromotion delegates to the PromotionService
+func (s *CompositeService) DeletePromotion(ctx context.Context, id int) error {
+	return s.promotionService.DeletePromotion(ctx, id)
+}
+
+// ValidateCoupon delegates to the PromotionService
+func (s *CompositeService) ValidateCoupon(ctx context.Context, req *types.ValidateCouponRequest) (*types.CouponValidation, error) {
+	return s.promotionService.ValidateCoupon(ctx, req)
+}
+
+// ListTaxRules delegates to the TaxService
+func (s *CompositeService) ListTaxRules(ctx context.Context) ([]*types.TaxRule, error) {
+	return s.taxService.ListTaxRules(ctx)
+}
+
+// CreateTaxRule delegates to the TaxService
+func (s *CompositeService) CreateTaxRule(ctx context.Context, req *types.CreateTaxRuleRequest) (*types.TaxRule, error) {
+	return s.taxService.CreateTaxRule(ctx, req)
+}
+
+// DeleteTaxRule delegates to the TaxService
+func (s *CompositeService) DeleteTaxRule(ctx context.Context, id int) error {
+	return s.taxService.DeleteTaxRule(ctx, id)
+}
+
+// CreateBackup delegates to the BackupService
+func (s *CompositeService) CreateBackup(ctx context.Context) (*types.Backup, error) {
+	return s.backupService.CreateBackup(ctx)
+}
+
+// ListBackups delegates to the BackupService
+func (s *CompositeService) ListBackups(ctx context.Context) ([]*types.Backup, error) {
+	return s.backupService.ListBackups(ctx)
+}
+
+// ListAuditEntries delegates to the AuditService
+func (s *CompositeService) ListAuditEntries(ctx context.Context, filter types.AuditFilter) ([]*types.AuditEntry, error) {
+	return s.auditService.ListAuditEntries(ctx, filter)
+}
+
+// GetProductHistory delegates to the AuditService
+func (s *CompositeService) GetProductHistory(ctx context.Context, productID int) ([]*types.AuditEntry, error) {
+	return s.auditService.GetProductHistory(ctx, productID)
+}
+
+// VerifyAuditLog delegates to the AuditService
+func (s *CompositeService) VerifyAuditLog(ctx context.Context) (*types.AuditVerification, error) {
+	return s.auditService.VerifyAuditLog(ctx)
+}
+
+// ListOutbox delegates to the OutboxService
+func (s *CompositeService) ListOutbox(ctx context.Context, status string, limit int) ([]*types.OutboxEntry, error) {
+	return s.outboxService.ListOutbox(ctx, status, limit)
+}
+
+// RetryOutboxEvent delegates to the OutboxService
+func (s *CompositeService) RetryOutboxEvent(ctx context.Context, id int) error {
+	return s.outboxService.RetryOutboxEvent(ctx, id)
+}
+
+// ListWebhooks delegates to the WebhookService
+func (s *CompositeService) ListWebhooks(ctx context.Context) ([]*types.WebhookSubscription, error) {
+	return s.webhookService.ListWebhooks(ctx)
+}
+
+// GetWebhook delegates to the WebhookService
+func (s *CompositeService) GetWebhook(ctx context.Context, id int) (*types.WebhookSubscription, error) {
+	return s.webhookService.GetWebhook(ctx, id)
+}
+
+// CreateWebhook delegates to the WebhookService
+func (s *CompositeService) CreateWebhook(ctx context.Context, req *types.CreateWebhookRequest) (*types.WebhookSubscription, error) {
+	return s.webhookService.CreateWebhook(ctx, req)
+}
+
+// UpdateWebhook delegates to the WebhookService
+func (s *CompositeService) UpdateWebhook(ctx context.Context, id int, req *types.UpdateWebhookRequest) (*types.WebhookSubscription, error) {
+	return s.webhookService.UpdateWebhook(ctx, id, req)
+}
+
+// DeleteWebhook delegates to the WebhookService
+func (s *CompositeService) DeleteWebhook(ctx context.Context, id int) error {
+	return s.webhookService.DeleteWebhook(ctx, id)
+}
+
+// ListWebhookDeliveries delegates to the WebhookService
+func (s *CompositeService) ListWebhookDeliveries(ctx context.Context, id, limit int) ([]*types.WebhookDelivery, error) {
+	return s.webhookService.ListWebhookDeliveries(ctx, id, limit)
+}
+
+// RedeliverWebhook delegates to the WebhookService
+func (s *CompositeService) RedeliverWebhook(ctx context.Context, id, deliveryID int) (*types.WebhookDelivery, error) {
+	return s.webhookService.RedeliverWebhook(ctx, id, deliveryID)
+}
+
+// SubscribeProductEvents delegates to the EventBroker
+func (s *CompositeService) SubscribeProductEvents(ctx context.Context, filter types.ProductEventFilter, lastEventID string) (*types.EventStream, error) {
+	return s.eventBroker.SubscribeProductEvents(ctx, filter, lastEventID)
+}
@@ -1,15 +1,24 @@
 package services
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
 
-// InitDatabase initializes a SQLite database connection
-func InitDatabase(dbPath string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite", dbPath)
+// DriverSQLite is the database/sql driver SQLite databases are opened with
+const DriverSQLite = "sqlite"
+
+// InitDatabase initializes a SQLite database connection, with the pragmas of
+// DefaultSQLiteOptions on every connection
+func InitDatabase(dsn string) (*sql.DB, error) {
+	db, err := sql.Open(DriverSQLite, DefaultSQLiteOptions().writeDSN(dsn))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -22,6 +31,147 @@ func InitDatabase(dbPath string) (*sql.DB, error) {
 	return db, nil
 }
 
+// SQLiteOptions configures the pragmas and connection pools of a SQLite database
+type SQLiteOptions struct {
+	// JournalMode is the journal_mode pragma. WAL lets readers run alongside the writer.
+	JournalMode string
+	// Synchronous is the synchronous pragma. NORMAL is durable in WAL mode
+	// except for the last transactions before a power loss.
+	Synchronous string
+	// BusyTimeout is how long a connection waits for a lock before failing with SQLITE_BUSY
+	BusyTimeout time.Duration
+	// ForeignKeys enables foreign key enforcement. The schema relies on it being
+	// off: deleted products keep their orders, reservations and stock ledger.
+	ForeignKeys bool
+	// CacheSize is the cache_size pragma: pages if positive, KiB if negative
+	CacheSize int
+	// MaxReadConns limits the read pool. The write pool always has a single
+	// connection, since SQLite allows one writer at a time.
+	MaxReadConns int
+	// ConnMaxIdleTime closes connections idle for longer, if positive
+	ConnMaxIdleTime time.Duration
+}
+
+// DefaultSQLiteOptions returns the options used unless configured otherwise
+func DefaultSQLiteOptions() SQLiteOptions {
+	return SQLiteOptions{
+		JournalMode:     "WAL",
+		Synchronous:     "NORMAL",
+		BusyTimeout:     5 * time.Second,
+		ForeignKeys:     false,
+		CacheSize:       -20000,
+		MaxReadConns:    4,
+		ConnMaxIdleTime: 5 * time.Minute,
+	}
+}
+
+// pragmas returns the _pragma DSN parameters applied to every connection. The
+// busy timeout comes first so the other pragmas can wait for locks.
+func (o SQLiteOptions) pragmas() []string {
+	foreignKeys := 0
+	if o.ForeignKeys {
+		foreignKeys = 1
+	}
+	return []string{
+		fmt.Sprintf("busy_timeout(%d)", o.BusyTimeout.Milliseconds()),
+		fmt.Sprintf("synchronous(%s)", o.Synchronous),
+		fmt.Sprintf("foreign_keys(%d)", foreignKeys),
+		fmt.Sprintf("cache_size(%d)", o.CacheSize),
+	}
+}
+
+// writeDSN adds the pragmas of the write pool to dsn, including the journal
+// mode, which is a property of the database file and is set by the writer
+func (o SQLiteOptions) writeDSN(dsn string) string {
+	return withPragmas(dsn, append(o.pragmas(), fmt.Sprintf("journal_mode(%s)", o.JournalMode)))
+}
+
+// readDSN adds the pragmas of the read pool to dsn. Its connections refuse writes.
+func (o SQLiteOptions) readDSN(dsn string) string {
+	return withPragmas(dsn, append(o.pragmas(), "query_only(1)"))
+}
+
+// withPragmas appends _pragma parameters to a SQLite DSN
+func withPragmas(dsn string, pragmas []string) string {
+	params := make([]string, len(pragmas))
+	for i, pragma := range pragmas {
+		params[i] = "_pragma=" + url.QueryEscape(pragma)
+	}
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + strings.Join(params, "&")
+}
+
+// SQLiteDatabase is a SQLite database opened with separate pools: Write has a
+// single connection, so writers queue in Go instead of failing on the database
+// lock, and Read serves queries outside transactions
+type SQLiteDatabase struct {
+	Write *sql.DB
+	Read  *sql.DB
+}
+
+// OpenSQLite opens the SQLite database at dsn with the given options. dsn must
+// name a file: the pools would otherwise open separate in-memory databases.
+func OpenSQLite(dsn string, opts SQLiteOptions) (*SQLiteDatabase, error) {
+	write, err := sql.Open(DriverSQLite, opts.writeDSN(dsn))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	write.SetMaxOpenConns(1)
+	write.SetMaxIdleConns(1)
+	write.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+
+	// Connect the writer first so the journal mode is set before readers open the file
+	if err := write.Ping(); err != nil {
+		write.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	read, err := sql.Open(DriverSQLite, opts.readDSN(dsn))
+	if err != nil {
+		write.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	read.SetMaxOpenConns(opts.MaxReadConns)
+	read.SetMaxIdleConns(opts.MaxReadConns)
+	read.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+
+	if err := read.Ping(); err != nil {
+		write.Close()
+		read.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return &SQLiteDatabase{Write: write, Read: read}, nil
+}
+
+// Close closes both pools
+func (d *SQLiteDatabase) Close() error {
+	return errors.Join(d.Read.Close(), d.Write.Close())
+}
+
+// Settings returns the effective value of the tuned pragmas on a connection of
+// the write pool, together with the pool limits
+func (d *SQLiteDatabase) Settings(ctx context.Context) (string, error) {
+	var settings []string
+	for _, pragma := range []string{"journal_mode", "synchronous", "busy_timeout", "foreign_keys", "cache_size"} {
+		var value string
+		if err := d.Write.QueryRowContext(ctx, "PRAGMA "+pragma).Scan(&value); err != nil {
+			return "", fmt.Errorf("failed to read pragma %s: %w", pragma, err)
+		}
+		settings = append(settings, pragma+"="+value)
+	}
+
+	settings = append(settings,
+		fmt.Sprintf("write_conns=%d", d.Write.Stats().MaxOpenConnections),
+		fmt.Sprintf("read_conns=%d", d.Read.Stats().MaxOpenConnections),
+	)
+	return strings.Join(settings, " "), nil
+}
+
 // CreateProductsTable creates the products table if it doesn't exist
 func CreateProductsTable(db *sql.DB) error {
 	query := `
@@ -0,0 +1,41 @@
+package services_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go-circleci/repository/repotest"
+	"go-circleci/services"
+)
+
+func TestOpenSQLiteAppliesPragmas(t *testing.T) {
+	dsn := repotest.SQLiteDSN(t)
+	db, err := services.OpenSQLite(dsn, services.DefaultSQLiteOptions())
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	settings, err := db.Settings(ctx)
+	if err != nil {
+		t.Fatalf("Settings: %v", err)
+	}
+	for _, want := range []string{"journal_mode=wal", "busy_timeout=5000", "foreign_keys=0", "write_conns=1", "read_conns=4"} {
+		if !strings.Contains(settings, want) {
+			t.Errorf("Settings = %q, want it to contain %q", settings, want)
+		}
+	}
+
+	if _, err := db.Write.ExecContext(ctx, `CREATE TABLE items (id INTEGER PRIMARY KEY)`); err != nil {
+		t.Fatalf("write pool: %v", err)
+	}
+	if _, err := db.Read.ExecContext(ctx, `INSERT INTO items (id) VALUES (1)`); err == nil {
+		t.Error("read pool accepted a write")
+	}
+	var count int
+	if err := db.Read.QueryRowContext(ctx, `SELECT COUNT(*) FROM items`).Scan(&count); err != nil {
+		t.Errorf("read pool: %v", err)
+	}
+}
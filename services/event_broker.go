@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+
+	"go-circleci/types"
+)
+
+// eventSubscriberBuffer is how many events a subscriber may fall behind before it is dropped
+const eventSubscriberBuffer = 64
+
+// eventSubscriber is a live subscription to the broker
+type eventSubscriber struct {
+	filter types.ProductEventFilter
+	ch     chan *types.Event
+}
+
+// matches reports whether event passes the subscriber's filter
+func (s *eventSubscriber) matches(event *types.Event) bool {
+	if len(s.filter.ProductIDs) > 0 && !slices.Contains(s.filter.ProductIDs, event.ProductID) {
+		return false
+	}
+	return len(s.filter.Types) == 0 || slices.Contains(s.filter.Types, event.Type)
+}
+
+// EventBroker fans product events out to live subscribers, such as the
+// dashboard's event stream, and keeps the latest ones so that a client that
+// reconnects can resume where it left off. It is an outbox Publisher, so it
+// only sees committed changes.
+type EventBroker struct {
+	mu     sync.Mutex
+	size   int
+	buffer []*types.Event
+	ids    map[int]bool
+	subs   map[*eventSubscriber]bool
+}
+
+// NewEventBroker creates a broker that keeps the latest size events for resuming
+func NewEventBroker(size int) *EventBroker {
+	return &EventBroker{
+		size: size,
+		ids:  make(map[int]bool),
+		subs: make(map[*eventSubscriber]bool),
+	}
+}
+
+// Name returns "sse"
+func (b *EventBroker) Name() string { return "sse" }
+
+// Publish buffers the event and sends it to every subscriber whose filter
+// matches. A subscriber too far behind to take it is dropped, and can resume
+// from the buffer when it reconnects. Events already buffered are ignored.
+func (b *EventBroker) Publish(ctx context.Context, event *types.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.ids[event.ID] {
+		return nil
+	}
+	b.buffer = append(b.buffer, event)
+	b.ids[event.ID] = true
+	if len(b.buffer) > b.size {
+		delete(b.ids, b.buffer[0].ID)
+		b.buffer = slices.Delete(b.buffer, 0, 1)
+	}
+
+	for sub := range b.subs {
+		if !sub.matches(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			b.remove(sub)
+		}
+	}
+	return nil
+}
+
+// remove ends a subscription. The caller must hold b.mu.
+func (b *EventBroker) remove(sub *eventSubscriber) {
+	if b.subs[sub] {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// SubscribeProductEvents subscribes to the events matching filter until ctx is
+// done. If lastEventID is set, the buffered events after it are returned as
+// the backlog.
+func (b *EventBroker) SubscribeProductEvents(ctx context.Context, filter types.ProductEventFilter, lastEventID string) (*types.EventStream, error) {
+	for _, id := range filter.ProductIDs {
+		if id <= 0 {
+			return nil, errors.New("invalid product ID: must be greater than 0")
+		}
+	}
+	for _, eventType := range filter.Types {
+		switch eventType {
+		case types.EventProductCreated, types.EventProductUpdated, types.EventProductDeleted, types.EventStockChanged:
+		default:
+			return nil, fmt.Errorf("invalid event type %q", eventType)
+		}
+	}
+
+	after := 0
+	if lastEventID != "" {
+		id, err := strconv.Atoi(lastEventID)
+		if err != nil || id <= 0 {
+			return nil, errors.New("invalid last event ID: must be a positive integer")
+		}
+		after = id
+	}
+
+	sub := &eventSubscriber{filter: filter, ch: make(chan *types.Event, eventSubscriberBuffer)}
+	stream := &types.EventStream{Backlog: []*types.Event{}, Events: sub.ch}
+
+	b.mu.Lock()
+	if after > 0 {
+		// Events can be relayed out of ID order, so resume from the position
+		// of the last event in the buffer rather than from the IDs above it
+		i := slices.IndexFunc(b.buffer, func(e *types.Event) bool { return e.ID == after })
+		if i < 0 {
+			stream.Reset = true
+		} else {
+			for _, event := range b.buffer[i+1:] {
+				if sub.matches(event) {
+					stream.Backlog = append(stream.Backlog, event)
+				}
+			}
+		}
+	}
+	b.subs[sub] = true
+	b.mu.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(sub)
+	})
+	return stream, nil
+}
+
+// Subscribers returns how many subscriptions are live
+func (b *EventBroker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
@@ -0,0 +1,106 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+// publishEvents publishes an event of the given type about product i+1 for
+// each type, with IDs counting up from firstID
+func publishEvents(t *testing.T, broker *services.EventBroker, firstID int, eventTypes ...string) {
+	t.Helper()
+	for i, eventType := range eventTypes {
+		event := &types.Event{ID: firstID + i, Type: eventType, ProductID: i + 1}
+		checkErr(t, broker.Publish(context.Background(), event), "")
+	}
+}
+
+func TestEventBrokerFiltersAndResumes(t *testing.T) {
+	broker := services.NewEventBroker(3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := broker.SubscribeProductEvents(ctx, types.ProductEventFilter{Types: []string{types.EventStockChanged}}, "")
+	checkErr(t, err, "")
+	publishEvents(t, broker, 1, types.EventProductCreated, types.EventStockChanged, types.EventProductUpdated, types.EventStockChanged)
+	// A relayed event already buffered is not sent again
+	publishEvents(t, broker, 2, types.EventStockChanged)
+
+	for _, want := range []int{2, 4} {
+		select {
+		case event := <-stream.Events:
+			if event.ID != want {
+				t.Fatalf("got event %d, want %d", event.ID, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no event %d", want)
+		}
+	}
+	select {
+	case event := <-stream.Events:
+		t.Fatalf("got unexpected event %+v", event)
+	default:
+	}
+
+	// Resuming after a buffered event returns the matching events since
+	resumed, err := broker.SubscribeProductEvents(ctx, types.ProductEventFilter{ProductIDs: []int{3, 4}}, "2")
+	checkErr(t, err, "")
+	if resumed.Reset || len(resumed.Backlog) != 2 || resumed.Backlog[0].ID != 3 || resumed.Backlog[1].ID != 4 {
+		t.Errorf("resumed stream = %+v, want events 3 and 4", resumed)
+	}
+
+	// Event 1 has left the buffer of 3, so the client must reload
+	reset, err := broker.SubscribeProductEvents(ctx, types.ProductEventFilter{}, "1")
+	checkErr(t, err, "")
+	if !reset.Reset || len(reset.Backlog) != 0 {
+		t.Errorf("stream resumed after a dropped event = %+v, want a reset", reset)
+	}
+
+	_, err = broker.SubscribeProductEvents(ctx, types.ProductEventFilter{Types: []string{"OrderPlaced"}}, "")
+	checkErr(t, err, "invalid event type")
+	_, err = broker.SubscribeProductEvents(ctx, types.ProductEventFilter{}, "abc")
+	checkErr(t, err, "invalid last event ID")
+}
+
+func TestEventBrokerDropsSlowAndCancelledSubscribers(t *testing.T) {
+	broker := services.NewEventBroker(1000)
+
+	slow, err := broker.SubscribeProductEvents(context.Background(), types.ProductEventFilter{}, "")
+	checkErr(t, err, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled, err := broker.SubscribeProductEvents(ctx, types.ProductEventFilter{}, "")
+	checkErr(t, err, "")
+	if n := broker.Subscribers(); n != 2 {
+		t.Fatalf("Subscribers = %d, want 2", n)
+	}
+
+	cancel()
+	for deadline := time.Now().Add(time.Second); broker.Subscribers() != 1; {
+		if time.Now().After(deadline) {
+			t.Fatalf("cancelled subscriber was not removed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := <-cancelled.Events; ok {
+		t.Errorf("cancelled stream is still open")
+	}
+
+	// A subscriber that does not keep up is dropped rather than blocking the broker
+	for i := 1; i <= 100; i++ {
+		publishEvents(t, broker, i, types.EventProductUpdated)
+	}
+	if n := broker.Subscribers(); n != 0 {
+		t.Fatalf("Subscribers = %d after overflowing, want 0", n)
+	}
+	received := 0
+	for range slow.Events {
+		received++
+	}
+	if received == 0 || received >= 100 {
+		t.Errorf("slow subscriber received %d events before being dropped", received)
+	}
+}
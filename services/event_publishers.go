@@ -0,0 +1,216 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-circleci/types"
+)
+
+// Publisher delivers domain events from the outbox to a downstream system.
+// Events may be delivered more than once, so consumers should deduplicate by event ID.
+type Publisher interface {
+	// Name identifies the publisher in the outbox's delivery records, so it
+	// must stay the same across restarts
+	Name() string
+	Publish(ctx context.Context, event *types.Event) error
+}
+
+// LogPublisher prints events to stdout alongside the service logs
+type LogPublisher struct{}
+
+// Name returns "log"
+func (LogPublisher) Name() string { return "log" }
+
+// Publish prints the event
+func (LogPublisher) Publish(ctx context.Context, event *types.Event) error {
+	fmt.Printf("EVENT id=%d type=%s product_id=%d payload=%s\n", event.ID, event.Type, event.ProductID, event.Payload)
+	return nil
+}
+
+// WebhookPublisher posts events as JSON to a URL
+type WebhookPublisher struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookPublisher creates a publisher that posts events to url
+func NewWebhookPublisher(url string) *WebhookPublisher {
+	return &WebhookPublisher{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns "webhook"
+func (p *WebhookPublisher) Name() string { return "webhook" }
+
+// Publish posts the event and fails on any non-2xx response
+func (p *WebhookPublisher) Publish(ctx context.Context, event *types.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", strconv.Itoa(event.ID))
+	req.Header.Set("X-Event-Type", event.Type)
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// NATSConn is the part of a NATS connection the NATS publisher uses. A
+// *nats.Conn satisfies it.
+type NATSConn interface {
+	Publish(subject string, data []byte) error
+}
+
+// NATSPublisher publishes events as JSON to the subject "<prefix>.<event type>"
+type NATSPublisher struct {
+	conn   NATSConn
+	prefix string
+}
+
+// NewNATSPublisher creates a publisher on conn under the given subject prefix
+func NewNATSPublisher(conn NATSConn, prefix string) *NATSPublisher {
+	return &NATSPublisher{conn: conn, prefix: prefix}
+}
+
+// Name returns "nats"
+func (p *NATSPublisher) Name() string { return "nats" }
+
+// Publish sends the event to its subject
+func (p *NATSPublisher) Publish(ctx context.Context, event *types.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.conn.Publish(p.prefix+"."+event.Type, data)
+}
+
+// KafkaProducer is the part of a Kafka client the Kafka publisher uses, to be
+// adapted from a client library
+type KafkaProducer interface {
+	Produce(ctx context.Context, topic string, key, value []byte) error
+}
+
+// KafkaPublisher publishes events as JSON to a topic, keyed by product ID so
+// that the events of a product land in one partition and keep their order
+type KafkaPublisher struct {
+	producer KafkaProducer
+	topic    string
+}
+
+// NewKafkaPublisher creates a publisher producing to topic
+func NewKafkaPublisher(producer KafkaProducer, topic string) *KafkaPublisher {
+	return &KafkaPublisher{producer: producer, topic: topic}
+}
+
+// Name returns "kafka"
+func (p *KafkaPublisher) Name() string { return "kafka" }
+
+// Publish produces the event to the topic
+func (p *KafkaPublisher) Publish(ctx context.Context, event *types.Event) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.producer.Produce(ctx, p.topic, []byte(strconv.Itoa(event.ProductID)), value)
+}
+
+// InProcessNATS is an in-memory stand-in for a NATS server, for running the NATS
+// publisher without one. Subscribers are called synchronously on publish, and
+// messages without a subscriber are dropped.
+type InProcessNATS struct {
+	mu   sync.RWMutex
+	subs map[string][]func(subject string, data []byte)
+}
+
+// NewInProcessNATS creates an InProcessNATS without subscribers
+func NewInProcessNATS() *InProcessNATS {
+	return &InProcessNATS{subs: make(map[string][]func(string, []byte))}
+}
+
+// Subscribe calls handler for every message on subject. A subject ending in
+// ".>" matches every subject under its prefix, as in NATS.
+func (n *InProcessNATS) Subscribe(subject string, handler func(subject string, data []byte)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.subs[subject] = append(n.subs[subject], handler)
+}
+
+// Publish calls the subscribers of subject with data
+func (n *InProcessNATS) Publish(subject string, data []byte) error {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	for pattern, handlers := range n.subs {
+		prefix, wildcard := strings.CutSuffix(pattern, ">")
+		if pattern != subject && !(wildcard && strings.HasPrefix(subject, prefix)) {
+			continue
+		}
+		for _, handler := range handlers {
+			handler(subject, data)
+		}
+	}
+	return nil
+}
+
+// KafkaMessage is a message held by InProcessKafka
+type KafkaMessage struct {
+	Key   []byte
+	Value []byte
+}
+
+// InProcessKafka is an in-memory stand-in for a Kafka cluster, for running the
+// Kafka publisher without one. It keeps the latest messages produced to each topic.
+type InProcessKafka struct {
+	mu     sync.RWMutex
+	retain int
+	topics map[string][]KafkaMessage
+}
+
+// NewInProcessKafka creates an InProcessKafka keeping the latest retain
+// messages of each topic
+func NewInProcessKafka(retain int) *InProcessKafka {
+	return &InProcessKafka{retain: retain, topics: make(map[string][]KafkaMessage)}
+}
+
+// Produce appends a message to topic, dropping the oldest past the retention
+func (k *InProcessKafka) Produce(ctx context.Context, topic string, key, value []byte) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	messages := append(k.topics[topic], KafkaMessage{Key: key, Value: value})
+	if len(messages) > k.retain {
+		messages = append([]KafkaMessage(nil), messages[len(messages)-k.retain:]...)
+	}
+	k.topics[topic] = messages
+	return nil
+}
+
+// Messages returns the messages of topic still retained, oldest first
+func (k *InProcessKafka) Messages(topic string) []KafkaMessage {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return append([]KafkaMessage(nil), k.topics[topic]...)
+}
@@ -0,0 +1,47 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+func TestNATSPublisherPublishesToEventSubject(t *testing.T) {
+	nats := services.NewInProcessNATS()
+	var subjects []string
+	nats.Subscribe("catalog.>", func(subject string, data []byte) {
+		var event types.Event
+		if err := json.Unmarshal(data, &event); err != nil {
+			t.Errorf("unmarshal event: %v", err)
+		}
+		subjects = append(subjects, subject)
+	})
+
+	publisher := services.NewNATSPublisher(nats, "catalog")
+	event := &types.Event{ID: 1, Type: types.EventProductCreated, ProductID: 1}
+	checkErr(t, publisher.Publish(context.Background(), event), "")
+
+	if len(subjects) != 1 || subjects[0] != "catalog."+types.EventProductCreated {
+		t.Errorf("published to %v, want catalog.%s", subjects, types.EventProductCreated)
+	}
+}
+
+func TestKafkaPublisherKeepsLatestMessagesInProcess(t *testing.T) {
+	kafka := services.NewInProcessKafka(2)
+	publisher := services.NewKafkaPublisher(kafka, "catalog")
+	for id := 1; id <= 3; id++ {
+		event := &types.Event{ID: id, Type: types.EventStockChanged, ProductID: id * 10}
+		checkErr(t, publisher.Publish(context.Background(), event), "")
+	}
+
+	messages := kafka.Messages("catalog")
+	if len(messages) != 2 || string(messages[0].Key) != "20" || string(messages[1].Key) != "30" {
+		t.Errorf("retained %d messages, want the last 2 keyed by product ID 20 and 30", len(messages))
+	}
+	if len(kafka.Messages("other")) != 0 {
+		t.Errorf("other topic has messages, want none")
+	}
+}
@@ -0,0 +1,4 @@
+package services
+
+// RoundCents exposes roundCents to the services_test package
+var RoundCents = roundCents
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-circleci/repository"
+	"go-circleci/types"
+)
+
+// InventoryService manages locations and per-location stock levels
+type InventoryService struct {
+	repo repository.InventoryRepository
+	now  func() time.Time
+}
+
+// NewInventoryService creates a new InventoryService with the given repository
+func NewInventoryService(repo repository.InventoryRepository) *InventoryService {
+	return &InventoryService{
+		repo: repo,
+		now:  func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// ListLocations retrieves all locations
+func (s *InventoryService) ListLocations(ctx context.Context) ([]*types.Location, error) {
+	locations, err := s.repo.ListLocations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locations: %w", err)
+	}
+
+	// Return empty slice instead of nil for consistency
+	if locations == nil {
+		return []*types.Location{}, nil
+	}
+
+	return locations, nil
+}
+
+// CreateLocation creates a new warehouse or store with input validation
+func (s *InventoryService) CreateLocation(ctx context.Context, req *types.CreateLocationRequest) (*types.Location, error) {
+	// Validate required fields
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	if code == "" {
+		return nil, errors.New("location code is required")
+	}
+
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, errors.New("location name is required")
+	}
+
+	kind := req.Kind
+	if kind == "" {
+		kind = types.LocationWarehouse
+	}
+	if kind != types.LocationWarehouse && kind != types.LocationStore {
+		return nil, fmt.Errorf("location kind must be %q or %q", types.LocationWarehouse, types.LocationStore)
+	}
+
+	location := &types.Location{
+		Code: code,
+		Name: req.Name,
+		Kind: kind,
+	}
+
+	if err := s.repo.CreateLocation(ctx, location); err == repository.ErrLocationCodeTaken {
+		return nil, fmt.Errorf("location code %s already exists", code)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to create location: %w", err)
+	}
+
+	return location, nil
+}
+
+// GetProductStock returns a product's on-hand stock broken down by location
+func (s *InventoryService) GetProductStock(ctx context.Context, productID int) (*types.ProductStock, error) {
+	// Validate ID
+	if productID <= 0 {
+		return nil, errors.New("invalid product ID: must be greater than 0")
+	}
+
+	stock, err := s.repo.GetProductStock(ctx, productID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("product with ID %d not found", productID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product stock: %w", err)
+	}
+
+	return stock, nil
+}
+
+// TransferStock moves units of a product from one location to another
+func (s *InventoryService) TransferStock(ctx context.Context, req *types.StockTransferRequest) (*types.StockTransfer, error) {
+	// Validate required fields
+	if req.ProductID <= 0 {
+		return nil, errors.New("invalid product ID: must be greater than 0")
+	}
+
+	if req.FromLocationID <= 0 || req.ToLocationID <= 0 {
+		return nil, errors.New("invalid location ID: must be greater than 0")
+	}
+
+	if req.FromLocationID == req.ToLocationID {
+		return nil, errors.New("transfer locations must be different")
+	}
+
+	if req.Quantity <= 0 {
+		return nil, errors.New("transfer quantity must be greater than 0")
+	}
+
+	for _, locationID := range []int{req.FromLocationID, req.ToLocationID} {
+		if _, err := s.repo.GetLocation(ctx, locationID); err == sql.ErrNoRows {
+			return nil, fmt.Errorf("location with ID %d not found", locationID)
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get location: %w", err)
+		}
+	}
+
+	// Ensure the product exists before writing to the ledger
+	if _, err := s.GetProductStock(ctx, req.ProductID); err != nil {
+		return nil, err
+	}
+
+	transfer := &types.StockTransfer{
+		ProductID:      req.ProductID,
+		FromLocationID: req.FromLocationID,
+		ToLocationID:   req.ToLocationID,
+		Quantity:       req.Quantity,
+		Reference:      req.Reference,
+		CreatedAt:      s.now(),
+	}
+
+	if err := s.repo.Transfer(ctx, transfer); err == repository.ErrInsufficientStock {
+		return nil, fmt.Errorf("insufficient stock for product %d at location %d", req.ProductID, req.FromLocationID)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to transfer stock: %w", err)
+	}
+
+	return transfer, nil
+}
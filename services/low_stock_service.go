@@ -0,0 +1,298 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	"go-circleci/repository"
+	"go-circleci/types"
+)
+
+// AlertSink delivers low-stock alerts to people or systems that reorder stock
+type AlertSink interface {
+	Send(ctx context.Context, alert *types.LowStockAlert) error
+}
+
+// LogAlertSink prints alerts to stdout alongside the service logs
+type LogAlertSink struct{}
+
+// Send prints the alert
+func (LogAlertSink) Send(ctx context.Context, alert *types.LowStockAlert) error {
+	fmt.Printf("LOW STOCK product_id=%d name=%s available=%d reorder_point=%d reorder_qty=%d\n",
+		alert.ProductID, alert.Name, alert.Available, alert.ReorderPoint, alert.ReorderQty)
+	return nil
+}
+
+// WebhookAlertSink posts alerts as JSON to a URL
+type WebhookAlertSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookAlertSink creates a sink that posts alerts to url
+func NewWebhookAlertSink(url string) *WebhookAlertSink {
+	return &WebhookAlertSink{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send posts the alert and fails on any non-2xx response
+func (s *WebhookAlertSink) Send(ctx context.Context, alert *types.LowStockAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// EmailAlertSink emails alerts through an SMTP server, such as a local
+// MailHog or smtp4dev instance during development
+type EmailAlertSink struct {
+	addr    string
+	from    string
+	to      []string
+	timeout time.Duration
+}
+
+// NewEmailAlertSink creates a sink that sends alerts from one address to the given recipients
+func NewEmailAlertSink(addr, from string, to []string) *EmailAlertSink {
+	return &EmailAlertSink{addr: addr, from: from, to: to, timeout: 10 * time.Second}
+}
+
+// Send emails the alert as a plain-text message, upgrading the connection with
+// STARTTLS when the server offers it. The whole exchange with the server must
+// finish within the sink's timeout.
+func (s *EmailAlertSink) Send(ctx context.Context, alert *types.LowStockAlert) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&msg, "Subject: Low stock: %s\r\n", alert.Name)
+	fmt.Fprintf(&msg, "\r\n")
+	fmt.Fprintf(&msg, "Product %d (%s) has %d units available, at or below its reorder point of %d.\r\n",
+		alert.ProductID, alert.Name, alert.Available, alert.ReorderPoint)
+	fmt.Fprintf(&msg, "Suggested reorder quantity: %d\r\n", alert.ReorderQty)
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	host, _, _ := net.SplitHostPort(s.addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.from); err != nil {
+		return err
+	}
+	for _, to := range s.to {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(msg.String())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// LowStockService checks products against their reorder points and emits one
+// alert per threshold crossing to every configured sink. Alerts are delivered
+// in the background, so slow sinks do not hold up the changes that trigger them.
+type LowStockService struct {
+	repo     repository.LowStockRepository
+	products repository.ProductRepository
+	sinks    []AlertSink
+	now      func() time.Time
+
+	mu         sync.Mutex
+	delivering map[int]bool
+	wg         sync.WaitGroup
+}
+
+// NewLowStockService creates a new LowStockService delivering alerts to the given sinks
+func NewLowStockService(repo repository.LowStockRepository, products repository.ProductRepository, sinks ...AlertSink) *LowStockService {
+	return &LowStockService{
+		repo:     repo,
+		products: products,
+		sinks:    sinks,
+		now:      func() time.Time { return time.Now().UTC() },
+
+		delivering: make(map[int]bool),
+	}
+}
+
+// GetLowStockReport lists the products that are at or below their reorder point
+func (s *LowStockService) GetLowStockReport(ctx context.Context) ([]*types.LowStockItem, error) {
+	items, err := s.repo.ListLowStock(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get low stock report: %w", err)
+	}
+
+	// Return empty slice instead of nil for consistency
+	if items == nil {
+		return []*types.LowStockItem{}, nil
+	}
+
+	return items, nil
+}
+
+// Check starts delivering an alert if the product is at or below its reorder
+// point and no alert has been delivered for this crossing yet, and re-arms
+// alerting once it is back above it. The crossing is only marked alerted once
+// a sink accepts the alert, so an alert every sink failed is retried on the
+// next check.
+func (s *LowStockService) Check(ctx context.Context, product *types.Product) error {
+	if product.ReorderPoint <= 0 || product.Available > product.ReorderPoint {
+		return s.repo.ClearAlert(ctx, product.ID)
+	}
+
+	alerted, err := s.repo.Alerted(ctx, product.ID)
+	if err != nil || alerted {
+		return err
+	}
+
+	// Only one alert per product is in flight at a time
+	s.mu.Lock()
+	if s.delivering[product.ID] {
+		s.mu.Unlock()
+		return nil
+	}
+	s.delivering[product.ID] = true
+	s.mu.Unlock()
+
+	alert := &types.LowStockAlert{
+		ProductID:    product.ID,
+		Name:         product.Name,
+		Available:    product.Available,
+		ReorderPoint: product.ReorderPoint,
+		ReorderQty:   product.ReorderQty,
+		At:           s.now(),
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.deliver(context.WithoutCancel(ctx), product, alert)
+	}()
+
+	return nil
+}
+
+// deliver sends an alert to every sink and marks the product alerted if at
+// least one of them accepted it
+func (s *LowStockService) deliver(ctx context.Context, product *types.Product, alert *types.LowStockAlert) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.delivering, product.ID)
+		s.mu.Unlock()
+	}()
+
+	delivered := false
+	for _, sink := range s.sinks {
+		if err := sink.Send(ctx, alert); err != nil {
+			fmt.Printf("low stock alert sink=%T product_id=%d err=%v\n", sink, product.ID, err)
+			continue
+		}
+		delivered = true
+	}
+	if !delivered {
+		return
+	}
+
+	if _, err := s.repo.MarkAlerted(ctx, product, alert.At); err != nil {
+		fmt.Printf("low stock alert product_id=%d err=%v\n", product.ID, err)
+	}
+}
+
+// Wait blocks until the alerts being delivered have been sent or have failed
+func (s *LowStockService) Wait() {
+	s.wg.Wait()
+}
+
+// CheckAll checks every product, catching stock changes made outside ProductService
+// such as confirmed reservations and placed orders
+func (s *LowStockService) CheckAll(ctx context.Context) error {
+	products, err := s.products.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get products: %w", err)
+	}
+
+	for _, product := range products {
+		if err := s.Check(ctx, product); err != nil {
+			return fmt.Errorf("failed to check product %d: %w", product.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// StockChanged implements StockObserver so ProductService checks a product after every change
+func (s *LowStockService) StockChanged(ctx context.Context, product *types.Product) {
+	if err := s.Check(ctx, product); err != nil {
+		fmt.Printf("low stock check product_id=%d err=%v\n", product.ID, err)
+	}
+}
+
+// Run checks every product each interval until ctx is cancelled
+func (s *LowStockService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.CheckAll(ctx); err != nil {
+				fmt.Printf("low stock checker err=%v\n", err)
+			}
+		}
+	}
+}
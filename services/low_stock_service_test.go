@@ -0,0 +1,63 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"go-circleci/repository"
+	"go-circleci/repository/repotest"
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+// failingAlertSink fails its first failures alerts and counts the alerts it accepts
+type failingAlertSink struct {
+	mu       sync.Mutex
+	failures int
+	sent     int
+}
+
+func (s *failingAlertSink) Send(ctx context.Context, alert *types.LowStockAlert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("mail server unavailable")
+	}
+	s.sent++
+	return nil
+}
+
+func TestLowStockServiceRetriesAlertsNoSinkAccepted(t *testing.T) {
+	ctx := context.Background()
+	db := repotest.OpenSQLite(t)
+	products := repository.NewSQLiteProductRepository(db)
+	sink := &failingAlertSink{failures: 1}
+	svc := services.NewLowStockService(repository.NewSQLiteLowStockRepository(db), products, sink)
+
+	product := &types.Product{Name: "Widget", Price: 5, Stock: 2, ReorderPoint: 3, ReorderQty: 10}
+	checkErr(t, products.Create(ctx, product), "")
+	product, err := products.GetByID(ctx, product.ID)
+	checkErr(t, err, "")
+
+	// The failed alert leaves the crossing unmarked, so the next check retries it
+	for range 3 {
+		checkErr(t, svc.Check(ctx, product), "")
+		svc.Wait()
+	}
+	if sink.sent != 1 {
+		t.Errorf("alerts sent = %d, want 1 after a failure and a retry", sink.sent)
+	}
+
+	// Back above the reorder point re-arms the alert
+	product.Available = 5
+	checkErr(t, svc.Check(ctx, product), "")
+	product.Available = 1
+	checkErr(t, svc.Check(ctx, product), "")
+	svc.Wait()
+	if sink.sent != 2 {
+		t.Errorf("alerts sent = %d, want 2 after the product fell again", sink.sent)
+	}
+}
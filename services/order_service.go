@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"go-circleci/repository"
+	"go-circleci/types"
+)
+
+// orderStatuses lists every order status
+var orderStatuses = []string{
+	types.OrderPending,
+	types.OrderPaid,
+	types.OrderFulfilled,
+	types.OrderCancelled,
+	types.OrderRefunded,
+}
+
+// orderTransitions is the order state machine: the statuses each status may move to.
+// Cancelled and refunded orders are final.
+var orderTransitions = map[string][]string{
+	types.OrderPending:   {types.OrderPaid, types.OrderCancelled},
+	types.OrderPaid:      {types.OrderFulfilled, types.OrderCancelled, types.OrderRefunded},
+	types.OrderFulfilled: {types.OrderRefunded},
+}
+
+// OrderService places orders and moves them through their lifecycle
+type OrderService struct {
+	repo       repository.OrderRepository
+	products   repository.ProductRepository
+	promotions *PromotionService
+	taxes      *TaxService
+	stock      *StockEvents
+	now        func() time.Time
+}
+
+// NewOrderService creates a new OrderService with the given order and product repositories
+func NewOrderService(repo repository.OrderRepository, products repository.ProductRepository) *OrderService {
+	return &OrderService{
+		repo:     repo,
+		products: products,
+		now:      func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// SetPromotions sets the PromotionService used to price order lines and redeem
+// coupons. Without one, lines are priced at list price and coupons are rejected.
+func (s *OrderService) SetPromotions(promotions *PromotionService) {
+	s.promotions = promotions
+}
+
+// SetTaxes sets the TaxService used to tax orders placed for a region.
+// Without one, orders for a region are rejected.
+func (s *OrderService) SetTaxes(taxes *TaxService) {
+	s.taxes = taxes
+}
+
+// SetStockEvents sets the StockEvents that record the stock orders take and
+// return, at checkout too. Without one, no StockChanged events are recorded.
+func (s *OrderService) SetStockEvents(stock *StockEvents) {
+	s.stock = stock
+}
+
+// CreateOrder validates the requested products, prices each line at the current
+// effective price and places a pending order, deducting its stock
+func (s *OrderService) CreateOrder(ctx context.Context, req *types.CreateOrderRequest) (*types.Order, error) {
+	order, err := s.prepareOrder(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.stock.track(ctx, orderProductIDs(order), func(ctx context.Context) error {
+		return s.repo.Create(ctx, order)
+	})
+	if err != nil {
+		return nil, createOrderError(err)
+	}
+
+	return order, nil
+}
+
+// prepareOrder validates an order request and builds a pending order priced at
+// the current effective prices for its customer and coupon and taxed for its
+// region, without persisting it
+func (s *OrderService) prepareOrder(ctx context.Context, req *types.CreateOrderRequest) (*types.Order, error) {
+	// Validate required fields
+	if len(req.Lines) == 0 {
+		return nil, errors.New("order lines are required")
+	}
+
+	// Merge repeated products into a single line
+	quantities := make(map[int]int)
+	var productIDs []int
+	for _, line := range req.Lines {
+		if line.ProductID <= 0 {
+			return nil, errors.New("invalid product ID: must be greater than 0")
+		}
+		if line.Quantity <= 0 {
+			return nil, errors.New("order line quantity must be greater than 0")
+		}
+		if _, ok := quantities[line.ProductID]; !ok {
+			productIDs = append(productIDs, line.ProductID)
+		}
+		quantities[line.ProductID] += line.Quantity
+	}
+
+	coupon := normalizeCouponCode(req.Coupon)
+	if coupon != "" {
+		if err := s.checkCoupon(ctx, coupon, req.Customer); err != nil {
+			return nil, err
+		}
+	}
+
+	var rates *TaxRates
+	region := normalizeRegion(req.Region)
+	if region != "" {
+		if s.taxes == nil {
+			return nil, fmt.Errorf("invalid region %q: taxes are not enabled", region)
+		}
+		var err error
+		if rates, err = s.taxes.RatesFor(ctx, region); err != nil {
+			return nil, err
+		}
+	}
+
+	now := s.now()
+	order := &types.Order{
+		Status:    types.OrderPending,
+		Customer:  req.Customer,
+		Region:    region,
+		Lines:     make([]types.OrderLine, 0, len(productIDs)),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	for _, productID := range productIDs {
+		product, err := s.products.GetByID(ctx, productID)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("product with ID %d not found", productID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get product: %w", err)
+		}
+
+		if s.promotions != nil {
+			pricingCtx := WithPricingContext(ctx, types.PricingContext{At: now, Customer: req.Customer, Coupon: coupon})
+			if err := s.promotions.ApplyPricing(pricingCtx, product); err != nil {
+				return nil, err
+			}
+		}
+		for _, applied := range product.Promotions {
+			if coupon != "" && applied.CouponCode == coupon {
+				order.Coupon = coupon
+			}
+		}
+
+		quantity := quantities[productID]
+		line := types.OrderLine{
+			ProductID:   product.ID,
+			ProductName: product.Name,
+			UnitPrice:   product.EffectivePrice,
+			Quantity:    quantity,
+			LineTotal:   roundCents(product.EffectivePrice * float64(quantity)),
+		}
+
+		// Tax is computed per line so line taxes add up to the order tax
+		gross := line.LineTotal
+		if rates != nil {
+			split := rates.Split(product.TaxClass, line.LineTotal)
+			line.TaxRate = split.Rate
+			line.Tax = split.Tax
+			gross = split.Gross
+		}
+
+		order.Lines = append(order.Lines, line)
+		order.Tax += line.Tax
+		order.Total += gross
+	}
+	order.Tax = roundCents(order.Tax)
+	order.Total = roundCents(order.Total)
+	order.Net = roundCents(order.Total - order.Tax)
+
+	if coupon != "" && order.Coupon == "" {
+		return nil, fmt.Errorf("invalid coupon %s: it does not apply to any product in the order", coupon)
+	}
+
+	return order, nil
+}
+
+// checkCoupon checks that a coupon can be used now by the customer
+func (s *OrderService) checkCoupon(ctx context.Context, coupon string, customer string) error {
+	if s.promotions == nil {
+		return fmt.Errorf("invalid coupon %s: coupons are not enabled", coupon)
+	}
+
+	_, reason, err := s.promotions.checkCoupon(ctx, coupon, customer)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		return fmt.Errorf("invalid coupon %s: %s", coupon, reason)
+	}
+
+	return nil
+}
+
+// createOrderError maps repository errors from placing an order into service errors
+func createOrderError(err error) error {
+	var stockErr *repository.StockError
+	if errors.As(err, &stockErr) {
+		return fmt.Errorf("insufficient stock for product %d", stockErr.ProductID)
+	}
+	if err == sql.ErrNoRows {
+		return errors.New("order references a product that no longer exists")
+	}
+	if err == repository.ErrCouponExhausted {
+		return errors.New("invalid coupon: usage limit reached")
+	}
+	return fmt.Errorf("failed to create order: %w", err)
+}
+
+// GetOrder retrieves a single order by its ID with validation
+func (s *OrderService) GetOrder(ctx context.Context, id int) (*types.Order, error) {
+	// Validate ID
+	if id <= 0 {
+		return nil, errors.New("invalid order ID: must be greater than 0")
+	}
+
+	order, err := s.repo.GetByID(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("order with ID %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	return order, nil
+}
+
+// ListOrders retrieves the orders matching filter, newest first
+func (s *OrderService) ListOrders(ctx context.Context, filter types.OrderFilter) ([]*types.Order, error) {
+	if filter.Status != "" && !slices.Contains(orderStatuses, filter.Status) {
+		return nil, fmt.Errorf("invalid order status %q", filter.Status)
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, errors.New("order filter from must be before to")
+	}
+
+	orders, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+
+	// Return empty slice instead of nil for consistency
+	if orders == nil {
+		return []*types.Order{}, nil
+	}
+
+	return orders, nil
+}
+
+// PayOrder marks a pending order as paid
+func (s *OrderService) PayOrder(ctx context.Context, id int) (*types.Order, error) {
+	return s.transition(ctx, id, types.OrderPaid)
+}
+
+// FulfilOrder marks a paid order as fulfilled once it has shipped
+func (s *OrderService) FulfilOrder(ctx context.Context, id int) (*types.Order, error) {
+	return s.transition(ctx, id, types.OrderFulfilled)
+}
+
+// CancelOrder cancels a pending or paid order and returns its units to stock
+func (s *OrderService) CancelOrder(ctx context.Context, id int) (*types.Order, error) {
+	return s.transition(ctx, id, types.OrderCancelled)
+}
+
+// RefundOrder refunds a paid or fulfilled order. Units are returned to stock only
+// if the order had not been fulfilled.
+func (s *OrderService) RefundOrder(ctx context.Context, id int) (*types.Order, error) {
+	return s.transition(ctx, id, types.OrderRefunded)
+}
+
+// transition validates a status change against the state machine and applies it
+func (s *OrderService) transition(ctx context.Context, id int, to string) (*types.Order, error) {
+	order, err := s.GetOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(orderTransitions[order.Status], to) {
+		return nil, fmt.Errorf("order %d is %s and cannot be %s", id, order.Status, to)
+	}
+
+	// Units only go back on the shelf if the order never shipped
+	restock := to == types.OrderCancelled || (to == types.OrderRefunded && order.Status == types.OrderPaid)
+
+	var productIDs []int
+	if restock {
+		productIDs = orderProductIDs(order)
+	}
+	err = s.stock.track(ctx, productIDs, func(ctx context.Context) error {
+		return s.repo.Transition(ctx, id, order.Status, to, restock, s.now())
+	})
+	if err == repository.ErrOrderStatusChanged {
+		return nil, fmt.Errorf("order %d status changed concurrently, please retry", id)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to update order: %w", err)
+	}
+
+	return s.GetOrder(ctx, id)
+}
+
+// roundCents rounds an amount to two decimal places, halves away from zero.
+// The amount is nudged first so halves that binary floating point stores just
+// below the midpoint, such as 1.005, still round up.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100+math.Copysign(1e-6, amount)) / 100
+}
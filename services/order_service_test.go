@@ -0,0 +1,194 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"go-circleci/repository"
+	"go-circleci/repository/repotest"
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+// newOrderService returns an OrderService over an empty SQLite database
+// together with the product repository its orders take stock from
+func newOrderService(t *testing.T) (*services.OrderService, repository.ProductRepository) {
+	t.Helper()
+	db := repotest.OpenSQLite(t)
+	products := repository.NewSQLiteProductRepository(db)
+	return services.NewOrderService(repository.NewSQLiteOrderRepository(db, products), products), products
+}
+
+// checkStock fails the test unless the product has the wanted stock
+func checkStock(t *testing.T, products repository.ProductRepository, id int, want int) {
+	t.Helper()
+	got, err := products.GetByID(context.Background(), id)
+	checkErr(t, err, "")
+	if got.Stock != want {
+		t.Errorf("stock = %d, want %d", got.Stock, want)
+	}
+}
+
+func TestOrderServiceCreateOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		lines     []types.CreateOrderLineRequest
+		wantErr   string
+		wantTotal float64
+		wantStock int
+	}{
+		{"deducts stock", []types.CreateOrderLineRequest{{ProductID: 1, Quantity: 3}}, "", 7.5, 7},
+		{"merges repeated products", []types.CreateOrderLineRequest{{ProductID: 1, Quantity: 2}, {ProductID: 1, Quantity: 8}}, "", 25, 0},
+		{"insufficient stock", []types.CreateOrderLineRequest{{ProductID: 1, Quantity: 11}}, "insufficient stock for product 1", 0, 10},
+		{"no lines", nil, "order lines are required", 0, 10},
+		{"zero quantity", []types.CreateOrderLineRequest{{ProductID: 1, Quantity: 0}}, "order line quantity must be greater than 0", 0, 10},
+		{"invalid product ID", []types.CreateOrderLineRequest{{ProductID: 0, Quantity: 1}}, "invalid product ID: must be greater than 0", 0, 10},
+		{"unknown product", []types.CreateOrderLineRequest{{ProductID: 1, Quantity: 1}, {ProductID: 99, Quantity: 1}}, "product with ID 99 not found", 0, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			orders, products := newOrderService(t)
+			checkErr(t, products.Create(ctx, &types.Product{Name: "Widget", Price: 2.5, Stock: 10}), "")
+
+			order, err := orders.CreateOrder(ctx, &types.CreateOrderRequest{Lines: tt.lines})
+			checkErr(t, err, tt.wantErr)
+			if err == nil {
+				if order.Status != types.OrderPending {
+					t.Errorf("status = %q, want %q", order.Status, types.OrderPending)
+				}
+				if len(order.Lines) != 1 {
+					t.Errorf("got %d lines, want 1", len(order.Lines))
+				}
+				if order.Total != tt.wantTotal {
+					t.Errorf("total = %v, want %v", order.Total, tt.wantTotal)
+				}
+			}
+			checkStock(t, products, 1, tt.wantStock)
+		})
+	}
+}
+
+func TestOrderServiceTransitions(t *testing.T) {
+	actions := map[string]func(*services.OrderService, context.Context, int) (*types.Order, error){
+		"pay":    (*services.OrderService).PayOrder,
+		"fulfil": (*services.OrderService).FulfilOrder,
+		"cancel": (*services.OrderService).CancelOrder,
+		"refund": (*services.OrderService).RefundOrder,
+	}
+
+	tests := []struct {
+		name       string
+		before     []string
+		action     string
+		wantErr    string
+		wantStatus string
+		wantStock  int
+	}{
+		{"pay pending", nil, "pay", "", types.OrderPaid, 7},
+		{"cancel pending restocks", nil, "cancel", "", types.OrderCancelled, 10},
+		{"fulfil pending", nil, "fulfil", "order 1 is pending and cannot be fulfilled", types.OrderPending, 7},
+		{"refund pending", nil, "refund", "order 1 is pending and cannot be refunded", types.OrderPending, 7},
+		{"fulfil paid", []string{"pay"}, "fulfil", "", types.OrderFulfilled, 7},
+		{"cancel paid restocks", []string{"pay"}, "cancel", "", types.OrderCancelled, 10},
+		{"refund paid restocks", []string{"pay"}, "refund", "", types.OrderRefunded, 10},
+		{"pay paid", []string{"pay"}, "pay", "order 1 is paid and cannot be paid", types.OrderPaid, 7},
+		{"refund fulfilled keeps stock", []string{"pay", "fulfil"}, "refund", "", types.OrderRefunded, 7},
+		{"cancel fulfilled", []string{"pay", "fulfil"}, "cancel", "order 1 is fulfilled and cannot be cancelled", types.OrderFulfilled, 7},
+		{"pay cancelled", []string{"cancel"}, "pay", "order 1 is cancelled and cannot be paid", types.OrderCancelled, 10},
+		{"cancel cancelled", []string{"cancel"}, "cancel", "order 1 is cancelled and cannot be cancelled", types.OrderCancelled, 10},
+		{"cancel refunded", []string{"pay", "refund"}, "cancel", "order 1 is refunded and cannot be cancelled", types.OrderRefunded, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			orders, products := newOrderService(t)
+			checkErr(t, products.Create(ctx, &types.Product{Name: "Widget", Price: 2.5, Stock: 10}), "")
+			order, err := orders.CreateOrder(ctx, &types.CreateOrderRequest{Lines: []types.CreateOrderLineRequest{{ProductID: 1, Quantity: 3}}})
+			checkErr(t, err, "")
+			for _, action := range tt.before {
+				_, err := actions[action](orders, ctx, order.ID)
+				checkErr(t, err, "")
+			}
+
+			_, err = actions[tt.action](orders, ctx, order.ID)
+			checkErr(t, err, tt.wantErr)
+
+			got, err := orders.GetOrder(ctx, order.ID)
+			checkErr(t, err, "")
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", got.Status, tt.wantStatus)
+			}
+			checkStock(t, products, 1, tt.wantStock)
+		})
+	}
+}
+
+func TestRoundCents(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   float64
+	}{
+		{0, 0},
+		{10, 10},
+		{0.124, 0.12},
+		{0.125, 0.13},
+		{1.005, 1.01},
+		{2.675, 2.68},
+		{-1.005, -1.01},
+		{-0.124, -0.12},
+		{0.1 + 0.2, 0.3},
+	}
+
+	for _, tt := range tests {
+		if got := services.RoundCents(tt.amount); got != tt.want {
+			t.Errorf("RoundCents(%v) = %v, want %v", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestOrderServiceRestocksTrashedAndPurgedProducts(t *testing.T) {
+	ctx := context.Background()
+	db := repotest.OpenSQLite(t)
+	products := repository.NewSQLiteProductRepository(db)
+	orders := services.NewOrderService(repository.NewSQLiteOrderRepository(db, products), products)
+
+	trashed := &types.Product{Name: "Widget", Price: 5, Stock: 5}
+	purged := &types.Product{Name: "Gadget", Price: 8, Stock: 5}
+	checkErr(t, products.Create(ctx, trashed), "")
+	checkErr(t, products.Create(ctx, purged), "")
+
+	var placed []*types.Order
+	for range 2 {
+		order, err := orders.CreateOrder(ctx, &types.CreateOrderRequest{Lines: []types.CreateOrderLineRequest{
+			{ProductID: trashed.ID, Quantity: 2},
+			{ProductID: purged.ID, Quantity: 1},
+		}})
+		checkErr(t, err, "")
+		placed = append(placed, order)
+	}
+	_, err := orders.PayOrder(ctx, placed[1].ID)
+	checkErr(t, err, "")
+
+	checkErr(t, products.Delete(ctx, trashed.ID), "")
+	checkErr(t, products.Delete(ctx, purged.ID), "")
+	checkErr(t, products.Purge(ctx, purged.ID), "")
+
+	order, err := orders.CancelOrder(ctx, placed[0].ID)
+	checkErr(t, err, "")
+	if order.Status != types.OrderCancelled {
+		t.Errorf("cancelled order has status %q, want %q", order.Status, types.OrderCancelled)
+	}
+	_, err = orders.RefundOrder(ctx, placed[1].ID)
+	checkErr(t, err, "")
+
+	// The trashed product gets its units back once restored
+	checkErr(t, products.Restore(ctx, trashed.ID), "")
+	got, err := products.GetByID(ctx, trashed.ID)
+	checkErr(t, err, "")
+	if got.Stock != 5 {
+		t.Errorf("restored product has stock %d, want 5 with both orders returned", got.Stock)
+	}
+}
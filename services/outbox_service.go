@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-circleci/repository"
+	"go-circleci/types"
+)
+
+// OutboxOptions tunes the relay. Zero values take the defaults of DefaultOutboxOptions.
+type OutboxOptions struct {
+	// BatchSize is the most events delivered per pass
+	BatchSize int
+	// MaxAttempts is how often delivery of an event is tried before it is marked failed
+	MaxAttempts int
+	// Backoff is the wait after the first failed attempt, doubling after every
+	// further one up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultOutboxOptions returns the relay settings used unless overridden
+func DefaultOutboxOptions() OutboxOptions {
+	return OutboxOptions{BatchSize: 100, MaxAttempts: 10, Backoff: time.Second, MaxBackoff: 5 * time.Minute}
+}
+
+// Outbox listings return at most maxOutboxEntries events, and
+// defaultOutboxEntries unless a limit is given
+const (
+	defaultOutboxEntries = 100
+	maxOutboxEntries     = 1000
+)
+
+// retrySchedule is the exponential backoff with which the outbox relay and
+// webhook dispatch retry failed deliveries
+type retrySchedule struct {
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+}
+
+// newRetrySchedule returns the schedule of the given settings, taking those
+// left unset from defaults
+func newRetrySchedule(maxAttempts int, backoff, maxBackoff time.Duration, defaults retrySchedule) retrySchedule {
+	if maxAttempts <= 0 {
+		maxAttempts = defaults.maxAttempts
+	}
+	if backoff <= 0 {
+		backoff = defaults.backoff
+	}
+	if maxBackoff < backoff {
+		maxBackoff = max(defaults.maxBackoff, backoff)
+	}
+	return retrySchedule{maxAttempts: maxAttempts, backoff: backoff, maxBackoff: maxBackoff}
+}
+
+// wait returns the wait before the next attempt after the given number of failed ones
+func (r retrySchedule) wait(attempts int) time.Duration {
+	wait := r.backoff
+	for i := 1; i < attempts && wait < r.maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, r.maxBackoff)
+}
+
+// exhausted reports whether the given number of attempts is the last allowed
+func (r retrySchedule) exhausted(attempts int) bool {
+	return attempts >= r.maxAttempts
+}
+
+// OutboxService records domain events in the outbox as part of the change they
+// describe and relays them to publishers afterwards, at least once each
+type OutboxService struct {
+	repo       repository.OutboxRepository
+	publishers []Publisher
+	batchSize  int
+	retry      retrySchedule
+	now        func() time.Time
+}
+
+// NewOutboxService creates a new OutboxService relaying events to the given publishers
+func NewOutboxService(repo repository.OutboxRepository, opts OutboxOptions, publishers ...Publisher) *OutboxService {
+	defaults := DefaultOutboxOptions()
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaults.BatchSize
+	}
+	return &OutboxService{
+		repo:       repo,
+		publishers: publishers,
+		batchSize:  opts.BatchSize,
+		retry:      newRetrySchedule(opts.MaxAttempts, opts.Backoff, opts.MaxBackoff, retrySchedule{defaults.MaxAttempts, defaults.Backoff, defaults.MaxBackoff}),
+		now:        func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// AddPublisher registers another publisher. Events already delivered are not
+// sent to it, so it should be added before the relay starts.
+func (s *OutboxService) AddPublisher(publisher Publisher) {
+	s.publishers = append(s.publishers, publisher)
+}
+
+// Record writes an event about a product to the outbox in the transaction
+// carried by ctx, so it is only delivered if the change is committed
+func (s *OutboxService) Record(ctx context.Context, eventType string, productID int, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	event := &types.Event{Type: eventType, ProductID: productID, Payload: data, OccurredAt: s.now()}
+	if err := s.repo.Append(ctx, event); err != nil {
+		return fmt.Errorf("failed to record %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+// Relay delivers the events that are due to every publisher they have not
+// reached yet and returns how many were fully delivered. Events about a
+// product are delivered in order: one that fails holds back later events
+// about the same product until it succeeds or is marked failed.
+func (s *OutboxService) Relay(ctx context.Context) (int, error) {
+	now := s.now()
+	entries, err := s.repo.ListDue(ctx, now, s.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list due events: %w", err)
+	}
+
+	delivered := 0
+	held := make(map[int]bool)
+	for _, entry := range entries {
+		if held[entry.ProductID] {
+			continue
+		}
+
+		if err := s.deliver(ctx, entry); err != nil {
+			entry.Attempts++
+			entry.LastError = err.Error()
+			entry.NextAttemptAt = s.now().Add(s.retry.wait(entry.Attempts))
+			if s.retry.exhausted(entry.Attempts) {
+				entry.Status = types.OutboxFailed
+			} else {
+				held[entry.ProductID] = true
+			}
+		} else {
+			deliveredAt := s.now()
+			entry.Status = types.OutboxDelivered
+			entry.LastError = ""
+			entry.DeliveredAt = &deliveredAt
+			delivered++
+		}
+
+		if err := s.repo.UpdateStatus(ctx, entry); err != nil {
+			return delivered, fmt.Errorf("failed to update event %d: %w", entry.ID, err)
+		}
+	}
+
+	return delivered, nil
+}
+
+// deliver publishes an event to every publisher it has not reached yet,
+// recording each delivery, and returns the failures joined together
+func (s *OutboxService) deliver(ctx context.Context, entry *types.OutboxEntry) error {
+	var failures []string
+	for _, publisher := range s.publishers {
+		name := publisher.Name()
+		if containsString(entry.Publishers, name) {
+			continue
+		}
+
+		if err := publisher.Publish(ctx, &entry.Event); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		if err := s.repo.RecordDelivery(ctx, entry.ID, name, s.now()); err != nil {
+			return fmt.Errorf("failed to record delivery to %s: %w", name, err)
+		}
+		entry.Publishers = append(entry.Publishers, name)
+	}
+
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// RunRelay delivers due events every interval until ctx is cancelled
+func (s *OutboxService) RunRelay(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Relay(ctx); err != nil {
+				fmt.Printf("outbox relay err=%v\n", err)
+			}
+		}
+	}
+}
+
+// ListOutbox retrieves up to limit outbox events, newest first, with the given
+// status or any status if it is empty
+func (s *OutboxService) ListOutbox(ctx context.Context, status string, limit int) ([]*types.OutboxEntry, error) {
+	switch status {
+	case "", types.OutboxPending, types.OutboxDelivered, types.OutboxFailed:
+	default:
+		return nil, fmt.Errorf("invalid outbox status %q", status)
+	}
+	if limit < 0 || limit > maxOutboxEntries {
+		return nil, fmt.Errorf("outbox limit must be between 1 and %d", maxOutboxEntries)
+	}
+	if limit == 0 {
+		limit = defaultOutboxEntries
+	}
+
+	entries, err := s.repo.List(ctx, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outbox events: %w", err)
+	}
+	return entries, nil
+}
+
+// RetryOutboxEvent makes a failed event pending again so the relay retries it
+// on its next pass, with a fresh set of attempts
+func (s *OutboxService) RetryOutboxEvent(ctx context.Context, id int) error {
+	if id <= 0 {
+		return errors.New("invalid outbox event ID: must be greater than 0")
+	}
+
+	if err := s.repo.Requeue(ctx, id, s.now()); err == sql.ErrNoRows {
+		return fmt.Errorf("failed outbox event %d not found", id)
+	} else if err != nil {
+		return fmt.Errorf("failed to retry outbox event: %w", err)
+	}
+	return nil
+}
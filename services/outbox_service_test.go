@@ -0,0 +1,267 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go-circleci/repository"
+	"go-circleci/repository/repotest"
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+// flakyPublisher fails its first failures publishes and records the events it delivers
+type flakyPublisher struct {
+	failures  int
+	delivered []*types.Event
+}
+
+func (p *flakyPublisher) Name() string { return "flaky" }
+
+func (p *flakyPublisher) Publish(ctx context.Context, event *types.Event) error {
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("broker unavailable")
+	}
+	p.delivered = append(p.delivered, event)
+	return nil
+}
+
+func TestProductServicePublishesEvents(t *testing.T) {
+	ctx := context.Background()
+	kafka := services.NewInProcessKafka(100)
+	s := newSQLServices(t, sqlOptions{publishers: []services.Publisher{services.NewKafkaPublisher(kafka, "products")}})
+	svc, outbox := s.product, s.outbox
+
+	product, err := svc.CreateProduct(ctx, &types.CreateProductRequest{Name: "Widget", Price: 9.99, Stock: 5})
+	checkErr(t, err, "")
+	_, err = svc.UpdateProduct(ctx, product.ID, &types.UpdateProductRequest{Name: "Widget", Price: 12.5, Stock: 5})
+	checkErr(t, err, "")
+	_, err = svc.UpdateProduct(ctx, product.ID, &types.UpdateProductRequest{Name: "Widget", Price: 12.5, Stock: 2})
+	checkErr(t, err, "")
+	checkErr(t, svc.DeleteProduct(ctx, product.ID), "")
+
+	// A change that is rolled back publishes nothing
+	_, err = svc.CreateProduct(ctx, &types.CreateProductRequest{SKU: "W-1", Name: "Gadget"})
+	checkErr(t, err, "")
+	_, err = svc.CreateProduct(ctx, &types.CreateProductRequest{SKU: "W-1", Name: "Gizmo"})
+	checkErr(t, err, "already exists")
+
+	delivered, err := outbox.Relay(ctx)
+	checkErr(t, err, "")
+	if delivered != 7 {
+		t.Fatalf("Relay delivered %d events, want 7", delivered)
+	}
+
+	var got []string
+	for _, msg := range kafka.Messages("products") {
+		var event types.Event
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			t.Fatalf("unmarshal event: %v", err)
+		}
+		got = append(got, event.Type)
+	}
+	want := []string{
+		types.EventProductCreated, types.EventStockChanged, types.EventProductUpdated,
+		types.EventProductUpdated, types.EventStockChanged, types.EventProductDeleted, types.EventProductCreated,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("published %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("published %v, want %v", got, want)
+		}
+	}
+
+	var stock types.StockChangedPayload
+	var event types.Event
+	checkErr(t, json.Unmarshal(kafka.Messages("products")[4].Value, &event), "")
+	checkErr(t, json.Unmarshal(event.Payload, &stock), "")
+	if stock.Previous != 5 || stock.Stock != 2 || stock.Delta != -3 {
+		t.Errorf("StockChanged payload = %+v, want 5 to 2", stock)
+	}
+
+	// Delivered events are not relayed again
+	delivered, err = outbox.Relay(ctx)
+	checkErr(t, err, "")
+	if delivered != 0 || len(kafka.Messages("products")) != 7 {
+		t.Errorf("second Relay delivered %d events, want 0", delivered)
+	}
+}
+
+func TestOutboxServiceRetriesDelivery(t *testing.T) {
+	ctx := context.Background()
+	flaky := &flakyPublisher{failures: 1}
+	kafka := services.NewInProcessKafka(100)
+	s := newSQLServices(t, sqlOptions{
+		outbox:     services.OutboxOptions{MaxAttempts: 2, Backoff: time.Millisecond},
+		publishers: []services.Publisher{services.NewKafkaPublisher(kafka, "products"), flaky},
+	})
+	svc, outbox := s.product, s.outbox
+
+	product, err := svc.CreateProduct(ctx, &types.CreateProductRequest{Name: "Widget"})
+	checkErr(t, err, "")
+	checkErr(t, svc.DeleteProduct(ctx, product.ID), "")
+
+	// The failed event holds back the later event about the same product
+	delivered, err := outbox.Relay(ctx)
+	checkErr(t, err, "")
+	if delivered != 0 || len(flaky.delivered) != 0 {
+		t.Fatalf("first Relay delivered %d events, want 0", delivered)
+	}
+	pending, err := outbox.ListOutbox(ctx, types.OutboxPending, 0)
+	checkErr(t, err, "")
+	if len(pending) != 2 {
+		t.Fatalf("pending events = %d, want 2", len(pending))
+	}
+	created := pending[1]
+	if created.Attempts != 1 || created.LastError != "flaky: broker unavailable" || strings.Join(created.Publishers, ",") != "kafka,webhooks" {
+		t.Errorf("failed event = %+v, want one attempt delivered to kafka and webhooks only", created)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	delivered, err = outbox.Relay(ctx)
+	checkErr(t, err, "")
+	if delivered != 2 || len(flaky.delivered) != 2 || flaky.delivered[0].Type != types.EventProductCreated {
+		t.Fatalf("second Relay delivered %d events, flaky got %d, want both in order", delivered, len(flaky.delivered))
+	}
+	// Publishers that already had the event do not get it again
+	if n := len(kafka.Messages("products")); n != 2 {
+		t.Errorf("kafka got %d messages, want 2", n)
+	}
+
+	// An event is marked failed after the last attempt and can be retried
+	flaky.failures = 2
+	// Purging a product in the trash publishes nothing more
+	checkErr(t, svc.PurgeProduct(ctx, product.ID), "")
+	_, err = svc.CreateProduct(ctx, &types.CreateProductRequest{Name: "Gadget"})
+	checkErr(t, err, "")
+	for range 2 {
+		time.Sleep(5 * time.Millisecond)
+		_, err = outbox.Relay(ctx)
+		checkErr(t, err, "")
+	}
+	failed, err := outbox.ListOutbox(ctx, types.OutboxFailed, 0)
+	checkErr(t, err, "")
+	if len(failed) != 1 || failed[0].Attempts != 2 {
+		t.Fatalf("failed events = %+v, want one after 2 attempts", failed)
+	}
+
+	checkErr(t, outbox.RetryOutboxEvent(ctx, failed[0].ID), "")
+	checkErr(t, outbox.RetryOutboxEvent(ctx, failed[0].ID), "not found")
+	delivered, err = outbox.Relay(ctx)
+	checkErr(t, err, "")
+	if delivered != 1 {
+		t.Errorf("Relay after retry delivered %d events, want 1", delivered)
+	}
+
+	_, err = outbox.ListOutbox(ctx, "lost", 0)
+	checkErr(t, err, "invalid outbox status")
+}
+
+func TestStockEventsRecordOrdersAndReservations(t *testing.T) {
+	ctx := context.Background()
+	db := repotest.OpenSQLite(t)
+	products := repository.NewSQLiteProductRepository(db)
+	outbox := services.NewOutboxService(repository.NewSQLiteOutboxRepository(db), services.OutboxOptions{})
+	stock := services.NewStockEvents(outbox, products, repository.NewSQLTxManager(db))
+
+	orderService := services.NewOrderService(repository.NewSQLiteOrderRepository(db, products), products)
+	orderService.SetStockEvents(stock)
+	reservationService := services.NewReservationService(repository.NewSQLiteReservationRepository(db))
+	reservationService.SetStockEvents(stock)
+	inventoryService := services.NewInventoryService(repository.NewSQLiteInventoryRepository(db))
+
+	product := &types.Product{Name: "Widget", Price: 5, Stock: 10}
+	checkErr(t, products.Create(ctx, product), "")
+
+	order, err := orderService.CreateOrder(ctx, &types.CreateOrderRequest{Lines: []types.CreateOrderLineRequest{{ProductID: product.ID, Quantity: 3}}})
+	checkErr(t, err, "")
+	_, err = orderService.CancelOrder(ctx, order.ID)
+	checkErr(t, err, "")
+
+	// Holds change only the available stock
+	reservation, err := reservationService.CreateReservation(ctx, &types.CreateReservationRequest{ProductID: product.ID, Quantity: 2})
+	checkErr(t, err, "")
+	_, err = reservationService.ConfirmReservation(ctx, reservation.ID)
+	checkErr(t, err, "")
+	reservation, err = reservationService.CreateReservation(ctx, &types.CreateReservationRequest{ProductID: product.ID, Quantity: 1})
+	checkErr(t, err, "")
+	_, err = reservationService.ReleaseReservation(ctx, reservation.ID)
+	checkErr(t, err, "")
+
+	// A transfer between locations and a failed movement record nothing
+	location, err := inventoryService.CreateLocation(ctx, &types.CreateLocationRequest{Code: "STORE-1", Name: "Store", Kind: types.LocationStore})
+	checkErr(t, err, "")
+	_, err = inventoryService.TransferStock(ctx, &types.StockTransferRequest{ProductID: product.ID, FromLocationID: 1, ToLocationID: location.ID, Quantity: 4})
+	checkErr(t, err, "")
+	_, err = orderService.CreateOrder(ctx, &types.CreateOrderRequest{Lines: []types.CreateOrderLineRequest{{ProductID: product.ID, Quantity: 100}}})
+	checkErr(t, err, "insufficient stock")
+
+	// The outbox lists the newest events first
+	pending, err := outbox.ListOutbox(ctx, types.OutboxPending, 0)
+	checkErr(t, err, "")
+	slices.Reverse(pending)
+	want := []types.StockChangedPayload{
+		{ProductID: product.ID, Previous: 10, Stock: 7, Delta: -3, Available: 7},
+		{ProductID: product.ID, Previous: 7, Stock: 10, Delta: 3, Available: 10},
+		{ProductID: product.ID, Previous: 10, Stock: 10, Delta: 0, Available: 8},
+		{ProductID: product.ID, Previous: 10, Stock: 8, Delta: -2, Available: 8},
+		{ProductID: product.ID, Previous: 8, Stock: 8, Delta: 0, Available: 7},
+		{ProductID: product.ID, Previous: 8, Stock: 8, Delta: 0, Available: 8},
+	}
+	if len(pending) != len(want) {
+		t.Fatalf("recorded %d events, want %d", len(pending), len(want))
+	}
+	for i, entry := range pending {
+		var got types.StockChangedPayload
+		checkErr(t, json.Unmarshal(entry.Payload, &got), "")
+		if entry.Type != types.EventStockChanged || got != want[i] {
+			t.Errorf("event %d = %s %+v, want StockChanged %+v", i, entry.Type, got, want[i])
+		}
+	}
+}
+
+func TestOrdersAndReservationsInvalidateCachedProducts(t *testing.T) {
+	ctx := context.Background()
+	db := repotest.OpenSQLite(t)
+	products := repository.NewSQLiteProductRepository(db)
+	cached := repository.NewCachedProductRepository(products, repository.NewLRUProductCache(100, time.Hour))
+	stock := services.NewStockEvents(services.NewOutboxService(repository.NewSQLiteOutboxRepository(db), services.OutboxOptions{}), products, repository.NewSQLTxManager(db))
+	stock.SetCache(cached)
+
+	// Orders invalidate through the cached repository even without StockEvents
+	orderService := services.NewOrderService(repository.NewSQLiteOrderRepository(db, cached), cached)
+	reservationService := services.NewReservationService(repository.NewSQLiteReservationRepository(db))
+	reservationService.SetStockEvents(stock)
+
+	product := &types.Product{Name: "Widget", Price: 5, Stock: 5}
+	checkErr(t, products.Create(ctx, product), "")
+	checkCached := func(step string, wantStock, wantAvailable int) {
+		t.Helper()
+		got, err := cached.GetByID(ctx, product.ID)
+		checkErr(t, err, "")
+		if got.Stock != wantStock || got.Available != wantAvailable {
+			t.Errorf("after %s cached product has stock %d, available %d, want %d and %d", step, got.Stock, got.Available, wantStock, wantAvailable)
+		}
+	}
+	checkCached("create", 5, 5)
+
+	_, err := orderService.CreateOrder(ctx, &types.CreateOrderRequest{Lines: []types.CreateOrderLineRequest{{ProductID: product.ID, Quantity: 2}}})
+	checkErr(t, err, "")
+	checkCached("an order for 2", 3, 3)
+
+	reservation, err := reservationService.CreateReservation(ctx, &types.CreateReservationRequest{ProductID: product.ID, Quantity: 1})
+	checkErr(t, err, "")
+	checkCached("a hold of 1", 3, 2)
+
+	_, err = reservationService.ReleaseReservation(ctx, reservation.ID)
+	checkErr(t, err, "")
+	checkCached("releasing the hold", 3, 3)
+}
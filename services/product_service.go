@@ -3,21 +3,153 @@ package services
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"go-circleci/repository"
 	"go-circleci/types"
 )
 
+// StockObserver is notified after a product has been created or updated, since
+// either may change its stock or reorder point
+type StockObserver interface {
+	StockChanged(ctx context.Context, product *types.Product)
+}
+
 // ProductService implements the Service interface for product operations
 type ProductService struct {
-	repo repository.ProductRepository
+	repo       repository.ProductRepository
+	tx         repository.TxManager
+	observers  []StockObserver
+	promotions *PromotionService
+	taxes      *TaxService
+	audit      *AuditService
+	outbox     *OutboxService
+}
+
+// NewProductService creates a new ProductService with the given repository. Operations
+// that take several repository calls run in a transaction of the given manager.
+func NewProductService(repo repository.ProductRepository, tx repository.TxManager) *ProductService {
+	return &ProductService{repo: repo, tx: tx}
+}
+
+// AddStockObserver registers an observer to run after every stock change
+func (s *ProductService) AddStockObserver(observer StockObserver) {
+	s.observers = append(s.observers, observer)
+}
+
+// SetPromotions sets the PromotionService used to compute effective prices.
+// Without one, products are returned at their list price.
+func (s *ProductService) SetPromotions(promotions *PromotionService) {
+	s.promotions = promotions
+}
+
+// SetTaxes sets the TaxService used to add gross and net prices when a region
+// is requested. Without one, requests for a region are rejected.
+func (s *ProductService) SetTaxes(taxes *TaxService) {
+	s.taxes = taxes
+}
+
+// SetAudit sets the AuditService that records every change to a product.
+// Without one, changes are not audited.
+func (s *ProductService) SetAudit(audit *AuditService) {
+	s.audit = audit
+}
+
+// record appends an audit entry for an operation on a product, in the
+// transaction carried by ctx so it is only kept if the change is
+func (s *ProductService) record(ctx context.Context, operation string, id int, before, after *types.Product) error {
+	if s.audit == nil {
+		return nil
+	}
+	return s.audit.RecordProductChange(ctx, operation, id, before, after)
+}
+
+// SetOutbox sets the OutboxService that domain events about products are
+// recorded in. Without one, no events are published.
+func (s *ProductService) SetOutbox(outbox *OutboxService) {
+	s.outbox = outbox
+}
+
+// publish records the domain events of a change to a product in the outbox, in
+// the transaction carried by ctx: ProductCreated, ProductUpdated or
+// ProductDeleted as eventType says, and StockChanged if the stock moved.
+// before is nil for a creation.
+func (s *ProductService) publish(ctx context.Context, eventType string, before, after *types.Product) error {
+	if s.outbox == nil {
+		return nil
+	}
+	
+	payload, err := snapshot(after)
+	if err != nil {
+		return err
+	}
+	if err := s.outbox.Record(ctx, eventType, after.ID, payload); err != nil {
+		return err
+	}
+	
+	previous := 0
+	if before != nil {
+		previous = before.Stock
+	}
+	if after.Stock == previous || eventType == types.EventProductDeleted {
+		return nil
+	}
+	return s.outbox.Record(ctx, types.EventStockChanged, after.ID, types.StockChangedPayload{
+		ProductID: after.ID,
+		Previous:  previous,
+		Stock:     after.Stock,
+		Delta:     after.Stock - previous,
+		Available: after.Available,
+	})
+}
+
+// findProduct retrieves a product by its ID whether it is in the trash or not
+func (s *ProductService) findProduct(ctx context.Context, id int) (*types.Product, error) {
+	product, err := s.repo.GetByID(ctx, id)
+	if err != sql.ErrNoRows {
+		return product, err
+	}
+
+	deleted, err := s.repo.ListDeleted(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, product := range deleted {
+		if product.ID == id {
+			return product, nil
+		}
+	}
+	return nil, sql.ErrNoRows
 }
 
-// NewProductService creates a new ProductService with the given repository
-func NewProductService(repo repository.ProductRepository) *ProductService {
-	return &ProductService{repo: repo}
+// applyPricing sets the effective price of products, and their tax when a
+// region is requested, for the pricing context carried by ctx
+func (s *ProductService) applyPricing(ctx context.Context, products ...*types.Product) error {
+	if s.promotions != nil {
+		if err := s.promotions.ApplyPricing(ctx, products...); err != nil {
+			return err
+		}
+	}
+	
+	if region := pricingContextFrom(ctx).Region; region != "" && s.taxes == nil {
+		return fmt.Errorf("invalid region %q: taxes are not enabled", region)
+	} else if s.taxes != nil {
+		return s.taxes.ApplyTax(ctx, products...)
+	}
+	
+	return nil
+}
+
+// notifyStockChanged runs every registered observer for the given product
+func (s *ProductService) notifyStockChanged(ctx context.Context, product *types.Product) {
+	for _, observer := range s.observers {
+		observer.StockChanged(ctx, product)
+	}
 }
 
 // GetAllProducts retrieves all products from the repository
@@ -32,6 +164,10 @@ func (s *ProductService) GetAllProducts(ctx context.Context) ([]*types.Product,
 		return []*types.Product{}, nil
 	}
 	
+	if err := s.applyPricing(ctx, products...); err != nil {
+		return nil, err
+	}
+	
 	return products, nil
 }
 
@@ -50,9 +186,58 @@ func (s *ProductService) GetProductByID(ctx context.Context, id int) (*types.Pro
 		return nil, fmt.Errorf("failed to get product: %w", err)
 	}
 	
+	if err := s.applyPricing(ctx, product); err != nil {
+		return nil, err
+	}
+	
 	return product, nil
 }
 
+// GetProductsByIDs retrieves the products with the given IDs in one read, in
+// ID order. Missing products are left out rather than reported.
+func (s *ProductService) GetProductsByIDs(ctx context.Context, ids []int) ([]*types.Product, error) {
+	for _, id := range ids {
+		if id <= 0 {
+			return nil, errors.New("invalid product ID: must be greater than 0")
+		}
+	}
+
+	products, err := s.repo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get products: %w", err)
+	}
+
+	if err := s.applyPricing(ctx, products...); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// ListProductsPage retrieves the products a filter matches with IDs above
+// after, at most limit of them in ID order, with the number matching in all.
+// The filter applies to list prices; the page is priced like GetAllProducts.
+func (s *ProductService) ListProductsPage(ctx context.Context, filter types.ProductFilter, after, limit int) (*types.ProductPage, error) {
+	if after < 0 {
+		return nil, errors.New("invalid product ID: must be greater than or equal to 0")
+	}
+	if limit <= 0 {
+		return nil, errors.New("page limit must be greater than 0")
+	}
+
+	page, err := s.repo.ListPage(ctx, filter, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list products: %w", err)
+	}
+	if page.Products == nil {
+		page.Products = []*types.Product{}
+	}
+
+	if err := s.applyPricing(ctx, page.Products...); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
 // CreateProduct creates a new product with input validation
 func (s *ProductService) CreateProduct(ctx context.Context, req *types.CreateProductRequest) (*types.Product, error) {
 	// Validate required fields
@@ -68,19 +253,48 @@ func (s *ProductService) CreateProduct(ctx context.Context, req *types.CreatePro
 		return nil, errors.New("product stock must be greater than or equal to 0")
 	}
 	
+	if req.ReorderPoint < 0 {
+		return nil, errors.New("product reorder_point must be greater than or equal to 0")
+	}
+	
+	if req.ReorderQty < 0 {
+		return nil, errors.New("product reorder_qty must be greater than or equal to 0")
+	}
+	
 	// Create product entity
 	product := &types.Product{
-		Name:        req.Name,
-		Description: req.Description,
-		Price:       req.Price,
-		Stock:       req.Stock,
+		SKU:          strings.TrimSpace(req.SKU),
+		Name:         req.Name,
+		Description:  req.Description,
+		Category:     strings.TrimSpace(req.Category),
+		TaxClass:     normalizeTaxClass(req.TaxClass),
+		Price:        req.Price,
+		Stock:        req.Stock,
+		ReorderPoint: req.ReorderPoint,
+		ReorderQty:   req.ReorderQty,
 	}
 	
-	// Call repository to create
-	if err := s.repo.Create(ctx, product); err != nil {
-		return nil, fmt.Errorf("failed to create product: %w", err)
+	// Create and record the creation in one transaction
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.Create(ctx, product); err == repository.ErrSKUTaken {
+			return fmt.Errorf("product sku %q already exists", product.SKU)
+		} else if err != nil {
+			return fmt.Errorf("failed to create product: %w", err)
+		}
+		if err := s.record(ctx, types.AuditCreate, product.ID, nil, product); err != nil {
+			return err
+		}
+		return s.publish(ctx, types.EventProductCreated, nil, product)
+	})
+	if err != nil {
+		return nil, err
 	}
 	
+	if err := s.applyPricing(ctx, product); err != nil {
+		return nil, err
+	}
+	
+	s.notifyStockChanged(ctx, product)
 	return product, nil
 }
 
@@ -104,40 +318,680 @@ func (s *ProductService) UpdateProduct(ctx context.Context, id int, req *types.U
 		return nil, errors.New("product stock must be greater than or equal to 0")
 	}
 	
+	if req.ReorderPoint < 0 {
+		return nil, errors.New("product reorder_point must be greater than or equal to 0")
+	}
+	
+	if req.ReorderQty < 0 {
+		return nil, errors.New("product reorder_qty must be greater than or equal to 0")
+	}
+	
 	// Create product entity with ID
 	product := &types.Product{
-		ID:          id,
-		Name:        req.Name,
-		Description: req.Description,
-		Price:       req.Price,
-		Stock:       req.Stock,
+		ID:           id,
+		SKU:          strings.TrimSpace(req.SKU),
+		Name:         req.Name,
+		Description:  req.Description,
+		Category:     strings.TrimSpace(req.Category),
+		TaxClass:     normalizeTaxClass(req.TaxClass),
+		Price:        req.Price,
+		Stock:        req.Stock,
+		ReorderPoint: req.ReorderPoint,
+		ReorderQty:   req.ReorderQty,
 	}
 	
-	// Call repository to update
-	if err := s.repo.Update(ctx, product); err == sql.ErrNoRows {
-		return nil, fmt.Errorf("product with ID %d not found", id)
-	} else if err != nil {
-		return nil, fmt.Errorf("failed to update product: %w", err)
+	// Update and re-read in one transaction so the returned product is the one
+	// that was written, with on-hand and available stock reflecting active reservations
+	var updated *types.Product
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		before, err := s.repo.GetByID(ctx, id)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("product with ID %d not found", id)
+		} else if err != nil {
+			return fmt.Errorf("failed to update product: %w", err)
+		}
+		
+		if err := s.repo.Update(ctx, product); err == sql.ErrNoRows {
+			return fmt.Errorf("product with ID %d not found", id)
+		} else if err == repository.ErrInsufficientStock {
+			// Stock set on the product is booked against the main warehouse
+			return errors.New("product stock must be greater than or equal to the units held at other locations")
+		} else if err == repository.ErrSKUTaken {
+			return fmt.Errorf("product sku %q already exists", product.SKU)
+		} else if err != nil {
+			return fmt.Errorf("failed to update product: %w", err)
+		}
+		
+		updated, err = s.GetProductByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.record(ctx, types.AuditUpdate, id, before, updated); err != nil {
+			return err
+		}
+		return s.publish(ctx, types.EventProductUpdated, before, updated)
+	})
+	if err != nil {
+		return nil, err
 	}
 	
-	return product, nil
+	s.notifyStockChanged(ctx, updated)
+	return updated, nil
 }
 
-// DeleteProduct deletes a product by its ID with validation
+// AdjustStock changes a product's stock by delta, such as a count from a
+// warehouse scanner, through the same validation, auditing and events as
+// UpdateProduct, and returns the product
+func (s *ProductService) AdjustStock(ctx context.Context, id, delta int) (*types.Product, error) {
+	if id <= 0 {
+		return nil, errors.New("invalid product ID: must be greater than 0")
+	}
+	if delta == 0 {
+		return nil, errors.New("stock delta must be non-zero")
+	}
+
+	// Read and write the stock in one transaction so concurrent adjustments add up.
+	// The transaction's service skips pricing and observers until it commits.
+	var adjusted *types.Product
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		product, err := s.repo.GetByID(ctx, id)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("product with ID %d not found", id)
+		} else if err != nil {
+			return fmt.Errorf("failed to adjust stock: %w", err)
+		}
+
+		if product.Stock+delta < 0 {
+			return fmt.Errorf("insufficient stock for product %d: %d on hand, cannot remove %d", id, product.Stock, -delta)
+		}
+
+		txService := &ProductService{repo: s.repo, tx: s.tx, audit: s.audit, outbox: s.outbox}
+		adjusted, err = txService.UpdateProduct(ctx, id, &types.UpdateProductRequest{
+			SKU:          product.SKU,
+			Name:         product.Name,
+			Description:  product.Description,
+			Category:     product.Category,
+			TaxClass:     product.TaxClass,
+			Price:        product.Price,
+			Stock:        product.Stock + delta,
+			ReorderPoint: product.ReorderPoint,
+			ReorderQty:   product.ReorderQty,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.applyPricing(ctx, adjusted); err != nil {
+		return nil, err
+	}
+
+	s.notifyStockChanged(ctx, adjusted)
+	return adjusted, nil
+}
+
+// DeleteProduct moves a product to the trash by its ID with validation. It can
+// be restored with RestoreProduct until it is purged.
 func (s *ProductService) DeleteProduct(ctx context.Context, id int) error {
 	// Validate ID
 	if id <= 0 {
 		return errors.New("invalid product ID: must be greater than 0")
 	}
 	
-	// Call repository to delete
-	if err := s.repo.Delete(ctx, id); err == sql.ErrNoRows {
-		return fmt.Errorf("product with ID %d not found", id)
-	} else if err != nil {
-		return fmt.Errorf("failed to delete product: %w", err)
+	// Delete and record the deletion in one transaction
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		before, err := s.repo.GetByID(ctx, id)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("product with ID %d not found", id)
+		} else if err != nil {
+			return fmt.Errorf("failed to delete product: %w", err)
+		}
+		
+		if err := s.repo.Delete(ctx, id); err == sql.ErrNoRows {
+			return fmt.Errorf("product with ID %d not found", id)
+		} else if err != nil {
+			return fmt.Errorf("failed to delete product: %w", err)
+		}
+		
+		after := *before
+		deletedAt := time.Now().UTC()
+		after.DeletedAt = &deletedAt
+		if err := s.record(ctx, types.AuditDelete, id, before, &after); err != nil {
+			return err
+		}
+		return s.publish(ctx, types.EventProductDeleted, before, &after)
+	})
+}
+
+// ListDeletedProducts retrieves the products in the trash, most recently deleted first
+func (s *ProductService) ListDeletedProducts(ctx context.Context) ([]*types.Product, error) {
+	products, err := s.repo.ListDeleted(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deleted products: %w", err)
+	}
+
+	if products == nil {
+		return []*types.Product{}, nil
+	}
+	return products, nil
+}
+
+// RestoreProduct takes a product out of the trash by its ID and returns it. It
+// fails if another product has taken its SKU since it was deleted.
+func (s *ProductService) RestoreProduct(ctx context.Context, id int) (*types.Product, error) {
+	if id <= 0 {
+		return nil, errors.New("invalid product ID: must be greater than 0")
+	}
+
+	var restored *types.Product
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		before, err := s.findProduct(ctx, id)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to restore product: %w", err)
+		}
+
+		if err := s.repo.Restore(ctx, id); err == sql.ErrNoRows {
+			return fmt.Errorf("product with ID %d not found in trash", id)
+		} else if errors.Is(err, repository.ErrSKUTaken) {
+			return fmt.Errorf("product with ID %d cannot be restored: its sku is in use by another product", id)
+		} else if err != nil {
+			return fmt.Errorf("failed to restore product: %w", err)
+		}
+
+		restored, err = s.GetProductByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.record(ctx, types.AuditRestore, id, before, restored); err != nil {
+			return err
+		}
+		return s.publish(ctx, types.EventProductUpdated, before, restored)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The product counts towards low stock alerts again
+	s.notifyStockChanged(ctx, restored)
+	return restored, nil
+}
+
+// PurgeProduct permanently deletes a product by its ID, whether it is in the
+// trash or not. It cannot be restored afterwards.
+func (s *ProductService) PurgeProduct(ctx context.Context, id int) error {
+	if id <= 0 {
+		return errors.New("invalid product ID: must be greater than 0")
+	}
+
+	return s.tx.InTx(ctx, func(ctx context.Context) error {
+		before, err := s.findProduct(ctx, id)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("product with ID %d not found", id)
+		} else if err != nil {
+			return fmt.Errorf("failed to purge product: %w", err)
+		}
+
+		if err := s.repo.Purge(ctx, id); err == sql.ErrNoRows {
+			return fmt.Errorf("product with ID %d not found", id)
+		} else if err != nil {
+			return fmt.Errorf("failed to purge product: %w", err)
+		}
+
+		if err := s.record(ctx, types.AuditPurge, id, before, nil); err != nil {
+			return err
+		}
+		// Products in the trash were already announced as deleted
+		if before.DeletedAt != nil {
+			return nil
+		}
+		return s.publish(ctx, types.EventProductDeleted, before, before)
+	})
+}
+
+// PurgeDeletedProducts permanently deletes the products that have been in the
+// trash for longer than retention and returns how many were deleted
+func (s *ProductService) PurgeDeletedProducts(ctx context.Context, retention time.Duration) (int, error) {
+	cutoff := time.Now().Add(-retention)
+	purged := 0
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		// Record each product leaving the trash before it is gone
+		if s.audit != nil {
+			deleted, err := s.repo.ListDeleted(ctx)
+			if err != nil {
+				return err
+			}
+			for _, product := range deleted {
+				if product.DeletedAt != nil && product.DeletedAt.Before(cutoff) {
+					if err := s.record(ctx, types.AuditPurge, product.ID, product, nil); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		var err error
+		purged, err = s.repo.PurgeDeleted(ctx, cutoff)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted products: %w", err)
+	}
+	return purged, nil
+}
+
+// RunPurger permanently deletes products that have been in the trash for
+// longer than retention every interval until ctx is cancelled
+func (s *ProductService) RunPurger(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.PurgeDeletedProducts(ctx, retention)
+			if err != nil {
+				fmt.Printf("product purger err=%v\n", err)
+				continue
+			}
+			if n > 0 {
+				fmt.Printf("product purger purged=%d\n", n)
+			}
+		}
+	}
+}
+
+// asOfPricing returns ctx with its pricing context set to the time at unless
+// it names a time of its own, so products are priced as they were then
+func asOfPricing(ctx context.Context, at time.Time) context.Context {
+	pc := pricingContextFrom(ctx)
+	if pc.At.IsZero() {
+		pc.At = at
+	}
+	return WithPricingContext(ctx, pc)
+}
+
+// GetProductAsOf retrieves a product by its ID as it was at the given time,
+// priced with the promotions running then
+func (s *ProductService) GetProductAsOf(ctx context.Context, id int, at time.Time) (*types.Product, error) {
+	if id <= 0 {
+		return nil, errors.New("invalid product ID: must be greater than 0")
+	}
+
+	product, err := s.repo.GetAsOf(ctx, id, at)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("product with ID %d not found as of %s", id, at.UTC().Format(time.RFC3339))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+
+	if err := s.applyPricing(asOfPricing(ctx, at), product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// GetAllProductsAsOf retrieves the products that existed outside the trash at
+// the given time as they were then, priced with the promotions running then
+func (s *ProductService) GetAllProductsAsOf(ctx context.Context, at time.Time) ([]*types.Product, error) {
+	products, err := s.repo.GetAllAsOf(ctx, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get products: %w", err)
+	}
+
+	if err := s.applyPricing(asOfPricing(ctx, at), products...); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// ListProductVersions retrieves every version of a product, oldest first,
+// including those of a product in the trash or purged
+func (s *ProductService) ListProductVersions(ctx context.Context, id int) ([]*types.ProductVersion, error) {
+	if id <= 0 {
+		return nil, errors.New("invalid product ID: must be greater than 0")
+	}
+
+	versions, err := s.repo.ListVersions(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product versions: %w", err)
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("product with ID %d not found", id)
+	}
+	return versions, nil
+}
+
+// DiffProductVersions lists the catalog fields that changed between two
+// versions of a product. from may come after to, which lists the changes that
+// would undo the ones in between.
+func (s *ProductService) DiffProductVersions(ctx context.Context, id, from, to int) (*types.ProductVersionDiff, error) {
+	if from <= 0 || to <= 0 {
+		return nil, errors.New("invalid version: must be greater than 0")
+	}
+
+	versions, err := s.ListProductVersions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	find := func(number int) (*types.ProductVersion, error) {
+		for _, version := range versions {
+			if version.Version == number {
+				return version, nil
+			}
+		}
+		return nil, fmt.Errorf("version %d of product %d not found", number, id)
+	}
+	fromVersion, err := find(from)
+	if err != nil {
+		return nil, err
+	}
+	toVersion, err := find(to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compare the catalog fields only, leaving out when each version was current
+	fields := func(version *types.ProductVersion) (json.RawMessage, error) {
+		catalog := *version
+		catalog.Version = 0
+		catalog.ValidFrom = time.Time{}
+		catalog.ValidTo = nil
+		return json.Marshal(catalog)
+	}
+	fromFields, err := fields(fromVersion)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := fields(toVersion)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := diffSnapshots(fromFields, toFields)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.ProductVersionDiff{ProductID: id, From: fromVersion, To: toVersion, Changes: changes}, nil
+}
+
+// maxBatchOperations bounds the size of a product batch, which holds a
+// write transaction open for its whole duration in atomic mode
+const maxBatchOperations = 1000
+
+// BatchProducts applies a list of create, update and delete operations. In atomic
+// mode they run in one transaction and the first failure rolls back the whole
+// batch; in best-effort mode each operation is applied on its own. Observers and
+// pricing run only for changes that were committed.
+func (s *ProductService) BatchProducts(ctx context.Context, req *types.ProductBatchRequest) (*types.ProductBatchResponse, error) {
+	mode := req.Mode
+	if mode == "" {
+		mode = types.BatchAtomic
+	}
+	if mode != types.BatchAtomic && mode != types.BatchBestEffort {
+		return nil, fmt.Errorf("invalid batch mode %q: must be %s or %s", mode, types.BatchAtomic, types.BatchBestEffort)
+	}
+	
+	if len(req.Operations) == 0 {
+		return nil, errors.New("batch operations are required")
 	}
 	
-	return nil
+	if len(req.Operations) > maxBatchOperations {
+		return nil, fmt.Errorf("batch must be at most %d operations", maxBatchOperations)
+	}
+	
+	res := &types.ProductBatchResponse{Mode: mode, Results: make([]types.ProductBatchResult, len(req.Operations))}
+	resetResults := func() {
+		for i, op := range req.Operations {
+			res.Results[i] = types.ProductBatchResult{Index: i, Op: op.Op, ID: op.ID}
+		}
+	}
+	resetResults()
+	
+	if mode == types.BatchBestEffort {
+		for i, op := range req.Operations {
+			product, err := s.applyBatchOperation(ctx, op)
+			s.recordBatchResult(&res.Results[i], product, err)
+		}
+		res.Committed = true
+	} else {
+		failed := -1
+		err := s.tx.InTx(ctx, func(ctx context.Context) error {
+			// A transaction retried after losing a race starts over, so nothing
+			// recorded by an earlier attempt may leak into the response
+			failed = -1
+			resetResults()
+			
+			// The transaction's service skips pricing and observers, which would
+			// otherwise see changes that may yet be rolled back. Its updates run
+			// in savepoints nested inside the batch's transaction.
+			txService := &ProductService{repo: s.repo, tx: s.tx, audit: s.audit, outbox: s.outbox}
+			for i, op := range req.Operations {
+				product, err := txService.applyBatchOperation(ctx, op)
+				if err != nil {
+					failed = i
+					return err
+				}
+				s.recordBatchResult(&res.Results[i], product, nil)
+			}
+			return nil
+		})
+		
+		if err != nil && failed < 0 {
+			return nil, fmt.Errorf("failed to commit batch: %w", err)
+		}
+		
+		if err != nil {
+			for i := range res.Results {
+				// Rolled back products were never saved, so only the requested IDs remain
+				result := &res.Results[i]
+				result.ID = req.Operations[i].ID
+				result.Product = nil
+				switch {
+				case i < failed:
+					result.Status = types.BatchRolledBack
+				case i == failed:
+					result.Status = types.BatchFailed
+					result.Error = err.Error()
+				default:
+					result.Status = types.BatchSkipped
+				}
+			}
+		} else {
+			res.Committed = true
+			for i := range res.Results {
+				if product := res.Results[i].Product; product != nil {
+					if err := s.applyPricing(ctx, product); err != nil {
+						return nil, err
+					}
+					s.notifyStockChanged(ctx, product)
+				}
+			}
+		}
+	}
+	
+	for _, result := range res.Results {
+		switch result.Status {
+		case types.BatchOK:
+			res.Succeeded++
+		case types.BatchFailed:
+			res.Failed++
+		}
+	}
+	
+	return res, nil
+}
+
+// applyBatchOperation applies a single batch operation through the service's own
+// methods, so batches get the same validation as single-product requests
+func (s *ProductService) applyBatchOperation(ctx context.Context, op types.ProductBatchOperation) (*types.Product, error) {
+	switch op.Op {
+	case types.BatchCreate:
+		if op.Product == nil {
+			return nil, errors.New("product is required for create")
+		}
+		return s.CreateProduct(ctx, op.Product)
+	case types.BatchUpdate:
+		if op.Product == nil {
+			return nil, errors.New("product is required for update")
+		}
+		req := types.UpdateProductRequest(*op.Product)
+		return s.UpdateProduct(ctx, op.ID, &req)
+	case types.BatchDelete:
+		return nil, s.DeleteProduct(ctx, op.ID)
+	default:
+		return nil, fmt.Errorf("invalid batch op %q: must be %s, %s or %s", op.Op, types.BatchCreate, types.BatchUpdate, types.BatchDelete)
+	}
+}
+
+// recordBatchResult fills in the outcome of a best-effort batch operation
+func (s *ProductService) recordBatchResult(result *types.ProductBatchResult, product *types.Product, err error) {
+	if err != nil {
+		result.Status = types.BatchFailed
+		result.Error = err.Error()
+		return
+	}
+	result.Status = types.BatchOK
+	result.Product = product
+	if product != nil {
+		result.ID = product.ID
+	}
+}
+
+// ImportProducts creates or updates each row's product by SKU, reporting the
+// outcome of every row. Rows are applied independently, so a failing row does
+// not stop the others. In a dry run nothing is written and each result is what
+// would have happened.
+func (s *ProductService) ImportProducts(ctx context.Context, rows []*types.ProductImportRow, dryRun bool) ([]types.ImportRowResult, error) {
+	results := make([]types.ImportRowResult, 0, len(rows))
+	for _, row := range rows {
+		result := types.ImportRowResult{Line: row.Line, SKU: strings.TrimSpace(row.SKU)}
+		
+		product, created, err := s.importRow(ctx, row, dryRun)
+		if err != nil {
+			// A failed context fails the whole import rather than every remaining row
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			result.Action = types.ImportFailed
+			result.Error = err.Error()
+		} else if created {
+			result.Action = types.ImportCreated
+			result.ProductID = product.ID
+		} else {
+			result.Action = types.ImportUpdated
+			result.ProductID = product.ID
+		}
+		
+		results = append(results, result)
+	}
+	
+	return results, nil
+}
+
+// importRow creates or updates the product with the row's SKU and reports
+// whether it was created. In a dry run it only validates the row and returns
+// the product that would be updated, or an unsaved product if one would be created.
+func (s *ProductService) importRow(ctx context.Context, row *types.ProductImportRow, dryRun bool) (*types.Product, bool, error) {
+	sku := strings.TrimSpace(row.SKU)
+	if sku == "" {
+		return nil, false, errors.New("product sku is required")
+	}
+	
+	existing, err := s.repo.GetBySKU(ctx, sku)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, false, fmt.Errorf("failed to get product: %w", err)
+	}
+	
+	if dryRun {
+		if row.ReorderPoint < 0 {
+			return nil, false, errors.New("product reorder_point must be greater than or equal to 0")
+		}
+		if row.ReorderQty < 0 {
+			return nil, false, errors.New("product reorder_qty must be greater than or equal to 0")
+		}
+		if existing == nil {
+			if strings.TrimSpace(row.Name) == "" {
+				return nil, false, errors.New("product name is required")
+			}
+			return &types.Product{}, true, nil
+		}
+		return existing, false, nil
+	}
+	
+	if existing == nil {
+		product, err := s.CreateProduct(ctx, &types.CreateProductRequest{
+			SKU:          sku,
+			Name:         row.Name,
+			Description:  row.Description,
+			Category:     row.Category,
+			TaxClass:     row.TaxClass,
+			Price:        row.Price,
+			Stock:        row.Stock,
+			ReorderPoint: row.ReorderPoint,
+			ReorderQty:   row.ReorderQty,
+		})
+		return product, true, err
+	}
+	
+	product, err := s.UpdateProduct(ctx, existing.ID, importUpdate(row, existing))
+	return product, false, err
+}
+
+// importUpdate returns the update an import row makes to an existing product:
+// the row's value of every column it carries and the product's own value of
+// the rest, so a file with only some columns leaves the others untouched
+func importUpdate(row *types.ProductImportRow, existing *types.Product) *types.UpdateProductRequest {
+	req := &types.UpdateProductRequest{
+		SKU:          existing.SKU,
+		Name:         existing.Name,
+		Description:  existing.Description,
+		Category:     existing.Category,
+		TaxClass:     existing.TaxClass,
+		Price:        existing.Price,
+		Stock:        existing.Stock,
+		ReorderPoint: existing.ReorderPoint,
+		ReorderQty:   existing.ReorderQty,
+	}
+	
+	carries := func(column string) bool {
+		return row.Columns == nil || slices.Contains(row.Columns, column)
+	}
+	if carries("name") {
+		req.Name = row.Name
+	}
+	if carries("description") {
+		req.Description = row.Description
+	}
+	if carries("category") {
+		req.Category = row.Category
+	}
+	if carries("tax_class") {
+		req.TaxClass = row.TaxClass
+	}
+	if carries("price") {
+		req.Price = row.Price
+	}
+	if carries("stock") {
+		req.Stock = row.Stock
+	}
+	if carries("reorder_point") {
+		req.ReorderPoint = row.ReorderPoint
+	}
+	if carries("reorder_qty") {
+		req.ReorderQty = row.ReorderQty
+	}
+	return req
+}
+
+// ExportProducts calls fn for every product in ID order at its list price,
+// streaming from the repository so the catalog is never loaded at once
+func (s *ProductService) ExportProducts(ctx context.Context, fn func(product *types.Product) error) error {
+	return s.repo.ForEach(ctx, fn)
 }
 
 // GetCatFact is a stub implementation to satisfy the Service interface
@@ -0,0 +1,499 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go-circleci/repository"
+	"go-circleci/repository/repotest"
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+// newProductService returns a ProductService backed by an empty in-memory repository
+func newProductService(t *testing.T) *services.ProductService {
+	t.Helper()
+	repo := repository.NewMemoryProductRepository()
+	return services.NewProductService(repo, repository.NewMemoryTxManager(repo))
+}
+
+// sqlServices is a ProductService over an empty SQLite database together with
+// the services that audit its changes and relay its events
+type sqlServices struct {
+	product  *services.ProductService
+	audit    *services.AuditService
+	outbox   *services.OutboxService
+	webhooks *services.WebhookService
+}
+
+// sqlOptions tunes the services returned by newSQLServices
+type sqlOptions struct {
+	outbox     services.OutboxOptions
+	webhook    services.WebhookOptions
+	publishers []services.Publisher
+}
+
+// newSQLServices returns a ProductService backed by an empty SQLite database
+// that audits every change and records its events in an outbox, relayed to the
+// webhooks and then to opts.publishers
+func newSQLServices(t *testing.T, opts sqlOptions) sqlServices {
+	t.Helper()
+	db := repotest.OpenSQLite(t)
+	s := sqlServices{
+		product:  services.NewProductService(repository.NewSQLiteProductRepository(db), repository.NewSQLTxManager(db)),
+		audit:    services.NewAuditService(repository.NewSQLiteAuditRepository(db)),
+		webhooks: services.NewWebhookService(repository.NewSQLiteWebhookRepository(db), opts.webhook),
+	}
+	s.outbox = services.NewOutboxService(repository.NewSQLiteOutboxRepository(db), opts.outbox, append([]services.Publisher{s.webhooks}, opts.publishers...)...)
+	s.product.SetAudit(s.audit)
+	s.product.SetOutbox(s.outbox)
+	return s
+}
+
+// checkErr fails the test unless err contains want, or is nil when want is empty
+func checkErr(t *testing.T, err error, want string) {
+	t.Helper()
+	if want == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("error = %v, want one containing %q", err, want)
+	}
+}
+
+func TestProductServiceCreateProduct(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     types.CreateProductRequest
+		wantErr string
+	}{
+		{"valid", types.CreateProductRequest{Name: "Widget", Price: 9.99, Stock: 5}, ""},
+		{"free and out of stock", types.CreateProductRequest{Name: "Sample", Price: 0, Stock: 0}, ""},
+		{"missing name", types.CreateProductRequest{Price: 1, Stock: 1}, "product name is required"},
+		{"negative price", types.CreateProductRequest{Name: "Widget", Price: -0.01}, "product price must be greater than or equal to 0"},
+		{"negative stock", types.CreateProductRequest{Name: "Widget", Stock: -1}, "product stock must be greater than or equal to 0"},
+		{"negative reorder point", types.CreateProductRequest{Name: "Widget", ReorderPoint: -1}, "product reorder_point must be greater than or equal to 0"},
+		{"negative reorder quantity", types.CreateProductRequest{Name: "Widget", ReorderQty: -1}, "product reorder_qty must be greater than or equal to 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newProductService(t)
+			product, err := svc.CreateProduct(context.Background(), &tt.req)
+			checkErr(t, err, tt.wantErr)
+			if err != nil {
+				return
+			}
+
+			if product.ID <= 0 || product.Name != tt.req.Name || product.Price != tt.req.Price || product.Stock != tt.req.Stock {
+				t.Errorf("CreateProduct = %+v, want the requested fields and an ID", product)
+			}
+			if product.TaxClass != types.DefaultTaxClass {
+				t.Errorf("TaxClass = %q, want the default %q", product.TaxClass, types.DefaultTaxClass)
+			}
+		})
+	}
+}
+
+func TestProductServiceCreateProductNormalizesFields(t *testing.T) {
+	svc := newProductService(t)
+	product, err := svc.CreateProduct(context.Background(), &types.CreateProductRequest{
+		Name: "Widget", SKU: "  W-1 ", Category: " tools ", TaxClass: "Reduced",
+	})
+	checkErr(t, err, "")
+
+	if product.SKU != "W-1" || product.Category != "tools" || product.TaxClass != "reduced" {
+		t.Errorf("CreateProduct = sku %q, category %q, tax class %q, want W-1, tools, reduced", product.SKU, product.Category, product.TaxClass)
+	}
+
+	_, err = svc.CreateProduct(context.Background(), &types.CreateProductRequest{Name: "Other", SKU: "W-1"})
+	checkErr(t, err, `product sku "W-1" already exists`)
+}
+
+func TestProductServiceGetProductByID(t *testing.T) {
+	svc := newProductService(t)
+	created, err := svc.CreateProduct(context.Background(), &types.CreateProductRequest{Name: "Widget", Price: 2.5, Stock: 3})
+	checkErr(t, err, "")
+
+	tests := []struct {
+		name    string
+		id      int
+		wantErr string
+	}{
+		{"existing", created.ID, ""},
+		{"missing", 999, "product with ID 999 not found"},
+		{"zero", 0, "invalid product ID: must be greater than 0"},
+		{"negative", -1, "invalid product ID: must be greater than 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product, err := svc.GetProductByID(context.Background(), tt.id)
+			checkErr(t, err, tt.wantErr)
+			if err == nil && (product.ID != created.ID || product.EffectivePrice != 2.5) {
+				t.Errorf("GetProductByID = %+v, want product %d at its list price", product, created.ID)
+			}
+		})
+	}
+}
+
+func TestProductServiceUpdateProduct(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      int
+		req     types.UpdateProductRequest
+		wantErr string
+	}{
+		{"valid", 1, types.UpdateProductRequest{Name: "Renamed", Price: 3, Stock: 7}, ""},
+		{"missing product", 999, types.UpdateProductRequest{Name: "Renamed"}, "product with ID 999 not found"},
+		{"invalid ID", 0, types.UpdateProductRequest{Name: "Renamed"}, "invalid product ID: must be greater than 0"},
+		{"missing name", 1, types.UpdateProductRequest{Price: 3}, "product name is required"},
+		{"negative price", 1, types.UpdateProductRequest{Name: "Renamed", Price: -1}, "product price must be greater than or equal to 0"},
+		{"negative stock", 1, types.UpdateProductRequest{Name: "Renamed", Stock: -1}, "product stock must be greater than or equal to 0"},
+		{"taken SKU", 1, types.UpdateProductRequest{Name: "Renamed", SKU: "OTHER"}, `product sku "OTHER" already exists`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newProductService(t)
+			ctx := context.Background()
+			_, err := svc.CreateProduct(ctx, &types.CreateProductRequest{Name: "Widget", Price: 1, Stock: 1})
+			checkErr(t, err, "")
+			_, err = svc.CreateProduct(ctx, &types.CreateProductRequest{Name: "Other", SKU: "OTHER"})
+			checkErr(t, err, "")
+
+			product, err := svc.UpdateProduct(ctx, tt.id, &tt.req)
+			checkErr(t, err, tt.wantErr)
+			if err != nil {
+				return
+			}
+
+			if product.Name != tt.req.Name || product.Price != tt.req.Price || product.Stock != tt.req.Stock || product.Available != tt.req.Stock {
+				t.Errorf("UpdateProduct = %+v, want the requested fields", product)
+			}
+		})
+	}
+}
+
+func TestProductServiceDeleteProduct(t *testing.T) {
+	svc := newProductService(t)
+	ctx := context.Background()
+	created, err := svc.CreateProduct(ctx, &types.CreateProductRequest{Name: "Widget"})
+	checkErr(t, err, "")
+
+	checkErr(t, svc.DeleteProduct(ctx, 0), "invalid product ID: must be greater than 0")
+	checkErr(t, svc.DeleteProduct(ctx, 999), "product with ID 999 not found")
+	checkErr(t, svc.DeleteProduct(ctx, created.ID), "")
+
+	_, err = svc.GetProductByID(ctx, created.ID)
+	checkErr(t, err, "not found")
+}
+
+func TestProductServiceGetAllProducts(t *testing.T) {
+	svc := newProductService(t)
+	ctx := context.Background()
+
+	products, err := svc.GetAllProducts(ctx)
+	checkErr(t, err, "")
+	if products == nil || len(products) != 0 {
+		t.Errorf("GetAllProducts of an empty catalogue = %#v, want an empty, non-nil slice", products)
+	}
+
+	for _, name := range []string{"A", "B"} {
+		_, err := svc.CreateProduct(ctx, &types.CreateProductRequest{Name: name})
+		checkErr(t, err, "")
+	}
+
+	products, err = svc.GetAllProducts(ctx)
+	checkErr(t, err, "")
+	if len(products) != 2 || products[0].Name != "A" || products[1].Name != "B" {
+		t.Errorf("GetAllProducts = %v, want A and B", products)
+	}
+
+	// A region needs a TaxService to price products
+	_, err = svc.GetAllProducts(services.WithPricingContext(ctx, types.PricingContext{Region: "GB"}))
+	checkErr(t, err, `invalid region "GB"`)
+}
+
+func TestProductServiceListProductsPage(t *testing.T) {
+	svc := newProductService(t)
+	ctx := context.Background()
+
+	page, err := svc.ListProductsPage(ctx, types.ProductFilter{}, 0, 10)
+	checkErr(t, err, "")
+	if page.Products == nil || len(page.Products) != 0 || page.Total != 0 {
+		t.Errorf("ListProductsPage of an empty catalogue = %#v, want an empty, non-nil page", page)
+	}
+
+	for _, name := range []string{"A", "B", "C"} {
+		_, err := svc.CreateProduct(ctx, &types.CreateProductRequest{Name: name, Category: "tools"})
+		checkErr(t, err, "")
+	}
+
+	page, err = svc.ListProductsPage(ctx, types.ProductFilter{Category: "tools"}, 1, 1)
+	checkErr(t, err, "")
+	if len(page.Products) != 1 || page.Products[0].Name != "B" || page.Total != 3 {
+		t.Errorf("ListProductsPage after 1 = %v of %d, want B of 3", page.Products, page.Total)
+	}
+
+	_, err = svc.ListProductsPage(ctx, types.ProductFilter{}, -1, 10)
+	checkErr(t, err, "invalid product ID")
+	_, err = svc.ListProductsPage(ctx, types.ProductFilter{}, 0, 0)
+	checkErr(t, err, "page limit must be greater than 0")
+}
+
+func TestProductServiceBatchProducts(t *testing.T) {
+	ctx := context.Background()
+	widget := &types.CreateProductRequest{Name: "Widget", Price: 1, Stock: 1}
+
+	tests := []struct {
+		name          string
+		req           types.ProductBatchRequest
+		wantErr       string
+		wantCommitted bool
+		wantStatuses  []string
+		wantProducts  int
+	}{
+		{
+			name:    "invalid mode",
+			req:     types.ProductBatchRequest{Mode: "sometimes", Operations: []types.ProductBatchOperation{{Op: types.BatchCreate, Product: widget}}},
+			wantErr: `invalid batch mode "sometimes"`,
+		},
+		{
+			name:    "no operations",
+			req:     types.ProductBatchRequest{},
+			wantErr: "batch operations are required",
+		},
+		{
+			name: "atomic success",
+			req: types.ProductBatchRequest{Operations: []types.ProductBatchOperation{
+				{Op: types.BatchCreate, Product: widget},
+				{Op: types.BatchUpdate, ID: 1, Product: &types.CreateProductRequest{Name: "Renamed"}},
+			}},
+			wantCommitted: true,
+			wantStatuses:  []string{types.BatchOK, types.BatchOK},
+			wantProducts:  2,
+		},
+		{
+			name: "atomic failure rolls back",
+			req: types.ProductBatchRequest{Operations: []types.ProductBatchOperation{
+				{Op: types.BatchCreate, Product: widget},
+				{Op: types.BatchDelete, ID: 999},
+				{Op: types.BatchCreate, Product: widget},
+			}},
+			wantStatuses: []string{types.BatchRolledBack, types.BatchFailed, types.BatchSkipped},
+			wantProducts: 1,
+		},
+		{
+			name: "best effort keeps successes",
+			req: types.ProductBatchRequest{Mode: types.BatchBestEffort, Operations: []types.ProductBatchOperation{
+				{Op: types.BatchCreate, Product: widget},
+				{Op: types.BatchDelete, ID: 999},
+				{Op: types.BatchCreate, Product: widget},
+			}},
+			wantCommitted: true,
+			wantStatuses:  []string{types.BatchOK, types.BatchFailed, types.BatchOK},
+			wantProducts:  3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newProductService(t)
+			_, err := svc.CreateProduct(ctx, widget)
+			checkErr(t, err, "")
+
+			res, err := svc.BatchProducts(ctx, &tt.req)
+			checkErr(t, err, tt.wantErr)
+			if err != nil {
+				return
+			}
+
+			if res.Committed != tt.wantCommitted {
+				t.Errorf("Committed = %v, want %v", res.Committed, tt.wantCommitted)
+			}
+			var statuses []string
+			for _, result := range res.Results {
+				statuses = append(statuses, result.Status)
+			}
+			if strings.Join(statuses, ",") != strings.Join(tt.wantStatuses, ",") {
+				t.Errorf("statuses = %v, want %v", statuses, tt.wantStatuses)
+			}
+
+			products, err := svc.GetAllProducts(ctx)
+			checkErr(t, err, "")
+			if len(products) != tt.wantProducts {
+				t.Errorf("%d products after the batch, want %d", len(products), tt.wantProducts)
+			}
+		})
+	}
+}
+
+// retryingTxManager runs a top-level transaction a second time after it fails,
+// as SQLTxManager does when it loses a race, calling between first. The second
+// attempt's changes are kept but it reports err.
+type retryingTxManager struct {
+	repository.TxManager
+	between func()
+	err     error
+}
+
+// retryKey marks a context inside a retryingTxManager transaction
+type retryKey struct{}
+
+func (m *retryingTxManager) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(retryKey{}) != nil {
+		return m.TxManager.InTx(ctx, fn)
+	}
+	ctx = context.WithValue(ctx, retryKey{}, true)
+	if err := m.TxManager.InTx(ctx, fn); err == nil {
+		return nil
+	}
+	m.between()
+	if err := m.TxManager.InTx(ctx, fn); err != nil {
+		return err
+	}
+	return m.err
+}
+
+func TestProductServiceBatchProductsForgetsEarlierAttempts(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryProductRepository()
+	direct := services.NewProductService(repo, repository.NewMemoryTxManager(repo))
+	taken, err := direct.CreateProduct(ctx, &types.CreateProductRequest{SKU: "T-1", Name: "Taken"})
+	checkErr(t, err, "")
+
+	// The first attempt fails on the taken SKU, which is freed before the
+	// second, and the second fails to commit
+	tx := &retryingTxManager{TxManager: repository.NewMemoryTxManager(repo), err: errors.New("database is closed")}
+	tx.between = func() {
+		_, err := direct.UpdateProduct(ctx, taken.ID, &types.UpdateProductRequest{SKU: "T-2", Name: "Taken"})
+		checkErr(t, err, "")
+	}
+	svc := services.NewProductService(repo, tx)
+
+	_, err = svc.BatchProducts(ctx, &types.ProductBatchRequest{Operations: []types.ProductBatchOperation{
+		{Op: types.BatchCreate, Product: &types.CreateProductRequest{SKU: "N-1", Name: "New"}},
+		{Op: types.BatchCreate, Product: &types.CreateProductRequest{SKU: "T-1", Name: "Other"}},
+	}})
+	checkErr(t, err, "failed to commit batch: database is closed")
+}
+
+func TestProductServiceTrash(t *testing.T) {
+	svc := newProductService(t)
+	ctx := context.Background()
+	created, err := svc.CreateProduct(ctx, &types.CreateProductRequest{Name: "Widget", SKU: "W-1", Stock: 3})
+	checkErr(t, err, "")
+
+	trash, err := svc.ListDeletedProducts(ctx)
+	checkErr(t, err, "")
+	if trash == nil || len(trash) != 0 {
+		t.Errorf("ListDeletedProducts of an empty trash = %#v, want an empty, non-nil slice", trash)
+	}
+
+	_, err = svc.RestoreProduct(ctx, created.ID)
+	checkErr(t, err, "product with ID 1 not found in trash")
+	checkErr(t, svc.DeleteProduct(ctx, created.ID), "")
+
+	trash, err = svc.ListDeletedProducts(ctx)
+	checkErr(t, err, "")
+	if len(trash) != 1 || trash[0].ID != created.ID {
+		t.Errorf("ListDeletedProducts = %v, want product %d", trash, created.ID)
+	}
+
+	restored, err := svc.RestoreProduct(ctx, created.ID)
+	checkErr(t, err, "")
+	if restored.Name != "Widget" || restored.Stock != 3 || restored.DeletedAt != nil {
+		t.Errorf("RestoreProduct = %+v, want the product as it was", restored)
+	}
+
+	// The SKU is free while the product is in the trash
+	checkErr(t, svc.DeleteProduct(ctx, created.ID), "")
+	_, err = svc.CreateProduct(ctx, &types.CreateProductRequest{Name: "Replacement", SKU: "W-1"})
+	checkErr(t, err, "")
+	_, err = svc.RestoreProduct(ctx, created.ID)
+	checkErr(t, err, "product with ID 1 cannot be restored: its sku is in use by another product")
+
+	checkErr(t, svc.PurgeProduct(ctx, 0), "invalid product ID: must be greater than 0")
+	checkErr(t, svc.PurgeProduct(ctx, created.ID), "")
+	checkErr(t, svc.PurgeProduct(ctx, created.ID), "product with ID 1 not found")
+
+	n, err := svc.PurgeDeletedProducts(ctx, 0)
+	checkErr(t, err, "")
+	if n != 0 {
+		t.Errorf("PurgeDeletedProducts = %d, want 0 after the product was purged by hand", n)
+	}
+}
+
+func TestProductServiceVersions(t *testing.T) {
+	ctx := context.Background()
+	svc := newProductService(t)
+
+	product, err := svc.CreateProduct(ctx, &types.CreateProductRequest{Name: "Widget", Price: 9.99, Stock: 5})
+	checkErr(t, err, "")
+	time.Sleep(2 * time.Millisecond)
+	created := time.Now()
+	time.Sleep(2 * time.Millisecond)
+	_, err = svc.UpdateProduct(ctx, product.ID, &types.UpdateProductRequest{Name: "Widget", Category: "tools", Price: 12.5, Stock: 5})
+	checkErr(t, err, "")
+
+	got, err := svc.GetProductAsOf(ctx, product.ID, created)
+	checkErr(t, err, "")
+	if got.Price != 9.99 || got.EffectivePrice != 9.99 || got.Category != "" {
+		t.Errorf("GetProductAsOf = %+v, want the product before the update", got)
+	}
+	_, err = svc.GetProductAsOf(ctx, product.ID, created.Add(-time.Hour))
+	checkErr(t, err, "not found as of")
+
+	diff, err := svc.DiffProductVersions(ctx, product.ID, 1, 2)
+	checkErr(t, err, "")
+	if len(diff.Changes) != 2 || diff.Changes[0].Field != "category" || diff.Changes[1].Field != "price" || diff.Changes[1].From != 9.99 || diff.Changes[1].To != 12.5 {
+		t.Errorf("DiffProductVersions = %+v, want the category and price changes", diff.Changes)
+	}
+
+	_, err = svc.DiffProductVersions(ctx, product.ID, 1, 3)
+	checkErr(t, err, "version 3 of product 1 not found")
+	_, err = svc.DiffProductVersions(ctx, 99, 1, 2)
+	checkErr(t, err, "product with ID 99 not found")
+	_, err = svc.DiffProductVersions(ctx, product.ID, 0, 2)
+	checkErr(t, err, "invalid version")
+}
+
+func TestProductServiceAdjustStock(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLServices(t, sqlOptions{})
+	svc, audit := s.product, s.audit
+	product, err := svc.CreateProduct(ctx, &types.CreateProductRequest{SKU: "W-1", Name: "Widget", Price: 9.99, Stock: 5})
+	checkErr(t, err, "")
+
+	adjusted, err := svc.AdjustStock(ctx, product.ID, -3)
+	checkErr(t, err, "")
+	if adjusted.Stock != 2 || adjusted.SKU != "W-1" || adjusted.Price != 9.99 {
+		t.Errorf("adjusted product = %+v, want stock 2 and the other fields kept", adjusted)
+	}
+	adjusted, err = svc.AdjustStock(ctx, product.ID, 4)
+	checkErr(t, err, "")
+	if adjusted.Stock != 6 {
+		t.Errorf("stock = %d after adding 4, want 6", adjusted.Stock)
+	}
+
+	_, err = svc.AdjustStock(ctx, product.ID, -7)
+	checkErr(t, err, "insufficient stock")
+	_, err = svc.AdjustStock(ctx, product.ID, 0)
+	checkErr(t, err, "must be non-zero")
+	_, err = svc.AdjustStock(ctx, 99, 1)
+	checkErr(t, err, "not found")
+
+	// Each adjustment is audited as an update
+	history, err := audit.GetProductHistory(ctx, product.ID)
+	checkErr(t, err, "")
+	if len(history) != 3 || history[0].Operation != types.AuditUpdate || history[0].Diff[0].Field != "stock" {
+		t.Errorf("history = %+v, want two stock updates after the creation", history)
+	}
+}
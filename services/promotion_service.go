@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-circleci/repository"
+	"go-circleci/types"
+)
+
+// pricingContextKey is the context key for the pricing context of a request
+type pricingContextKey struct{}
+
+// WithPricingContext returns a copy of ctx carrying the customer, time and coupon
+// that product prices should be computed for
+func WithPricingContext(ctx context.Context, pc types.PricingContext) context.Context {
+	return context.WithValue(ctx, pricingContextKey{}, pc)
+}
+
+// pricingContextFrom returns the pricing context carried by ctx, if any
+func pricingContextFrom(ctx context.Context) types.PricingContext {
+	pc, _ := ctx.Value(pricingContextKey{}).(types.PricingContext)
+	return pc
+}
+
+// normalizeCouponCode makes coupon codes case-insensitive
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// PromotionService manages promotions and coupons and computes effective prices
+type PromotionService struct {
+	repo     repository.PromotionRepository
+	products repository.ProductRepository
+	now      func() time.Time
+}
+
+// NewPromotionService creates a new PromotionService with the given promotion and product repositories
+func NewPromotionService(repo repository.PromotionRepository, products repository.ProductRepository) *PromotionService {
+	return &PromotionService{
+		repo:     repo,
+		products: products,
+		now:      func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// ListPromotions retrieves every promotion, newest first
+func (s *PromotionService) ListPromotions(ctx context.Context) ([]*types.Promotion, error) {
+	promotions, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list promotions: %w", err)
+	}
+
+	// Return empty slice instead of nil for consistency
+	if promotions == nil {
+		return []*types.Promotion{}, nil
+	}
+
+	return promotions, nil
+}
+
+// GetPromotion retrieves a single promotion by its ID with validation
+func (s *PromotionService) GetPromotion(ctx context.Context, id int) (*types.Promotion, error) {
+	// Validate ID
+	if id <= 0 {
+		return nil, errors.New("invalid promotion ID: must be greater than 0")
+	}
+
+	promotion, err := s.repo.GetByID(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("promotion with ID %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promotion: %w", err)
+	}
+
+	return promotion, nil
+}
+
+// CreatePromotion creates a new promotion with input validation
+func (s *PromotionService) CreatePromotion(ctx context.Context, req *types.CreatePromotionRequest) (*types.Promotion, error) {
+	// Validate required fields
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, errors.New("promotion name is required")
+	}
+
+	switch req.Kind {
+	case types.PromotionPercentage:
+		if req.Value <= 0 || req.Value > 100 {
+			return nil, errors.New("percentage promotion value must be greater than 0 and at most 100")
+		}
+	case types.PromotionFixed:
+		if req.Value <= 0 {
+			return nil, errors.New("fixed promotion value must be greater than 0")
+		}
+	default:
+		return nil, fmt.Errorf("invalid promotion kind %q: must be %s or %s", req.Kind, types.PromotionPercentage, types.PromotionFixed)
+	}
+
+	if req.MaxUses < 0 {
+		return nil, errors.New("promotion max_uses must be greater than or equal to 0")
+	}
+
+	if req.StartsAt != nil && req.EndsAt != nil && !req.StartsAt.Before(*req.EndsAt) {
+		return nil, errors.New("promotion starts_at must be before ends_at")
+	}
+
+	if req.ProductID != nil {
+		if *req.ProductID <= 0 {
+			return nil, errors.New("invalid product ID: must be greater than 0")
+		}
+		if _, err := s.products.GetByID(ctx, *req.ProductID); err == sql.ErrNoRows {
+			return nil, fmt.Errorf("product with ID %d not found", *req.ProductID)
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get product: %w", err)
+		}
+	}
+
+	promotion := &types.Promotion{
+		Name:       strings.TrimSpace(req.Name),
+		Kind:       req.Kind,
+		Value:      req.Value,
+		ProductID:  req.ProductID,
+		Category:   strings.TrimSpace(req.Category),
+		Customer:   strings.TrimSpace(req.Customer),
+		CouponCode: normalizeCouponCode(req.CouponCode),
+		MaxUses:    req.MaxUses,
+		StartsAt:   req.StartsAt,
+		EndsAt:     req.EndsAt,
+		CreatedAt:  s.now(),
+	}
+
+	if err := s.repo.Create(ctx, promotion); err == repository.ErrCouponCodeTaken {
+		return nil, fmt.Errorf("coupon code %q already exists", promotion.CouponCode)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to create promotion: %w", err)
+	}
+
+	return promotion, nil
+}
+
+// DeletePromotion deletes a promotion by its ID with validation
+func (s *PromotionService) DeletePromotion(ctx context.Context, id int) error {
+	// Validate ID
+	if id <= 0 {
+		return errors.New("invalid promotion ID: must be greater than 0")
+	}
+
+	if err := s.repo.Delete(ctx, id); err == sql.ErrNoRows {
+		return fmt.Errorf("promotion with ID %d not found", id)
+	} else if err != nil {
+		return fmt.Errorf("failed to delete promotion: %w", err)
+	}
+
+	return nil
+}
+
+// ValidateCoupon reports whether a coupon can be used now by the customer and,
+// when a product is given, whether it applies to that product and the price it brings it to.
+// An unusable coupon is not an error; the reason is returned in the validation.
+func (s *PromotionService) ValidateCoupon(ctx context.Context, req *types.ValidateCouponRequest) (*types.CouponValidation, error) {
+	code := normalizeCouponCode(req.Code)
+	if code == "" {
+		return nil, errors.New("coupon code is required")
+	}
+
+	validation := &types.CouponValidation{Code: code, ProductID: req.ProductID}
+
+	promotion, reason, err := s.checkCoupon(ctx, code, req.Customer)
+	if err != nil {
+		return nil, err
+	}
+	validation.Promotion = promotion
+	if reason != "" {
+		validation.Reason = reason
+		return validation, nil
+	}
+
+	if req.ProductID != 0 {
+		if req.ProductID < 0 {
+			return nil, errors.New("invalid product ID: must be greater than 0")
+		}
+
+		product, err := s.products.GetByID(ctx, req.ProductID)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("product with ID %d not found", req.ProductID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get product: %w", err)
+		}
+
+		if !promotionAppliesTo(promotion, product, req.Customer) {
+			validation.Reason = fmt.Sprintf("coupon does not apply to product %d", req.ProductID)
+			return validation, nil
+		}
+
+		pricingCtx := WithPricingContext(ctx, types.PricingContext{Customer: req.Customer, Coupon: code})
+		if err := s.ApplyPricing(pricingCtx, product); err != nil {
+			return nil, err
+		}
+		validation.Price = product.Price
+		validation.EffectivePrice = product.EffectivePrice
+	}
+
+	validation.Valid = true
+	return validation, nil
+}
+
+// ApplyPricing sets the effective price and applied promotions of each product
+// for the pricing context carried by ctx. The best automatic promotion applies
+// first and a presented coupon is taken off the discounted price.
+func (s *PromotionService) ApplyPricing(ctx context.Context, products ...*types.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	pc := pricingContextFrom(ctx)
+	if pc.At.IsZero() {
+		pc.At = s.now()
+	}
+	pc.Coupon = normalizeCouponCode(pc.Coupon)
+
+	promotions, err := s.repo.ListCurrent(ctx, pc.At.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to list promotions: %w", err)
+	}
+
+	for _, product := range products {
+		applyPromotions(product, promotions, pc)
+	}
+
+	return nil
+}
+
+// checkCoupon looks up a coupon and checks it can be used now by the customer.
+// It returns the reason the coupon cannot be used, or an empty reason if it can.
+func (s *PromotionService) checkCoupon(ctx context.Context, code string, customer string) (*types.Promotion, string, error) {
+	promotion, err := s.repo.GetByCouponCode(ctx, code)
+	if err == sql.ErrNoRows {
+		return nil, "coupon not found", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get coupon: %w", err)
+	}
+
+	now := s.now()
+	switch {
+	case promotion.StartsAt != nil && now.Before(*promotion.StartsAt):
+		return promotion, "coupon is not valid yet", nil
+	case promotion.EndsAt != nil && !now.Before(*promotion.EndsAt):
+		return promotion, "coupon has expired", nil
+	case promotion.MaxUses > 0 && promotion.Uses >= promotion.MaxUses:
+		return promotion, "coupon usage limit reached", nil
+	case promotion.Customer != "" && !strings.EqualFold(promotion.Customer, customer):
+		return promotion, "coupon is not valid for this customer", nil
+	}
+
+	return promotion, "", nil
+}
+
+// applyPromotions prices a product with the best matching automatic promotion
+// and, if presented, a matching coupon. promotions must already be current at pc.At.
+func applyPromotions(product *types.Product, promotions []*types.Promotion, pc types.PricingContext) {
+	product.EffectivePrice = product.Price
+	product.Promotions = []types.AppliedPromotion{}
+
+	var best, coupon *types.Promotion
+	for _, promotion := range promotions {
+		if !promotionAppliesTo(promotion, product, pc.Customer) {
+			continue
+		}
+		if promotion.CouponCode == "" {
+			if best == nil || discountFor(promotion, product.Price) > discountFor(best, product.Price) {
+				best = promotion
+			}
+		} else if promotion.CouponCode == pc.Coupon {
+			coupon = promotion
+		}
+	}
+
+	price := product.Price
+	for _, promotion := range []*types.Promotion{best, coupon} {
+		if promotion == nil {
+			continue
+		}
+		discount := discountFor(promotion, price)
+		price = roundCents(price - discount)
+		product.Promotions = append(product.Promotions, types.AppliedPromotion{
+			ID:         promotion.ID,
+			Name:       promotion.Name,
+			CouponCode: promotion.CouponCode,
+			Discount:   discount,
+		})
+	}
+	product.EffectivePrice = price
+}
+
+// promotionAppliesTo reports whether a promotion targets the product and customer
+func promotionAppliesTo(promotion *types.Promotion, product *types.Product, customer string) bool {
+	if promotion.ProductID != nil && *promotion.ProductID != product.ID {
+		return false
+	}
+	if promotion.Category != "" && !strings.EqualFold(promotion.Category, product.Category) {
+		return false
+	}
+	if promotion.Customer != "" && !strings.EqualFold(promotion.Customer, customer) {
+		return false
+	}
+	return true
+}
+
+// discountFor is the amount a promotion takes off a price, never more than the price itself
+func discountFor(promotion *types.Promotion, price float64) float64 {
+	discount := promotion.Value
+	if promotion.Kind == types.PromotionPercentage {
+		discount = price * promotion.Value / 100
+	}
+	return roundCents(min(discount, price))
+}
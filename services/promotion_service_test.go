@@ -0,0 +1,133 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"go-circleci/repository"
+	"go-circleci/repository/repotest"
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+// newPromotionServices returns a PromotionService over an empty SQLite database
+// together with an OrderService redeeming its coupons and the product repository both use
+func newPromotionServices(t *testing.T) (*services.PromotionService, *services.OrderService, repository.ProductRepository) {
+	t.Helper()
+	db := repotest.OpenSQLite(t)
+	products := repository.NewSQLiteProductRepository(db)
+	promotions := services.NewPromotionService(repository.NewSQLitePromotionRepository(db), products)
+	orders := services.NewOrderService(repository.NewSQLiteOrderRepository(db, products), products)
+	orders.SetPromotions(promotions)
+	return promotions, orders, products
+}
+
+func TestPromotionServiceStacking(t *testing.T) {
+	percent := func(name string, value float64) types.CreatePromotionRequest {
+		return types.CreatePromotionRequest{Name: name, Kind: types.PromotionPercentage, Value: value}
+	}
+	fixed := func(name string, value float64) types.CreatePromotionRequest {
+		return types.CreatePromotionRequest{Name: name, Kind: types.PromotionFixed, Value: value}
+	}
+	coupon := func(req types.CreatePromotionRequest, code string) types.CreatePromotionRequest {
+		req.CouponCode = code
+		return req
+	}
+	targeted := func(req types.CreatePromotionRequest, category string, customer string) types.CreatePromotionRequest {
+		req.Category, req.Customer = category, customer
+		return req
+	}
+
+	tests := []struct {
+		name        string
+		promotions  []types.CreatePromotionRequest
+		pricing     types.PricingContext
+		wantPrice   float64
+		wantApplied []string
+	}{
+		{"no promotions", nil, types.PricingContext{}, 100, nil},
+		{"best automatic promotion wins", []types.CreatePromotionRequest{percent("Tenth off", 10), fixed("Fifteen off", 15)}, types.PricingContext{}, 85, []string{"Fifteen off"}},
+		{"automatic promotions do not stack", []types.CreatePromotionRequest{percent("Tenth off", 10), percent("Fifth off", 20)}, types.PricingContext{}, 80, []string{"Fifth off"}},
+		{"coupon comes off the discounted price", []types.CreatePromotionRequest{percent("Fifth off", 20), coupon(percent("Voucher", 50), "HALF")}, types.PricingContext{Coupon: "HALF"}, 40, []string{"Fifth off", "Voucher"}},
+		{"coupon codes are case-insensitive", []types.CreatePromotionRequest{coupon(fixed("Voucher", 10), "SAVE10")}, types.PricingContext{Coupon: " save10 "}, 90, []string{"Voucher"}},
+		{"coupon not presented", []types.CreatePromotionRequest{percent("Fifth off", 20), coupon(percent("Voucher", 50), "HALF")}, types.PricingContext{}, 80, []string{"Fifth off"}},
+		{"other coupon presented", []types.CreatePromotionRequest{coupon(percent("Voucher", 50), "HALF")}, types.PricingContext{Coupon: "OTHER"}, 100, nil},
+		{"discount never exceeds the price", []types.CreatePromotionRequest{fixed("Giveaway", 150), coupon(fixed("Voucher", 5), "FIVE")}, types.PricingContext{Coupon: "FIVE"}, 0, []string{"Giveaway", "Voucher"}},
+		{"category and customer match", []types.CreatePromotionRequest{targeted(percent("Loyal tools", 30), "TOOLS", "Alice")}, types.PricingContext{Customer: "alice"}, 70, []string{"Loyal tools"}},
+		{"other category", []types.CreatePromotionRequest{targeted(percent("Garden", 30), "garden", "")}, types.PricingContext{}, 100, nil},
+		{"other customer", []types.CreatePromotionRequest{targeted(percent("Loyal", 30), "", "bob")}, types.PricingContext{Customer: "alice"}, 100, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			promotions, _, _ := newPromotionServices(t)
+			for _, req := range tt.promotions {
+				_, err := promotions.CreatePromotion(ctx, &req)
+				checkErr(t, err, "")
+			}
+
+			product := &types.Product{ID: 1, Name: "Hammer", Category: "tools", Price: 100}
+			checkErr(t, promotions.ApplyPricing(services.WithPricingContext(ctx, tt.pricing), product), "")
+
+			if product.EffectivePrice != tt.wantPrice {
+				t.Errorf("effective price = %v, want %v", product.EffectivePrice, tt.wantPrice)
+			}
+			var applied []string
+			for _, promotion := range product.Promotions {
+				applied = append(applied, promotion.Name)
+			}
+			if fmt.Sprint(applied) != fmt.Sprint(tt.wantApplied) {
+				t.Errorf("applied promotions = %v, want %v", applied, tt.wantApplied)
+			}
+		})
+	}
+}
+
+func TestPromotionServiceCouponUsageLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxUses    int
+		orders     int
+		wantErr    string
+		wantUses   int
+		wantReason string
+	}{
+		{"unlimited", 0, 3, "", 3, ""},
+		{"within the limit", 3, 2, "", 2, ""},
+		{"up to the limit", 2, 2, "", 2, "coupon usage limit reached"},
+		{"past the limit", 2, 3, "invalid coupon SAVE: coupon usage limit reached", 2, "coupon usage limit reached"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			promotions, orders, products := newPromotionServices(t)
+			checkErr(t, products.Create(ctx, &types.Product{Name: "Widget", Price: 10, Stock: 10}), "")
+			promotion, err := promotions.CreatePromotion(ctx, &types.CreatePromotionRequest{Name: "Voucher", Kind: types.PromotionFixed, Value: 2, CouponCode: "save", MaxUses: tt.maxUses})
+			checkErr(t, err, "")
+
+			for i := range tt.orders {
+				_, err = orders.CreateOrder(ctx, &types.CreateOrderRequest{Coupon: "SAVE", Lines: []types.CreateOrderLineRequest{{ProductID: 1, Quantity: 1}}})
+				if i < tt.orders-1 {
+					checkErr(t, err, "")
+				}
+			}
+			checkErr(t, err, tt.wantErr)
+
+			got, err := promotions.GetPromotion(ctx, promotion.ID)
+			checkErr(t, err, "")
+			if got.Uses != tt.wantUses {
+				t.Errorf("uses = %d, want %d", got.Uses, tt.wantUses)
+			}
+			checkStock(t, products, 1, 10-tt.wantUses)
+
+			validation, err := promotions.ValidateCoupon(ctx, &types.ValidateCouponRequest{Code: "save"})
+			checkErr(t, err, "")
+			if validation.Valid != (tt.wantReason == "") || validation.Reason != tt.wantReason {
+				t.Errorf("validation = valid %v with reason %q, want reason %q", validation.Valid, validation.Reason, tt.wantReason)
+			}
+		})
+	}
+}
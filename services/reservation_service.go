@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-circleci/repository"
+	"go-circleci/types"
+)
+
+const (
+	// DefaultReservationTTL is used when a reservation request does not specify a TTL
+	DefaultReservationTTL = 15 * time.Minute
+
+	// MaxReservationTTL caps how long units can be held for a single checkout
+	MaxReservationTTL = 24 * time.Hour
+)
+
+// ReservationService manages stock reservations held during checkout
+type ReservationService struct {
+	repo  repository.ReservationRepository
+	stock *StockEvents
+	now   func() time.Time
+}
+
+// NewReservationService creates a new ReservationService with the given repository
+func NewReservationService(repo repository.ReservationRepository) *ReservationService {
+	return &ReservationService{
+		repo: repo,
+		now:  func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// SetStockEvents sets the StockEvents that record the stock reservations hold,
+// release and take. Without one, no StockChanged events are recorded.
+func (s *ReservationService) SetStockEvents(stock *StockEvents) {
+	s.stock = stock
+}
+
+// CreateReservation holds units of a product until the reservation expires
+func (s *ReservationService) CreateReservation(ctx context.Context, req *types.CreateReservationRequest) (*types.Reservation, error) {
+	// Validate required fields
+	if req.ProductID <= 0 {
+		return nil, errors.New("invalid product ID: must be greater than 0")
+	}
+
+	if req.Quantity <= 0 {
+		return nil, errors.New("reservation quantity must be greater than 0")
+	}
+
+	if req.TTLSeconds < 0 {
+		return nil, errors.New("reservation ttl_seconds must be greater than or equal to 0")
+	}
+
+	ttl := DefaultReservationTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if ttl > MaxReservationTTL {
+		return nil, fmt.Errorf("reservation ttl_seconds must be at most %d", int(MaxReservationTTL.Seconds()))
+	}
+
+	now := s.now()
+	reservation := &types.Reservation{
+		ProductID: req.ProductID,
+		Quantity:  req.Quantity,
+		Reference: req.Reference,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+
+	err := s.stock.track(ctx, []int{req.ProductID}, func(ctx context.Context) error {
+		return s.repo.Create(ctx, reservation)
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("product with ID %d not found", req.ProductID)
+	}
+	if err == repository.ErrInsufficientStock {
+		return nil, fmt.Errorf("insufficient stock for product %d", req.ProductID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reservation: %w", err)
+	}
+
+	return reservation, nil
+}
+
+// GetReservation retrieves a single reservation by its ID with validation
+func (s *ReservationService) GetReservation(ctx context.Context, id int) (*types.Reservation, error) {
+	// Validate ID
+	if id <= 0 {
+		return nil, errors.New("invalid reservation ID: must be greater than 0")
+	}
+
+	reservation, err := s.repo.GetByID(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("reservation with ID %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reservation: %w", err)
+	}
+
+	return reservation, nil
+}
+
+// ConfirmReservation turns an active reservation into a sale, deducting its units from on-hand stock
+func (s *ReservationService) ConfirmReservation(ctx context.Context, id int) (*types.Reservation, error) {
+	// Validate ID
+	if id <= 0 {
+		return nil, errors.New("invalid reservation ID: must be greater than 0")
+	}
+
+	reservation, err := s.GetReservation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.stock.track(ctx, []int{reservation.ProductID}, func(ctx context.Context) error {
+		return s.repo.Confirm(ctx, id, s.now())
+	})
+	if err != nil {
+		return nil, s.transitionError(id, "confirm", err)
+	}
+
+	return s.GetReservation(ctx, id)
+}
+
+// ReleaseReservation cancels an active reservation, returning its units to available stock
+func (s *ReservationService) ReleaseReservation(ctx context.Context, id int) (*types.Reservation, error) {
+	// Validate ID
+	if id <= 0 {
+		return nil, errors.New("invalid reservation ID: must be greater than 0")
+	}
+
+	reservation, err := s.GetReservation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.stock.track(ctx, []int{reservation.ProductID}, func(ctx context.Context) error {
+		return s.repo.Release(ctx, id)
+	})
+	if err != nil {
+		return nil, s.transitionError(id, "release", err)
+	}
+
+	return s.GetReservation(ctx, id)
+}
+
+// ExpireStaleReservations expires every active reservation past its expiry time
+func (s *ReservationService) ExpireStaleReservations(ctx context.Context) (int, error) {
+	n, err := s.repo.ExpireStale(ctx, s.now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire reservations: %w", err)
+	}
+	return n, nil
+}
+
+// RunSweeper expires stale reservations every interval until ctx is cancelled
+func (s *ReservationService) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.ExpireStaleReservations(ctx)
+			if err != nil {
+				fmt.Printf("reservation sweeper err=%v\n", err)
+				continue
+			}
+			if n > 0 {
+				fmt.Printf("reservation sweeper expired=%d\n", n)
+			}
+		}
+	}
+}
+
+// transitionError maps repository errors from confirm/release into service errors
+func (s *ReservationService) transitionError(id int, action string, err error) error {
+	switch err {
+	case sql.ErrNoRows:
+		return fmt.Errorf("reservation with ID %d not found", id)
+	case repository.ErrReservationNotActive:
+		return fmt.Errorf("reservation %d is not active or has expired", id)
+	case repository.ErrInsufficientStock:
+		return fmt.Errorf("insufficient stock to %s reservation %d", action, id)
+	default:
+		return fmt.Errorf("failed to %s reservation: %w", action, err)
+	}
+}
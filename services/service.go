@@ -3,8 +3,10 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"go-circleci/types"
 	"net/http"
+	"time"
 )
 
 type Service interface {
@@ -13,27 +15,117 @@ type Service interface {
 	// Product operations
 	GetAllProducts(context.Context) ([]*types.Product, error)
 	GetProductByID(ctx context.Context, id int) (*types.Product, error)
+	GetProductsByIDs(ctx context.Context, ids []int) ([]*types.Product, error)
+	ListProductsPage(ctx context.Context, filter types.ProductFilter, after, limit int) (*types.ProductPage, error)
 	CreateProduct(ctx context.Context, req *types.CreateProductRequest) (*types.Product, error)
 	UpdateProduct(ctx context.Context, id int, req *types.UpdateProductRequest) (*types.Product, error)
 	DeleteProduct(ctx context.Context, id int) error
+	AdjustStock(ctx context.Context, id, delta int) (*types.Product, error)
+	ListDeletedProducts(ctx context.Context) ([]*types.Product, error)
+	RestoreProduct(ctx context.Context, id int) (*types.Product, error)
+	PurgeProduct(ctx context.Context, id int) error
+	GetProductAsOf(ctx context.Context, id int, at time.Time) (*types.Product, error)
+	GetAllProductsAsOf(ctx context.Context, at time.Time) ([]*types.Product, error)
+	ListProductVersions(ctx context.Context, id int) ([]*types.ProductVersion, error)
+	DiffProductVersions(ctx context.Context, id, from, to int) (*types.ProductVersionDiff, error)
+	BatchProducts(ctx context.Context, req *types.ProductBatchRequest) (*types.ProductBatchResponse, error)
+	ImportProducts(ctx context.Context, rows []*types.ProductImportRow, dryRun bool) ([]types.ImportRowResult, error)
+	ExportProducts(ctx context.Context, fn func(product *types.Product) error) error
+
+	// Reservation operations
+	CreateReservation(ctx context.Context, req *types.CreateReservationRequest) (*types.Reservation, error)
+	GetReservation(ctx context.Context, id int) (*types.Reservation, error)
+	ConfirmReservation(ctx context.Context, id int) (*types.Reservation, error)
+	ReleaseReservation(ctx context.Context, id int) (*types.Reservation, error)
+
+	// Inventory operations
+	ListLocations(context.Context) ([]*types.Location, error)
+	CreateLocation(ctx context.Context, req *types.CreateLocationRequest) (*types.Location, error)
+	GetProductStock(ctx context.Context, productID int) (*types.ProductStock, error)
+	TransferStock(ctx context.Context, req *types.StockTransferRequest) (*types.StockTransfer, error)
+	GetLowStockReport(context.Context) ([]*types.LowStockItem, error)
+
+	// Order operations
+	CreateOrder(ctx context.Context, req *types.CreateOrderRequest) (*types.Order, error)
+	GetOrder(ctx context.Context, id int) (*types.Order, error)
+	ListOrders(ctx context.Context, filter types.OrderFilter) ([]*types.Order, error)
+	PayOrder(ctx context.Context, id int) (*types.Order, error)
+	FulfilOrder(ctx context.Context, id int) (*types.Order, error)
+	CancelOrder(ctx context.Context, id int) (*types.Order, error)
+	RefundOrder(ctx context.Context, id int) (*types.Order, error)
+
+	// Cart operations
+	CreateCart(ctx context.Context, req *types.CreateCartRequest) (*types.Cart, error)
+	GetCart(ctx context.Context, id int) (*types.Cart, error)
+	AddCartLine(ctx context.Context, cartID int, req *types.CartLineRequest) (*types.Cart, error)
+	UpdateCartLine(ctx context.Context, cartID int, req *types.CartLineRequest) (*types.Cart, error)
+	RemoveCartLine(ctx context.Context, cartID int, productID int) (*types.Cart, error)
+	CheckoutCart(ctx context.Context, cartID int) (*types.Order, error)
+
+	// Promotion operations
+	ListPromotions(ctx context.Context) ([]*types.Promotion, error)
+	GetPromotion(ctx context.Context, id int) (*types.Promotion, error)
+	CreatePromotion(ctx context.Context, req *types.CreatePromotionRequest) (*types.Promotion, error)
+	DeletePromotion(ctx context.Context, id int) error
+	ValidateCoupon(ctx context.Context, req *types.ValidateCouponRequest) (*types.CouponValidation, error)
+
+	// Tax operations
+	ListTaxRules(ctx context.Context) ([]*types.TaxRule, error)
+	CreateTaxRule(ctx context.Context, req *types.CreateTaxRuleRequest) (*types.TaxRule, error)
+	DeleteTaxRule(ctx context.Context, id int) error
+
+	// Backup operations
+	CreateBackup(ctx context.Context) (*types.Backup, error)
+	ListBackups(ctx context.Context) ([]*types.Backup, error)
+
+	// Audit operations
+	ListAuditEntries(ctx context.Context, filter types.AuditFilter) ([]*types.AuditEntry, error)
+	GetProductHistory(ctx context.Context, productID int) ([]*types.AuditEntry, error)
+	VerifyAuditLog(ctx context.Context) (*types.AuditVerification, error)
+
+	// Outbox operations
+	ListOutbox(ctx context.Context, status string, limit int) ([]*types.OutboxEntry, error)
+	RetryOutboxEvent(ctx context.Context, id int) error
+
+	// Webhook operations
+	ListWebhooks(ctx context.Context) ([]*types.WebhookSubscription, error)
+	GetWebhook(ctx context.Context, id int) (*types.WebhookSubscription, error)
+	CreateWebhook(ctx context.Context, req *types.CreateWebhookRequest) (*types.WebhookSubscription, error)
+	UpdateWebhook(ctx context.Context, id int, req *types.UpdateWebhookRequest) (*types.WebhookSubscription, error)
+	DeleteWebhook(ctx context.Context, id int) error
+	ListWebhookDeliveries(ctx context.Context, id, limit int) ([]*types.WebhookDelivery, error)
+	RedeliverWebhook(ctx context.Context, id, deliveryID int) (*types.WebhookDelivery, error)
+
+	// Event stream operations
+	SubscribeProductEvents(ctx context.Context, filter types.ProductEventFilter, lastEventID string) (*types.EventStream, error)
 }
 
 type CatFactService struct {
 	url string
 }
 
-func NewCatFactService(url string) Service {
+func NewCatFactService(url string) *CatFactService {
 	return &CatFactService{
 		url: url,
 	}
 }
 
 func (s *CatFactService) GetCatFact(ctx context.Context) (*types.CatFact, error) {
-	res, err := http.Get("https://catfact.ninja/fact")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cat fact upstream returned %s", res.Status)
+	}
+
 	fact := &types.CatFact{}
 	if err := json.NewDecoder(res.Body).Decode(fact); err != nil {
 		return nil, err
@@ -41,24 +133,3 @@ func (s *CatFactService) GetCatFact(ctx context.Context) (*types.CatFact, error)
 
 	return fact, nil
 }
-
-// Stub implementations for product methods - will be properly handled by CompositeService in task 9
-func (s *CatFactService) GetAllProducts(ctx context.Context) ([]*types.Product, error) {
-	return nil, nil
-}
-
-func (s *CatFactService) GetProductByID(ctx context.Context, id int) (*types.Product, error) {
-	return nil, nil
-}
-
-func (s *CatFactService) CreateProduct(ctx context.Context, req *types.CreateProductRequest) (*types.Product, error) {
-	return nil, nil
-}
-
-func (s *CatFactService) UpdateProduct(ctx context.Context, id int, req *types.UpdateProductRequest) (*types.Product, error) {
-	return nil, nil
-}
-
-func (s *CatFactService) DeleteProduct(ctx context.Context, id int) error {
-	return nil
-}
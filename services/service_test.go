@@ -0,0 +1,58 @@
+package services_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-circleci/services"
+)
+
+// newCatFactUpstream starts a stand-in for the cat fact API that answers every
+// request with status and body
+func newCatFactUpstream(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream
+}
+
+func TestCatFactServiceGetCatFact(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantFact string
+		wantErr  string
+	}{
+		{"fact", http.StatusOK, `{"fact":"Cats sleep for 70% of their lives.","length":34}`, "Cats sleep for 70% of their lives.", ""},
+		{"upstream error", http.StatusServiceUnavailable, `{}`, "", "cat fact upstream returned 503 Service Unavailable"},
+		{"malformed body", http.StatusOK, `not json`, "", "invalid character"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := newCatFactUpstream(t, tt.status, tt.body)
+
+			fact, err := services.NewCatFactService(upstream.URL).GetCatFact(context.Background())
+			checkErr(t, err, tt.wantErr)
+			if err == nil && fact.Fact != tt.wantFact {
+				t.Errorf("Fact = %q, want %q", fact.Fact, tt.wantFact)
+			}
+		})
+	}
+}
+
+func TestCatFactServiceUnreachableUpstream(t *testing.T) {
+	upstream := newCatFactUpstream(t, http.StatusOK, `{}`)
+	upstream.Close()
+
+	if _, err := services.NewCatFactService(upstream.URL).GetCatFact(context.Background()); err == nil {
+		t.Error("GetCatFact from a closed upstream succeeded")
+	}
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+
+	"go-circleci/repository"
+	"go-circleci/types"
+)
+
+// StockEvents records StockChanged events in the outbox for stock that moves
+// outside the ProductService, through orders and reservations, in the same
+// transaction as the movement. Holds count against available stock until they
+// expire, not until the sweeper marks them, so no event reports an expiry.
+// Transfers move stock between locations without changing a product's totals.
+type StockEvents struct {
+	outbox   *OutboxService
+	products repository.ProductRepository
+	tx       repository.TxManager
+	cache    *repository.CachedProductRepository
+}
+
+// NewStockEvents creates a StockEvents recording in outbox, reading stock
+// from products in transactions of tx
+func NewStockEvents(outbox *OutboxService, products repository.ProductRepository, tx repository.TxManager) *StockEvents {
+	return &StockEvents{outbox: outbox, products: products, tx: tx}
+}
+
+// SetCache sets the product cache to drop the products whose stock moves, which
+// reservations change without going through the cache
+func (e *StockEvents) SetCache(cache *repository.CachedProductRepository) {
+	e.cache = cache
+}
+
+// track runs fn in a transaction and records a StockChanged event for each of
+// the products whose on-hand or available stock it changed. On a nil
+// StockEvents, or without products, fn runs on its own.
+func (e *StockEvents) track(ctx context.Context, productIDs []int, fn func(ctx context.Context) error) error {
+	if e == nil || len(productIDs) == 0 {
+		return fn(ctx)
+	}
+
+	return e.tx.InTx(ctx, func(ctx context.Context) error {
+		before, err := e.products.GetByIDs(ctx, productIDs)
+		if err != nil {
+			return err
+		}
+		previous := make(map[int]*types.Product, len(before))
+		for _, product := range before {
+			previous[product.ID] = product
+		}
+
+		if err := fn(ctx); err != nil {
+			return err
+		}
+		if e.cache != nil {
+			e.cache.Invalidate(ctx, productIDs...)
+		}
+
+		after, err := e.products.GetByIDs(ctx, productIDs)
+		if err != nil {
+			return err
+		}
+		for _, product := range after {
+			old, ok := previous[product.ID]
+			if !ok || (old.Stock == product.Stock && old.Available == product.Available) {
+				continue
+			}
+			err := e.outbox.Record(ctx, types.EventStockChanged, product.ID, types.StockChangedPayload{
+				ProductID: product.ID,
+				Previous:  old.Stock,
+				Stock:     product.Stock,
+				Delta:     product.Stock - old.Stock,
+				Available: product.Available,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// orderProductIDs returns the IDs of the products on an order's lines
+func orderProductIDs(order *types.Order) []int {
+	ids := make([]int, 0, len(order.Lines))
+	for _, line := range order.Lines {
+		ids = append(ids, line.ProductID)
+	}
+	return ids
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go-circleci/repository"
+	"go-circleci/types"
+)
+
+// normalizeRegion makes region codes such as "gb" or "us-ca" case-insensitive
+func normalizeRegion(region string) string {
+	return strings.ToUpper(strings.TrimSpace(region))
+}
+
+// normalizeTaxClass makes tax classes case-insensitive and defaults them to the standard class
+func normalizeTaxClass(taxClass string) string {
+	taxClass = strings.ToLower(strings.TrimSpace(taxClass))
+	if taxClass == "" {
+		return types.DefaultTaxClass
+	}
+	return taxClass
+}
+
+// TaxRates are the tax rules of a single region, keyed by tax class
+type TaxRates struct {
+	region string
+	rules  map[string]*types.TaxRule
+}
+
+// Split divides an amount into net and tax under the rule for the tax class.
+// A class without a rule in the region is untaxed. Tax is rounded to the cent
+// once per amount, so line taxes add up to the order tax exactly.
+func (t *TaxRates) Split(taxClass string, amount float64) types.TaxAmount {
+	split := types.TaxAmount{Region: t.region, Net: amount, Gross: amount}
+
+	rule, ok := t.rules[normalizeTaxClass(taxClass)]
+	if !ok {
+		return split
+	}
+
+	split.Rate = rule.Rate
+	split.Inclusive = rule.Inclusive
+	if rule.Inclusive {
+		split.Net = roundCents(amount / (1 + rule.Rate/100))
+		split.Tax = roundCents(amount - split.Net)
+	} else {
+		split.Tax = roundCents(amount * rule.Rate / 100)
+		split.Gross = roundCents(amount + split.Tax)
+	}
+
+	return split
+}
+
+// TaxService manages tax rules and computes the tax on prices and orders
+type TaxService struct {
+	repo repository.TaxRuleRepository
+}
+
+// NewTaxService creates a new TaxService with the given repository
+func NewTaxService(repo repository.TaxRuleRepository) *TaxService {
+	return &TaxService{repo: repo}
+}
+
+// ListTaxRules retrieves every tax rule ordered by region and tax class
+func (s *TaxService) ListTaxRules(ctx context.Context) ([]*types.TaxRule, error) {
+	rules, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tax rules: %w", err)
+	}
+
+	// Return empty slice instead of nil for consistency
+	if rules == nil {
+		return []*types.TaxRule{}, nil
+	}
+
+	return rules, nil
+}
+
+// CreateTaxRule creates a tax rule for a region and tax class with input validation
+func (s *TaxService) CreateTaxRule(ctx context.Context, req *types.CreateTaxRuleRequest) (*types.TaxRule, error) {
+	// Validate required fields
+	region := normalizeRegion(req.Region)
+	if region == "" {
+		return nil, errors.New("tax rule region is required")
+	}
+
+	if req.Rate < 0 || req.Rate > 100 {
+		return nil, errors.New("tax rule rate must be between 0 and 100")
+	}
+
+	rule := &types.TaxRule{
+		Region:    region,
+		TaxClass:  normalizeTaxClass(req.TaxClass),
+		Rate:      req.Rate,
+		Inclusive: req.Inclusive,
+	}
+
+	if err := s.repo.Create(ctx, rule); err == repository.ErrTaxRuleExists {
+		return nil, fmt.Errorf("tax rule for region %s and class %s already exists", rule.Region, rule.TaxClass)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to create tax rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+// DeleteTaxRule deletes a tax rule by its ID with validation
+func (s *TaxService) DeleteTaxRule(ctx context.Context, id int) error {
+	// Validate ID
+	if id <= 0 {
+		return errors.New("invalid tax rule ID: must be greater than 0")
+	}
+
+	if err := s.repo.Delete(ctx, id); err == sql.ErrNoRows {
+		return fmt.Errorf("tax rule with ID %d not found", id)
+	} else if err != nil {
+		return fmt.Errorf("failed to delete tax rule: %w", err)
+	}
+
+	return nil
+}
+
+// RatesFor loads the tax rules of a region, failing if the region has none
+func (s *TaxService) RatesFor(ctx context.Context, region string) (*TaxRates, error) {
+	region = normalizeRegion(region)
+	rules, err := s.repo.ListByRegion(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tax rules: %w", err)
+	}
+
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("invalid region %q: no tax rules configured", region)
+	}
+
+	rates := &TaxRates{region: region, rules: make(map[string]*types.TaxRule, len(rules))}
+	for _, rule := range rules {
+		rates.rules[rule.TaxClass] = rule
+	}
+
+	return rates, nil
+}
+
+// ApplyTax sets the gross and net price of each product for the region in the
+// pricing context carried by ctx, leaving products untouched if there is none
+func (s *TaxService) ApplyTax(ctx context.Context, products ...*types.Product) error {
+	region := pricingContextFrom(ctx).Region
+	if region == "" || len(products) == 0 {
+		return nil
+	}
+
+	rates, err := s.RatesFor(ctx, region)
+	if err != nil {
+		return err
+	}
+
+	for _, product := range products {
+		split := rates.Split(product.TaxClass, product.EffectivePrice)
+		product.Tax = &split
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"go-circleci/repository"
+	"go-circleci/repository/repotest"
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+// newTaxService returns a TaxService over an empty SQLite database with the given rules
+func newTaxService(t *testing.T, rules ...types.CreateTaxRuleRequest) *services.TaxService {
+	t.Helper()
+	taxes := services.NewTaxService(repository.NewSQLiteTaxRuleRepository(repotest.OpenSQLite(t)))
+	for _, rule := range rules {
+		_, err := taxes.CreateTaxRule(context.Background(), &rule)
+		checkErr(t, err, "")
+	}
+	return taxes
+}
+
+func TestTaxServiceSplit(t *testing.T) {
+	taxes := newTaxService(t,
+		types.CreateTaxRuleRequest{Region: "gb", Rate: 20},
+		types.CreateTaxRuleRequest{Region: "GB", TaxClass: "reduced", Rate: 5, Inclusive: true},
+		types.CreateTaxRuleRequest{Region: "GB", TaxClass: "zero", Rate: 0},
+		types.CreateTaxRuleRequest{Region: "US-CA", Rate: 7.25},
+	)
+
+	tests := []struct {
+		name     string
+		region   string
+		taxClass string
+		amount   float64
+		want     types.TaxAmount
+	}{
+		{"exclusive", "GB", "standard", 10, types.TaxAmount{Region: "GB", Rate: 20, Net: 10, Tax: 2, Gross: 12}},
+		{"default class", "gb", "", 10, types.TaxAmount{Region: "GB", Rate: 20, Net: 10, Tax: 2, Gross: 12}},
+		{"class is case-insensitive", "GB", " Standard ", 10, types.TaxAmount{Region: "GB", Rate: 20, Net: 10, Tax: 2, Gross: 12}},
+		{"exclusive rounds the tax", "GB", "standard", 0.99, types.TaxAmount{Region: "GB", Rate: 20, Net: 0.99, Tax: 0.2, Gross: 1.19}},
+		{"inclusive", "GB", "reduced", 10.5, types.TaxAmount{Region: "GB", Rate: 5, Inclusive: true, Net: 10, Tax: 0.5, Gross: 10.5}},
+		{"inclusive rounds the net", "GB", "reduced", 9.99, types.TaxAmount{Region: "GB", Rate: 5, Inclusive: true, Net: 9.51, Tax: 0.48, Gross: 9.99}},
+		{"zero rate", "GB", "zero", 10, types.TaxAmount{Region: "GB", Net: 10, Gross: 10}},
+		{"class without a rule is untaxed", "GB", "books", 10, types.TaxAmount{Region: "GB", Net: 10, Gross: 10}},
+		{"fractional rate", "us-ca", "standard", 19.99, types.TaxAmount{Region: "US-CA", Rate: 7.25, Net: 19.99, Tax: 1.45, Gross: 21.44}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rates, err := taxes.RatesFor(context.Background(), tt.region)
+			checkErr(t, err, "")
+			if got := rates.Split(tt.taxClass, tt.amount); got != tt.want {
+				t.Errorf("Split(%q, %v) = %+v, want %+v", tt.taxClass, tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaxServiceRatesForUnknownRegion(t *testing.T) {
+	taxes := newTaxService(t, types.CreateTaxRuleRequest{Region: "GB", Rate: 20})
+
+	_, err := taxes.RatesFor(context.Background(), "fr")
+	checkErr(t, err, `invalid region "FR": no tax rules configured`)
+}
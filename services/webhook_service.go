@@ -0,0 +1,474 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-circleci/repository"
+	"go-circleci/types"
+)
+
+// Headers sent with every webhook. The signature is "sha256=" followed by the
+// hex HMAC-SHA256 of "<timestamp>.<body>" keyed by the subscription's secret,
+// so receivers can check both who sent a payload and when.
+const (
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// minWebhookSecretLength is the shortest secret a subscription may be given
+const minWebhookSecretLength = 16
+
+// Delivery log listings return at most maxWebhookDeliveries deliveries, and
+// defaultWebhookDeliveries unless a limit is given
+const (
+	defaultWebhookDeliveries = 50
+	maxWebhookDeliveries     = 500
+)
+
+// SignWebhook returns the signature header value of a payload sent at timestamp
+func SignWebhook(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks the signature of a received webhook and that it was
+// sent no more than tolerance from now, which stops old payloads being replayed
+func VerifyWebhook(secret string, header http.Header, payload []byte, tolerance time.Duration, now time.Time) error {
+	timestamp, err := strconv.ParseInt(header.Get(WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		return errors.New("invalid webhook timestamp")
+	}
+	if sent := time.Unix(timestamp, 0); now.Sub(sent) > tolerance || sent.Sub(now) > tolerance {
+		return errors.New("webhook timestamp is outside the tolerance")
+	}
+
+	want := SignWebhook(secret, timestamp, payload)
+	if !hmac.Equal([]byte(header.Get(WebhookSignatureHeader)), []byte(want)) {
+		return errors.New("invalid webhook signature")
+	}
+	return nil
+}
+
+// WebhookOptions tunes webhook delivery. Zero values take the defaults of DefaultWebhookOptions.
+type WebhookOptions struct {
+	// BatchSize is the most deliveries attempted per pass
+	BatchSize int
+	// MaxAttempts is how often a delivery is tried before it is marked failed
+	MaxAttempts int
+	// Backoff is the wait after the first failed attempt, doubling after every
+	// further one up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// DisableAfter is how many attempts in a row may fail before the
+	// subscription is disabled
+	DisableAfter int
+	// Timeout limits each request to a receiver
+	Timeout time.Duration
+}
+
+// DefaultWebhookOptions returns the delivery settings used unless overridden
+func DefaultWebhookOptions() WebhookOptions {
+	return WebhookOptions{
+		BatchSize:    50,
+		MaxAttempts:  8,
+		Backoff:      30 * time.Second,
+		MaxBackoff:   time.Hour,
+		DisableAfter: 20,
+		Timeout:      10 * time.Second,
+	}
+}
+
+// WebhookService manages partners' webhook subscriptions and delivers the
+// events they subscribe to as signed callbacks. It is an outbox Publisher:
+// the outbox hands it each event once it is committed, and it fans the event
+// out to a delivery per subscription that it retries independently.
+type WebhookService struct {
+	repo   repository.WebhookRepository
+	client *http.Client
+	opts   WebhookOptions
+	retry  retrySchedule
+	now    func() time.Time
+}
+
+// NewWebhookService creates a new WebhookService with the given repository
+func NewWebhookService(repo repository.WebhookRepository, opts WebhookOptions) *WebhookService {
+	defaults := DefaultWebhookOptions()
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaults.BatchSize
+	}
+	if opts.DisableAfter <= 0 {
+		opts.DisableAfter = defaults.DisableAfter
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaults.Timeout
+	}
+
+	return &WebhookService{
+		repo:   repo,
+		client: &http.Client{Timeout: opts.Timeout},
+		opts:   opts,
+		retry:  newRetrySchedule(opts.MaxAttempts, opts.Backoff, opts.MaxBackoff, retrySchedule{defaults.MaxAttempts, defaults.Backoff, defaults.MaxBackoff}),
+		now:    func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Name returns "webhooks"
+func (s *WebhookService) Name() string { return "webhooks" }
+
+// Publish enqueues a delivery of the event to every active subscription whose
+// filter matches it
+func (s *WebhookService) Publish(ctx context.Context, event *types.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.repo.Enqueue(ctx, event, payload, s.now()); err != nil {
+		return fmt.Errorf("failed to enqueue webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// Dispatch attempts the deliveries that are due and returns how many
+// succeeded. Subscriptions are sent to concurrently, so a slow or unreachable
+// receiver holds up only its own deliveries, which are attempted in order.
+func (s *WebhookService) Dispatch(ctx context.Context) (int, error) {
+	deliveries, err := s.repo.ListDueDeliveries(ctx, s.now(), s.opts.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list due webhook deliveries: %w", err)
+	}
+
+	bySubscription := make(map[int][]*types.WebhookDelivery)
+	for _, delivery := range deliveries {
+		bySubscription[delivery.SubscriptionID] = append(bySubscription[delivery.SubscriptionID], delivery)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+		errs      []error
+	)
+	for _, pending := range bySubscription {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := s.dispatchTo(ctx, pending)
+
+			mu.Lock()
+			defer mu.Unlock()
+			succeeded += n
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return succeeded, errors.Join(errs...)
+}
+
+// dispatchTo attempts the due deliveries to one subscription in order and
+// returns how many succeeded, stopping if the subscription is disabled
+func (s *WebhookService) dispatchTo(ctx context.Context, deliveries []*types.WebhookDelivery) (int, error) {
+	succeeded := 0
+	for _, delivery := range deliveries {
+		code, err := s.send(ctx, delivery)
+		delivery.Attempts++
+		delivery.ResponseCode = code
+		if err != nil {
+			delivery.LastError = err.Error()
+			delivery.NextAttemptAt = s.now().Add(s.retry.wait(delivery.Attempts))
+			if s.retry.exhausted(delivery.Attempts) {
+				delivery.Status = types.WebhookFailed
+			}
+		} else {
+			deliveredAt := s.now()
+			delivery.Status = types.WebhookSucceeded
+			delivery.LastError = ""
+			delivery.DeliveredAt = &deliveredAt
+			succeeded++
+		}
+
+		if err := s.repo.UpdateDelivery(ctx, delivery); err != nil {
+			return succeeded, fmt.Errorf("failed to update webhook delivery %d: %w", delivery.ID, err)
+		}
+
+		active, err := s.repo.RecordResult(ctx, delivery.SubscriptionID, delivery.Status == types.WebhookSucceeded, s.opts.DisableAfter, s.now())
+		if err != nil {
+			return succeeded, fmt.Errorf("failed to update webhook %d: %w", delivery.SubscriptionID, err)
+		}
+		if !active {
+			// A disabled subscription gets no more attempts
+			fmt.Printf("webhook id=%d disabled after %d consecutive failures\n", delivery.SubscriptionID, s.opts.DisableAfter)
+			break
+		}
+	}
+
+	return succeeded, nil
+}
+
+// send posts a delivery's payload, signed, to its subscription's URL and
+// returns the response status code, failing on any non-2xx response
+func (s *WebhookService) send(ctx context.Context, delivery *types.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, strings.NewReader(string(delivery.Payload)))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := s.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookDeliveryHeader, strconv.Itoa(delivery.ID))
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(delivery.Secret, timestamp, delivery.Payload))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	// Read some of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("webhook returned status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// RunDispatcher attempts due deliveries every interval until ctx is cancelled
+func (s *WebhookService) RunDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Dispatch(ctx); err != nil {
+				fmt.Printf("webhook dispatcher err=%v\n", err)
+			}
+		}
+	}
+}
+
+// newWebhookSecret generates a random signing secret
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validateWebhook checks a subscription's URL, secret and event filter, and
+// returns the filter without duplicates
+func validateWebhook(rawURL, secret string, events []string) ([]string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("webhook url must be an absolute http or https URL")
+	}
+	if secret != "" && len(secret) < minWebhookSecretLength {
+		return nil, fmt.Errorf("webhook secret must be at least %d characters", minWebhookSecretLength)
+	}
+
+	filter := []string{}
+	for _, event := range events {
+		switch event {
+		case types.EventProductCreated, types.EventProductUpdated, types.EventProductDeleted, types.EventStockChanged:
+		default:
+			return nil, fmt.Errorf("invalid event type %q", event)
+		}
+		if !containsString(filter, event) {
+			filter = append(filter, event)
+		}
+	}
+	return filter, nil
+}
+
+// ListWebhooks retrieves every subscription, without their secrets
+func (s *WebhookService) ListWebhooks(ctx context.Context) ([]*types.WebhookSubscription, error) {
+	subs, err := s.repo.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+
+	for _, sub := range subs {
+		sub.Secret = ""
+	}
+	return subs, nil
+}
+
+// GetWebhook retrieves a subscription by its ID, without its secret
+func (s *WebhookService) GetWebhook(ctx context.Context, id int) (*types.WebhookSubscription, error) {
+	sub, err := s.getWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	sub.Secret = ""
+	return sub, nil
+}
+
+// getWebhook retrieves a subscription by its ID
+func (s *WebhookService) getWebhook(ctx context.Context, id int) (*types.WebhookSubscription, error) {
+	if id <= 0 {
+		return nil, errors.New("invalid webhook ID: must be greater than 0")
+	}
+
+	sub, err := s.repo.GetSubscription(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("webhook with ID %d not found", id)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return sub, nil
+}
+
+// CreateWebhook subscribes a URL to events and returns the subscription with
+// its secret, which is not shown again
+func (s *WebhookService) CreateWebhook(ctx context.Context, req *types.CreateWebhookRequest) (*types.WebhookSubscription, error) {
+	events, err := validateWebhook(req.URL, req.Secret, req.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		if secret, err = newWebhookSecret(); err != nil {
+			return nil, fmt.Errorf("failed to create webhook: %w", err)
+		}
+	}
+
+	sub := &types.WebhookSubscription{
+		URL:       req.URL,
+		Secret:    secret,
+		Events:    events,
+		Active:    true,
+		CreatedAt: s.now(),
+	}
+	if err := s.repo.CreateSubscription(ctx, sub); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return sub, nil
+}
+
+// UpdateWebhook replaces a subscription's URL and event filter and enables or
+// disables it. Enabling a disabled subscription clears its failures. The
+// secret is returned only if it was rotated.
+func (s *WebhookService) UpdateWebhook(ctx context.Context, id int, req *types.UpdateWebhookRequest) (*types.WebhookSubscription, error) {
+	events, err := validateWebhook(req.URL, req.Secret, req.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := s.getWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	sub.URL = req.URL
+	sub.Events = events
+	if req.Active && !sub.Active {
+		sub.ConsecutiveFailures = 0
+		sub.DisabledAt = nil
+	} else if !req.Active && sub.Active {
+		disabledAt := s.now()
+		sub.DisabledAt = &disabledAt
+	}
+	sub.Active = req.Active
+	if req.Secret != "" {
+		sub.Secret = req.Secret
+	}
+
+	if err := s.repo.UpdateSubscription(ctx, sub); err == sql.ErrNoRows {
+		return nil, fmt.Errorf("webhook with ID %d not found", id)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	if req.Secret == "" {
+		sub.Secret = ""
+	}
+	return sub, nil
+}
+
+// DeleteWebhook removes a subscription and its delivery log
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id int) error {
+	if id <= 0 {
+		return errors.New("invalid webhook ID: must be greater than 0")
+	}
+
+	if err := s.repo.DeleteSubscription(ctx, id); err == sql.ErrNoRows {
+		return fmt.Errorf("webhook with ID %d not found", id)
+	} else if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return nil
+}
+
+// ListWebhookDeliveries retrieves up to limit deliveries to a subscription, newest first
+func (s *WebhookService) ListWebhookDeliveries(ctx context.Context, id, limit int) ([]*types.WebhookDelivery, error) {
+	if limit < 0 || limit > maxWebhookDeliveries {
+		return nil, fmt.Errorf("delivery limit must be between 1 and %d", maxWebhookDeliveries)
+	}
+	if limit == 0 {
+		limit = defaultWebhookDeliveries
+	}
+
+	if _, err := s.getWebhook(ctx, id); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.repo.ListDeliveries(ctx, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// RedeliverWebhook sends a delivery to its subscription again, whatever its
+// outcome was, as a new delivery attempted on the next pass
+func (s *WebhookService) RedeliverWebhook(ctx context.Context, id, deliveryID int) (*types.WebhookDelivery, error) {
+	sub, err := s.getWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !sub.Active {
+		return nil, fmt.Errorf("webhook with ID %d is disabled and cannot be redelivered to", id)
+	}
+
+	delivery, err := s.repo.GetDelivery(ctx, deliveryID)
+	if err == sql.ErrNoRows || (err == nil && delivery.SubscriptionID != id) {
+		return nil, fmt.Errorf("delivery with ID %d of webhook %d not found", deliveryID, id)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to redeliver webhook: %w", err)
+	}
+
+	redelivery, err := s.repo.Redeliver(ctx, delivery.ID, s.now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeliver webhook: %w", err)
+	}
+	return redelivery, nil
+}
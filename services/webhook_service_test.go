@@ -0,0 +1,194 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go-circleci/services"
+	"go-circleci/types"
+)
+
+// webhookReceiver is a partner endpoint that verifies signatures and answers
+// with status, recording the events it accepted
+type webhookReceiver struct {
+	mu     sync.Mutex
+	secret string
+	status int
+	events []types.Event
+	errs   []error
+}
+
+func (rcv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	if err := services.VerifyWebhook(rcv.secret, r.Header, body, 5*time.Minute, time.Now()); err != nil {
+		rcv.errs = append(rcv.errs, err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if rcv.status != http.StatusOK {
+		w.WriteHeader(rcv.status)
+		return
+	}
+
+	var event types.Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		rcv.errs = append(rcv.errs, err)
+	}
+	rcv.events = append(rcv.events, event)
+}
+
+func TestWebhookServiceDeliversSignedEvents(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLServices(t, sqlOptions{})
+	svc, outbox, webhooks := s.product, s.outbox, s.webhooks
+	receiver := &webhookReceiver{secret: "0123456789abcdef", status: http.StatusOK}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	sub, err := webhooks.CreateWebhook(ctx, &types.CreateWebhookRequest{URL: server.URL, Secret: receiver.secret, Events: []string{types.EventStockChanged}})
+	checkErr(t, err, "")
+	all, err := webhooks.CreateWebhook(ctx, &types.CreateWebhookRequest{URL: server.URL + "/all"})
+	checkErr(t, err, "")
+	if len(all.Secret) != 64 {
+		t.Errorf("generated secret = %q, want 32 random bytes in hex", all.Secret)
+	}
+	checkErr(t, webhooks.DeleteWebhook(ctx, all.ID), "")
+
+	_, err = svc.CreateProduct(ctx, &types.CreateProductRequest{Name: "Widget", Stock: 5})
+	checkErr(t, err, "")
+	_, err = outbox.Relay(ctx)
+	checkErr(t, err, "")
+	// An event relayed again is not delivered twice
+	checkErr(t, webhooks.Publish(ctx, &types.Event{ID: 2, Type: types.EventStockChanged}), "")
+
+	sent, err := webhooks.Dispatch(ctx)
+	checkErr(t, err, "")
+	if sent != 1 || len(receiver.errs) != 0 || len(receiver.events) != 1 || receiver.events[0].Type != types.EventStockChanged {
+		t.Fatalf("Dispatch sent %d, receiver got %+v with errors %v, want the StockChanged event only", sent, receiver.events, receiver.errs)
+	}
+
+	deliveries, err := webhooks.ListWebhookDeliveries(ctx, sub.ID, 0)
+	checkErr(t, err, "")
+	if len(deliveries) != 1 || deliveries[0].Status != types.WebhookSucceeded || deliveries[0].ResponseCode != http.StatusOK {
+		t.Fatalf("deliveries = %+v, want one succeeded with status 200", deliveries)
+	}
+
+	redelivery, err := webhooks.RedeliverWebhook(ctx, sub.ID, deliveries[0].ID)
+	checkErr(t, err, "")
+	if redelivery.RedeliveryOf == nil || *redelivery.RedeliveryOf != deliveries[0].ID || string(redelivery.Payload) != string(deliveries[0].Payload) {
+		t.Errorf("redelivery = %+v, want a copy of delivery %d", redelivery, deliveries[0].ID)
+	}
+	sent, err = webhooks.Dispatch(ctx)
+	checkErr(t, err, "")
+	if sent != 1 || len(receiver.events) != 2 || receiver.events[1].ID != receiver.events[0].ID {
+		t.Errorf("redelivery sent %d, receiver got %+v, want the same event again", sent, receiver.events)
+	}
+
+	got, err := webhooks.GetWebhook(ctx, sub.ID)
+	checkErr(t, err, "")
+	if got.Secret != "" {
+		t.Errorf("GetWebhook returned the secret")
+	}
+	_, err = webhooks.RedeliverWebhook(ctx, sub.ID, 99)
+	checkErr(t, err, "not found")
+	_, err = webhooks.CreateWebhook(ctx, &types.CreateWebhookRequest{URL: "ftp://example.com"})
+	checkErr(t, err, "must be an absolute http or https URL")
+	_, err = webhooks.CreateWebhook(ctx, &types.CreateWebhookRequest{URL: server.URL, Events: []string{"OrderPlaced"}})
+	checkErr(t, err, "invalid event type")
+}
+
+func TestWebhookServiceRetriesAndDisables(t *testing.T) {
+	ctx := context.Background()
+	webhooks := newSQLServices(t, sqlOptions{webhook: services.WebhookOptions{MaxAttempts: 2, DisableAfter: 3, Backoff: time.Millisecond}}).webhooks
+	receiver := &webhookReceiver{secret: "0123456789abcdef", status: http.StatusServiceUnavailable}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	sub, err := webhooks.CreateWebhook(ctx, &types.CreateWebhookRequest{URL: server.URL, Secret: receiver.secret})
+	checkErr(t, err, "")
+	checkErr(t, webhooks.Publish(ctx, &types.Event{ID: 1, Type: types.EventProductCreated}), "")
+	checkErr(t, webhooks.Publish(ctx, &types.Event{ID: 2, Type: types.EventProductUpdated}), "")
+
+	// Each pass fails both due deliveries until the first gives up after 2
+	// attempts and the third failure in a row disables the subscription
+	for range 3 {
+		time.Sleep(5 * time.Millisecond)
+		_, err := webhooks.Dispatch(ctx)
+		checkErr(t, err, "")
+	}
+
+	deliveries, err := webhooks.ListWebhookDeliveries(ctx, sub.ID, 0)
+	checkErr(t, err, "")
+	if len(deliveries) != 2 {
+		t.Fatalf("deliveries = %d, want 2", len(deliveries))
+	}
+	first, second := deliveries[1], deliveries[0]
+	if first.Status != types.WebhookFailed || first.Attempts != 2 || first.ResponseCode != http.StatusServiceUnavailable || first.LastError != "webhook returned status 503" {
+		t.Errorf("first delivery = %+v, want failed after 2 attempts with status 503", first)
+	}
+	if second.Status != types.WebhookPending || second.Attempts != 1 {
+		t.Errorf("second delivery = %+v, want pending after 1 attempt", second)
+	}
+
+	got, err := webhooks.GetWebhook(ctx, sub.ID)
+	checkErr(t, err, "")
+	if got.Active || got.DisabledAt == nil || got.ConsecutiveFailures != 3 {
+		t.Fatalf("webhook = %+v, want disabled after 3 failures", got)
+	}
+	_, err = webhooks.RedeliverWebhook(ctx, sub.ID, first.ID)
+	checkErr(t, err, "cannot be redelivered")
+
+	// Re-enabling the subscription resumes its pending deliveries
+	receiver.status = http.StatusOK
+	got, err = webhooks.UpdateWebhook(ctx, sub.ID, &types.UpdateWebhookRequest{URL: server.URL, Active: true})
+	checkErr(t, err, "")
+	if !got.Active || got.ConsecutiveFailures != 0 || got.Secret != "" {
+		t.Fatalf("updated webhook = %+v, want active with no failures", got)
+	}
+	time.Sleep(5 * time.Millisecond)
+	sent, err := webhooks.Dispatch(ctx)
+	checkErr(t, err, "")
+	if sent != 1 || len(receiver.events) != 1 || receiver.events[0].ID != 2 {
+		t.Errorf("Dispatch after enabling sent %d, receiver got %+v, want event 2", sent, receiver.events)
+	}
+}
+
+func TestWebhookServiceDispatchesSubscriptionsConcurrently(t *testing.T) {
+	ctx := context.Background()
+	webhooks := newSQLServices(t, sqlOptions{}).webhooks
+
+	// The slow receiver only answers once the fast one has been called, which
+	// fails unless the two are sent to at the same time
+	called := make(chan struct{})
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { close(called) }))
+	defer fast.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-called:
+		case <-time.After(2 * time.Second):
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}
+	}))
+	defer slow.Close()
+
+	for _, url := range []string{slow.URL, fast.URL} {
+		_, err := webhooks.CreateWebhook(ctx, &types.CreateWebhookRequest{URL: url})
+		checkErr(t, err, "")
+	}
+	checkErr(t, webhooks.Publish(ctx, &types.Event{ID: 1, Type: types.EventProductCreated}), "")
+
+	sent, err := webhooks.Dispatch(ctx)
+	checkErr(t, err, "")
+	if sent != 2 {
+		t.Errorf("Dispatch sent %d, want both subscriptions in one pass", sent)
+	}
+}
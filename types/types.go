@@ -1,27 +1,718 @@
 package types
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type CatFact struct {
 	Fact string `json:"fact"`
 }
 
 type Product struct {
-	ID          int     `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Stock       int     `json:"stock"`
+	ID             int                `json:"id"`
+	SKU            string             `json:"sku"`
+	Name           string             `json:"name"`
+	Description    string             `json:"description"`
+	Category       string             `json:"category"`
+	Price          float64            `json:"price"`
+	EffectivePrice float64            `json:"effective_price"`
+	Promotions     []AppliedPromotion `json:"promotions"`
+	TaxClass       string             `json:"tax_class"`
+	Tax            *TaxAmount         `json:"tax,omitempty"`
+	Stock          int                `json:"stock"`
+	OnHand         int                `json:"on_hand"`
+	Available      int                `json:"available"`
+	ReorderPoint   int                `json:"reorder_point"`
+	ReorderQty     int                `json:"reorder_qty"`
+	DeletedAt      *time.Time         `json:"deleted_at,omitempty"`
 }
 
 type CreateProductRequest struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Stock       int     `json:"stock"`
+	SKU          string  `json:"sku"`
+	Name         string  `json:"name"`
+	Description  string  `json:"description"`
+	Category     string  `json:"category"`
+	TaxClass     string  `json:"tax_class"`
+	Price        float64 `json:"price"`
+	Stock        int     `json:"stock"`
+	ReorderPoint int     `json:"reorder_point"`
+	ReorderQty   int     `json:"reorder_qty"`
 }
 
 type UpdateProductRequest struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Stock       int     `json:"stock"`
+	SKU          string  `json:"sku"`
+	Name         string  `json:"name"`
+	Description  string  `json:"description"`
+	Category     string  `json:"category"`
+	TaxClass     string  `json:"tax_class"`
+	Price        float64 `json:"price"`
+	Stock        int     `json:"stock"`
+	ReorderPoint int     `json:"reorder_point"`
+	ReorderQty   int     `json:"reorder_qty"`
+}
+
+// Reservation statuses
+const (
+	ReservationActive    = "active"
+	ReservationConfirmed = "confirmed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+// Reservation holds units of a product for a checkout until it is confirmed, released or expires
+type Reservation struct {
+	ID        int       `json:"id"`
+	ProductID int       `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+	Reference string    `json:"reference"`
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateReservationRequest struct {
+	ProductID  int    `json:"product_id"`
+	Quantity   int    `json:"quantity"`
+	Reference  string `json:"reference"`
+	TTLSeconds int    `json:"ttl_seconds"`
+}
+
+// Location kinds
+const (
+	LocationWarehouse = "warehouse"
+	LocationStore     = "store"
+)
+
+// DefaultLocationID is the main warehouse, which receives stock set directly on a product
+const DefaultLocationID = 1
+
+// Location is a warehouse or store that holds stock
+type Location struct {
+	ID   int    `json:"id"`
+	Code string `json:"code"`
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+type CreateLocationRequest struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+// LocationStock is the stock level of a product at a single location
+type LocationStock struct {
+	LocationID int    `json:"location_id"`
+	Code       string `json:"code"`
+	Name       string `json:"name"`
+	Quantity   int    `json:"quantity"`
+}
+
+// ProductStock breaks a product's on-hand stock down by location
+type ProductStock struct {
+	ProductID int             `json:"product_id"`
+	Total     int             `json:"total"`
+	Locations []LocationStock `json:"locations"`
+}
+
+// Stock movement kinds recorded in the ledger
+const (
+	MovementInitial     = "initial"
+	MovementAdjustment  = "adjustment"
+	MovementTransferOut = "transfer_out"
+	MovementTransferIn  = "transfer_in"
+	MovementSale        = "sale"
+)
+
+// StockMovement is a single ledger entry changing the stock of a product at a location
+type StockMovement struct {
+	ID         int       `json:"id"`
+	ProductID  int       `json:"product_id"`
+	LocationID int       `json:"location_id"`
+	Quantity   int       `json:"quantity"`
+	Kind       string    `json:"kind"`
+	Reference  string    `json:"reference"`
+	TransferID *int      `json:"transfer_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type StockTransferRequest struct {
+	ProductID      int    `json:"product_id"`
+	FromLocationID int    `json:"from_location_id"`
+	ToLocationID   int    `json:"to_location_id"`
+	Quantity       int    `json:"quantity"`
+	Reference      string `json:"reference"`
+}
+
+// StockTransfer moves units between two locations as a pair of ledger entries
+type StockTransfer struct {
+	ID             int       `json:"id"`
+	ProductID      int       `json:"product_id"`
+	FromLocationID int       `json:"from_location_id"`
+	ToLocationID   int       `json:"to_location_id"`
+	Quantity       int       `json:"quantity"`
+	Reference      string    `json:"reference"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// LowStockItem is a product whose available stock is at or below its reorder point
+type LowStockItem struct {
+	ProductID    int        `json:"product_id"`
+	Name         string     `json:"name"`
+	OnHand       int        `json:"on_hand"`
+	Available    int        `json:"available"`
+	ReorderPoint int        `json:"reorder_point"`
+	ReorderQty   int        `json:"reorder_qty"`
+	AlertedAt    *time.Time `json:"alerted_at,omitempty"`
+}
+
+// LowStockAlert is emitted once each time a product's available stock falls to its reorder point
+type LowStockAlert struct {
+	ProductID    int       `json:"product_id"`
+	Name         string    `json:"name"`
+	Available    int       `json:"available"`
+	ReorderPoint int       `json:"reorder_point"`
+	ReorderQty   int       `json:"reorder_qty"`
+	At           time.Time `json:"at"`
+}
+
+// Order statuses
+const (
+	OrderPending   = "pending"
+	OrderPaid      = "paid"
+	OrderFulfilled = "fulfilled"
+	OrderCancelled = "cancelled"
+	OrderRefunded  = "refunded"
+)
+
+// Order is a customer's purchase of one or more products
+type Order struct {
+	ID        int         `json:"id"`
+	Status    string      `json:"status"`
+	Customer  string      `json:"customer"`
+	Coupon    string      `json:"coupon,omitempty"`
+	Region    string      `json:"region,omitempty"`
+	Lines     []OrderLine `json:"lines"`
+	Net       float64     `json:"net"`
+	Tax       float64     `json:"tax"`
+	Total     float64     `json:"total"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// OrderLine is a product and quantity on an order, priced at its effective price when the order was placed
+type OrderLine struct {
+	ID          int     `json:"id"`
+	ProductID   int     `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	UnitPrice   float64 `json:"unit_price"`
+	Quantity    int     `json:"quantity"`
+	LineTotal   float64 `json:"line_total"`
+	TaxRate     float64 `json:"tax_rate"`
+	Tax         float64 `json:"tax"`
+}
+
+type CreateOrderRequest struct {
+	Customer string                   `json:"customer"`
+	Coupon   string                   `json:"coupon"`
+	Region   string                   `json:"region"`
+	Lines    []CreateOrderLineRequest `json:"lines"`
+}
+
+type CreateOrderLineRequest struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+// OrderFilter narrows an order listing; zero values match everything
+type OrderFilter struct {
+	Status string
+	From   time.Time
+	To     time.Time
+}
+
+// Cart statuses
+const (
+	CartOpen       = "open"
+	CartCheckedOut = "checked_out"
+	CartExpired    = "expired"
+)
+
+// Cart is a customer's server-side shopping cart, priced at current effective prices
+type Cart struct {
+	ID        int        `json:"id"`
+	Status    string     `json:"status"`
+	Customer  string     `json:"customer"`
+	Lines     []CartLine `json:"lines"`
+	Total     float64    `json:"total"`
+	OrderID   *int       `json:"order_id,omitempty"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// CartLine is a product in a cart. UnitPrice is always the product's current
+// effective price; PriceChanged reports that it differs from the price when the line was last changed.
+type CartLine struct {
+	ProductID    int     `json:"product_id"`
+	ProductName  string  `json:"product_name"`
+	UnitPrice    float64 `json:"unit_price"`
+	AddedPrice   float64 `json:"added_price"`
+	PriceChanged bool    `json:"price_changed"`
+	Quantity     int     `json:"quantity"`
+	Available    int     `json:"available"`
+	LineTotal    float64 `json:"line_total"`
+}
+
+type CreateCartRequest struct {
+	Customer string `json:"customer"`
+}
+
+type CartLineRequest struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+// Promotion kinds
+const (
+	PromotionPercentage = "percentage"
+	PromotionFixed      = "fixed"
+)
+
+// Promotion discounts products by a percentage or a fixed amount. It can be limited
+// to one product or category, a time window and a customer, and when it has a
+// coupon code it only applies to requests presenting that code, up to MaxUses times.
+// Requests name their customer without proving it, so targeting a customer
+// personalises prices but does not keep others from claiming them; a coupon
+// code is what restricts a promotion.
+type Promotion struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Kind       string     `json:"kind"`
+	Value      float64    `json:"value"`
+	ProductID  *int       `json:"product_id,omitempty"`
+	Category   string     `json:"category,omitempty"`
+	Customer   string     `json:"customer,omitempty"`
+	CouponCode string     `json:"coupon_code,omitempty"`
+	MaxUses    int        `json:"max_uses"`
+	Uses       int        `json:"uses"`
+	StartsAt   *time.Time `json:"starts_at,omitempty"`
+	EndsAt     *time.Time `json:"ends_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreatePromotionRequest struct {
+	Name       string     `json:"name"`
+	Kind       string     `json:"kind"`
+	Value      float64    `json:"value"`
+	ProductID  *int       `json:"product_id"`
+	Category   string     `json:"category"`
+	Customer   string     `json:"customer"`
+	CouponCode string     `json:"coupon_code"`
+	MaxUses    int        `json:"max_uses"`
+	StartsAt   *time.Time `json:"starts_at"`
+	EndsAt     *time.Time `json:"ends_at"`
+}
+
+// AppliedPromotion is a promotion applied to a product's price and the amount it took off
+type AppliedPromotion struct {
+	ID         int     `json:"id"`
+	Name       string  `json:"name"`
+	CouponCode string  `json:"coupon_code,omitempty"`
+	Discount   float64 `json:"discount"`
+}
+
+// PricingContext is who is buying, when, and with which coupon, which decides
+// the promotions that apply to a price. A zero At means now. When Region is set,
+// prices also carry their tax for that region.
+type PricingContext struct {
+	At       time.Time
+	Customer string
+	Coupon   string
+	Region   string
+}
+
+type ValidateCouponRequest struct {
+	Code      string `json:"code"`
+	Customer  string `json:"customer"`
+	ProductID int    `json:"product_id"`
+}
+
+// CouponValidation reports whether a coupon can be used now and, for a product,
+// the price it would bring the product to
+type CouponValidation struct {
+	Code           string     `json:"code"`
+	Valid          bool       `json:"valid"`
+	Reason         string     `json:"reason,omitempty"`
+	Promotion      *Promotion `json:"promotion,omitempty"`
+	ProductID      int        `json:"product_id,omitempty"`
+	Price          float64    `json:"price,omitempty"`
+	EffectivePrice float64    `json:"effective_price,omitempty"`
+}
+
+// DefaultTaxClass is the tax class of products created without one
+const DefaultTaxClass = "standard"
+
+// TaxRule is the tax rate for a product class in a region. Inclusive rules
+// treat prices as already including the tax; exclusive rules add it on top.
+type TaxRule struct {
+	ID        int     `json:"id"`
+	Region    string  `json:"region"`
+	TaxClass  string  `json:"tax_class"`
+	Rate      float64 `json:"rate"`
+	Inclusive bool    `json:"inclusive"`
+}
+
+type CreateTaxRuleRequest struct {
+	Region    string  `json:"region"`
+	TaxClass  string  `json:"tax_class"`
+	Rate      float64 `json:"rate"`
+	Inclusive bool    `json:"inclusive"`
+}
+
+// TaxAmount splits a price into its net amount and tax for a region
+type TaxAmount struct {
+	Region    string  `json:"region"`
+	Rate      float64 `json:"rate"`
+	Inclusive bool    `json:"inclusive"`
+	Net       float64 `json:"net"`
+	Tax       float64 `json:"tax"`
+	Gross     float64 `json:"gross"`
+}
+
+// ProductImportRow is one row of a product import, matched to an existing product by SKU
+type ProductImportRow struct {
+	Line         int     `json:"-"`
+	SKU          string  `json:"sku"`
+	Name         string  `json:"name"`
+	Description  string  `json:"description"`
+	Category     string  `json:"category"`
+	TaxClass     string  `json:"tax_class"`
+	Price        float64 `json:"price"`
+	Stock        int     `json:"stock"`
+	ReorderPoint int     `json:"reorder_point"`
+	ReorderQty   int     `json:"reorder_qty"`
+	// Columns names the columns the row carries, or is nil if it carries them
+	// all. An update keeps the existing product's value of every other column.
+	Columns []string `json:"-"`
+}
+
+// Import row actions
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportFailed  = "failed"
+)
+
+// ImportRowResult is the outcome of importing a single row. In a dry run the
+// action is what would have happened.
+type ImportRowResult struct {
+	Line      int    `json:"line"`
+	SKU       string `json:"sku"`
+	Action    string `json:"action"`
+	ProductID int    `json:"product_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ImportReport summarises a product import and lists the rows that failed
+type ImportReport struct {
+	DryRun  bool              `json:"dry_run"`
+	Rows    int               `json:"rows"`
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Failed  int               `json:"failed"`
+	Errors  []ImportRowResult `json:"errors"`
+}
+
+// Product batch modes
+const (
+	BatchAtomic     = "atomic"
+	BatchBestEffort = "best_effort"
+)
+
+// Product batch operations
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// Product batch operation statuses
+const (
+	BatchOK         = "ok"
+	BatchFailed     = "failed"
+	BatchRolledBack = "rolled_back"
+	BatchSkipped    = "skipped"
+)
+
+// ProductBatchOperation creates a product, or updates or deletes the product with ID
+type ProductBatchOperation struct {
+	Op      string                `json:"op"`
+	ID      int                   `json:"id,omitempty"`
+	Product *CreateProductRequest `json:"product,omitempty"`
+}
+
+// ProductBatchRequest is a list of product operations applied all-or-nothing in
+// atomic mode, the default, or independently in best-effort mode
+type ProductBatchRequest struct {
+	Mode       string                  `json:"mode"`
+	Operations []ProductBatchOperation `json:"operations"`
+}
+
+// ProductBatchResult is the outcome of one operation of a batch
+type ProductBatchResult struct {
+	Index   int      `json:"index"`
+	Op      string   `json:"op"`
+	ID      int      `json:"id,omitempty"`
+	Status  string   `json:"status"`
+	Product *Product `json:"product,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// ProductBatchResponse reports whether a batch was committed and the outcome of each operation
+type ProductBatchResponse struct {
+	Mode      string               `json:"mode"`
+	Committed bool                 `json:"committed"`
+	Succeeded int                  `json:"succeeded"`
+	Failed    int                  `json:"failed"`
+	Results   []ProductBatchResult `json:"results"`
+}
+
+// Backup is a backup file of the database. Compressed backups are gzipped and
+// encrypted backups are sealed with AES-256-GCM; a backup that is both is
+// compressed first.
+type Backup struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	CreatedAt  time.Time `json:"created_at"`
+	Compressed bool      `json:"compressed"`
+	Encrypted  bool      `json:"encrypted"`
+}
+
+// AuditContext identifies who made a change and the request it was made in
+type AuditContext struct {
+	Actor     string
+	RequestID string
+}
+
+// Audited product operations
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+)
+
+// FieldChange is a field of a product that an operation changed
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// AuditEntry records a change to a product with snapshots of the product
+// before and after it, either of which is absent for creations and purges.
+// Hash covers the entry and PrevHash, the hash of the entry before it.
+type AuditEntry struct {
+	ID        int             `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Actor     string          `json:"actor"`
+	RequestID string          `json:"request_id,omitempty"`
+	Operation string          `json:"operation"`
+	ProductID int             `json:"product_id"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	Diff      []FieldChange   `json:"diff"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+}
+
+// AuditFilter selects audit entries; zero fields match every entry
+type AuditFilter struct {
+	ProductID int
+	Actor     string
+	Operation string
+	RequestID string
+	From      time.Time
+	To        time.Time
+	Limit     int
+}
+
+// AuditVerification is the outcome of checking the audit log's hash chain.
+// BrokenAt is the ID of the first entry that does not match its hash.
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Entries  int    `json:"entries"`
+	BrokenAt int    `json:"broken_at,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ProductVersion is the state of a product's catalog fields over a period of
+// time. A new version starts with every change to the product, and the open
+// version, with no ValidTo, is the current one. A product in the trash has no
+// open version.
+type ProductVersion struct {
+	ProductID    int        `json:"product_id"`
+	Version      int        `json:"version"`
+	SKU          string     `json:"sku"`
+	Name         string     `json:"name"`
+	Description  string     `json:"description"`
+	Category     string     `json:"category"`
+	TaxClass     string     `json:"tax_class"`
+	Price        float64    `json:"price"`
+	ReorderPoint int        `json:"reorder_point"`
+	ReorderQty   int        `json:"reorder_qty"`
+	ValidFrom    time.Time  `json:"valid_from"`
+	ValidTo      *time.Time `json:"valid_to,omitempty"`
+}
+
+// ProductVersionDiff lists the fields that changed between two versions of a product
+type ProductVersionDiff struct {
+	ProductID int             `json:"product_id"`
+	From      *ProductVersion `json:"from"`
+	To        *ProductVersion `json:"to"`
+	Changes   []FieldChange   `json:"changes"`
+}
+
+// Domain event types
+const (
+	EventProductCreated = "ProductCreated"
+	EventProductUpdated = "ProductUpdated"
+	EventProductDeleted = "ProductDeleted"
+	EventStockChanged   = "StockChanged"
+)
+
+// Event is a domain event describing a change to a product. Events are
+// delivered at least once, so consumers should ignore IDs they have seen.
+type Event struct {
+	ID         int             `json:"id"`
+	Type       string          `json:"type"`
+	ProductID  int             `json:"product_id"`
+	Payload    json.RawMessage `json:"payload"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+// StockChangedPayload is the payload of a StockChanged event
+type StockChangedPayload struct {
+	ProductID int `json:"product_id"`
+	Previous  int `json:"previous"`
+	Stock     int `json:"stock"`
+	Delta     int `json:"delta"`
+	Available int `json:"available"`
+}
+
+// Outbox delivery statuses
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxFailed    = "failed"
+)
+
+// OutboxEntry is an event in the outbox with its delivery status. Publishers
+// lists those it has reached; a failed event gave up after too many attempts.
+type OutboxEntry struct {
+	Event
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	Publishers    []string   `json:"publishers"`
+}
+
+// Webhook delivery statuses
+const (
+	WebhookPending   = "pending"
+	WebhookSucceeded = "succeeded"
+	WebhookFailed    = "failed"
+)
+
+// WebhookSubscription sends the events it subscribes to, or every event if
+// Events is empty, to a partner's URL. Secret signs the payloads and is only
+// returned when the subscription is created or its secret is rotated.
+type WebhookSubscription struct {
+	ID                  int        `json:"id"`
+	URL                 string     `json:"url"`
+	Secret              string     `json:"secret,omitempty"`
+	Events              []string   `json:"events"`
+	Active              bool       `json:"active"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// CreateWebhookRequest subscribes a URL to events. A secret is generated if none is given.
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// UpdateWebhookRequest replaces a subscription's URL and event filter. Setting
+// Active re-enables a disabled subscription, and a non-empty Secret rotates it.
+type UpdateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+	Active bool     `json:"active"`
+}
+
+// WebhookDelivery is an event sent, or to be sent, to a subscription, with the
+// outcome of its latest attempt
+type WebhookDelivery struct {
+	ID             int             `json:"id"`
+	SubscriptionID int             `json:"subscription_id"`
+	EventID        int             `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	RedeliveryOf   *int            `json:"redelivery_of,omitempty"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseCode   int             `json:"response_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+
+	// The URL and secret of the subscription, for sending
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// ProductFilter selects the products outside the trash by category, text,
+// list price and available stock. Empty fields match every product. Category
+// matches case-insensitively, and Search matches a case-insensitive substring
+// of the name, description or SKU.
+type ProductFilter struct {
+	Category string
+	Search   string
+	MinPrice *float64
+	MaxPrice *float64
+	InStock  *bool
+}
+
+// ProductPage is a page of the products matching a filter, in ID order,
+// together with the number of products matching it across all pages
+type ProductPage struct {
+	Products []*Product
+	Total    int
+}
+
+// ProductEventFilter limits a stream of product events to some products and
+// event types. An empty field matches everything.
+type ProductEventFilter struct {
+	ProductIDs []int
+	Types      []string
+}
+
+// EventStream is a live subscription to product events. Backlog holds the
+// buffered events missed since the event a client resumed after, and Reset
+// is set if that event is no longer buffered, so the client must reload
+// instead. Events is closed when the subscription ends.
+type EventStream struct {
+	Backlog []*Event
+	Reset   bool
+	Events  <-chan *Event
 }